@@ -0,0 +1,304 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// arrayElements maps the supported array types onto their element types.
+// https://www.postgresql.org/docs/current/arrays.html
+var arrayElements = map[oid.Oid]oid.Oid{
+	oid.T__bool:        oid.T_bool,
+	oid.T__bytea:       oid.T_bytea,
+	oid.T__char:        oid.T_char,
+	oid.T__name:        oid.T_name,
+	oid.T__int2:        oid.T_int2,
+	oid.T__int4:        oid.T_int4,
+	oid.T__int8:        oid.T_int8,
+	oid.T__text:        oid.T_text,
+	oid.T__varchar:     oid.T_varchar,
+	oid.T__bpchar:      oid.T_bpchar,
+	oid.T__float4:      oid.T_float4,
+	oid.T__float8:      oid.T_float8,
+	oid.T__numeric:     oid.T_numeric,
+	oid.T__money:       oid.T_money,
+	oid.T__date:        oid.T_date,
+	oid.T__time:        oid.T_time,
+	oid.T__timestamp:   oid.T_timestamp,
+	oid.T__timestamptz: oid.T_timestamptz,
+	oid.T__interval:    oid.T_interval,
+	oid.T__uuid:        oid.T_uuid,
+	oid.T__json:        oid.T_json,
+	oid.T__jsonb:       oid.T_jsonb,
+	oid.T__xml:         oid.T_xml,
+	oid.T__inet:        oid.T_inet,
+	oid.T__cidr:        oid.T_cidr,
+	oid.T__oid:         oid.T_oid,
+	oid.T__regclass:    oid.T_regclass,
+}
+
+// ErrArrayDimensions is returned whenever a multidimensional array contains
+// sub-arrays of different lengths.
+var ErrArrayDimensions = errors.New("multidimensional arrays must have sub-arrays with matching dimensions")
+
+// arraySource returns the reflected value of the given source whenever the
+// source is a Go slice or array which should be encoded as a Postgres array.
+// Byte slices and raw JSON messages are not considered to be arrays, they
+// represent a single value.
+func arraySource(src any) (reflect.Value, bool) {
+	switch src.(type) {
+	case []byte, json.RawMessage:
+		return reflect.Value{}, false
+	}
+
+	value := reflect.ValueOf(src)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		return value, true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// isArrayType returns true whenever values of the given type are encoded as
+// (sub-)arrays instead of array elements.
+func isArrayType(typ reflect.Type) bool {
+	if typ == reflect.TypeOf([]byte(nil)) || typ == reflect.TypeOf(json.RawMessage(nil)) {
+		return false
+	}
+
+	return typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array
+}
+
+// arrayDimensions returns the length of each dimension of the given array.
+// Nested slices are only treated as dimensions when their type is known
+// upfront, slices inside a []any are passed as is to the element encoder.
+func arrayDimensions(value reflect.Value) []int {
+	dimensions := []int{value.Len()}
+	for isArrayType(value.Type().Elem()) && value.Len() > 0 {
+		value = value.Index(0)
+		dimensions = append(dimensions, value.Len())
+	}
+
+	return dimensions
+}
+
+// arrayElement encodes the elements of a single array column.
+type arrayElement struct {
+	encoder *columnEncoder
+	format  FormatCode
+	buf     []byte
+	null    bool
+}
+
+// encodeArray encodes the given slice or array using the given element type
+// including the Postgres array header. Elements are encoded using the column
+// format. Multidimensional arrays could be encoded using nested slices.
+// https://www.postgresql.org/docs/current/arrays.html#ARRAYS-IO
+func (encoder *columnEncoder) encodeArray(ctx context.Context, element oid.Oid, value reflect.Value) ([]byte, bool, error) {
+	if value.Kind() == reflect.Slice && value.IsNil() {
+		return nil, true, nil
+	}
+
+	if encoder.element == nil {
+		encoder.element = &arrayElement{
+			encoder: &columnEncoder{column: Column{Oid: element, Format: encoder.column.Format}},
+			format:  encoder.column.Format,
+		}
+	}
+
+	dimensions := arrayDimensions(value)
+	encoder.element.buf = encoder.element.buf[:0]
+	encoder.element.null = false
+
+	err := encoder.element.walk(ctx, value, dimensions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bb := encoder.buf[:0]
+	if bb == nil {
+		bb = make([]byte, 0, len(encoder.element.buf)+64)
+	}
+
+	switch encoder.column.Format {
+	case BinaryFormat:
+		bb = appendArrayHeader(bb, element, dimensions, encoder.element.null)
+	default:
+		if dimensions[0] == 0 {
+			bb = append(bb, '{', '}')
+		}
+	}
+
+	bb = append(bb, encoder.element.buf...)
+	encoder.buf = bb
+	return bb, false, nil
+}
+
+// encodeValue encodes the given pgtype value using its own format encoders.
+// NOTE: pgtype array values could not be assigned to other pgtype array
+// values and are therefore not set on the column type value.
+func (encoder *columnEncoder) encodeValue(ctx context.Context, value any) ([]byte, bool, error) {
+	ci := TypeInfo(ctx)
+	if ci == nil {
+		return nil, false, errors.New("postgres connection info has not been defined inside the given context")
+	}
+
+	var encode FormatEncoder
+	switch encoder.column.Format {
+	case TextFormat:
+		if value, ok := value.(pgtype.TextEncoder); ok {
+			encode = value.EncodeText
+		}
+	case BinaryFormat:
+		if value, ok := value.(pgtype.BinaryEncoder); ok {
+			encode = value.EncodeBinary
+		}
+	}
+
+	if encode == nil {
+		return nil, false, fmt.Errorf("value of type %T could not be encoded using format %d", value, encoder.column.Format)
+	}
+
+	if encoder.buf == nil {
+		encoder.buf = make([]byte, 0, 64)
+	}
+
+	bb, err := encode(ci, encoder.buf[:0])
+	if err != nil {
+		return nil, false, err
+	}
+
+	if bb == nil {
+		return nil, true, nil
+	}
+
+	encoder.buf = bb
+	return bb, false, nil
+}
+
+// appendArrayHeader appends the binary array header to the given buffer. Empty
+// arrays are encoded without any dimensions.
+func appendArrayHeader(bb []byte, element oid.Oid, dimensions []int, null bool) []byte {
+	ndim := len(dimensions)
+	if dimensions[0] == 0 {
+		ndim = 0
+	}
+
+	var flags uint32
+	if null {
+		flags = 1
+	}
+
+	bb = binary.BigEndian.AppendUint32(bb, uint32(ndim))
+	bb = binary.BigEndian.AppendUint32(bb, flags)
+	bb = binary.BigEndian.AppendUint32(bb, uint32(element))
+
+	for _, dimension := range dimensions[:ndim] {
+		bb = binary.BigEndian.AppendUint32(bb, uint32(dimension))
+		// NOTE: the lower bound of each dimension is always 1
+		bb = binary.BigEndian.AppendUint32(bb, 1)
+	}
+
+	return bb
+}
+
+// walk encodes the elements of the given (sub-)array. Text formatted arrays
+// are enclosed in braces and delimited by commas, the elements of binary
+// formatted arrays are prefixed with their length.
+func (element *arrayElement) walk(ctx context.Context, value reflect.Value, dimensions []int) error {
+	if value.Len() != dimensions[0] {
+		return ErrArrayDimensions
+	}
+
+	if element.format == TextFormat && dimensions[0] > 0 {
+		element.buf = append(element.buf, '{')
+	}
+
+	for index := 0; index < value.Len(); index++ {
+		if element.format == TextFormat && index > 0 {
+			element.buf = append(element.buf, ',')
+		}
+
+		item := value.Index(index)
+		if len(dimensions) > 1 {
+			err := element.walk(ctx, item, dimensions[1:])
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := element.write(ctx, item.Interface())
+		if err != nil {
+			return err
+		}
+	}
+
+	if element.format == TextFormat && dimensions[0] > 0 {
+		element.buf = append(element.buf, '}')
+	}
+
+	return nil
+}
+
+// write encodes the given array element.
+func (element *arrayElement) write(ctx context.Context, src any) error {
+	bb, null, err := element.encoder.encode(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	if null {
+		element.null = true
+	}
+
+	if element.format == BinaryFormat {
+		length := uint32(len(bb))
+		if null {
+			length = 0xffffffff
+		}
+
+		element.buf = binary.BigEndian.AppendUint32(element.buf, length)
+		element.buf = append(element.buf, bb...)
+		return nil
+	}
+
+	if null {
+		element.buf = append(element.buf, "NULL"...)
+		return nil
+	}
+
+	element.buf = appendArrayText(element.buf, string(bb))
+	return nil
+}
+
+// appendArrayText appends the given text formatted element to the given
+// buffer. Elements are double quoted whenever they are empty, equal NULL or
+// contain braces, quotes, commas, backslashes or whitespace.
+func appendArrayText(bb []byte, value string) []byte {
+	quote := value == "" || strings.EqualFold(value, "NULL") || strings.ContainsAny(value, "{}\",\\ \t\n\r\v\f")
+	if !quote {
+		return append(bb, value...)
+	}
+
+	bb = append(bb, '"')
+	for index := 0; index < len(value); index++ {
+		if value[index] == '"' || value[index] == '\\' {
+			bb = append(bb, '\\')
+		}
+
+		bb = append(bb, value[index])
+	}
+
+	return append(bb, '"')
+}
@@ -0,0 +1,202 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrayColumnEncoding(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		oid    oid.Oid
+		value  any
+		text   string
+		binary []byte
+	}
+
+	tests := map[string]test{
+		"int": {
+			oid:   oid.T__int4,
+			value: []int{1, 2, 3},
+			text:  "{1,2,3}",
+			binary: []byte{
+				0, 0, 0, 1, // dimensions
+				0, 0, 0, 0, // null flag
+				0, 0, 0, 23, // element oid
+				0, 0, 0, 3, 0, 0, 0, 1, // dimension length and lower bound
+				0, 0, 0, 4, 0, 0, 0, 1,
+				0, 0, 0, 4, 0, 0, 0, 2,
+				0, 0, 0, 4, 0, 0, 0, 3,
+			},
+		},
+		"any": {
+			oid:   oid.T__int8,
+			value: []any{1, nil},
+			text:  "{1,NULL}",
+			binary: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 1,
+				0, 0, 0, 20,
+				0, 0, 0, 2, 0, 0, 0, 1,
+				0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 1,
+				0xff, 0xff, 0xff, 0xff,
+			},
+		},
+		"string": {
+			oid:   oid.T__text,
+			value: []string{"a", "b c", `"`, "", "null"},
+			text:  `{a,"b c","\"","","null"}`,
+			binary: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 0,
+				0, 0, 0, 25,
+				0, 0, 0, 5, 0, 0, 0, 1,
+				0, 0, 0, 1, 'a',
+				0, 0, 0, 3, 'b', ' ', 'c',
+				0, 0, 0, 1, '"',
+				0, 0, 0, 0,
+				0, 0, 0, 4, 'n', 'u', 'l', 'l',
+			},
+		},
+		"multidimensional": {
+			oid:   oid.T__int2,
+			value: [][]int16{{1, 2}, {3, 4}},
+			text:  "{{1,2},{3,4}}",
+			binary: []byte{
+				0, 0, 0, 2,
+				0, 0, 0, 0,
+				0, 0, 0, 21,
+				0, 0, 0, 2, 0, 0, 0, 1,
+				0, 0, 0, 2, 0, 0, 0, 1,
+				0, 0, 0, 2, 0, 1,
+				0, 0, 0, 2, 0, 2,
+				0, 0, 0, 2, 0, 3,
+				0, 0, 0, 2, 0, 4,
+			},
+		},
+		"pgtype": {
+			oid: oid.T__int4,
+			value: pgtype.Int4Array{
+				Elements:   []pgtype.Int4{{Int: 1, Status: pgtype.Present}, {Status: pgtype.Null}},
+				Dimensions: []pgtype.ArrayDimension{{Length: 2, LowerBound: 1}},
+				Status:     pgtype.Present,
+			},
+			text: "{1,NULL}",
+			binary: []byte{
+				0, 0, 0, 1,
+				0, 0, 0, 1,
+				0, 0, 0, 23,
+				0, 0, 0, 2, 0, 0, 0, 1,
+				0, 0, 0, 4, 0, 0, 0, 1,
+				0xff, 0xff, 0xff, 0xff,
+			},
+		},
+		"empty": {
+			oid:   oid.T__int4,
+			value: []int{},
+			text:  "{}",
+			binary: []byte{
+				0, 0, 0, 0,
+				0, 0, 0, 0,
+				0, 0, 0, 23,
+			},
+		},
+	}
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+
+	for name, test := range tests {
+		expected := map[FormatCode][]byte{
+			TextFormat:   []byte(test.text),
+			BinaryFormat: test.binary,
+		}
+
+		for format, bb := range expected {
+			test := test
+			bb := bb
+
+			t.Run(fmt.Sprintf("%s/%d", name, format), func(t *testing.T) {
+				writer := buffer.NewWriter(&bytes.Buffer{})
+				writer.Start(types.ServerDataRow)
+
+				column := Column{Name: "values", Oid: test.oid, Format: format}
+				err := column.Write(ctx, writer, test.value)
+				require.NoError(t, err)
+
+				// NOTE: the written message contains the message type (1 byte),
+				// message length (4 bytes) and value length (4 bytes).
+				assert.Equal(t, bb, writer.Bytes()[9:])
+			})
+		}
+	}
+
+	t.Run("null", func(t *testing.T) {
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		column := Column{Name: "values", Oid: oid.T__int4, Format: BinaryFormat}
+		err := column.Write(ctx, writer, []int(nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, writer.Bytes()[5:])
+	})
+
+	t.Run("dimensions", func(t *testing.T) {
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		column := Column{Name: "values", Oid: oid.T__int4, Format: TextFormat}
+		err := column.Write(ctx, writer, [][]int{{1, 2}, {3}})
+		assert.ErrorIs(t, err, ErrArrayDimensions)
+	})
+}
+
+func TestArrayColumnScan(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "int_text", Oid: oid.T__int4, Format: TextFormat},
+			{Name: "int_binary", Oid: oid.T__int4, Format: BinaryFormat},
+			{Name: "text_text", Oid: oid.T__text, Format: TextFormat},
+			{Name: "text_binary", Oid: oid.T__text, Format: BinaryFormat},
+		})
+
+		ints := []any{1, 2, 3}
+		texts := []string{"a", "b,c", `d"e`}
+		writer.Row([]any{ints, ints, texts, texts}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var intText, intBinary []int32
+	var textText, textBinary []string
+	err = conn.QueryRow(ctx, "SELECT *;").Scan(&intText, &intBinary, &textText, &textBinary)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int32{1, 2, 3}, intText)
+	assert.Equal(t, []int32{1, 2, 3}, intBinary)
+	assert.Equal(t, []string{"a", "b,c", `d"e`}, textText)
+	assert.Equal(t, []string{"a", "b,c", `d"e`}, textBinary)
+}
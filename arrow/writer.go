@@ -0,0 +1,346 @@
+// Package arrow writes query results as Apache Arrow record batches. The
+// package is kept inside a separate Go module to avoid requiring the Arrow
+// libraries inside applications not making use of it.
+package arrow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/lib/pq/oid"
+)
+
+// ErrColumnMismatch is thrown when the defined columns do not match the fields
+// of the Arrow schema.
+var ErrColumnMismatch = errors.New("columns do not match the arrow schema")
+
+// NewArrowDataWriter constructs a new data writer encoding the written rows as
+// a single Arrow record batch using the Arrow IPC stream format. The record
+// batch is written to the given io.Writer once the command has been completed.
+// The given schema is used to encode the rows, whenever no schema is given is
+// the schema constructed from the columns passed to Define.
+func NewArrowDataWriter(schema *arrow.Schema, w io.Writer) wire.DataWriter {
+	return &dataWriter{
+		schema: schema,
+		sink:   w,
+	}
+}
+
+// dataWriter is a implementation of the wire.DataWriter interface encoding
+// rows as Arrow record batches.
+type dataWriter struct {
+	columns wire.Columns
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	sink    io.Writer
+	closed  bool
+	written uint64
+}
+
+func (writer *dataWriter) Define(columns wire.Columns) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.schema == nil {
+		fields := make([]arrow.Field, len(columns))
+		for index, column := range columns {
+			field, err := Field(column)
+			if err != nil {
+				return err
+			}
+
+			fields[index] = field
+		}
+
+		writer.schema = arrow.NewSchema(fields, nil)
+	}
+
+	if len(writer.schema.Fields()) != len(columns) {
+		return fmt.Errorf("%w: %d columns defined, schema contains %d fields", ErrColumnMismatch, len(columns), len(writer.schema.Fields()))
+	}
+
+	writer.columns = columns
+	writer.builder = array.NewRecordBuilder(memory.DefaultAllocator, writer.schema)
+	return nil
+}
+
+func (writer *dataWriter) Row(values []any) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.builder == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	if len(values) != len(writer.columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given data row but %d were expected", len(values), len(writer.columns))
+	}
+
+	for index, value := range values {
+		err := appendValue(writer.builder.Field(index), value)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", writer.columns[index].Name, err)
+		}
+	}
+
+	writer.written++
+	return nil
+}
+
+func (writer *dataWriter) Written() uint64 {
+	return writer.written
+}
+
+func (writer *dataWriter) Empty() error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.builder == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	if writer.written != 0 {
+		return wire.ErrDataWritten
+	}
+
+	return writer.flush()
+}
+
+func (writer *dataWriter) Complete(description string) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.builder == nil {
+		writer.closed = true
+		return nil
+	}
+
+	return writer.flush()
+}
+
+// ErrorFromErr closes the writer and returns the given error. Errors could not
+// be encoded inside Arrow record batches and should be handled by the caller.
+func (writer *dataWriter) ErrorFromErr(err error) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	writer.closed = true
+	return err
+}
+
+func (writer *dataWriter) ColumnNames() []string {
+	if writer.columns == nil {
+		return nil
+	}
+
+	names := make([]string, len(writer.columns))
+	for index, column := range writer.columns {
+		names[index] = column.Name
+	}
+
+	return names
+}
+
+// Peek validates the given row by appending the values to temporary builders
+// of the schema field types. The record builder is left untouched.
+func (writer *dataWriter) Peek(row []any) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.builder == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	if len(row) != len(writer.columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given data row but %d were expected", len(row), len(writer.columns))
+	}
+
+	for index, value := range row {
+		column := writer.columns[index]
+		if value == nil && column.NotNull {
+			return wire.NewErrNotNullViolation(column.Name)
+		}
+
+		err := peekValue(writer.schema.Field(index).Type, value)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", column.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// peekValue validates whether the given value could be appended to a builder
+// of the given Arrow type.
+func peekValue(typ arrow.DataType, value any) error {
+	builder := array.NewBuilder(memory.DefaultAllocator, typ)
+	defer builder.Release()
+
+	return appendValue(builder, value)
+}
+
+// flush writes the buffered rows as a single record batch to the underlaying
+// writer and closes the data writer. A stream only containing the schema is
+// written whenever no rows have been written.
+func (writer *dataWriter) flush() (err error) {
+	writer.closed = true
+	defer writer.builder.Release()
+
+	stream := ipc.NewWriter(writer.sink, ipc.WithSchema(writer.schema))
+	defer func() {
+		cerr := stream.Close()
+		if err == nil {
+			err = cerr
+		}
+	}()
+
+	if writer.written == 0 {
+		return nil
+	}
+
+	record := writer.builder.NewRecord()
+	defer record.Release()
+
+	return stream.Write(record)
+}
+
+// Field maps the given column to a nullable Arrow field. An error is returned
+// whenever the column type could not be represented as a Arrow data type.
+func Field(column wire.Column) (arrow.Field, error) {
+	var typed arrow.DataType
+
+	switch column.Oid {
+	case oid.T_bool:
+		typed = arrow.FixedWidthTypes.Boolean
+	case oid.T_int2:
+		typed = arrow.PrimitiveTypes.Int16
+	case oid.T_int4:
+		typed = arrow.PrimitiveTypes.Int32
+	case oid.T_int8:
+		typed = arrow.PrimitiveTypes.Int64
+	case oid.T_float4:
+		typed = arrow.PrimitiveTypes.Float32
+	case oid.T_float8:
+		typed = arrow.PrimitiveTypes.Float64
+	case oid.T_text, oid.T_varchar, oid.T_bpchar, oid.T_name:
+		typed = arrow.BinaryTypes.String
+	case oid.T_bytea:
+		typed = arrow.BinaryTypes.Binary
+	case oid.T_date:
+		typed = arrow.FixedWidthTypes.Date32
+	case oid.T_timestamp:
+		typed = &arrow.TimestampType{Unit: arrow.Microsecond}
+	case oid.T_timestamptz:
+		typed = &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	default:
+		return arrow.Field{}, fmt.Errorf("unsupported column type %d for column %q", column.Oid, column.Name)
+	}
+
+	return arrow.Field{Name: column.Name, Type: typed, Nullable: true}, nil
+}
+
+// appendValue appends the given value to the given Arrow builder. Nil values
+// are appended as null values.
+func appendValue(builder array.Builder, value any) error {
+	if value == nil {
+		builder.AppendNull()
+		return nil
+	}
+
+	switch builder := builder.(type) {
+	case *array.BooleanBuilder:
+		if v, ok := value.(bool); ok {
+			builder.Append(v)
+			return nil
+		}
+	case *array.Int16Builder:
+		if v, ok := value.(int16); ok {
+			builder.Append(v)
+			return nil
+		}
+	case *array.Int32Builder:
+		switch v := value.(type) {
+		case int32:
+			builder.Append(v)
+			return nil
+		case int16:
+			builder.Append(int32(v))
+			return nil
+		}
+	case *array.Int64Builder:
+		switch v := value.(type) {
+		case int64:
+			builder.Append(v)
+			return nil
+		case int:
+			builder.Append(int64(v))
+			return nil
+		case int32:
+			builder.Append(int64(v))
+			return nil
+		case int16:
+			builder.Append(int64(v))
+			return nil
+		}
+	case *array.Float32Builder:
+		if v, ok := value.(float32); ok {
+			builder.Append(v)
+			return nil
+		}
+	case *array.Float64Builder:
+		switch v := value.(type) {
+		case float64:
+			builder.Append(v)
+			return nil
+		case float32:
+			builder.Append(float64(v))
+			return nil
+		}
+	case *array.StringBuilder:
+		switch v := value.(type) {
+		case string:
+			builder.Append(v)
+			return nil
+		case []byte:
+			builder.Append(string(v))
+			return nil
+		}
+	case *array.BinaryBuilder:
+		switch v := value.(type) {
+		case []byte:
+			builder.Append(v)
+			return nil
+		case string:
+			builder.AppendString(v)
+			return nil
+		}
+	case *array.Date32Builder:
+		if v, ok := value.(time.Time); ok {
+			builder.Append(arrow.Date32FromTime(v))
+			return nil
+		}
+	case *array.TimestampBuilder:
+		if v, ok := value.(time.Time); ok {
+			unit := builder.Type().(*arrow.TimestampType).Unit
+			builder.Append(arrow.Timestamp(v.UnixNano() / int64(unit.Multiplier())))
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported arrow type %s", builder.Type())
+	}
+
+	return fmt.Errorf("unexpected value type %T for arrow type %s", value, builder.Type())
+}
@@ -0,0 +1,122 @@
+package arrow
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	"github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/ipc"
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrowDataWriter(t *testing.T) {
+	sink := &bytes.Buffer{}
+	columns := wire.Columns{
+		{Name: "id", Oid: oid.T_int8},
+		{Name: "name", Oid: oid.T_text},
+		{Name: "active", Oid: oid.T_bool},
+	}
+
+	const rows = 1000
+
+	writer := NewArrowDataWriter(nil, sink)
+	require.NoError(t, writer.Define(columns))
+
+	for index := 0; index < rows; index++ {
+		var name any = fmt.Sprintf("row-%d", index)
+		if index%10 == 0 {
+			name = nil
+		}
+
+		require.NoError(t, writer.Row([]any{int64(index), name, index%2 == 0}))
+	}
+
+	require.NoError(t, writer.Complete("SELECT 1000"))
+	assert.Equal(t, uint64(rows), writer.Written())
+	assert.Equal(t, []string{"id", "name", "active"}, wire.ColumnNames(writer))
+
+	reader, err := ipc.NewReader(sink)
+	require.NoError(t, err)
+	defer reader.Release()
+
+	require.Len(t, reader.Schema().Fields(), len(columns))
+	assert.Equal(t, arrow.PrimitiveTypes.Int64, reader.Schema().Field(0).Type)
+	assert.Equal(t, arrow.BinaryTypes.String, reader.Schema().Field(1).Type)
+	assert.Equal(t, arrow.FixedWidthTypes.Boolean, reader.Schema().Field(2).Type)
+
+	require.True(t, reader.Next())
+	record := reader.Record()
+	require.Equal(t, int64(rows), record.NumRows())
+
+	ids := record.Column(0).(*array.Int64)
+	names := record.Column(1).(*array.String)
+	active := record.Column(2).(*array.Boolean)
+
+	for index := 0; index < rows; index++ {
+		assert.Equal(t, int64(index), ids.Value(index))
+		assert.Equal(t, index%2 == 0, active.Value(index))
+
+		if index%10 == 0 {
+			assert.True(t, names.IsNull(index))
+			continue
+		}
+
+		assert.Equal(t, fmt.Sprintf("row-%d", index), names.Value(index))
+	}
+
+	assert.False(t, reader.Next())
+	require.NoError(t, reader.Err())
+}
+
+func TestArrowDataWriterSchema(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "value", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	}, nil)
+
+	t.Run("mismatch", func(t *testing.T) {
+		writer := NewArrowDataWriter(schema, &bytes.Buffer{})
+		err := writer.Define(wire.Columns{{Name: "a"}, {Name: "b"}})
+		assert.ErrorIs(t, err, ErrColumnMismatch)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		sink := &bytes.Buffer{}
+		writer := NewArrowDataWriter(schema, sink)
+		require.NoError(t, writer.Define(wire.Columns{{Name: "value"}}))
+		require.NoError(t, writer.Empty())
+		assert.ErrorIs(t, writer.Row([]any{1.0}), wire.ErrClosedWriter)
+
+		reader, err := ipc.NewReader(sink)
+		require.NoError(t, err)
+		defer reader.Release()
+
+		assert.True(t, reader.Schema().Equal(schema))
+		assert.False(t, reader.Next())
+	})
+
+	t.Run("unexpected value", func(t *testing.T) {
+		writer := NewArrowDataWriter(schema, &bytes.Buffer{})
+		require.NoError(t, writer.Define(wire.Columns{{Name: "value"}}))
+		assert.Error(t, writer.Row([]any{"text"}))
+	})
+
+	t.Run("peek", func(t *testing.T) {
+		writer := NewArrowDataWriter(schema, &bytes.Buffer{})
+		require.NoError(t, writer.Define(wire.Columns{{Name: "value", NotNull: true}}))
+		assert.NoError(t, wire.Peek(writer, []any{1.0}))
+		assert.Error(t, wire.Peek(writer, []any{"text"}))
+		assert.ErrorIs(t, wire.Peek(writer, []any{nil}), wire.ErrNotNullViolation)
+		assert.Equal(t, uint64(0), writer.Written())
+	})
+}
+
+func TestArrowDataWriterUndefinedColumns(t *testing.T) {
+	writer := NewArrowDataWriter(nil, &bytes.Buffer{})
+	assert.ErrorIs(t, writer.Row([]any{1}), wire.ErrUndefinedColumns)
+	assert.ErrorIs(t, writer.Empty(), wire.ErrUndefinedColumns)
+}
@@ -0,0 +1,106 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+// AuthAudit logs every authentication attempt to the given writer. Each
+// attempt is logged as a single line including the timestamp, username,
+// database, remote address, authentication method and whether the attempt
+// succeeded. Lines are formatted similar to the Postgres log_connections
+// output (ex: `LOG:  connection authorized: user="john" ...`). Client provided
+// values are quoted, preventing clients from forging log lines.
+func AuthAudit(w io.Writer) OptionFn {
+	return func(srv *Server) error {
+		srv.authAudit = &authAuditor{writer: w}
+		return nil
+	}
+}
+
+// authMethods contains the pg_hba.conf names of the authentication types
+// requested by the server.
+var authMethods = map[authType]string{
+	authOK:                "trust",
+	authKerberosV5:        "krb5",
+	authClearTextPassword: "password",
+	authMD5Password:       "md5",
+	authSASL:              "scram-sha-256",
+}
+
+// authAuditor writes authentication attempts to the configured writer.
+type authAuditor struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// log writes the given authentication attempt of the client connection of the
+// given context.
+func (auditor *authAuditor) log(ctx context.Context, method authType, authenticated bool) {
+	params := ClientParameters(ctx)
+	user := params[ParamUsername]
+	database := params[ParamDatabase]
+
+	host, port := "", ""
+	if addr := RemoteAddress(ctx); addr != nil {
+		host, port, _ = net.SplitHostPort(addr.String())
+	}
+
+	name, has := authMethods[method]
+	if !has {
+		name = fmt.Sprintf("unknown(%d)", method)
+	}
+
+	prefix := fmt.Sprintf("%s [%d]", time.Now().UTC().Format("2006-01-02 15:04:05.000 MST"), os.Getpid())
+
+	var line string
+	if authenticated {
+		line = fmt.Sprintf("%s LOG:  connection authorized: user=%q database=%q host=%q port=%q method=%s\n", prefix, user, database, host, port, name)
+	} else {
+		line = fmt.Sprintf("%s FATAL:  %s authentication failed for user %q: database=%q host=%q port=%q method=%s\n", prefix, name, user, database, host, port, name)
+	}
+
+	auditor.mu.Lock()
+	defer auditor.mu.Unlock()
+
+	// NOTE: audit failures should not prevent clients from connecting
+	io.WriteString(auditor.writer, line) //nolint:errcheck
+}
+
+// authRecorder inspects the authentication requests written to the client to
+// record the requested authentication method, whether the client has been
+// authenticated and whether any data has been written.
+type authRecorder struct {
+	io.Writer
+	method        authType
+	authenticated bool
+	written       bool
+}
+
+func (recorder *authRecorder) Write(p []byte) (int, error) {
+	recorder.written = true
+
+	// NOTE: authentication messages contain the message type, length and
+	// authentication type.
+	if len(p) >= 9 && p[0] == byte(types.ServerAuth) {
+		status := authType(binary.BigEndian.Uint32(p[5:9]))
+		switch status {
+		case authOK:
+			recorder.authenticated = true
+		case authSASLContinue, authSASLFinal:
+			// NOTE: SASL exchange messages are part of the requested method
+		default:
+			recorder.method = status
+		}
+	}
+
+	return recorder.Writer.Write(p)
+}
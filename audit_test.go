@@ -0,0 +1,93 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes buffer safe for concurrent use.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (buffer *syncBuffer) Write(p []byte) (int, error) {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return buffer.buffer.Write(p)
+}
+
+func (buffer *syncBuffer) Lines() []string {
+	buffer.mu.Lock()
+	defer buffer.mu.Unlock()
+	return strings.Split(strings.TrimSpace(buffer.buffer.String()), "\n")
+}
+
+func TestAuthAudit(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	validate := func(username, password string) (bool, error) {
+		return password == "secret", nil
+	}
+
+	sink := &syncBuffer{}
+	server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(ClearTextPassword(validate)), AuthAudit(sink))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:secret@%s:%d/users", address.IP, address.Port))
+	require.NoError(t, err)
+	conn.Close(ctx)
+
+	_, err = pgx.Connect(ctx, fmt.Sprintf("postgres://jane:invalid@%s:%d/users", address.IP, address.Port))
+	require.Error(t, err)
+
+	// NOTE: the attempt is logged once the authentication strategy returned
+	require.Eventually(t, func() bool {
+		return len(sink.Lines()) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	lines := sink.Lines()
+
+	assert.Contains(t, lines[0], `LOG:  connection authorized: user="john" database="users" host="127.0.0.1" port="`)
+	assert.Contains(t, lines[0], "method=password")
+
+	assert.Contains(t, lines[1], `FATAL:  password authentication failed for user "jane": database="users" host="127.0.0.1" port="`)
+	assert.Contains(t, lines[1], "method=password")
+
+	t.Run("forged", func(t *testing.T) {
+		config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+
+		config.User = "mallory\nLOG:  connection authorized: user=admin"
+		config.Database = "users\r\nforged"
+		config.Password = "secret"
+
+		conn, err := pgx.ConnectConfig(ctx, config)
+		require.NoError(t, err)
+		conn.Close(ctx)
+
+		require.Eventually(t, func() bool {
+			return len(sink.Lines()) == 3
+		}, time.Second, 10*time.Millisecond)
+
+		// NOTE: client provided values should never start a new log line
+		line := sink.Lines()[2]
+		assert.Contains(t, line, `user="mallory\nLOG:  connection authorized: user=admin" database="users\r\nforged"`)
+	})
+}
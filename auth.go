@@ -2,7 +2,13 @@ package wire
 
 import (
 	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/jeroenrinzema/psql-wire/codes"
 	pgerror "github.com/jeroenrinzema/psql-wire/errors"
@@ -17,9 +23,22 @@ const (
 	// authOK indicates that the connection has been authenticated and the client
 	// is allowed to proceed.
 	authOK authType = 0
+	// authKerberosV5 is a authentication type used to tell the client to
+	// identify itself using Kerberos V5.
+	authKerberosV5 authType = 2
 	// authClearTextPassword is a authentication type used to tell the client to identify
 	// itself by sending the password in clear text to the Postgres server.
 	authClearTextPassword authType = 3
+	// authMD5Password is a authentication type used to tell the client to
+	// identify itself by sending a salted MD5 hash of its password.
+	authMD5Password authType = 5
+	// authSASL is a authentication type used to tell the client to
+	// authenticate using one of the listed SASL mechanisms.
+	authSASL authType = 10
+	// authSASLContinue contains the SASL challenge send to the client.
+	authSASLContinue authType = 11
+	// authSASLFinal contains the SASL outcome send to the client.
+	authSASLFinal authType = 12
 )
 
 // AuthStrategy represents a authentication strategy used to authenticate a user
@@ -29,9 +48,19 @@ type AuthStrategy func(ctx context.Context, writer *buffer.Writer, reader *buffe
 // This methods validates the incoming credentials and writes to the client whether
 // the provided credentials are correct. When the provided credentials are invalid
 // or any unexpected error occures is an error returned and should the connection be closed.
-func (srv *Server) handleAuth(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+func (srv *Server) handleAuth(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) (err error) {
 	srv.logger.Debug("authenticating client connection")
 
+	if srv.authAudit != nil {
+		recorder := &authRecorder{Writer: writer.Writer}
+		writer.Writer = recorder
+
+		defer func() {
+			writer.Writer = recorder.Writer
+			srv.authAudit.log(ctx, recorder.method, recorder.authenticated && err == nil)
+		}()
+	}
+
 	if srv.Auth == nil {
 		// No authentication strategy configured.
 		// Announcing to the client that the connection is authenticated
@@ -73,7 +102,185 @@ func ClearTextPassword(validate func(username, password string) (bool, error)) A
 		}
 
 		if !valid {
-			return ErrorCode(writer, pgerror.WithCode(errors.New("invalid username/password"), codes.InvalidPassword))
+			err = pgerror.WithCode(errors.New("invalid username/password"), codes.InvalidPassword)
+			err = pgerror.WithSeverity(err, pgerror.LevelFatal)
+
+			werr := writeErrorResponse(writer, err)
+			if werr != nil {
+				return werr
+			}
+
+			return err
+		}
+
+		return writeAuthType(writer, authOK)
+	}
+}
+
+// MD5Password announces to the client to authenticate by sending a salted MD5
+// hash of its password. The given function should return the hashed password
+// of the given username as stored by Postgres inside pg_authid
+// ("md5" + md5(password + username)). The client response is validated
+// against the hashed password using a random 4-byte salt. If the provided
+// credentials are invalid is an error returned and should the connection be
+// closed.
+func MD5Password(hashed func(username string) (string, error)) AuthStrategy {
+	return func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) (err error) {
+		salt := make([]byte, 4)
+		_, err = rand.Read(salt)
+		if err != nil {
+			return err
+		}
+
+		writer.Start(types.ServerAuth)
+		writer.AddInt32(int32(authMD5Password))
+		writer.AddBytes(salt)
+		err = writer.End()
+		if err != nil {
+			return err
+		}
+
+		username := ClientParameters(ctx)[ParamUsername]
+		t, _, err := reader.ReadTypedMsg()
+		if err != nil {
+			return err
+		}
+
+		if t != types.ClientPassword {
+			return errors.New("unexpected password message")
+		}
+
+		response, err := reader.GetString()
+		if err != nil {
+			return err
+		}
+
+		stored, err := hashed(username)
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(stored, "md5") {
+			return errors.New("invalid MD5 hashed password")
+		}
+
+		sum := md5.Sum(append([]byte(stored[3:]), salt...))
+		expected := "md5" + hex.EncodeToString(sum[:])
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(response)) != 1 {
+			err = pgerror.WithCode(fmt.Errorf("password authentication failed for user %q", username), codes.InvalidPassword)
+			err = pgerror.WithSeverity(err, pgerror.LevelFatal)
+
+			werr := writeErrorResponse(writer, err)
+			if werr != nil {
+				return werr
+			}
+
+			return err
+		}
+
+		return writeAuthType(writer, authOK)
+	}
+}
+
+// KerberosV5 announces to the client to authenticate using Kerberos V5. The
+// received Kerberos token is not validated, any token is accepted. This
+// strategy is intended for legacy clients in environments where Kerberos
+// authentication is handled at the network layer.
+func KerberosV5() AuthStrategy {
+	return func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) (err error) {
+		err = writeAuthType(writer, authKerberosV5)
+		if err != nil {
+			return err
+		}
+
+		t, _, err := reader.ReadTypedMsg()
+		if err != nil {
+			return err
+		}
+
+		if t != types.ClientPassword {
+			return errors.New("unexpected kerberos message")
+		}
+
+		return writeAuthType(writer, authOK)
+	}
+}
+
+// ErrAuthChainRejected is returned whenever none of the authentication
+// strategies inside a authentication chain accepted the client connection.
+var ErrAuthChainRejected = errors.New("no authentication strategy accepted the connection")
+
+// AuthChain sets a chain of authentication strategies which are tried in the
+// given order. A strategy rejecting the connection by returning an error
+// before writing any message to the client passes the connection to the next
+// strategy inside the chain. The first strategy sending a challenge (or any
+// other message) to the client wins, subsequent strategies are not consulted.
+// The connection is rejected whenever all strategies have rejected the
+// connection.
+func AuthChain(strategies ...AuthStrategy) OptionFn {
+	return func(srv *Server) error {
+		if len(strategies) == 0 {
+			return errors.New("at least a single authentication strategy has to be defined")
+		}
+
+		for _, strategy := range strategies {
+			if strategy == nil {
+				return errors.New("authentication strategies cannot be nil")
+			}
+		}
+
+		srv.Auth = authChain(strategies)
+		return nil
+	}
+}
+
+// authChain constructs a new authentication strategy trying each of the given
+// strategies until a strategy writes a message to the client. Strategies are
+// only considered to have authenticated the connection once they have written
+// a authentication ok message and returned without an error.
+func authChain(strategies []AuthStrategy) AuthStrategy {
+	return func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) (err error) {
+		rejected := make([]error, 0, len(strategies))
+		for _, strategy := range strategies {
+			recorder := &authRecorder{Writer: writer.Writer}
+			writer.Writer = recorder
+
+			err = strategy(ctx, writer, reader)
+			writer.Writer = recorder.Writer
+
+			if err == nil && !recorder.authenticated {
+				err = errors.New("authentication strategy did not authenticate the connection")
+			}
+
+			if err == nil || recorder.written {
+				return err
+			}
+
+			rejected = append(rejected, err)
+		}
+
+		err = fmt.Errorf("%w: %w", ErrAuthChainRejected, errors.Join(rejected...))
+		err = pgerror.WithCode(err, codes.InvalidAuthorizationSpecification)
+		err = pgerror.WithSeverity(err, pgerror.LevelFatal)
+
+		werr := writeErrorResponse(writer, err)
+		if werr != nil {
+			return werr
+		}
+
+		return err
+	}
+}
+
+// Trust accepts client connections without requiring a password whenever the
+// given function returns true. Connections which are not trusted are
+// rejected without writing any message to the client, allowing the next
+// strategy inside a authentication chain to authenticate the connection.
+func Trust(allow func(ctx context.Context) bool) AuthStrategy {
+	return func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) (err error) {
+		if !allow(ctx) {
+			return errors.New("connection is not trusted")
 		}
 
 		return writeAuthType(writer, authOK)
@@ -3,12 +3,26 @@ package wire
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -82,3 +96,325 @@ func TestClearTextPassword(t *testing.T) {
 		t.Error("unexpected error:", err)
 	}
 }
+
+func TestKerberosV5(t *testing.T) {
+	input := bytes.NewBuffer([]byte{})
+	incoming := buffer.NewWriter(input)
+
+	incoming.Start(types.ServerMessage(types.ClientPassword))
+	incoming.AddBytes([]byte{0x60, 0x82, 0x01, 0x00})
+	incoming.End() //nolint:errcheck
+
+	sink := bytes.NewBuffer([]byte{})
+
+	ctx := context.Background()
+	reader := buffer.NewReader(input, buffer.DefaultBufferSize)
+	writer := buffer.NewWriter(sink)
+
+	server := &Server{logger: zap.NewNop(), Auth: KerberosV5()}
+	err := server.handleAuth(ctx, reader, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := buffer.NewReader(sink, buffer.DefaultBufferSize)
+	for _, expected := range []authType{authKerberosV5, authOK} {
+		ty, _, err := result.ReadTypedMsg()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ty != 'R' {
+			t.Fatalf("unexpected message type %s, expected 'R'", strconv.QuoteRune(rune(ty)))
+		}
+
+		status, err := result.GetUint32()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if authType(status) != expected {
+			t.Errorf("unexpected auth status %d, expected %d", status, expected)
+		}
+	}
+}
+
+func TestMD5Password(t *testing.T) {
+	t.Parallel()
+
+	sum := md5.Sum([]byte("secret" + "john"))
+	stored := "md5" + hex.EncodeToString(sum[:])
+
+	lookup := func(username string) (string, error) {
+		if username != "john" {
+			return "", errors.New("unknown user")
+		}
+
+		return stored, nil
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(MD5Password(lookup)))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("lib/pq", func(t *testing.T) {
+		connstr := fmt.Sprintf("host=%s port=%d user=john password=secret sslmode=disable", address.IP, address.Port)
+		conn, err := sql.Open("postgres", connstr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		err = conn.Ping()
+		require.NoError(t, err)
+	})
+
+	t.Run("jackc/pgx", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:secret@%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+
+	t.Run("lib/pq invalid", func(t *testing.T) {
+		connstr := fmt.Sprintf("host=%s port=%d user=john password=wrong sslmode=disable", address.IP, address.Port)
+		conn, err := sql.Open("postgres", connstr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		err = conn.Ping()
+		pqerr := &pq.Error{}
+		require.ErrorAs(t, err, &pqerr)
+		assert.Equal(t, string(codes.InvalidPassword), string(pqerr.Code))
+	})
+
+	t.Run("jackc/pgx invalid", func(t *testing.T) {
+		_, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:wrong@%s:%d", address.IP, address.Port))
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.InvalidPassword), pgerr.Code)
+	})
+}
+
+func TestInvalidPasswordClosesConnection(t *testing.T) {
+	t.Parallel()
+
+	sum := md5.Sum([]byte("secret" + ""))
+	stored := "md5" + hex.EncodeToString(sum[:])
+
+	strategies := map[string]AuthStrategy{
+		"clear text": ClearTextPassword(func(username, password string) (bool, error) {
+			return password == "secret", nil
+		}),
+		"md5": MD5Password(func(username string) (string, error) {
+			return stored, nil
+		}),
+	}
+
+	for name, strategy := range strategies {
+		strategy := strategy
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+				return writer.Complete("OK")
+			}
+
+			server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(strategy))
+			require.NoError(t, err)
+
+			address := TListenAndServe(t, server)
+
+			conn, err := net.Dial("tcp", address.String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			client := mock.NewClient(conn)
+			client.Handshake(t)
+
+			typed, _, err := client.ReadTypedMsg()
+			require.NoError(t, err)
+			require.Equal(t, types.ServerAuth, typed)
+
+			client.Start(types.ClientPassword)
+			client.AddString("wrong")
+			client.AddNullTerminate()
+			require.NoError(t, client.End())
+
+			typed, _, err = client.ReadTypedMsg()
+			require.NoError(t, err)
+			require.Equal(t, types.ServerErrorResponse, typed)
+
+			// NOTE: the connection should be closed by the server after the
+			// fatal error without announcing that the connection is ready for
+			// queries.
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+			_, _, err = client.ReadTypedMsg()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestAuthChain(t *testing.T) {
+	t.Parallel()
+
+	sum := md5.Sum([]byte("secret" + "john"))
+	stored := "md5" + hex.EncodeToString(sum[:])
+
+	lookup := func(username string) (string, error) {
+		return stored, nil
+	}
+
+	// NOTE: only known users are authenticated using MD5, unknown users are
+	// passed to the next strategy before a challenge is written.
+	known := func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) error {
+		if AuthenticatedUsername(ctx) != "john" {
+			return errors.New("unknown user")
+		}
+
+		return MD5Password(lookup)(ctx, writer, reader)
+	}
+
+	localhost := Trust(func(ctx context.Context) bool {
+		addr, ok := RemoteAddress(ctx).(*net.TCPAddr)
+		return ok && addr.IP.IsLoopback()
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), AuthChain(known, localhost))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("md5", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:secret@%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+
+	t.Run("md5 invalid", func(t *testing.T) {
+		// NOTE: the MD5 challenge has been send, the trust strategy is not
+		// consulted.
+		_, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:wrong@%s:%d", address.IP, address.Port))
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.InvalidPassword), pgerr.Code)
+	})
+
+	t.Run("trust", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://jane@%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+}
+
+func TestAuthChainInvalidPassword(t *testing.T) {
+	t.Parallel()
+
+	sum := md5.Sum([]byte("secret" + ""))
+	stored := "md5" + hex.EncodeToString(sum[:])
+
+	password := MD5Password(func(username string) (string, error) {
+		return stored, nil
+	})
+
+	// NOTE: strategies returning without writing a authentication ok
+	// message should not authenticate the connection.
+	silent := func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) error {
+		return writeErrorResponse(writer, errors.New("invalid password"))
+	}
+
+	trust := Trust(func(ctx context.Context) bool { return true })
+
+	chains := map[string][]AuthStrategy{
+		"md5":    {password, trust},
+		"silent": {silent, trust},
+	}
+
+	for name, chain := range chains {
+		chain := chain
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+				return writer.Complete("OK")
+			}
+
+			server, err := NewServer(SimpleQuery(handler), AuthChain(chain...))
+			require.NoError(t, err)
+
+			address := TListenAndServe(t, server)
+
+			conn, err := net.Dial("tcp", address.String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+			client := mock.NewClient(conn)
+			client.Handshake(t)
+
+			typed, _, err := client.ReadTypedMsg()
+			require.NoError(t, err)
+
+			if typed == types.ServerAuth {
+				client.Start(types.ClientPassword)
+				client.AddString("wrong")
+				client.AddNullTerminate()
+				require.NoError(t, client.End())
+
+				typed, _, err = client.ReadTypedMsg()
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, types.ServerErrorResponse, typed)
+
+			_, _, err = client.ReadTypedMsg()
+			assert.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestAuthChainRejected(t *testing.T) {
+	t.Parallel()
+
+	untrusted := Trust(func(ctx context.Context) bool { return false })
+
+	server, err := NewServer(AuthChain(untrusted, untrusted))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	_, err = pgx.Connect(context.Background(), fmt.Sprintf("postgres://jane@%s:%d", address.IP, address.Port))
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.InvalidAuthorizationSpecification), pgerr.Code)
+}
+
+func TestInvalidAuthChain(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewServer(AuthChain())
+	assert.Error(t, err)
+
+	_, err = NewServer(AuthChain(nil))
+	assert.Error(t, err)
+}
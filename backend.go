@@ -0,0 +1,93 @@
+package wire
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+)
+
+// backendKey represents the process ID and secret key identifying a single
+// client connection.
+type backendKey struct {
+	pid    int32
+	secret int32
+}
+
+// setBackendKey constructs a new context containing the given backend key.
+func setBackendKey(ctx context.Context, key backendKey) context.Context {
+	return context.WithValue(ctx, ctxBackendKey, key)
+}
+
+// BackendPID returns the process ID of the client connection if it has been
+// set inside the given context. The process ID is unique per connection and
+// is send to the client inside the BackendKeyData message.
+func BackendPID(ctx context.Context) int32 {
+	val := ctx.Value(ctxBackendKey)
+	if val == nil {
+		return 0
+	}
+
+	return val.(backendKey).pid
+}
+
+// writeBackendKeyData assigns a new process ID and secret key to the client
+// connection and writes them to the client.
+// https://www.postgresql.org/docs/current/protocol-message-formats.html
+func (srv *Server) writeBackendKeyData(ctx context.Context, writer *buffer.Writer) (context.Context, error) {
+	secret := make([]byte, 4)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return ctx, err
+	}
+
+	key := backendKey{
+		pid:    atomic.AddInt32(&srv.pids, 1),
+		secret: int32(binary.BigEndian.Uint32(secret)),
+	}
+
+	writer.Start(types.ServerBackendKeyData)
+	writer.AddInt32(key.pid)
+	writer.AddInt32(key.secret)
+	err = writer.End()
+	if err != nil {
+		return ctx, err
+	}
+
+	return setBackendKey(ctx, key), nil
+}
+
+// backendPIDQuery matches queries selecting the process ID of the client
+// connection.
+var backendPIDQuery = regexp.MustCompile(`(?is)^\s*SELECT\s+pg_backend_pid\s*\(\s*\)\s*;?\s*$`)
+
+// parseBuiltin returns a prepared statement for the given query if the query
+// is a builtin query answered by the server. A boolean is returned indicating
+// whether the query is handled by the server.
+func (srv *Server) parseBuiltin(query string) (PreparedStatementFn, bool) {
+	if !backendPIDQuery.MatchString(query) {
+		return nil, false
+	}
+
+	statement := func(ctx context.Context, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{{Name: "pg_backend_pid", Oid: oid.T_int4, Width: 4}})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{BackendPID(ctx)})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+	}
+
+	return statement, true
+}
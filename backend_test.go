@@ -0,0 +1,46 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackendPID(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var first, second int32
+	require.NoError(t, conn.QueryRow(ctx, "SELECT pg_backend_pid();").Scan(&first))
+	require.NoError(t, conn.QueryRow(ctx, "select pg_backend_pid()").Scan(&second))
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, uint32(first), conn.PgConn().PID())
+
+	other, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer other.Close(ctx)
+
+	var pid int32
+	require.NoError(t, other.QueryRow(ctx, "SELECT pg_backend_pid();").Scan(&pid))
+	assert.NotEqual(t, first, pid)
+}
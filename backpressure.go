@@ -0,0 +1,57 @@
+package wire
+
+import (
+	"errors"
+	"time"
+)
+
+// backpressureInterval represents the interval in which the backpressure
+// gauge is checked while accepting connections is paused.
+var backpressureInterval = 10 * time.Millisecond
+
+// backpressure pauses accepting new connections while the gauge exceeds the
+// threshold.
+type backpressure struct {
+	gauge     func() float64
+	threshold float64
+}
+
+// BackpressureGauge sets the given backpressure gauge. The server stops
+// accepting new client connections while the value returned by the given
+// gauge exceeds the given threshold. Accepting connections is resumed once the
+// gauge drops to or below the threshold. This could be used to prevent
+// cascading failures when the backend is overloaded. Existing connections are
+// not affected.
+func BackpressureGauge(fn func() float64, threshold float64) OptionFn {
+	return func(srv *Server) error {
+		if fn == nil {
+			return errors.New("backpressure gauge could not be nil")
+		}
+
+		srv.backpressure = &backpressure{gauge: fn, threshold: threshold}
+		return nil
+	}
+}
+
+// awaitBackpressure blocks until the configured backpressure gauge drops to or
+// below its threshold or the server is closed.
+func (srv *Server) awaitBackpressure() {
+	if srv.backpressure == nil || srv.backpressure.gauge() <= srv.backpressure.threshold {
+		return
+	}
+
+	srv.logger.Warn("backpressure threshold exceeded, pausing accepting connections")
+
+	ticker := time.NewTicker(backpressureInterval)
+	defer ticker.Stop()
+
+	for srv.backpressure.gauge() > srv.backpressure.threshold {
+		select {
+		case <-srv.closer:
+			return
+		case <-ticker.C:
+		}
+	}
+
+	srv.logger.Info("backpressure dropped below threshold, resuming accepting connections")
+}
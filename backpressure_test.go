@@ -0,0 +1,63 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackpressureGauge(t *testing.T) {
+	t.Parallel()
+
+	load := atomic.Uint64{}
+	load.Store(math.Float64bits(0.9))
+
+	gauge := func() float64 {
+		return math.Float64frombits(load.Load())
+	}
+
+	server, err := NewServer(BackpressureGauge(gauge, 0.8))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+
+	connected := make(chan error, 1)
+	go func() {
+		conn, err := pgx.Connect(ctx, connstr)
+		if err == nil {
+			conn.Close(ctx)
+		}
+
+		connected <- err
+	}()
+
+	select {
+	case err := <-connected:
+		t.Fatalf("unexpected connection while gauge exceeds threshold: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	load.Store(math.Float64bits(0.5))
+
+	select {
+	case err := <-connected:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("connection has not been accepted after the gauge dropped")
+	}
+}
+
+func TestInvalidBackpressureGauge(t *testing.T) {
+	_, err := NewServer(BackpressureGauge(nil, 0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,234 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+)
+
+func benchmarkColumnWrite(b *testing.B, column Column, value any) {
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	writer := buffer.NewWriter(io.Discard)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		writer.Start(types.ServerDataRow)
+		err := column.Write(ctx, writer, value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkColumnWrite_text_int4(b *testing.B) {
+	benchmarkColumnWrite(b, Column{Name: "id", Oid: oid.T_int4, Format: TextFormat}, int32(1234567))
+}
+
+func BenchmarkColumnWrite_binary_int4(b *testing.B) {
+	benchmarkColumnWrite(b, Column{Name: "id", Oid: oid.T_int4, Format: BinaryFormat}, int32(1234567))
+}
+
+func BenchmarkColumnWrite_text_text(b *testing.B) {
+	benchmarkColumnWrite(b, Column{Name: "name", Oid: oid.T_text, Format: TextFormat}, "John Doe")
+}
+
+func BenchmarkColumnWrite_binary_text(b *testing.B) {
+	benchmarkColumnWrite(b, Column{Name: "name", Oid: oid.T_text, Format: BinaryFormat}, "John Doe")
+}
+
+func BenchmarkColumns_Write_10col(b *testing.B) {
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	writer := buffer.NewWriter(io.Discard)
+
+	columns := make(Columns, 10)
+	row := make([]any, 10)
+	for index := range columns {
+		if index%2 == 0 {
+			columns[index] = Column{Name: fmt.Sprintf("int_%d", index), Oid: oid.T_int8, Format: BinaryFormat}
+			row[index] = int64(index)
+			continue
+		}
+
+		columns[index] = Column{Name: fmt.Sprintf("text_%d", index), Oid: oid.T_text, Format: TextFormat}
+		row[index] = fmt.Sprintf("value %d", index)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := columns.Write(ctx, writer, row)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkServer_10kQueries(b *testing.B) {
+	const queries = 10000
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+		})
+
+		writer.Row([]any{1}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	address := TListenAndServe(b, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer conn.Close(ctx)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for q := 0; q < queries; q++ {
+			_, err := conn.Exec(ctx, "SELECT 1;")
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.StopTimer()
+	runtime.ReadMemStats(&after)
+
+	total := float64(b.N * queries)
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/total, "ns/query")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/total, "allocs/query")
+}
+
+func BenchmarkServer_10MRows(b *testing.B) {
+	if testing.Short() {
+		b.Skip("skipping the 10M rows benchmark in short mode")
+	}
+
+	const rows = 10_000_000
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+		})
+
+		for i := 0; i < rows; i++ {
+			err := writer.Row([]any{i})
+			if err != nil {
+				return err
+			}
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", rows))
+	}
+
+	benchmarks := map[string][]OptionFn{
+		"default":   {SimpleQuery(handler)},
+		"coalesced": {SimpleQuery(handler), WriteCoalescing(64 << 10)},
+	}
+
+	for name, options := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			server, err := NewServer(options...)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			address := TListenAndServe(b, server)
+
+			ctx := context.Background()
+			connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+			conn, err := pgx.Connect(ctx, connstr)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			defer conn.Close(ctx)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				_, err := conn.Exec(ctx, "SELECT * FROM generate_series(1, 10000000);")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rows), "ns/row")
+		})
+	}
+}
+
+// benchmarkRows returns the columns and 10 000 pre-computed rows used to
+// benchmark the data writer.
+func benchmarkRows() (Columns, [][]any) {
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4, Format: BinaryFormat},
+		{Name: "name", Oid: oid.T_text, Format: TextFormat},
+	}
+
+	rows := make([][]any, 10000)
+	for index := range rows {
+		rows[index] = []any{int32(index), fmt.Sprintf("name %d", index)}
+	}
+
+	return columns, rows
+}
+
+func BenchmarkDataWriter_Row_10kRows(b *testing.B) {
+	columns, rows := benchmarkRows()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		writer := NewIoDataWriter(io.Discard, columns)
+		for _, row := range rows {
+			err := writer.Row(row)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDataWriter_Batch_10kRows(b *testing.B) {
+	columns, rows := benchmarkRows()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		writer := NewIoDataWriter(io.Discard, columns)
+		err := Batch(writer, rows)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
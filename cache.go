@@ -64,10 +64,12 @@ func (cache *DefaultPortalCache) Bind(ctx context.Context, name string, fn Prepa
 }
 
 func (cache *DefaultPortalCache) Execute(ctx context.Context, name string, writer DataWriter) error {
-	cache.mu.Lock()
-	defer cache.mu.Unlock()
-
+	// NOTE: the portal is executed outside of the lock to allow portals to be
+	// executed concurrently.
+	cache.mu.RLock()
 	portal, has := cache.portals[name]
+	cache.mu.RUnlock()
+
 	if !has {
 		return nil
 	}
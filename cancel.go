@@ -0,0 +1,147 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"go.uber.org/zap"
+)
+
+// ErrQueryCanceled is returned whenever the query has been cancelled by the
+// client through a CancelRequest.
+var ErrQueryCanceled = errors.New("canceling statement due to user request")
+
+// NewErrQueryCanceled constructs a new error wrapping the ErrQueryCanceled
+// type including the query canceled error code.
+func NewErrQueryCanceled() error {
+	return psqlerr.WithCode(ErrQueryCanceled, codes.QueryCanceled)
+}
+
+// canceler keeps track of the cancel function of the command currently being
+// executed by a single client connection.
+type canceler struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+}
+
+// begin constructs a new cancelable context for the next command. The
+// returned function should be called once the command has been executed.
+func (canceler *canceler) begin(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	canceler.mu.Lock()
+	canceler.cancel = cancel
+	canceler.mu.Unlock()
+
+	return ctx, func() {
+		canceler.mu.Lock()
+		canceler.cancel = nil
+		canceler.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// interrupt cancels the command currently being executed, if any.
+func (canceler *canceler) interrupt() {
+	canceler.mu.Lock()
+	defer canceler.mu.Unlock()
+
+	if canceler.cancel != nil {
+		canceler.cancel(NewErrQueryCanceled())
+	}
+}
+
+// getBackendKey returns the backend key if it has been set inside the given
+// context.
+func getBackendKey(ctx context.Context) (backendKey, bool) {
+	val := ctx.Value(ctxBackendKey)
+	if val == nil {
+		return backendKey{}, false
+	}
+
+	return val.(backendKey), true
+}
+
+// registerCanceler registers a new canceler for the client connection
+// identified by the backend key inside the given context. The returned
+// function unregisters the canceler once the connection is closed.
+func (srv *Server) registerCanceler(ctx context.Context) (*canceler, func()) {
+	entry := &canceler{}
+
+	key, has := getBackendKey(ctx)
+	if !has {
+		return entry, func() {}
+	}
+
+	srv.cancelersMu.Lock()
+	defer srv.cancelersMu.Unlock()
+
+	if srv.cancelers == nil {
+		srv.cancelers = map[backendKey]*canceler{}
+	}
+
+	srv.cancelers[key] = entry
+
+	return entry, func() {
+		srv.cancelersMu.Lock()
+		defer srv.cancelersMu.Unlock()
+		delete(srv.cancelers, key)
+	}
+}
+
+// handleCancelRequest reads the process ID and secret key of the given cancel
+// request and cancels the command currently being executed by the matching
+// client connection. Cancel requests not matching any connection are ignored
+// as no response is expected by the client.
+// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-CANCELING-REQUESTS
+func (srv *Server) handleCancelRequest(reader *buffer.Reader) error {
+	pid, err := reader.GetUint32()
+	if err != nil {
+		return err
+	}
+
+	secret, err := reader.GetUint32()
+	if err != nil {
+		return err
+	}
+
+	key := backendKey{
+		pid:    int32(pid),
+		secret: int32(secret),
+	}
+
+	srv.cancelersMu.RLock()
+	canceler, has := srv.cancelers[key]
+	srv.cancelersMu.RUnlock()
+
+	if !has {
+		srv.logger.Debug("ignoring cancel request, no matching connection", zap.Int32("pid", key.pid))
+		return nil
+	}
+
+	srv.logger.Debug("canceling query", zap.Int32("pid", key.pid))
+	canceler.interrupt()
+	return nil
+}
+
+// queryCanceled returns the cancel cause of the given context whenever the
+// query has been canceled through a cancel request. Any error returned by a
+// canceled query is reported as canceled, handlers could therefore return
+// errors not wrapping context.Canceled (ex: errors of a upstream driver) once
+// the query context has been canceled. The given error is returned otherwise.
+func queryCanceled(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+
+	cause := context.Cause(ctx)
+	if !errors.Is(cause, ErrQueryCanceled) {
+		return err
+	}
+
+	return cause
+}
@@ -0,0 +1,164 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelRequest(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+	canceled := make(chan error, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT fast;" {
+			return writer.Complete("OK")
+		}
+
+		started <- struct{}{}
+
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return writer.Complete("OK")
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	go func() {
+		<-started
+		conn.PgConn().CancelRequest(ctx) //nolint:errcheck
+	}()
+
+	start := time.Now()
+	_, err = conn.Exec(ctx, "SELECT slow;")
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.QueryCanceled), pgerr.Code)
+	assert.ErrorIs(t, <-canceled, context.Canceled)
+
+	t.Run("next query", func(t *testing.T) {
+		tag, err := conn.Exec(ctx, "SELECT fast;")
+		require.NoError(t, err)
+		assert.Equal(t, "OK", tag.String())
+	})
+}
+
+func TestCancelRequestIdle(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(SimpleQuery(func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	// NOTE: cancel requests without any query being executed are ignored.
+	require.NoError(t, conn.PgConn().CancelRequest(ctx))
+
+	tag, err := conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", tag.String())
+}
+
+func TestCancelRequestPoolAcquireTimeout(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT fast;" {
+			return writer.Complete("OK")
+		}
+
+		started <- struct{}{}
+
+		select {
+		case <-ctx.Done():
+			// NOTE: handlers do not have to wrap the context error for the
+			// query to be reported as canceled.
+			return errors.New("upstream query aborted")
+		case <-time.After(10 * time.Second):
+			return writer.Complete("OK")
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?pool_max_conns=1", address.IP, address.Port)
+	pool, err := pgxpool.New(ctx, connstr)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	conn, err := pool.Acquire(ctx)
+	require.NoError(t, err)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, "SELECT slow;")
+		result <- err
+	}()
+
+	<-started
+
+	// NOTE: the pool is exhausted, the acquire timeout is propagated as a
+	// cancel request of the query holding the connection.
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+
+	_, err = pool.Acquire(timeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, conn.Conn().PgConn().CancelRequest(ctx))
+
+	select {
+	case err = <-result:
+	case <-time.After(5 * time.Second):
+		t.Fatal("query has not been canceled")
+	}
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.QueryCanceled), pgerr.Code)
+
+	conn.Release()
+
+	tag, err := pool.Exec(ctx, "SELECT fast;")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", tag.String())
+}
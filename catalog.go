@@ -0,0 +1,221 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/lib/pq/oid"
+	"go.uber.org/zap"
+)
+
+// RegisterTable registers the given table and its columns inside the server
+// catalog. The (schema-qualified) table name is registered as relation name
+// of the given OID (see RegisterClass). Queries selecting the columns of a
+// registered table from pg_attribute are answered by the server, allowing
+// schema inspection tools to introspect the table.
+func RegisterTable(id uint32, name string, columns Columns) OptionFn {
+	return func(srv *Server) error {
+		err := RegisterClass(id, name)(srv)
+		if err != nil {
+			return err
+		}
+
+		if srv.tables == nil {
+			srv.tables = map[uint32]Columns{}
+		}
+
+		srv.tables[id] = columns
+		return nil
+	}
+}
+
+var (
+	// catalogAttributes matches queries selecting from the pg_attribute
+	// catalog. The select list is captured.
+	catalogAttributes = regexp.MustCompile(`(?is)^\s*SELECT\s+(.+?)\s+FROM\s+(?:pg_catalog\.)?pg_attribute\b`)
+	// catalogAttrelid matches the attrelid filter and captures the filtered
+	// relation as positional parameter, OID or regclass literal.
+	catalogAttrelid = regexp.MustCompile(`(?is)\battrelid\s*=\s*(?:\$(\d+)|(\d+)|'([^']*)'\s*::\s*regclass)`)
+	// catalogAlias matches the AS alias of a select list item.
+	catalogAlias = regexp.MustCompile(`(?is)^(.+?)\s+AS\s+"?([^"\s]+)"?$`)
+)
+
+// attributeColumns represent the pg_attribute columns which could be
+// selected from the catalog.
+var attributeColumns = Columns{
+	{Name: "attrelid", Oid: oid.T_oid, Width: 4},
+	{Name: "attname", Oid: oid.T_name, Width: 64},
+	{Name: "atttypid", Oid: oid.T_oid, Width: 4},
+	{Name: "attlen", Oid: oid.T_int2, Width: 2},
+	{Name: "attnum", Oid: oid.T_int2, Width: 2},
+	{Name: "atttypmod", Oid: oid.T_int4, Width: 4},
+	{Name: "attnotnull", Oid: oid.T_bool, Width: 1},
+}
+
+// parseCatalog returns a prepared statement for the given query if the query
+// selects the columns of a registered table from the pg_attribute catalog. A
+// boolean is returned indicating whether the query is handled by the catalog.
+func (srv *Server) parseCatalog(query string) (PreparedStatementFn, bool, error) {
+	if srv.tables == nil {
+		return nil, false, nil
+	}
+
+	selection := catalogAttributes.FindStringSubmatch(query)
+	if selection == nil {
+		return nil, false, nil
+	}
+
+	filter := catalogAttrelid.FindStringSubmatch(query)
+	if filter == nil {
+		return nil, false, nil
+	}
+
+	srv.logger.Debug("incoming catalog query", zap.String("query", query))
+
+	columns, indexes, err := selectAttributes(selection[1])
+	if err != nil {
+		return nil, true, err
+	}
+
+	statement := func(ctx context.Context, writer DataWriter, parameters []string) error {
+		relation, err := srv.resolveRelation(filter, parameters)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Define(columns)
+		if err != nil {
+			return err
+		}
+
+		for index, column := range srv.tables[relation] {
+			attributes := attributeValues(relation, index, column)
+
+			row := make([]any, len(indexes))
+			for position, attribute := range indexes {
+				row[position] = attributes[attribute]
+			}
+
+			err = writer.Row(row)
+			if err != nil {
+				return err
+			}
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+	}
+
+	return statement, true, nil
+}
+
+// selectAttributes returns the pg_attribute columns selected by the given
+// select list. The indexes of the selected columns are returned alongside the
+// columns.
+func selectAttributes(list string) (Columns, []int, error) {
+	if strings.TrimSpace(list) == "*" {
+		indexes := make([]int, len(attributeColumns))
+		for index := range indexes {
+			indexes[index] = index
+		}
+
+		return attributeColumns, indexes, nil
+	}
+
+	items := strings.Split(list, ",")
+	columns := make(Columns, 0, len(items))
+	indexes := make([]int, 0, len(items))
+
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		name := item
+
+		alias := catalogAlias.FindStringSubmatch(item)
+		if alias != nil {
+			item, name = alias[1], alias[2]
+		}
+
+		// NOTE: strip the table alias (ex: att.attname)
+		if dot := strings.LastIndexByte(item, '.'); dot != -1 {
+			item = item[dot+1:]
+		}
+
+		if dot := strings.LastIndexByte(name, '.'); dot != -1 {
+			name = name[dot+1:]
+		}
+
+		found := false
+		for index, column := range attributeColumns {
+			if !strings.EqualFold(column.Name, item) {
+				continue
+			}
+
+			column.Name = name
+			columns = append(columns, column)
+			indexes = append(indexes, index)
+			found = true
+			break
+		}
+
+		if !found {
+			err := fmt.Errorf("column %q of relation pg_attribute does not exist", item)
+			return nil, nil, psqlerr.WithCode(err, codes.UndefinedColumn)
+		}
+	}
+
+	return columns, indexes, nil
+}
+
+// resolveRelation resolves the relation OID filtered by the given attrelid
+// filter match using the given query parameters.
+func (srv *Server) resolveRelation(filter []string, parameters []string) (uint32, error) {
+	value := filter[2]
+	if filter[3] != "" {
+		value = filter[3]
+	}
+
+	if filter[1] != "" {
+		position, err := strconv.Atoi(filter[1])
+		if err != nil || position < 1 || position > len(parameters) {
+			err = fmt.Errorf("could not determine data type of parameter $%s", filter[1])
+			return 0, psqlerr.WithCode(err, codes.UndefinedParameter)
+		}
+
+		value = parameters[position-1]
+	}
+
+	relation, err := strconv.ParseUint(value, 10, 32)
+	if err == nil {
+		return uint32(relation), nil
+	}
+
+	for id, name := range srv.classes {
+		if name == value || strings.TrimPrefix(name, "public.") == value {
+			return id, nil
+		}
+	}
+
+	err = fmt.Errorf("relation %q does not exist", value)
+	return 0, psqlerr.WithCode(err, codes.UndefinedTable)
+}
+
+// attributeValues returns the pg_attribute values of the given column in the
+// order of the attribute columns.
+func attributeValues(relation uint32, index int, column Column) []any {
+	num := column.AttrNo
+	if num == 0 {
+		num = int16(index + 1)
+	}
+
+	// NOTE: variable length types are represented with a length of -1
+	width := column.Width
+	if width == 0 {
+		width = -1
+	}
+
+	return []any{relation, column.Name, uint32(column.Oid), width, num, column.typeModifier(), column.NotNull}
+}
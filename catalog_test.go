@@ -0,0 +1,114 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogAttributes(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	table := Columns{
+		{Name: "id", Oid: oid.T_int4, Width: 4},
+		{Name: "name", Oid: oid.T_varchar, TypeModifier: VarcharTypeModifier(32)},
+	}
+
+	server, err := NewServer(SimpleQuery(handler), RegisterTable(16384, "public.users", table))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	type attribute struct {
+		name    string
+		typid   uint32
+		len     int16
+		typmod  int32
+		notnull bool
+		num     int16
+	}
+
+	expected := []attribute{
+		{name: "id", typid: uint32(oid.T_int4), len: 4, typmod: -1, num: 1},
+		{name: "name", typid: uint32(oid.T_varchar), len: -1, typmod: 36, num: 2},
+	}
+
+	query := `SELECT att.attname, att.atttypid, att.attlen, att.atttypmod, att.attnotnull, att.attnum AS position
+		FROM pg_catalog.pg_attribute att
+		WHERE att.attrelid = $1 AND att.attnum > 0 AND NOT att.attisdropped
+		ORDER BY att.attnum`
+
+	scan := func(t *testing.T, rows pgx.Rows) []attribute {
+		result := []attribute{}
+		for rows.Next() {
+			var attr attribute
+			require.NoError(t, rows.Scan(&attr.name, &attr.typid, &attr.len, &attr.typmod, &attr.notnull, &attr.num))
+			result = append(result, attr)
+		}
+
+		require.NoError(t, rows.Err())
+		return result
+	}
+
+	rows, err := conn.Query(ctx, query, 16384)
+	require.NoError(t, err)
+	assert.Equal(t, expected, scan(t, rows))
+	assert.Equal(t, "position", string(rows.FieldDescriptions()[5].Name))
+
+	t.Run("regclass", func(t *testing.T) {
+		rows, err := conn.Query(ctx, "SELECT attname, atttypid, attlen, atttypmod, attnotnull, attnum FROM pg_attribute WHERE attrelid = 'users'::regclass")
+		require.NoError(t, err)
+		assert.Equal(t, expected, scan(t, rows))
+	})
+
+	t.Run("star", func(t *testing.T) {
+		rows, err := conn.Query(ctx, "SELECT * FROM pg_attribute WHERE attrelid = 16384")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		require.True(t, rows.Next())
+		values, err := rows.Values()
+		require.NoError(t, err)
+		assert.Len(t, values, 7)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		rows, err := conn.Query(ctx, "SELECT attname FROM pg_attribute WHERE attrelid = 'unknown'::regclass")
+		require.NoError(t, err)
+		rows.Close()
+		assert.Error(t, rows.Err())
+	})
+
+	t.Run("unsupported column", func(t *testing.T) {
+		query := "SELECT attname, attisdropped FROM pg_attribute WHERE attrelid = 16384"
+
+		for _, mode := range []pgx.QueryExecMode{pgx.QueryExecModeSimpleProtocol, pgx.QueryExecModeCacheStatement} {
+			_, err := conn.Exec(ctx, query, mode)
+			pgerr := &pgconn.PgError{}
+			require.ErrorAs(t, err, &pgerr)
+			assert.Equal(t, string(codes.UndefinedColumn), pgerr.Code)
+
+			// NOTE: the connection should remain usable
+			tag, err := conn.Exec(ctx, "SELECT 1;", mode)
+			require.NoError(t, err)
+			assert.Equal(t, "OK", tag.String())
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package wire
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// QueryCoalescer enables or disables query coalescing. Identical queries
+// (equal user, database, query and parameters) executed concurrently are only executed once,
+// the result is shared with all callers. Coalesced queries could not complete
+// individual statements, perform copy operations or write raw messages.
+func QueryCoalescer(enabled bool) OptionFn {
+	return func(srv *Server) error {
+		if !enabled {
+			srv.coalescer = nil
+			return nil
+		}
+
+		srv.coalescer = &coalescer{calls: map[string]*coalescedCall{}}
+		return nil
+	}
+}
+
+// coalescer keeps track of the queries currently being executed.
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall represents a single query execution shared by one or more
+// callers. The recorded result is available once done has been closed.
+type coalescedCall struct {
+	done   chan struct{}
+	result *recordWriter
+	err    error
+}
+
+// wrap returns a prepared statement sharing the results of the given
+// statement between concurrent executions with the same parameters.
+func (coalescer *coalescer) wrap(query string, statement PreparedStatementFn) PreparedStatementFn {
+	return func(ctx context.Context, writer DataWriter, parameters []string) error {
+		params := ClientParameters(ctx)
+		key := coalesceKey(params[ParamUsername], params[ParamDatabase], query, parameters)
+
+		coalescer.mu.Lock()
+		call, has := coalescer.calls[key]
+		if !has {
+			call = &coalescedCall{done: make(chan struct{}), result: &recordWriter{ctx: ctx}}
+			coalescer.calls[key] = call
+		}
+		coalescer.mu.Unlock()
+
+		if !has {
+			coalescer.execute(key, call, func() error {
+				return statement(ctx, call.result, parameters)
+			})
+		}
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		err := call.result.replay(writer)
+		if err != nil {
+			return err
+		}
+
+		return call.err
+	}
+}
+
+// execute executes the given function and shares its result with all callers
+// of the given call. Panics are shared as error and re-raised afterwards.
+func (coalescer *coalescer) execute(key string, call *coalescedCall, fn func() error) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			call.err = NewErrPanic(r)
+		}
+
+		coalescer.mu.Lock()
+		delete(coalescer.calls, key)
+		coalescer.mu.Unlock()
+
+		close(call.done)
+
+		if r != nil {
+			panic(r)
+		}
+	}()
+
+	call.err = fn()
+}
+
+// coalesceKey returns a unique key for the given user, database, query and
+// parameters. Results are never shared between sessions of different users or
+// databases. The length of each value is included to avoid ambiguous keys.
+func coalesceKey(user string, database string, query string, parameters []string) string {
+	key := strings.Builder{}
+	for _, value := range []string{user, database, query} {
+		key.WriteString(strconv.Itoa(len(value)))
+		key.WriteByte(':')
+		key.WriteString(value)
+	}
+
+	for _, parameter := range parameters {
+		key.WriteString(strconv.Itoa(len(parameter)))
+		key.WriteByte(':')
+		key.WriteString(parameter)
+	}
+
+	return key.String()
+}
+
+// recordWriter records all data written by a query handler allowing the
+// result to be replayed to multiple data writers.
+type recordWriter struct {
+	ctx      context.Context
+	columns  Columns
+	rows     [][]any
+	empty    bool
+	complete *string
+	err      error
+}
+
+func (writer *recordWriter) Define(columns Columns) error {
+	if writer.columns != nil {
+		return ErrColumnsDefined
+	}
+
+	writer.columns = columns
+	return nil
+}
+
+func (writer *recordWriter) Row(values []any) error {
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	row := make([]any, len(values))
+	copy(row, values)
+	writer.rows = append(writer.rows, row)
+	return nil
+}
+
+func (writer *recordWriter) Written() uint64 {
+	return uint64(len(writer.rows))
+}
+
+func (writer *recordWriter) Empty() error {
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	if len(writer.rows) != 0 {
+		return ErrDataWritten
+	}
+
+	writer.empty = true
+	return nil
+}
+
+func (writer *recordWriter) Complete(description string) error {
+	writer.complete = &description
+	return nil
+}
+
+func (writer *recordWriter) ErrorFromErr(err error) error {
+	writer.err = err
+	return nil
+}
+
+func (writer *recordWriter) ColumnNames() []string {
+	if writer.columns == nil {
+		return nil
+	}
+
+	names := make([]string, len(writer.columns))
+	for index, column := range writer.columns {
+		names[index] = column.Name
+	}
+
+	return names
+}
+
+func (writer *recordWriter) ColumnValues() [][]any {
+	return writer.rows
+}
+
+func (writer *recordWriter) Peek(row []any) error {
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	return writer.columns.validate(writer.ctx, row)
+}
+
+// replay writes the recorded result to the given data writer.
+func (writer *recordWriter) replay(target DataWriter) (err error) {
+	if writer.columns != nil {
+		err = target.Define(writer.columns)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range writer.rows {
+		err = target.Row(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.empty {
+		err = target.Empty()
+		if err != nil {
+			return err
+		}
+	}
+
+	if writer.err != nil {
+		return ErrorFromErr(target, writer.err)
+	}
+
+	if writer.complete != nil {
+		return target.Complete(*writer.complete)
+	}
+
+	return nil
+}
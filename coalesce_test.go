@@ -0,0 +1,152 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCoalescer(t *testing.T) {
+	t.Parallel()
+
+	const clients = 10
+
+	var calls int32
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(250 * time.Millisecond)
+
+		err := writer.Define(Columns{{Name: "name", Oid: oid.T_text, Format: TextFormat}})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{"John"})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), QueryCoalescer(true))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+
+	conns := make([]*pgx.Conn, clients)
+	for index := range conns {
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		conns[index] = conn
+	}
+
+	start := make(chan struct{})
+	results := make(chan string, clients)
+	wg := sync.WaitGroup{}
+
+	for _, conn := range conns {
+		wg.Add(1)
+
+		go func(conn *pgx.Conn) {
+			defer wg.Done()
+			<-start
+
+			var name string
+			err := conn.QueryRow(ctx, "SELECT name FROM users;").Scan(&name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			results <- name
+		}(conn)
+	}
+
+	close(start)
+	wg.Wait()
+	close(results)
+
+	count := 0
+	for name := range results {
+		assert.Equal(t, "John", name)
+		count++
+	}
+
+	assert.Equal(t, clients, count)
+	assert.LessOrEqual(t, atomic.LoadInt32(&calls), int32(3))
+}
+
+func TestCoalesceKey(t *testing.T) {
+	assert.NotEqual(t, coalesceKey("john", "db", "SELECT $1", []string{"a", "b"}), coalesceKey("john", "db", "SELECT $1", []string{"ab"}))
+	assert.Equal(t, coalesceKey("john", "db", "SELECT $1", []string{"a"}), coalesceKey("john", "db", "SELECT $1", []string{"a"}))
+	assert.NotEqual(t, coalesceKey("john", "db", "SELECT 1", nil), coalesceKey("marry", "db", "SELECT 1", nil))
+	assert.NotEqual(t, coalesceKey("john", "db", "SELECT 1", nil), coalesceKey("john", "other", "SELECT 1", nil))
+	assert.NotEqual(t, coalesceKey("john", "db", "SELECT 1", nil), coalesceKey("johndb", "", "SELECT 1", nil))
+}
+
+func TestQueryCoalescerSessions(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		time.Sleep(250 * time.Millisecond)
+
+		err := writer.Define(Columns{{Name: "user", Oid: oid.T_text, Format: TextFormat}})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{AuthenticatedUsername(ctx)})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), QueryCoalescer(true))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	users := []string{"john", "marry"}
+	wg := sync.WaitGroup{}
+
+	for _, user := range users {
+		connstr := fmt.Sprintf("postgres://%s@%s:%d", user, address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		wg.Add(1)
+		go func(user string, conn *pgx.Conn) {
+			defer wg.Done()
+
+			var result string
+			err := conn.QueryRow(ctx, "SELECT current_user;").Scan(&result)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			// NOTE: results should never be shared between users
+			assert.Equal(t, user, result)
+		}(user, conn)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,124 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// TextEncoder appends the text format of the given source value to the given
+// buffer. NULL values should append nothing and return (nil, nil).
+type TextEncoder func(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error)
+
+// BinaryEncoder appends the binary format of the given source value to the
+// given buffer. NULL values should append nothing and return (nil, nil).
+type BinaryEncoder func(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error)
+
+// RegisterType registers the given codecs for the given type OID inside the
+// type info used to encode column values. This could be used to support types
+// defined by extensions (ex: the pgvector vector type) or to replace the
+// codecs of builtin types such as uuid or numeric. A nil encoder indicates
+// that the given format is not supported by the type. NULL values are encoded
+// as NULL without calling the encoders.
+func RegisterType(id oid.Oid, text TextEncoder, binary BinaryEncoder) OptionFn {
+	return func(srv *Server) error {
+		if text == nil && binary == nil {
+			return errors.New("at least a single type encoder has to be defined")
+		}
+
+		// NOTE: the name of builtin types is preserved when replacing their
+		// codecs to allow types to be looked up by name.
+		name := fmt.Sprintf("oid_%d", id)
+		if typed, has := srv.types.DataTypeForOID(uint32(id)); has {
+			name = typed.Name
+		}
+
+		value := &customType{id: id, name: name, text: text, binary: binary}
+		srv.types.RegisterDataType(pgtype.DataType{Value: value, Name: name, OID: uint32(id)})
+		return nil
+	}
+}
+
+// customType represents a type value encoded using user defined codecs. The
+// given source value is stored as is and passed to the codecs once encoded.
+type customType struct {
+	id     oid.Oid
+	name   string
+	text   TextEncoder
+	binary BinaryEncoder
+	src    any
+	status pgtype.Status
+}
+
+// NewTypeValue constructs a new value using the codecs of the type.
+func (typed *customType) NewTypeValue() pgtype.Value {
+	return &customType{id: typed.id, name: typed.name, text: typed.text, binary: typed.binary}
+}
+
+// TypeName returns the name of the type.
+func (typed *customType) TypeName() string {
+	return typed.name
+}
+
+// Set assigns the given source to itself.
+func (typed *customType) Set(src any) error {
+	if src == nil {
+		typed.src = nil
+		typed.status = pgtype.Null
+		return nil
+	}
+
+	typed.src = src
+	typed.status = pgtype.Present
+	return nil
+}
+
+// Get returns the assigned source value.
+func (typed *customType) Get() any {
+	switch typed.status {
+	case pgtype.Present:
+		return typed.src
+	case pgtype.Null:
+		return nil
+	default:
+		return typed.status
+	}
+}
+
+// AssignTo is not supported, custom types are only encoded.
+func (typed *customType) AssignTo(dst any) error {
+	return fmt.Errorf("unable to assign type %d to %T", typed.id, dst)
+}
+
+// EncodeText appends the text format of the value using the registered text
+// encoder.
+func (typed *customType) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	if typed.text == nil {
+		return nil, fmt.Errorf("type %d does not support the text format", typed.id)
+	}
+
+	return typed.encode(ci, typed.text, buf)
+}
+
+// EncodeBinary appends the binary format of the value using the registered
+// binary encoder.
+func (typed *customType) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	if typed.binary == nil {
+		return nil, fmt.Errorf("type %d does not support the binary format", typed.id)
+	}
+
+	return typed.encode(ci, typed.binary, buf)
+}
+
+func (typed *customType) encode(ci *pgtype.ConnInfo, encoder func(*pgtype.ConnInfo, any, []byte) ([]byte, error), buf []byte) ([]byte, error) {
+	switch typed.status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return encoder(ci, typed.src, buf)
+}
@@ -0,0 +1,159 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// vectorOID represents the OID assigned to the pgvector vector type.
+const vectorOID oid.Oid = 16385
+
+func encodeVectorText(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error) {
+	values, ok := src.([]float32)
+	if !ok {
+		return nil, fmt.Errorf("unexpected vector type %T", src)
+	}
+
+	buf = append(buf, '[')
+	for index, value := range values {
+		if index > 0 {
+			buf = append(buf, ',')
+		}
+
+		buf = strconv.AppendFloat(buf, float64(value), 'f', -1, 32)
+	}
+
+	return append(buf, ']'), nil
+}
+
+func encodeVectorBinary(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error) {
+	values, ok := src.([]float32)
+	if !ok {
+		return nil, fmt.Errorf("unexpected vector type %T", src)
+	}
+
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(values)))
+	buf = binary.BigEndian.AppendUint16(buf, 0)
+	for _, value := range values {
+		buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(value))
+	}
+
+	return buf, nil
+}
+
+func TestRegisterType(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(RegisterType(vectorOID, encodeVectorText, encodeVectorBinary))
+	require.NoError(t, err)
+
+	ctx := setTypeInfo(context.Background(), server.types)
+	vector := []float32{1, 2.5, 3}
+
+	tests := map[FormatCode][]byte{
+		TextFormat: []byte("[1,2.5,3]"),
+		BinaryFormat: {
+			0, 3, 0, 0,
+			0x3f, 0x80, 0, 0,
+			0x40, 0x20, 0, 0,
+			0x40, 0x40, 0, 0,
+		},
+	}
+
+	for format, expected := range tests {
+		t.Run(fmt.Sprintf("%d", format), func(t *testing.T) {
+			writer := buffer.NewWriter(&bytes.Buffer{})
+			writer.Start(types.ServerDataRow)
+
+			column := Column{Name: "embedding", Oid: vectorOID, Format: format}
+			err := column.Write(ctx, writer, vector)
+			require.NoError(t, err)
+
+			// NOTE: the written message contains the message type (1 byte),
+			// message length (4 bytes) and value length (4 bytes).
+			assert.Equal(t, expected, writer.Bytes()[9:])
+		})
+	}
+
+	t.Run("null", func(t *testing.T) {
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		column := Column{Name: "embedding", Oid: vectorOID, Format: TextFormat}
+		err := column.Write(ctx, writer, nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, writer.Bytes()[5:])
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		server, err := NewServer(RegisterType(vectorOID, encodeVectorText, nil))
+		require.NoError(t, err)
+
+		ctx := setTypeInfo(context.Background(), server.types)
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		column := Column{Name: "embedding", Oid: vectorOID, Format: BinaryFormat}
+		err = column.Write(ctx, writer, vector)
+		assert.Error(t, err)
+	})
+}
+
+func TestRegisterTypeReplaceBuiltin(t *testing.T) {
+	t.Parallel()
+
+	uuid := func(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error) {
+		return append(buf, strings.ToUpper(fmt.Sprint(src))...), nil
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "id", Oid: oid.T_uuid, Format: TextFormat},
+		})
+
+		writer.Row([]any{"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), RegisterType(oid.T_uuid, uuid, nil))
+	require.NoError(t, err)
+
+	typed, has := server.types.DataTypeForName("uuid")
+	require.True(t, has)
+	assert.Equal(t, uint32(oid.T_uuid), typed.OID)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var result string
+	err = conn.QueryRow(ctx, "SELECT *;").Scan(&result)
+	require.NoError(t, err)
+	assert.Equal(t, "A0EEBC99-9C0B-4EF8-BB6D-6BB9BD380A11", result)
+}
+
+func TestInvalidRegisterType(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewServer(RegisterType(vectorOID, nil, nil))
+	assert.Error(t, err)
+}
@@ -240,6 +240,7 @@ var (
 	CrashShutdown        Code = "57P02"
 	CannotConnectNow     Code = "57P03"
 	DatabaseDropped      Code = "57P04"
+	IdleSessionTimeout   Code = "57P05"
 	// Section: Class 58 - System Error
 	System        Code = "58000"
 	Io            Code = "58030"
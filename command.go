@@ -44,15 +44,63 @@ func (srv *Server) consumeCommands(ctx context.Context, conn net.Conn, reader *b
 	// include a identification value inside the context that
 	// could be used to identify connections at a later stage.
 
+	if srv.CoalesceSize > 0 {
+		coalescer := buffer.NewCoalescingWriter(writer.Writer, srv.CoalesceSize)
+		writer.Writer = coalescer
+		defer coalescer.Flush() //nolint:errcheck
+	}
+
+	counter := &errorCounter{Writer: writer.Writer}
+	if srv.MaxConnErrors > 0 {
+		writer.Writer = counter
+	}
+
+	sub := newSubscriber(conn, writer, BackendPID(ctx), srv.logger)
+	srv.subscribe(sub)
+	defer srv.unsubscribe(sub)
+	defer sub.close()
+
+	go sub.run()
+
+	ctx = setSubscriber(ctx, sub)
+	ctx = setExtendedQuery(ctx, newExtendedQuery())
+
+	canceler, unregister := srv.registerCanceler(ctx)
+	defer unregister()
+
 	err = readyForQuery(writer, types.ServerIdle)
 	if err != nil {
 		return err
 	}
 
+	err = sub.end()
+	if err != nil {
+		return err
+	}
+
+	deadlines := getDeadlineConn(ctx)
+
 	for {
+		deadlines.next()
+
 		t, length, err := reader.ReadTypedMsg()
+
+		// NOTE: notifications should not be written while the incoming
+		// message is handled or while a read error is written to the client.
+		sub.begin()
 		if err == io.EOF {
-			return nil
+			return srv.handleConnClose(ctx)
+		}
+
+		// NOTE: pending reads are interrupted once the server is shutting
+		// down, the client is notified before the connection is closed.
+		if err != nil && srv.isDraining() {
+			return writeErrorResponse(writer, NewErrServerShutdown())
+		}
+
+		if deadlines.isReadTimeout(err) {
+			srv.logger.Debug("closing connection, read timeout exceeded", zap.Duration("timeout", srv.ReadTimeout))
+			return writeErrorResponse(writer, NewErrIdleSessionTimeout(srv.ReadTimeout))
 		}
 
 		// NOTE: we could recover from this scenario
@@ -62,6 +110,11 @@ func (srv *Server) consumeCommands(ctx context.Context, conn net.Conn, reader *b
 				return err
 			}
 
+			err = sub.end()
+			if err != nil {
+				return err
+			}
+
 			continue
 		}
 
@@ -71,7 +124,9 @@ func (srv *Server) consumeCommands(ctx context.Context, conn net.Conn, reader *b
 			return err
 		}
 
-		err = srv.handleCommand(ctx, conn, t, reader, writer)
+		cmd, done := canceler.begin(ctx)
+		err = srv.handleCommand(cmd, conn, t, reader, writer)
+		done()
 		if errors.Is(err, io.EOF) {
 			return nil
 		}
@@ -79,6 +134,16 @@ func (srv *Server) consumeCommands(ctx context.Context, conn net.Conn, reader *b
 		if err != nil {
 			return err
 		}
+
+		err = sub.end()
+		if err != nil {
+			return err
+		}
+
+		if srv.MaxConnErrors > 0 && counter.errors >= srv.MaxConnErrors {
+			srv.logger.Warn("closing connection, maximum number of errors reached", zap.Int("errors", counter.errors))
+			return writeErrorResponse(writer, NewErrTooManyErrors(srv.MaxConnErrors))
+		}
 	}
 }
 
@@ -105,6 +170,11 @@ func (srv *Server) handleMessageSizeExceeded(reader *buffer.Reader, writer *buff
 	return ErrorCode(writer, exceeded)
 }
 
+// flusher represents a writer buffering data until it is flushed.
+type flusher interface {
+	Flush() error
+}
+
 // handleCommand handles the given client message. A client message includes a
 // message type and reader buffer containing the actual message. The type
 // indecates a action executed by the client.
@@ -113,36 +183,23 @@ func (srv *Server) handleCommand(ctx context.Context, conn net.Conn, t types.Cli
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	// NOTE: extended query messages are discarded until a Sync message is
+	// received once an error occurred while processing a extended query
+	// message.
+	if getExtendedQuery(ctx).failed && isExtendedQueryMessage(t) {
+		srv.logger.Debug("discarding extended query message", zap.String("type", string(t)))
+		return nil
+	}
+
 	switch t {
 	case types.ClientSimpleQuery:
-		return srv.handleSimpleQuery(ctx, reader, writer)
+		return srv.handleSimpleQuery(ctx, conn, reader, writer)
 	case types.ClientExecute:
-		return srv.handleExecute(ctx, reader, writer)
+		return srv.handleExecute(ctx, conn, reader, writer)
 	case types.ClientParse:
 		return srv.handleParse(ctx, reader, writer)
 	case types.ClientDescribe:
-		// TODO: Server should return the column types that will be
-		// returned for the given portal or statement.
-		//
-		// The Describe message (portal variant) specifies the name of an
-		// existing portal (or an empty string for the unnamed portal). The
-		// response is a RowDescription message describing the rows that will be
-		// returned by executing the portal; or a NoData message if the portal
-		// does not contain a query that will return rows; or ErrorResponse if
-		// there is no such portal.
-		//
-		// The Describe message (statement variant) specifies the name of an
-		// existing prepared statement (or an empty string for the unnamed
-		// prepared statement). The response is a ParameterDescription message
-		// describing the parameters needed by the statement, followed by a
-		// RowDescription message describing the rows that will be returned when
-		// the statement is eventually executed (or a NoData message if the
-		// statement will not return rows). ErrorResponse is issued if there is
-		// no such prepared statement. Note that since Bind has not yet been
-		// issued, the formats to be used for returned columns are not yet known
-		// to the backend; the format code fields in the RowDescription message
-		// will be zeroes in this case.
-		// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-EXT-QUERY
+		return srv.handleDescribe(ctx, reader, writer)
 	case types.ClientSync:
 		// TODO: Include the ability to catch sync messages in order to
 		// close the current transaction.
@@ -161,36 +218,29 @@ func (srv *Server) handleCommand(ctx context.Context, conn net.Conn, t types.Cli
 		// — this ensures that there is one and only one ReadyForQuery sent for
 		// each Sync.)
 		// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-EXT-QUERY
+		getExtendedQuery(ctx).failed = false
 		return readyForQuery(writer, types.ServerIdle)
 	case types.ClientBind:
 		return srv.handleBind(ctx, reader, writer)
 	case types.ClientFlush:
-		// TODO: Flush all remaining rows inside connection buffer if
-		// any are remaining.
-		//
-		// The Flush message does not cause any specific
-		// output to be generated, but forces the backend to deliver any data
-		// pending in its output buffers. A Flush must be sent after any
-		// extended-query command except Sync, if the frontend wishes to examine
-		// the results of that command before issuing more commands. Without
-		// Flush, messages returned by the backend will be combined into the
-		// minimum possible number of packets to minimize network overhead.
+		// NOTE: The Flush message does not cause any specific output to be
+		// generated, but forces the backend to deliver any data pending in its
+		// output buffers.
 		// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-EXT-QUERY
-		return readyForQuery(writer, types.ServerIdle)
+		if flusher, ok := writer.Writer.(flusher); ok {
+			return flusher.Flush()
+		}
+
+		return nil
 	case types.ClientCopyData, types.ClientCopyDone, types.ClientCopyFail:
 		// We're supposed to ignore these messages, per the protocol spec. This
 		// state will happen when an error occurs on the server-side during a copy
 		// operation: the server will send an error and a ready message back to
 		// the client, and must then ignore further copy messages. See:
 		// https://github.com/postgres/postgres/blob/6e1dd2773eb60a6ab87b27b8d9391b756e904ac3/src/backend/tcop/postgres.c#L4295
-		return readyForQuery(writer, types.ServerIdle)
+		return nil
 	case types.ClientClose:
-		err = srv.handleConnClose(ctx)
-		if err != nil {
-			return err
-		}
-
-		return conn.Close()
+		return srv.handleClose(ctx, reader, writer)
 	case types.ClientTerminate:
 		err = srv.handleConnTerminate(ctx)
 		if err != nil {
@@ -206,11 +256,9 @@ func (srv *Server) handleCommand(ctx context.Context, conn net.Conn, t types.Cli
 	default:
 		return ErrorCode(writer, NewErrUnimplementedMessageType(t))
 	}
-
-	return nil
 }
 
-func (srv *Server) handleSimpleQuery(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+func (srv *Server) handleSimpleQuery(ctx context.Context, conn net.Conn, reader *buffer.Reader, writer *buffer.Writer) error {
 	if srv.Parse == nil {
 		return ErrorCode(writer, NewErrUnimplementedMessageType(types.ClientSimpleQuery))
 	}
@@ -236,20 +284,67 @@ func (srv *Server) handleSimpleQuery(ctx context.Context, reader *buffer.Reader,
 		return readyForQuery(writer, types.ServerIdle)
 	}
 
-	statement, _, err := srv.Parse(ctx, query)
+	err = srv.authorizeQuery(query)
 	if err != nil {
-		return err
+		return ErrorCode(writer, err)
 	}
 
+	err = srv.checkQueryQuota(ctx)
 	if err != nil {
 		return ErrorCode(writer, err)
 	}
 
-	err = statement(ctx, NewDataWriter(ctx, writer), nil)
+	handled, err := srv.handleListen(ctx, writer, query)
+	if handled || err != nil {
+		return err
+	}
+
+	statement, _, err := srv.parse(ctx, query)
 	if err != nil {
 		return ErrorCode(writer, err)
 	}
 
+	ctx, endTrace := srv.traceQuery(ctx, query)
+	logSlow := srv.logSlowQuery(ctx, query, nil)
+	collect := srv.collectQuery(ctx)
+	result := &dataWriter{
+		ctx:    ctx,
+		reader: reader,
+		client: writer,
+	}
+
+	err = srv.enforceHardQueryTimeout(ctx, conn, writer, func(ctx context.Context) error {
+		return srv.limitMemory(ctx, func(ctx context.Context) error {
+			result.ctx = ctx
+			return srv.panicSafe(func() error {
+				return statement(ctx, srv.wrapDataWriter(ctx, result), nil)
+			})
+		})
+	})
+
+	logSlow()
+	collect(err)
+	endTrace(err)
+
+	if errors.Is(err, ErrHardQueryTimeout) {
+		return err
+	}
+
+	// NOTE: notices (ex: warnings) returned by the handler do not abort the
+	// command, the notice is written and the command is completed.
+	if isNotice(err) {
+		err = result.notice(err)
+		if err != nil {
+			return err
+		}
+
+		return readyForQuery(writer, types.ServerIdle)
+	}
+
+	if err != nil {
+		return ErrorCode(writer, queryCanceled(ctx, err))
+	}
+
 	return readyForQuery(writer, types.ServerIdle)
 }
 
@@ -272,45 +367,80 @@ func (srv *Server) handleParse(ctx context.Context, reader *buffer.Reader, write
 	// zero). Note that this is not an indication of the number of parameters
 	// that might appear in the query string, only the number that the frontend
 	// wants to prespecify types for.
-	parameters, err := reader.GetUint16()
+	length, err := reader.GetUint16()
 	if err != nil {
 		return err
 	}
 
-	for i := uint16(0); i < parameters; i++ {
-		// TODO: Specifies the object ID of the parameter data type
-		//
-		// Specifies the object ID of the parameter data type. Placing a zero here
-		// is equivalent to leaving the type unspecified.
-		// `reader.GetUint32()`
+	// NOTE: Specifies the object ID of the parameter data type. Placing a
+	// zero here is equivalent to leaving the type unspecified.
+	specified := make([]oid.Oid, length)
+	for i := uint16(0); i < length; i++ {
+		id, err := reader.GetUint32()
+		if err != nil {
+			return err
+		}
+
+		specified[i] = oid.Oid(id)
 	}
 
-	statement, descriptions, err := srv.Parse(ctx, query)
+	err = srv.authorizeQuery(query)
 	if err != nil {
-		return ErrorCode(writer, err)
+		return extendedQueryError(ctx, writer, err)
 	}
 
-	srv.logger.Debug("incoming extended query", zap.String("query", query), zap.String("name", name), zap.Int("parameters", len(descriptions)))
+	err = srv.checkPreparedStatementLimit(ctx, name)
+	if err != nil {
+		return extendedQueryError(ctx, writer, err)
+	}
 
-	err = srv.writeParameterDescriptions(writer, descriptions)
+	statement, parameters, err := srv.parse(ctx, query)
 	if err != nil {
-		return err
+		return extendedQueryError(ctx, writer, err)
+	}
+
+	for index, id := range specified {
+		if id == 0 {
+			continue
+		}
+
+		for len(parameters) <= index {
+			parameters = append(parameters, 0)
+		}
+
+		if parameters[index] == 0 {
+			parameters[index] = id
+		}
+	}
+
+	srv.logger.Debug("incoming extended query", zap.String("query", query), zap.String("name", name), zap.Int("parameters", len(parameters)))
+
+	var columns Columns
+	if srv.Describe != nil {
+		columns, err = srv.Describe(ctx, query)
+		if err != nil {
+			return extendedQueryError(ctx, writer, err)
+		}
 	}
 
 	err = srv.Statements.Set(ctx, name, statement)
 	if err != nil {
-		return ErrorCode(writer, err)
+		return extendedQueryError(ctx, writer, err)
+	}
+
+	getExtendedQuery(ctx).statements[name] = &statementDescription{
+		query:      query,
+		parameters: parameters,
+		columns:    columns,
 	}
 
 	writer.Start(types.ServerParseComplete)
 	return writer.End()
 }
 
-func (srv *Server) writeParameterDescriptions(writer *buffer.Writer, parameters []oid.Oid) error {
-	if len(parameters) == 0 {
-		return nil
-	}
-
+// writeParameterDescriptions writes a parameter description containing the
+// given parameter types.
+func writeParameterDescriptions(writer *buffer.Writer, parameters []oid.Oid) error {
 	writer.Start(types.ServerParameterDescription)
 	writer.AddInt16(int16(len(parameters)))
 
@@ -332,19 +462,39 @@ func (srv *Server) handleBind(ctx context.Context, reader *buffer.Reader, writer
 		return err
 	}
 
-	parameters, err := srv.readParameters(ctx, reader)
+	parameters, formats, err := srv.readParameters(ctx, reader)
 	if err != nil {
 		return err
 	}
 
+	state := getExtendedQuery(ctx)
+	description, has := state.statements[statement]
+	if !has {
+		return extendedQueryError(ctx, writer, NewErrUnknownPreparedStatement(statement))
+	}
+
 	fn, err := srv.Statements.Get(ctx, statement)
 	if err != nil {
-		return err
+		return extendedQueryError(ctx, writer, err)
+	}
+
+	if fn == nil {
+		return extendedQueryError(ctx, writer, NewErrUnkownStatement(statement))
+	}
+
+	if len(formats) > 0 {
+		fn = resultFormats(fn, formats)
 	}
 
 	err = srv.Portals.Bind(ctx, name, fn, parameters)
 	if err != nil {
-		return err
+		return extendedQueryError(ctx, writer, err)
+	}
+
+	state.portals[name] = &portalDescription{
+		statement:  description,
+		parameters: parameters,
+		formats:    formats,
 	}
 
 	writer.Start(types.ServerBindComplete)
@@ -352,14 +502,15 @@ func (srv *Server) handleBind(ctx context.Context, reader *buffer.Reader, writer
 }
 
 // readParameters attempts to read all incoming parameters from the given
-// reader. The parameters are parsed and returned.
+// reader. The parameters and the requested result-column format codes are
+// parsed and returned.
 // https://www.postgresql.org/docs/14/protocol-message-formats.html
-func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([]string, error) {
+func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([]string, []FormatCode, error) {
 	// NOTE: read the total amount of parameter format codes that will
 	// be send by the client.
 	length, err := reader.GetUint16()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	srv.logger.Debug("reading parameters format codes", zap.Uint16("length", length))
@@ -367,13 +518,13 @@ func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([
 	for i := uint16(0); i < length; i++ {
 		format, err := reader.GetUint16()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// NOTE: the parameter format codes. Each must presently be zero (text) or one (binary).
 		// https://www.postgresql.org/docs/14/protocol-message-formats.html
 		if format != 0 {
-			return nil, errors.New("unsupported binary parameter format, only text formatted parameter types are currently supported")
+			return nil, nil, errors.New("unsupported binary parameter format, only text formatted parameter types are currently supported")
 		}
 
 		// TODO: Handle multiple parameter format codes.
@@ -387,7 +538,7 @@ func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([
 	// by the client.
 	length, err = reader.GetUint16()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	srv.logger.Debug("reading parameters values", zap.Uint16("length", length))
@@ -396,12 +547,12 @@ func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([
 	for i := uint16(0); i < length; i++ {
 		length, err := reader.GetUint32()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		value, err := reader.GetBytes(int(length))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		srv.logger.Debug("incoming parameter", zap.String("value", string(value)))
@@ -412,29 +563,31 @@ func (srv *Server) readParameters(ctx context.Context, reader *buffer.Reader) ([
 	// send by the client.
 	length, err = reader.GetUint16()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	srv.logger.Debug("reading result-column format codes", zap.Uint16("length", length))
 
+	// NOTE: the result-column format codes. Each must presently be zero
+	// (text) or one (binary).
+	// https://www.postgresql.org/docs/current/protocol-message-formats.html
+	formats := make([]FormatCode, length)
 	for i := uint16(0); i < length; i++ {
-		// TODO: Handle incoming result-column format codes
-		//
-		// Incoming format codes are currently ignored and should be handled in
-		// the future. The result-column format codes. Each must presently be
-		// zero (text) or one (binary). These format codes should be returned
-		// and handled by the parent function to return the proper column formats.
-		// https://www.postgresql.org/docs/current/protocol-message-formats.html
-		_, err := reader.GetUint16()
+		format, err := reader.GetUint16()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		formats[i] = FormatCode(format)
+		if formats[i] != TextFormat && formats[i] != BinaryFormat {
+			return nil, nil, fmt.Errorf("unsupported result-column format code %d", format)
 		}
 	}
 
-	return parameters, nil
+	return parameters, formats, nil
 }
 
-func (srv *Server) handleExecute(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+func (srv *Server) handleExecute(ctx context.Context, conn net.Conn, reader *buffer.Reader, writer *buffer.Writer) error {
 	if srv.Statements == nil {
 		return ErrorCode(writer, NewErrUnimplementedMessageType(types.ClientExecute))
 	}
@@ -444,21 +597,76 @@ func (srv *Server) handleExecute(ctx context.Context, reader *buffer.Reader, wri
 		return err
 	}
 
-	// TODO: Limit the maximum number of records to be returned.
-	//
-	// Maximum number of limit to return, if portal contains a
-	// query that returns limit (ignored otherwise). Zero denotes “no limit”.
+	// NOTE: Maximum number of rows to return, if portal contains a query
+	// that returns rows (ignored otherwise). Zero denotes “no limit”.
 	limit, err := reader.GetUint32()
 	if err != nil {
 		return err
 	}
 
 	srv.logger.Debug("executing", zap.String("name", name), zap.Uint32("limit", limit))
-	err = srv.Portals.Execute(ctx, name, NewDataWriter(ctx, writer))
+
+	portal, has := getExtendedQuery(ctx).portals[name]
+	if !has {
+		return extendedQueryError(ctx, writer, NewErrUnknownPortal(name))
+	}
+
+	// NOTE: a suspended portal is resumed by writing the remaining rows of
+	// the previous execution.
+	if portal.suspended != nil {
+		return resumePortal(ctx, writer, portal, limit)
+	}
+
+	err = srv.checkQueryQuota(ctx)
 	if err != nil {
-		return ErrorCode(writer, err)
+		return extendedQueryError(ctx, writer, err)
+	}
+
+	result := &dataWriter{
+		ctx:        ctx,
+		reader:     reader,
+		client:     writer,
+		described:  portal.statement.columns != nil,
+		extended:   true,
+		limit:      uint64(limit),
+		maxPending: uint64(srv.MaxPendingRows),
+	}
+
+	ctx, endTrace := srv.traceQuery(ctx, portal.statement.query)
+	logSlow := srv.logSlowQuery(ctx, portal.statement.query, portal.parameters)
+	collect := srv.collectQuery(ctx)
+	err = srv.enforceHardQueryTimeout(ctx, conn, writer, func(ctx context.Context) error {
+		return srv.limitMemory(ctx, func(ctx context.Context) error {
+			result.ctx = ctx
+			return srv.panicSafe(func() error {
+				return srv.Portals.Execute(ctx, name, srv.wrapDataWriter(ctx, result))
+			})
+		})
+	})
+
+	logSlow()
+	collect(err)
+	endTrace(err)
+
+	if errors.Is(err, ErrHardQueryTimeout) {
+		return err
+	}
+
+	if isNotice(err) {
+		err = result.notice(err)
+		if err != nil {
+			return err
+		}
+
+		portal.suspended = result.suspended()
+		return nil
+	}
+
+	if err != nil {
+		return extendedQueryError(ctx, writer, queryCanceled(ctx, err))
 	}
 
+	portal.suspended = result.suspended()
 	return nil
 }
 
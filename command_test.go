@@ -2,16 +2,21 @@ package wire
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
 	"github.com/jeroenrinzema/psql-wire/internal/mock"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
 	"github.com/lib/pq/oid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -128,3 +133,41 @@ func TestBindMessageParameters(t *testing.T) {
 	})
 
 }
+
+func TestSimpleQueryParseError(t *testing.T) {
+	t.Parallel()
+
+	parse := func(ctx context.Context, query string) (PreparedStatementFn, []oid.Oid, error) {
+		if query == "SELECT broken;" {
+			return nil, nil, psqlerr.WithCode(errors.New("syntax error"), codes.Syntax)
+		}
+
+		statement := func(ctx context.Context, writer DataWriter, parameters []string) error {
+			return writer.Complete("OK")
+		}
+
+		return statement, nil, nil
+	}
+
+	server, err := NewServer(Parse(parse))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT broken;", pgx.QueryExecModeSimpleProtocol)
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.Syntax), pgerr.Code)
+
+	// NOTE: the connection should remain usable once the parse error has
+	// been written to the client.
+	tag, err := conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+	assert.Equal(t, "OK", tag.String())
+}
@@ -0,0 +1,230 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/pglz"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/zap"
+)
+
+// CompressionAlg represents a algorithm used to compress the messages written
+// to the client.
+type CompressionAlg string
+
+// Supported compression algorithms.
+const (
+	CompressionNone CompressionAlg = "none"
+	CompressionPGLZ CompressionAlg = "pglz"
+	CompressionZstd CompressionAlg = "zstd"
+)
+
+// ParamCompression represents the client startup parameter containing a comma
+// separated list of compression algorithms supported by the client. The
+// negotiated algorithm is returned as a server parameter with the same name
+// (without the protocol extension prefix).
+const ParamCompression ParameterStatus = "_pq_.compression"
+
+// compressionFrameSize represents the size of the header preceding each
+// compressed frame containing the decompressed and compressed frame length.
+const compressionFrameSize = 8
+
+// compressor compresses the given source.
+type compressor func(src []byte) []byte
+
+// decompressor decompresses the given source into a buffer of the given size.
+type decompressor func(src []byte, size int) ([]byte, error)
+
+// Compression sets the algorithm used to compress the messages written to
+// clients. Compression is not part of the standard Postgres protocol, clients
+// have to negotiate compression by including the algorithm inside the
+// ParamCompression startup parameter. The server announces the negotiated
+// algorithm through a "compression" parameter status message once the client
+// has been authenticated, all messages written afterwards are compressed.
+// Messages send by the client are not compressed. Clients could decompress the
+// received messages using NewDecompressionReader.
+func Compression(alg CompressionAlg) OptionFn {
+	return func(srv *Server) error {
+		if alg == CompressionNone {
+			srv.compression = ""
+			return nil
+		}
+
+		_, err := newCompressor(alg)
+		if err != nil {
+			return err
+		}
+
+		srv.compression = alg
+		return nil
+	}
+}
+
+// newCompressor returns the compressor for the given algorithm.
+func newCompressor(alg CompressionAlg) (compressor, error) {
+	switch alg {
+	case CompressionPGLZ:
+		return pglz.Compress, nil
+	case CompressionZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(src []byte) []byte {
+			return encoder.EncodeAll(src, nil)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression algorithm: %s", alg)
+}
+
+// newDecompressor returns the decompressor for the given algorithm.
+func newDecompressor(alg CompressionAlg) (decompressor, error) {
+	switch alg {
+	case CompressionPGLZ:
+		return pglz.Decompress, nil
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(src []byte, size int) ([]byte, error) {
+			return decoder.DecodeAll(src, make([]byte, 0, size))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression algorithm: %s", alg)
+}
+
+// negotiateCompression compresses all messages written to the given
+// connection once the client has requested the configured compression
+// algorithm. The negotiated algorithm is announced to the client before
+// enabling compression.
+func (srv *Server) negotiateCompression(ctx context.Context, conn net.Conn, writer *buffer.Writer) (net.Conn, error) {
+	if srv.compression == "" || !requestsCompression(ClientParameters(ctx)[ParamCompression], srv.compression) {
+		return conn, nil
+	}
+
+	srv.logger.Debug("enabling message compression", zap.String("alg", string(srv.compression)))
+
+	compress, err := newCompressor(srv.compression)
+	if err != nil {
+		return conn, err
+	}
+
+	writer.Start(types.ServerParameterStatus)
+	writer.AddString(strings.TrimPrefix(string(ParamCompression), "_pq_."))
+	writer.AddNullTerminate()
+	writer.AddString(string(srv.compression))
+	writer.AddNullTerminate()
+	err = writer.End()
+	if err != nil {
+		return conn, err
+	}
+
+	compressed := &compressedConn{Conn: conn, compress: compress}
+	writer.Writer = compressed
+	return compressed, nil
+}
+
+// requestsCompression checks whether the given comma separated list of
+// algorithms contains the given algorithm.
+func requestsCompression(requested string, alg CompressionAlg) bool {
+	for _, value := range strings.Split(requested, ",") {
+		if CompressionAlg(strings.TrimSpace(value)) == alg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// compressedConn compresses all data written to the underlying connection.
+// Each write is written as a single frame prefixed with the decompressed and
+// compressed frame length. Frames which could not be compressed are written
+// as is, in which case both lengths are equal.
+type compressedConn struct {
+	net.Conn
+	mu       sync.Mutex
+	compress compressor
+}
+
+func (conn *compressedConn) Write(p []byte) (int, error) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	payload := conn.compress(p)
+	if len(payload) >= len(p) {
+		payload = p
+	}
+
+	frame := make([]byte, compressionFrameSize, compressionFrameSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(p)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(len(payload)))
+	frame = append(frame, payload...)
+
+	_, err := conn.Conn.Write(frame)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// NewDecompressionReader constructs a new reader decompressing the frames
+// written by a server using the given compression algorithm.
+func NewDecompressionReader(alg CompressionAlg, reader io.Reader) (io.Reader, error) {
+	decompress, err := newDecompressor(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decompressionReader{reader: reader, decompress: decompress}, nil
+}
+
+type decompressionReader struct {
+	reader     io.Reader
+	decompress decompressor
+	header     [compressionFrameSize]byte
+	buffer     []byte
+}
+
+func (reader *decompressionReader) Read(p []byte) (int, error) {
+	for len(reader.buffer) == 0 {
+		_, err := io.ReadFull(reader.reader, reader.header[:])
+		if err != nil {
+			return 0, err
+		}
+
+		size := int(binary.BigEndian.Uint32(reader.header[0:4]))
+		payload := make([]byte, binary.BigEndian.Uint32(reader.header[4:8]))
+		_, err = io.ReadFull(reader.reader, payload)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(payload) == size {
+			reader.buffer = payload
+			continue
+		}
+
+		reader.buffer, err = reader.decompress(payload, size)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, reader.buffer)
+	reader.buffer = reader.buffer[n:]
+	return n, nil
+}
@@ -0,0 +1,129 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readCompressionTestMsg reads a single typed message from the given reader
+// without buffering.
+func readCompressionTestMsg(t *testing.T, reader io.Reader) (types.ServerMessage, []byte) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(reader, header)
+	require.NoError(t, err)
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:])-4)
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+
+	return types.ServerMessage(header[0]), payload
+}
+
+// compressionTestRows connects to the given address requesting the given
+// compression algorithm and returns the data row payloads returned by the
+// server.
+func compressionTestRows(t *testing.T, address net.Addr, alg CompressionAlg) [][]byte {
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	version := make([]byte, 4)
+	binary.BigEndian.PutUint32(version, uint32(types.Version30))
+
+	parameters := []byte("user\x00john\x00" + string(ParamCompression) + "\x00" + string(alg) + "\x00\x00")
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(header)+len(version)+len(parameters)))
+
+	_, err = conn.Write(append(header, append(version, parameters...)...))
+	require.NoError(t, err)
+
+	var reader io.Reader = conn
+	negotiated := CompressionNone
+
+	for {
+		typed, payload := readCompressionTestMsg(t, reader)
+		if typed == types.ServerReady {
+			break
+		}
+
+		if typed == types.ServerParameterStatus && string(payload) == "compression\x00"+string(alg)+"\x00" {
+			reader, err = NewDecompressionReader(alg, conn)
+			require.NoError(t, err)
+			negotiated = alg
+		}
+	}
+
+	assert.Equal(t, alg, negotiated)
+
+	client := mock.NewWriter(conn)
+	client.Start(types.ClientSimpleQuery)
+	client.AddString("SELECT * FROM users")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	rows := [][]byte{}
+	for {
+		typed, payload := readCompressionTestMsg(t, reader)
+		if typed == types.ServerReady {
+			break
+		}
+
+		if typed == types.ServerDataRow {
+			rows = append(rows, payload)
+		}
+	}
+
+	return rows
+}
+
+func TestCompression(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{{Name: "id", Oid: oid.T_int4}, {Name: "name", Oid: oid.T_text}})
+		if err != nil {
+			return err
+		}
+
+		for index := 0; index < 100; index++ {
+			err = writer.Row([]any{index, "user number " + strconv.Itoa(index)})
+			if err != nil {
+				return err
+			}
+		}
+
+		return writer.Complete("SELECT 100")
+	}
+
+	uncompressed, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	expected := compressionTestRows(t, TListenAndServe(t, uncompressed), CompressionNone)
+	require.Len(t, expected, 100)
+
+	for _, alg := range []CompressionAlg{CompressionNone, CompressionPGLZ, CompressionZstd} {
+		alg := alg
+		t.Run(string(alg), func(t *testing.T) {
+			server, err := NewServer(SimpleQuery(handler), Compression(alg))
+			require.NoError(t, err)
+
+			rows := compressionTestRows(t, TListenAndServe(t, server), alg)
+			assert.Equal(t, expected, rows)
+		})
+	}
+}
+
+func TestCompressionUnsupported(t *testing.T) {
+	_, err := NewServer(Compression("lz4"))
+	assert.Error(t, err)
+}
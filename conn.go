@@ -2,6 +2,7 @@ package wire
 
 import (
 	"context"
+	"net"
 
 	"github.com/jackc/pgtype"
 )
@@ -12,6 +13,13 @@ const (
 	ctxTypeInfo ctxKey = iota
 	ctxClientMetadata
 	ctxServerMetadata
+	ctxSubscriber
+	ctxRemoteAddress
+	ctxBackendKey
+	ctxExtendedQuery
+	ctxDeadlineConn
+	ctxChannelBinding
+	ctxMemoryBudget
 )
 
 // setTypeInfo constructs a new Postgres type connection info for the given value
@@ -50,8 +58,32 @@ const (
 	ParamDatabase             ParameterStatus = "database"
 	ParamUsername             ParameterStatus = "user"
 	ParamServerVersion        ParameterStatus = "server_version"
+	ParamDateStyle            ParameterStatus = "DateStyle"
+	ParamIntervalStyle        ParameterStatus = "IntervalStyle"
+	ParamTimeZone             ParameterStatus = "TimeZone"
+	ParamIntegerDatetimes     ParameterStatus = "integer_datetimes"
+	ParamStandardConforming   ParameterStatus = "standard_conforming_strings"
+	ParamReplication          ParameterStatus = "replication"
 )
 
+// DefaultServerVersion represents the server version broadcasted to clients
+// whenever no version has been set through the ServerVersion option.
+const DefaultServerVersion = "14.0"
+
+// DefaultParameters represents the server parameters send to all clients
+// once a handshake has been established. Parameters set through the
+// GlobalParameters option take precedence over the default parameters.
+var DefaultParameters = Parameters{
+	ParamServerVersion:      DefaultServerVersion,
+	ParamServerEncoding:     "UTF8",
+	ParamClientEncoding:     "UTF8",
+	ParamDateStyle:          "ISO, MDY",
+	ParamIntervalStyle:      "postgres",
+	ParamTimeZone:           "UTC",
+	ParamIntegerDatetimes:   "on",
+	ParamStandardConforming: "on",
+}
+
 // setClientParameters constructs a new context containing the given parameters.
 // Any previously defined metadata will be overriden.
 func setClientParameters(ctx context.Context, params Parameters) context.Context {
@@ -73,6 +105,25 @@ func ClientParameters(ctx context.Context) Parameters {
 	return val.(Parameters)
 }
 
+// StartupParameters returns the key/value parameters send by the client inside
+// the startup message (ex: database, user, application_name) if they have
+// been set inside the given context. The parameters are available to the
+// session handler and all query handlers. The returned map is a copy and could
+// safely be modified.
+func StartupParameters(ctx context.Context) map[string]string {
+	params := ClientParameters(ctx)
+	if params == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(params))
+	for key, value := range params {
+		result[string(key)] = value
+	}
+
+	return result
+}
+
 // setServerParameters constructs a new context containing the given parameters map.
 // Any previously defined metadata will be overriden.
 func setServerParameters(ctx context.Context, params Parameters) context.Context {
@@ -93,3 +144,20 @@ func ServerParameters(ctx context.Context) Parameters {
 
 	return val.(Parameters)
 }
+
+// setRemoteAddress constructs a new context containing the given remote
+// address of the client connection.
+func setRemoteAddress(ctx context.Context, addr net.Addr) context.Context {
+	return context.WithValue(ctx, ctxRemoteAddress, addr)
+}
+
+// RemoteAddress returns the remote address of the client connection if it has
+// been set inside the given context.
+func RemoteAddress(ctx context.Context) net.Addr {
+	val := ctx.Value(ctxRemoteAddress)
+	if val == nil {
+		return nil
+	}
+
+	return val.(net.Addr)
+}
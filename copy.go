@@ -0,0 +1,389 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+// CopyFormat represents the overall format of a COPY operation.
+type CopyFormat int8
+
+const (
+	// TextCopyFormat indicates a textual copy format. Rows are separated by
+	// newlines and columns are separated by a delimiter.
+	TextCopyFormat CopyFormat = 0
+	// BinaryCopyFormat indicates a binary copy format.
+	BinaryCopyFormat CopyFormat = 1
+)
+
+// ErrCopyUnsupported is returned when a copy operation is attempted on a data
+// writer which is not connected to a client.
+var ErrCopyUnsupported = errors.New("copy operations are not supported by the given data writer")
+
+// ErrCopyOutUnsupported is returned when a COPY TO STDOUT statement is
+// executed while rows are filtered, transformed, masked or encrypted. The raw
+// copy data written by the copy handler would otherwise bypass the configured
+// row filters, transformations, masks and encryption.
+var ErrCopyOutUnsupported = errors.New("copy to stdout is not supported while rows are filtered, transformed, masked or encrypted")
+
+// NewErrCopyOutUnsupported constructs a new error wrapping the
+// ErrCopyOutUnsupported type including the feature not supported error code.
+func NewErrCopyOutUnsupported() error {
+	return psqlerr.WithCode(ErrCopyOutUnsupported, codes.FeatureNotSupported)
+}
+
+// ErrCopyFailed is returned when the client aborted the copy operation.
+var ErrCopyFailed = errors.New("copy from stdin failed")
+
+// CopyInReader reads the copy data send by the client during a COPY FROM STDIN
+// operation. The raw copy data is read until the client announces that all
+// data has been send after which io.EOF is returned. An error wrapping
+// ErrCopyFailed is returned when the client aborted the copy operation.
+type CopyInReader interface {
+	io.Reader
+}
+
+// copyReader reads incoming CopyData messages from the client.
+type copyReader struct {
+	client    *buffer.Reader
+	deadlines *deadlineConn
+	chunk     []byte
+	err       error
+}
+
+func (reader *copyReader) Read(p []byte) (n int, err error) {
+	for len(reader.chunk) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+
+		reader.err = reader.next()
+	}
+
+	n = copy(p, reader.chunk)
+	reader.chunk = reader.chunk[n:]
+	return n, nil
+}
+
+// next reads the next copy message send by the client. Flush and sync
+// messages are ignored during a copy operation.
+// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-COPY
+func (reader *copyReader) next() error {
+	// NOTE: the read timeout applies to each copy message, long running copy
+	// operations are not interrupted as long as messages arrive in time.
+	reader.deadlines.next()
+
+	t, _, err := reader.client.ReadTypedMsg()
+	if err != nil {
+		return err
+	}
+
+	switch t {
+	case types.ClientCopyData:
+		reader.chunk = reader.client.Msg
+		return nil
+	case types.ClientCopyDone:
+		return io.EOF
+	case types.ClientCopyFail:
+		reason, err := reader.client.GetString()
+		if err != nil {
+			return err
+		}
+
+		return psqlerr.WithCode(fmt.Errorf("%w: %s", ErrCopyFailed, reason), codes.QueryCanceled)
+	case types.ClientFlush, types.ClientSync:
+		return nil
+	default:
+		err := fmt.Errorf("unexpected message type %q during copy", t)
+		return psqlerr.WithCode(err, codes.ProtocolViolation)
+	}
+}
+
+// copyInResponse announces to the client that the server is ready to copy
+// data from the client.
+func copyInResponse(writer *buffer.Writer, format CopyFormat) error {
+	writer.Start(types.ServerCopyInResponse)
+	writer.AddByte(byte(format))
+	// NOTE: the number of columns is not known ahead of time. Clients only
+	// use the overall format to encode the send copy data.
+	writer.AddInt16(0)
+	return writer.End()
+}
+
+// CSVOption configures the CSV output written through DataWriter.WriteCSV.
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	header []string
+}
+
+// CSVHeader writes the given column names as header row before any of the
+// data rows.
+func CSVHeader(names ...string) CSVOption {
+	return func(options *csvOptions) {
+		options.header = names
+	}
+}
+
+// writeCSV writes the given rows as RFC 4180 encoded CSV copy data to the
+// client. Each row is written as a single copy data message. The copy
+// operation is announced to the client before the first row is written and
+// completed once all rows have been written.
+func writeCSV(writer *buffer.Writer, rows [][]string, options csvOptions) error {
+	columns := len(options.header)
+	if columns == 0 && len(rows) > 0 {
+		columns = len(rows[0])
+	}
+
+	err := copyOutResponse(writer, TextCopyFormat, columns)
+	if err != nil {
+		return err
+	}
+
+	bb := &bytes.Buffer{}
+	encoder := csv.NewWriter(bb)
+
+	if options.header != nil {
+		rows = append([][]string{options.header}, rows...)
+	}
+
+	for _, row := range rows {
+		bb.Reset()
+
+		err = encoder.Write(row)
+		if err != nil {
+			return err
+		}
+
+		encoder.Flush()
+		err = encoder.Error()
+		if err != nil {
+			return err
+		}
+
+		writer.Start(types.ServerCopyData)
+		writer.AddBytes(bb.Bytes())
+		err = writer.End()
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Start(types.ServerCopyDone)
+	return writer.End()
+}
+
+// copyOutResponse announces to the client that the server is about to copy
+// data to the client using the given format and number of columns.
+func copyOutResponse(writer *buffer.Writer, format CopyFormat, columns int) error {
+	writer.Start(types.ServerCopyOutResponse)
+	writer.AddBytes(copyOutResponsePayload(format, columns))
+	return writer.End()
+}
+
+// CopyDirection represents the direction of a COPY operation.
+type CopyDirection int8
+
+const (
+	// CopyFrom indicates a COPY FROM STDIN operation copying data from the
+	// client to the server.
+	CopyFrom CopyDirection = iota
+	// CopyTo indicates a COPY TO STDOUT operation copying data from the server
+	// to the client.
+	CopyTo
+)
+
+// CopyStatement describes a intercepted COPY statement.
+type CopyStatement struct {
+	// Direction represents the direction in which the data is copied.
+	Direction CopyDirection
+	// Table contains the name of the copied table. The table name is empty
+	// whenever the result of a query is copied.
+	Table string
+	// Columns contains the (optional) list of copied columns.
+	Columns []string
+	// Query contains the copied query for COPY (query) TO STDOUT statements.
+	Query string
+	// Format represents the negotiated overall copy format.
+	Format CopyFormat
+	// Options contains the raw options following STDIN or STDOUT (ex: WITH
+	// (FORMAT csv, HEADER)).
+	Options string
+}
+
+// CopyReader is passed to copy handlers to read the copy data send by the
+// client, see CopyInReader.
+type CopyReader = CopyInReader
+
+// CopyWriter writes copy data to the client during a COPY TO STDOUT
+// operation. Each write is send as a single CopyData message, it is
+// recommended to write a single row per write.
+type CopyWriter interface {
+	io.Writer
+}
+
+// CopyHandler handles intercepted COPY statements. The given reader is set for
+// COPY FROM STDIN statements and the given writer for COPY TO STDOUT
+// statements. The number of copied rows is returned and used to complete the
+// command.
+type CopyHandler func(ctx context.Context, statement CopyStatement, reader CopyReader, writer CopyWriter) (rows int64, err error)
+
+// Copy sets the given copy handler. COPY FROM STDIN and COPY TO STDOUT
+// statements are intercepted and passed to the given handler instead of the
+// configured query parser. The copy sub-protocol (CopyInResponse,
+// CopyOutResponse, CopyData, CopyDone and CopyFail) is handled by the server.
+// The binary copy format is negotiated whenever requested inside the statement
+// options, the text format is used otherwise. COPY TO STDOUT statements are
+// rejected while row filters, transformations, masks or encryption are
+// configured, see ErrCopyOutUnsupported.
+func Copy(fn CopyHandler) OptionFn {
+	return func(srv *Server) error {
+		srv.Copy = fn
+		return nil
+	}
+}
+
+var (
+	copyStatement = regexp.MustCompile(`(?is)^\s*COPY\s+(\(.+\)|[^\s(]+)\s*(?:\(([^)]*)\))?\s+(FROM\s+STDIN|TO\s+STDOUT)\b\s*(.*?)\s*;?\s*$`)
+	copyBinary    = regexp.MustCompile(`(?i)\bFORMAT\s+'?binary'?|^(?:WITH\s+)?BINARY\b`)
+)
+
+// parseCopyStatement parses the given query as COPY FROM STDIN or COPY TO
+// STDOUT statement.
+func parseCopyStatement(query string) (CopyStatement, bool) {
+	matches := copyStatement.FindStringSubmatch(query)
+	if matches == nil {
+		return CopyStatement{}, false
+	}
+
+	statement := CopyStatement{
+		Direction: CopyFrom,
+		Table:     matches[1],
+		Format:    TextCopyFormat,
+		Options:   matches[4],
+	}
+
+	if strings.HasPrefix(matches[1], "(") {
+		statement.Table = ""
+		statement.Query = strings.TrimSpace(matches[1][1 : len(matches[1])-1])
+	}
+
+	if matches[2] != "" {
+		for _, column := range strings.Split(matches[2], ",") {
+			statement.Columns = append(statement.Columns, strings.TrimSpace(column))
+		}
+	}
+
+	if strings.HasPrefix(strings.ToUpper(matches[3]), "TO") {
+		statement.Direction = CopyTo
+	}
+
+	if copyBinary.MatchString(statement.Options) {
+		statement.Format = BinaryCopyFormat
+	}
+
+	return statement, true
+}
+
+// parseCopy returns a prepared statement handling the given query using the
+// configured copy handler if the given query is a COPY FROM STDIN or COPY TO
+// STDOUT statement.
+func (srv *Server) parseCopy(query string) (PreparedStatementFn, bool) {
+	if srv.Copy == nil {
+		return nil, false
+	}
+
+	request, has := parseCopyStatement(query)
+	if !has {
+		return nil, false
+	}
+
+	statement := func(ctx context.Context, writer DataWriter, parameters []string) error {
+		if request.Direction == CopyFrom {
+			reader, err := AcceptCopy(writer, request.Format)
+			if err != nil {
+				return err
+			}
+
+			rows, err := srv.Copy(ctx, request, reader, nil)
+			if err != nil {
+				return err
+			}
+
+			// NOTE: the remaining copy data has to be consumed before the
+			// command could be completed.
+			_, err = io.Copy(io.Discard, reader)
+			if err != nil {
+				return err
+			}
+
+			return CompleteCopy(writer, rows)
+		}
+
+		// NOTE: the row filters, transformations, masks and encryption wrapping
+		// the data writer reject raw messages. The copy operation is rejected
+		// before any copy data has been written to the client.
+		err := WriteRaw(writer, byte(types.ServerCopyOutResponse), copyOutResponsePayload(request.Format, len(request.Columns)))
+		if errors.Is(err, ErrRawUnsupported) {
+			return NewErrCopyOutUnsupported()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rows, err := srv.Copy(ctx, request, nil, &copyWriter{writer: writer})
+		if err != nil {
+			return err
+		}
+
+		err = WriteRaw(writer, byte(types.ServerCopyDone), nil)
+		if err != nil {
+			return err
+		}
+
+		return CompleteCopy(writer, rows)
+	}
+
+	return statement, true
+}
+
+// copyWriter writes copy data messages to the client.
+type copyWriter struct {
+	writer DataWriter
+}
+
+func (writer *copyWriter) Write(p []byte) (int, error) {
+	err := WriteRaw(writer.writer, byte(types.ServerCopyData), p)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// copyOutResponsePayload returns the payload of a CopyOutResponse message
+// using the given format and number of columns.
+func copyOutResponsePayload(format CopyFormat, columns int) []byte {
+	payload := make([]byte, 3+2*columns)
+	payload[0] = byte(format)
+	binary.BigEndian.PutUint16(payload[1:], uint16(columns))
+
+	for i := 0; i < columns; i++ {
+		binary.BigEndian.PutUint16(payload[3+2*i:], uint16(format))
+	}
+
+	return payload
+}
@@ -0,0 +1,368 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyIn(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan []string, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		reader, err := AcceptCopy(writer, TextCopyFormat)
+		if err != nil {
+			return err
+		}
+
+		rows := []string{}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			rows = append(rows, scanner.Text())
+		}
+
+		if scanner.Err() != nil {
+			return scanner.Err()
+		}
+
+		received <- rows
+		return CompleteCopy(writer, int64(len(rows)))
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	expected := make([]string, 50)
+	for index := range expected {
+		expected[index] = fmt.Sprintf("%d\tuser-%d", index, index)
+	}
+
+	data := strings.Join(expected, "\n") + "\n"
+	tag, err := conn.PgConn().CopyFrom(ctx, strings.NewReader(data), "COPY users FROM STDIN")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), tag.RowsAffected())
+	assert.Equal(t, expected, <-received)
+
+	t.Run("fail", func(t *testing.T) {
+		reader := io.MultiReader(strings.NewReader(data), &failingReader{err: errors.New("unexpected end")})
+		_, err := conn.PgConn().CopyFrom(ctx, reader, "COPY users FROM STDIN")
+		require.Error(t, err)
+
+		// NOTE: the connection should remain usable after a failed copy
+		tag, err = conn.PgConn().CopyFrom(ctx, strings.NewReader(data), "COPY users FROM STDIN")
+		require.NoError(t, err)
+		assert.Equal(t, int64(50), tag.RowsAffected())
+		<-received
+	})
+}
+
+type failingReader struct {
+	err error
+}
+
+func (reader *failingReader) Read([]byte) (int, error) {
+	return 0, reader.err
+}
+
+func TestCopyInUnsupported(t *testing.T) {
+	writer := NewIoDataWriter(io.Discard, nil)
+	_, err := AcceptCopy(writer, TextCopyFormat)
+	assert.ErrorIs(t, err, ErrCopyUnsupported)
+}
+
+func TestCopyOutCSV(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		rows := [][]string{
+			{"1", "John"},
+			{"2", "Marry, Jane"},
+			{"3", `The "Rock"`},
+		}
+
+		var options []CSVOption
+		if strings.Contains(query, "HEADER") {
+			options = append(options, CSVHeader("id", "name"))
+		}
+
+		err := WriteCSV(writer, rows, options...)
+		if err != nil {
+			return err
+		}
+
+		return CompleteCopy(writer, int64(len(rows)))
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	expected := "1,John\n2,\"Marry, Jane\"\n3,\"The \"\"Rock\"\"\"\n"
+
+	sink := &strings.Builder{}
+	tag, err := conn.PgConn().CopyTo(ctx, sink, "COPY users TO STDOUT WITH (FORMAT csv)")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), tag.RowsAffected())
+	assert.Equal(t, expected, sink.String())
+
+	t.Run("header", func(t *testing.T) {
+		sink := &strings.Builder{}
+		_, err := conn.PgConn().CopyTo(ctx, sink, "COPY users TO STDOUT WITH (FORMAT csv, HEADER)")
+		require.NoError(t, err)
+		assert.Equal(t, "id,name\n"+expected, sink.String())
+	})
+}
+
+func TestParseCopyStatement(t *testing.T) {
+	tests := map[string]struct {
+		query    string
+		expected CopyStatement
+	}{
+		"from stdin": {
+			query:    "COPY users FROM STDIN",
+			expected: CopyStatement{Direction: CopyFrom, Table: "users", Format: TextCopyFormat},
+		},
+		"columns": {
+			query:    `copy "users" ( "id", "name" ) from stdin binary;`,
+			expected: CopyStatement{Direction: CopyFrom, Table: `"users"`, Columns: []string{`"id"`, `"name"`}, Format: BinaryCopyFormat, Options: "binary"},
+		},
+		"to stdout": {
+			query:    "COPY users TO STDOUT WITH (FORMAT csv, HEADER)",
+			expected: CopyStatement{Direction: CopyTo, Table: "users", Format: TextCopyFormat, Options: "WITH (FORMAT csv, HEADER)"},
+		},
+		"query": {
+			query:    "COPY (SELECT * FROM users) TO STDOUT (FORMAT binary)",
+			expected: CopyStatement{Direction: CopyTo, Query: "SELECT * FROM users", Format: BinaryCopyFormat, Options: "(FORMAT binary)"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			statement, ok := parseCopyStatement(test.query)
+			require.True(t, ok)
+			assert.Equal(t, test.expected, statement)
+		})
+	}
+
+	_, ok := parseCopyStatement("COPY users FROM '/tmp/users.csv'")
+	assert.False(t, ok)
+}
+
+// countBinaryCopyTuples counts the tuples inside the given binary copy data.
+func countBinaryCopyTuples(t *testing.T, data []byte) int64 {
+	signature := "PGCOPY\n\377\r\n\x00"
+	require.True(t, strings.HasPrefix(string(data), signature))
+
+	// NOTE: skip the signature, flags field and header extension area
+	offset := len(signature) + 4
+	offset += 4 + int(binary.BigEndian.Uint32(data[offset:]))
+
+	// NOTE: the file trailer is optional
+	tuples := int64(0)
+	for offset < len(data) {
+		fields := int16(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+		if fields == -1 {
+			return tuples
+		}
+
+		for i := int16(0); i < fields; i++ {
+			length := int32(binary.BigEndian.Uint32(data[offset:]))
+			offset += 4
+			if length > 0 {
+				offset += int(length)
+			}
+		}
+
+		tuples++
+	}
+
+	return tuples
+}
+
+func TestCopyHandler(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		statement CopyStatement
+		data      []byte
+	}
+
+	copied := make(chan received, 1)
+
+	handler := func(ctx context.Context, statement CopyStatement, reader CopyReader, writer CopyWriter) (int64, error) {
+		if statement.Direction == CopyTo {
+			for _, row := range []string{"1\tJohn\n", "2\tMarry\n"} {
+				_, err := io.WriteString(writer, row)
+				if err != nil {
+					return 0, err
+				}
+			}
+
+			return 2, nil
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return 0, err
+		}
+
+		copied <- received{statement: statement, data: data}
+
+		if statement.Format == BinaryCopyFormat {
+			return countBinaryCopyTuples(t, data), nil
+		}
+
+		return int64(bytes.Count(data, []byte("\n"))), nil
+	}
+
+	// NOTE: pgx describes the copied columns before copying binary data
+	describe := func(ctx context.Context, query string) (Columns, error) {
+		return Columns{{Name: "id", Oid: oid.T_int4}, {Name: "name", Oid: oid.T_text}}, nil
+	}
+
+	server, err := NewServer(SimpleQuery(func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}), Copy(handler), Describe(describe))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	t.Run("from stdin text", func(t *testing.T) {
+		tag, err := conn.PgConn().CopyFrom(ctx, strings.NewReader("1\tJohn\n2\tMarry\n3\tJane\n"), "COPY users (id, name) FROM STDIN")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), tag.RowsAffected())
+
+		result := <-copied
+		assert.Equal(t, TextCopyFormat, result.statement.Format)
+		assert.Equal(t, []string{"id", "name"}, result.statement.Columns)
+		assert.Equal(t, "1\tJohn\n2\tMarry\n3\tJane\n", string(result.data))
+	})
+
+	t.Run("from stdin binary", func(t *testing.T) {
+		rows := [][]any{{int32(1), "John"}, {int32(2), "Marry"}}
+		count, err := conn.CopyFrom(ctx, pgx.Identifier{"users"}, []string{"id", "name"}, pgx.CopyFromRows(rows))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		result := <-copied
+		assert.Equal(t, BinaryCopyFormat, result.statement.Format)
+		assert.Equal(t, `"users"`, result.statement.Table)
+	})
+
+	t.Run("to stdout", func(t *testing.T) {
+		sink := &strings.Builder{}
+		tag, err := conn.PgConn().CopyTo(ctx, sink, "COPY users TO STDOUT")
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), tag.RowsAffected())
+		assert.Equal(t, "1\tJohn\n2\tMarry\n", sink.String())
+	})
+}
+
+func TestCopyOutRowWrappers(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, statement CopyStatement, reader CopyReader, writer CopyWriter) (int64, error) {
+		if statement.Direction == CopyTo {
+			_, err := io.WriteString(writer, "1\tJohn\n")
+			if err != nil {
+				return 0, err
+			}
+
+			return 1, nil
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return 0, err
+		}
+
+		return int64(bytes.Count(data, []byte("\n"))), nil
+	}
+
+	filter := func(ctx context.Context, row []any) (bool, error) {
+		return true, nil
+	}
+
+	masker := MaskerFunc(func(src any) any {
+		return "***"
+	})
+
+	options := map[string]OptionFn{
+		"filter": RowFilter(filter),
+		"mask":   MaskColumns(masker, "name"),
+	}
+
+	for name, option := range options {
+		option := option
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server, err := NewServer(SimpleQuery(func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+				return writer.Complete("OK")
+			}), Copy(handler), option)
+			require.NoError(t, err)
+
+			address := TListenAndServe(t, server)
+
+			ctx := context.Background()
+			connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+			conn, err := pgx.Connect(ctx, connstr)
+			require.NoError(t, err)
+			defer conn.Close(ctx)
+
+			// NOTE: the raw copy data would bypass the configured row wrappers
+			sink := &strings.Builder{}
+			_, err = conn.PgConn().CopyTo(ctx, sink, "COPY users TO STDOUT")
+			pgerr := &pgconn.PgError{}
+			require.ErrorAs(t, err, &pgerr)
+			assert.Equal(t, string(codes.FeatureNotSupported), pgerr.Code)
+			assert.Empty(t, sink.String())
+
+			tag, err := conn.PgConn().CopyFrom(ctx, strings.NewReader("1\tJohn\n2\tMarry\n"), "COPY users FROM STDIN")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), tag.RowsAffected())
+
+			tag, err = conn.Exec(ctx, "SELECT 1;")
+			require.NoError(t, err)
+			assert.Equal(t, "OK", tag.String())
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package wire
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq/oid"
+	"go.uber.org/zap"
+)
+
+// DDLHandlerFn handles the given DDL statement creating a new relation from a
+// query result, for example by executing it against a backing store. The
+// number of rows inserted into the new relation should be returned. The
+// command is completed once the handler returns without an error.
+type DDLHandlerFn func(ctx context.Context, statement string) (rows int64, err error)
+
+// DDLHandler sets the given DDL handler. CREATE TABLE AS, CREATE MATERIALIZED
+// VIEW and SELECT INTO statements are intercepted and passed to the given
+// handler instead of the configured query parser. Similar to Postgres the
+// command is completed with a "SELECT n" tag containing the number of inserted
+// rows, statements defined WITH NO DATA are completed with a "CREATE TABLE AS"
+// or "CREATE MATERIALIZED VIEW" tag.
+func DDLHandler(fn DDLHandlerFn) OptionFn {
+	return func(srv *Server) error {
+		srv.DDL = fn
+		return nil
+	}
+}
+
+// ddlStatement returns the command of the intercepted DDL statement defined by
+// the given top level words (see topLevelWords). The command is used as
+// command tag whenever no rows are inserted (WITH NO DATA). A boolean is
+// returned indicating whether the words define a intercepted DDL statement.
+func ddlStatement(words []string) (string, bool) {
+	if len(words) == 0 {
+		return "", false
+	}
+
+	switch words[0] {
+	case "CREATE":
+		index := 1
+		for index < len(words) && isTableModifier(words[index]) {
+			index++
+		}
+
+		if index+1 < len(words) && words[index] == "MATERIALIZED" && words[index+1] == "VIEW" {
+			return "CREATE MATERIALIZED VIEW", true
+		}
+
+		if index >= len(words) || words[index] != "TABLE" {
+			return "", false
+		}
+
+		for _, word := range words[index+1:] {
+			if word == "AS" {
+				return "CREATE TABLE AS", true
+			}
+		}
+	case "SELECT":
+		// NOTE: the INTO clause of a SELECT INTO statement is defined in
+		// between the select list and the FROM clause.
+		for _, word := range words[1:] {
+			switch word {
+			case "INTO":
+				return "SELECT INTO", true
+			case "FROM", "WHERE", "GROUP", "HAVING", "WINDOW", "ORDER", "LIMIT", "OFFSET", "FETCH", "FOR", "UNION", "INTERSECT", "EXCEPT":
+				return "", false
+			}
+		}
+	}
+
+	return "", false
+}
+
+// isTableModifier returns whether the given word is a modifier which could
+// be defined in between CREATE and TABLE.
+func isTableModifier(word string) bool {
+	switch word {
+	case "GLOBAL", "LOCAL", "TEMP", "TEMPORARY", "UNLOGGED":
+		return true
+	}
+
+	return false
+}
+
+// withNoData returns whether the given words end with a WITH NO DATA clause.
+func withNoData(words []string) bool {
+	if len(words) < 3 {
+		return false
+	}
+
+	tail := words[len(words)-3:]
+	return tail[0] == "WITH" && tail[1] == "NO" && tail[2] == "DATA"
+}
+
+// topLevelWords returns the upper case keywords and unquoted identifiers of
+// the given query which are not part of a comment, string constant, quoted
+// identifier or parenthesized expression.
+func topLevelWords(query string) []string {
+	words := []string{}
+	depth := 0
+
+	scanQuery(query, func(index int) int {
+		switch char := query[index]; {
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+		case isIdentifierChar(char):
+			end := index
+			for end < len(query) && (isIdentifierChar(query[end]) || query[end] == '$') {
+				end++
+			}
+
+			if depth == 0 {
+				words = append(words, strings.ToUpper(query[index:end]))
+			}
+
+			return end - 1
+		}
+
+		return index
+	})
+
+	return words
+}
+
+// parse parses the given query into a prepared statement. Catalog queries of
+// registered tables are handled by the server and DDL statements by the
+// configured DDL handler, all other queries are passed to the configured query
+// parser.
+func (srv *Server) parse(ctx context.Context, query string) (PreparedStatementFn, []oid.Oid, error) {
+	statement, handled := srv.parseBuiltin(query)
+	if handled {
+		return statement, nil, nil
+	}
+
+	statement, handled = srv.parseCopy(query)
+	if handled {
+		return statement, nil, nil
+	}
+
+	statement, handled, err := srv.parseCatalog(query)
+	if handled || err != nil {
+		return statement, queryParameters(query), err
+	}
+
+	if srv.DDL == nil {
+		return srv.parseQuery(ctx, query)
+	}
+
+	words := topLevelWords(query)
+	command, has := ddlStatement(words)
+	if !has {
+		return srv.parseQuery(ctx, query)
+	}
+
+	srv.logger.Debug("incoming DDL statement", zap.String("query", query), zap.String("command", command))
+
+	// NOTE: WITH NO DATA is only supported by CREATE TABLE AS and CREATE
+	// MATERIALIZED VIEW statements.
+	noData := command != "SELECT INTO" && withNoData(words)
+	statement = func(ctx context.Context, writer DataWriter, parameters []string) error {
+		rows, err := srv.DDL(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		if noData {
+			return writer.Complete(command)
+		}
+
+		return writer.Complete("SELECT " + strconv.FormatInt(rows, 10))
+	}
+
+	return statement, queryParameters(query), nil
+}
+
+// parseQuery parses the given query using the configured query parser. The
+// returned statement is wrapped by the query coalescer and query planner if
+// configured.
+func (srv *Server) parseQuery(ctx context.Context, query string) (PreparedStatementFn, []oid.Oid, error) {
+	statement, parameters, err := srv.Parse(ctx, query)
+	if err != nil {
+		return statement, parameters, err
+	}
+
+	if srv.coalescer != nil {
+		statement = srv.coalescer.wrap(query, statement)
+	}
+
+	// NOTE: the query plan is written by every caller and therefore has to
+	// wrap the coalesced statement.
+	if srv.Plan != nil {
+		statement = srv.planQuery(query, statement)
+	}
+
+	return statement, parameters, nil
+}
@@ -0,0 +1,80 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDDLHandler(t *testing.T) {
+	t.Parallel()
+
+	statements := make(chan string, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	ddl := func(ctx context.Context, statement string) (int64, error) {
+		if statement == "CREATE TABLE broken AS SELECT 1" {
+			return 0, errors.New("unable to create table")
+		}
+
+		statements <- statement
+		return 42, nil
+	}
+
+	server, err := NewServer(SimpleQuery(handler), DDLHandler(ddl))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	tests := map[string]string{
+		"CREATE TABLE active_users AS SELECT * FROM users WHERE active":    "SELECT 42",
+		"CREATE TEMP TABLE IF NOT EXISTS ids (id) AS SELECT id FROM users": "SELECT 42",
+		"CREATE MATERIALIZED VIEW names AS SELECT name FROM users":         "SELECT 42",
+		"SELECT id, name INTO UNLOGGED archive FROM users":                 "SELECT 42",
+		`CREATE TABLE "My Table" AS SELECT 1 WITH NO DATA`:                 "CREATE TABLE AS",
+		"CREATE MATERIALIZED VIEW empty AS SELECT 1 WITH NO DATA":          "CREATE MATERIALIZED VIEW",
+	}
+
+	for statement, expected := range tests {
+		tag, err := conn.Exec(ctx, statement)
+		require.NoError(t, err)
+		assert.Equal(t, expected, tag.String())
+		assert.Equal(t, statement, <-statements)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		queries := []string{
+			"CREATE TABLE users (id int, name text)",
+			"CREATE TABLE totals (total int GENERATED ALWAYS AS (1) STORED)",
+			"SELECT * FROM messages WHERE body = ' into archive '",
+			"SELECT id FROM users WHERE name IN (SELECT name INTO archive)",
+			"SELECT 1 /* INTO archive */ FROM users",
+			`SELECT "into" FROM users`,
+		}
+
+		for _, query := range queries {
+			tag, err := conn.Exec(ctx, query)
+			require.NoError(t, err, query)
+			assert.Equal(t, "OK", tag.String(), query)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := conn.Exec(ctx, "CREATE TABLE broken AS SELECT 1")
+		require.Error(t, err)
+	})
+}
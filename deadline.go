@@ -0,0 +1,150 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// ErrIdleSessionTimeout is returned whenever the client did not send a
+// complete message within the configured read timeout.
+var ErrIdleSessionTimeout = errors.New("terminating connection due to idle-session timeout")
+
+// NewErrIdleSessionTimeout constructs a new error wrapping the
+// ErrIdleSessionTimeout type including the idle session timeout error code.
+func NewErrIdleSessionTimeout(timeout time.Duration) error {
+	err := fmt.Errorf("%w: no message received within %s", ErrIdleSessionTimeout, timeout)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.IdleSessionTimeout), psqlerr.LevelFatal)
+}
+
+// ReadTimeout sets the maximum duration the server waits for a complete
+// message to be received from the client once the connection has been
+// established. The timeout is reset for each message, slow clients sending
+// large messages in multiple parts are therefore not penalized. The
+// connection is terminated with a idle session timeout error once the timeout
+// has been reached. No timeout is enforced when the given duration is zero.
+func ReadTimeout(d time.Duration) OptionFn {
+	return func(srv *Server) error {
+		if d < 0 {
+			return fmt.Errorf("read timeout must be positive, received %s", d)
+		}
+
+		srv.ReadTimeout = d
+		return nil
+	}
+}
+
+// WriteTimeout sets the maximum duration of writing a single message to the
+// client once the connection has been established. The connection is closed
+// once the timeout has been reached. No timeout is enforced when the given
+// duration is zero.
+func WriteTimeout(d time.Duration) OptionFn {
+	return func(srv *Server) error {
+		if d < 0 {
+			return fmt.Errorf("write timeout must be positive, received %s", d)
+		}
+
+		srv.WriteTimeout = d
+		return nil
+	}
+}
+
+// deadlineConn wraps a network connection and sets the read and write
+// deadlines before reading and writing messages. Deadlines are only set once
+// the connection has been started, the startup phase is guarded by the
+// startup timeout.
+type deadlineConn struct {
+	net.Conn
+	read    time.Duration
+	write   time.Duration
+	now     func() time.Time
+	started atomic.Bool
+	armed   atomic.Bool
+}
+
+// newDeadlineConn wraps the given connection whenever a read or write timeout
+// has been configured.
+func (srv *Server) newDeadlineConn(conn net.Conn) (net.Conn, *deadlineConn) {
+	if srv.ReadTimeout == 0 && srv.WriteTimeout == 0 {
+		return conn, nil
+	}
+
+	wrapped := &deadlineConn{
+		Conn:  conn,
+		read:  srv.ReadTimeout,
+		write: srv.WriteTimeout,
+		now:   time.Now,
+	}
+
+	return wrapped, wrapped
+}
+
+func (conn *deadlineConn) Read(p []byte) (int, error) {
+	// NOTE: the read deadline is only set for the first read of a message
+	// allowing the message to be received in multiple parts.
+	if conn.read > 0 && conn.started.Load() && conn.armed.CompareAndSwap(false, true) {
+		err := conn.Conn.SetReadDeadline(conn.now().Add(conn.read))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return conn.Conn.Read(p)
+}
+
+func (conn *deadlineConn) Write(p []byte) (int, error) {
+	if conn.write > 0 && conn.started.Load() {
+		err := conn.Conn.SetWriteDeadline(conn.now().Add(conn.write))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return conn.Conn.Write(p)
+}
+
+// next announces that a new message is expected to be read. The read deadline
+// is reset once the message is being read. Deadlines are enforced once this
+// method has been called for the first time.
+func (conn *deadlineConn) next() {
+	if conn == nil {
+		return
+	}
+
+	conn.started.Store(true)
+	conn.armed.Store(false)
+}
+
+// setDeadlineConn constructs a new context containing the given deadline
+// connection.
+func setDeadlineConn(ctx context.Context, conn *deadlineConn) context.Context {
+	if conn == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxDeadlineConn, conn)
+}
+
+// getDeadlineConn returns the deadline connection if it has been set inside
+// the given context.
+func getDeadlineConn(ctx context.Context) *deadlineConn {
+	val := ctx.Value(ctxDeadlineConn)
+	if val == nil {
+		return nil
+	}
+
+	return val.(*deadlineConn)
+}
+
+// isReadTimeout checks whether the given error has been caused by the read
+// deadline of the given connection.
+func (conn *deadlineConn) isReadTimeout(err error) bool {
+	return conn != nil && conn.read > 0 && errors.Is(err, os.ErrDeadlineExceeded)
+}
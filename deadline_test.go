@@ -0,0 +1,195 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadlineRecorder represents a mock network connection recording the read
+// and write deadlines set on the connection.
+type deadlineRecorder struct {
+	net.Conn
+	input  *bytes.Reader
+	output bytes.Buffer
+	reads  []time.Time
+	writes []time.Time
+}
+
+func (conn *deadlineRecorder) Read(p []byte) (int, error) {
+	// NOTE: a single byte is read at the time to simulate slow clients.
+	return conn.input.Read(p[:1])
+}
+
+func (conn *deadlineRecorder) Write(p []byte) (int, error) {
+	return conn.output.Write(p)
+}
+
+func (conn *deadlineRecorder) SetReadDeadline(t time.Time) error {
+	conn.reads = append(conn.reads, t)
+	return nil
+}
+
+func (conn *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	conn.writes = append(conn.writes, t)
+	return nil
+}
+
+func TestDeadlineConn(t *testing.T) {
+	t.Parallel()
+
+	clock := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder := &deadlineRecorder{input: bytes.NewReader([]byte("abcdef"))}
+
+	conn := &deadlineConn{
+		Conn:  recorder,
+		read:  time.Second,
+		write: 2 * time.Second,
+		now:   func() time.Time { return clock },
+	}
+
+	buf := make([]byte, 8)
+
+	// NOTE: deadlines are not set during the startup phase.
+	_, err := conn.Read(buf)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("startup"))
+	require.NoError(t, err)
+	assert.Empty(t, recorder.reads)
+	assert.Empty(t, recorder.writes)
+
+	conn.next()
+
+	for index := 0; index < 3; index++ {
+		_, err = conn.Read(buf)
+		require.NoError(t, err)
+		clock = clock.Add(100 * time.Millisecond)
+	}
+
+	// NOTE: the read deadline is only set once for all reads of a single
+	// message.
+	require.Len(t, recorder.reads, 1)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 1, 0, time.UTC), recorder.reads[0])
+
+	conn.next()
+
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	require.Len(t, recorder.reads, 2)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 1, int(300*time.Millisecond), time.UTC), recorder.reads[1])
+
+	_, err = conn.Write([]byte("first"))
+	require.NoError(t, err)
+	clock = clock.Add(time.Second)
+	_, err = conn.Write([]byte("second"))
+	require.NoError(t, err)
+
+	require.Len(t, recorder.writes, 2)
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 2, int(300*time.Millisecond), time.UTC), recorder.writes[0])
+	assert.Equal(t, time.Date(2022, 1, 1, 0, 0, 3, int(300*time.Millisecond), time.UTC), recorder.writes[1])
+}
+
+func TestDeadlineConnCopy(t *testing.T) {
+	t.Parallel()
+
+	input := bytes.Buffer{}
+	message := func(t types.ClientMessage, payload []byte) {
+		input.WriteByte(byte(t))
+		binary.Write(&input, binary.BigEndian, int32(len(payload)+4)) //nolint:errcheck
+		input.Write(payload)
+	}
+
+	rows := []string{"1,john\n", "2,jane\n", "3,bob\n"}
+	for _, row := range rows {
+		message(types.ClientCopyData, []byte(row))
+	}
+
+	message(types.ClientCopyDone, nil)
+
+	// NOTE: the clock advances half the read timeout each time a deadline is
+	// set, the copy operation as a whole therefore exceeds the read timeout.
+	clock := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	recorder := &deadlineRecorder{input: bytes.NewReader(input.Bytes())}
+	conn := &deadlineConn{
+		Conn: recorder,
+		read: time.Second,
+		now: func() time.Time {
+			clock = clock.Add(500 * time.Millisecond)
+			return clock
+		},
+	}
+
+	conn.next()
+
+	ctx := setDeadlineConn(setTypeInfo(context.Background(), newTypeInfo()), conn)
+	writer := newDataWriter(ctx, buffer.NewReader(conn, buffer.DefaultBufferSize), buffer.NewWriter(io.Discard))
+
+	reader, err := AcceptCopy(writer, TextCopyFormat)
+	require.NoError(t, err)
+
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(rows, ""), string(data))
+
+	// NOTE: a new read deadline is set for each copy message
+	require.Len(t, recorder.reads, len(rows)+1)
+	for index := 1; index < len(recorder.reads); index++ {
+		assert.Equal(t, 500*time.Millisecond, recorder.reads[index].Sub(recorder.reads[index-1]))
+	}
+
+	assert.Greater(t, recorder.reads[len(recorder.reads)-1].Sub(recorder.reads[0]), conn.read)
+}
+
+func TestReadTimeout(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), ReadTimeout(200*time.Millisecond), WriteTimeout(time.Second))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	for index := 0; index < 3; index++ {
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	time.Sleep(400 * time.Millisecond)
+
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.IdleSessionTimeout), pgerr.Code)
+}
+
+func TestInvalidTimeouts(t *testing.T) {
+	_, err := NewServer(ReadTimeout(-1))
+	assert.Error(t, err)
+
+	_, err = NewServer(WriteTimeout(-1))
+	assert.Error(t, err)
+}
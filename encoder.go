@@ -0,0 +1,71 @@
+package wire
+
+import (
+	"errors"
+
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// ColumnEncoder represents a encoder used to encode column values of a given
+// type. The returned bytes are written as column value to the client.
+type ColumnEncoder interface {
+	Encode(src any) ([]byte, error)
+}
+
+// ColumnEncoderFunc is an adapter allowing ordinary functions to be used as
+// column encoders.
+type ColumnEncoderFunc func(src any) ([]byte, error)
+
+// Encode calls fn(src).
+func (fn ColumnEncoderFunc) Encode(src any) ([]byte, error) {
+	return fn(src)
+}
+
+// RegisterEncoder registers the given encoder as text format encoder of the
+// given type OID inside the type info of the server. Columns of the given type
+// using the text format are encoded using the given encoder instead of the
+// pgtype encoders. Columns using the binary format are still encoded using the
+// binary encoder of the type, the binary format is not supported for types
+// without a binary encoder. NULL values are encoded as NULL without calling
+// the encoder. Use RegisterType to register both text and binary encoders.
+func RegisterEncoder(id oid.Oid, encoder ColumnEncoder) OptionFn {
+	return func(srv *Server) error {
+		if encoder == nil {
+			return errors.New("column encoder is required")
+		}
+
+		text := func(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error) {
+			bb, err := encoder.Encode(src)
+			if err != nil {
+				return nil, err
+			}
+
+			return append(buf, bb...), nil
+		}
+
+		var binary BinaryEncoder
+		if typed, has := srv.types.DataTypeForOID(uint32(id)); has {
+			if _, ok := typed.Value.(pgtype.BinaryEncoder); ok {
+				binary = valueBinaryEncoder(typed.Value)
+			}
+		}
+
+		return RegisterType(id, text, binary)(srv)
+	}
+}
+
+// valueBinaryEncoder returns a binary encoder encoding source values using
+// the binary encoder of the given type value. The given value is expected to
+// implement pgtype.BinaryEncoder.
+func valueBinaryEncoder(typed pgtype.Value) BinaryEncoder {
+	return func(ci *pgtype.ConnInfo, src any, buf []byte) ([]byte, error) {
+		value := pgtype.NewValue(typed)
+		err := value.Set(src)
+		if err != nil {
+			return nil, err
+		}
+
+		return value.(pgtype.BinaryEncoder).EncodeBinary(ci, buf)
+	}
+}
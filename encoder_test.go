@@ -0,0 +1,77 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterEncoder(t *testing.T) {
+	t.Parallel()
+
+	upper := ColumnEncoderFunc(func(src any) ([]byte, error) {
+		return []byte(strings.ToUpper(fmt.Sprint(src))), nil
+	})
+
+	server, err := NewServer(RegisterEncoder(oid.T_text, upper))
+	require.NoError(t, err)
+
+	ctx := setTypeInfo(context.Background(), server.types)
+
+	type test struct {
+		value    any
+		format   FormatCode
+		expected []byte
+	}
+
+	tests := map[string]test{
+		"text":   {value: "John", format: TextFormat, expected: []byte("JOHN")},
+		"binary": {value: "John", format: BinaryFormat, expected: []byte("John")},
+		"null":   {value: nil, format: TextFormat, expected: nil},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			writer := buffer.NewWriter(&bytes.Buffer{})
+			writer.Start(types.ServerDataRow)
+
+			column := Column{Name: "name", Oid: oid.T_text, Format: test.format}
+			err := column.Write(ctx, writer, test.value)
+			require.NoError(t, err)
+
+			// NOTE: the written message contains the message type (1 byte)
+			// and message length (4 bytes) followed by the value length.
+			if test.expected == nil {
+				assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, writer.Bytes()[5:])
+				return
+			}
+
+			assert.Equal(t, test.expected, writer.Bytes()[9:])
+		})
+	}
+
+	t.Run("isolated", func(t *testing.T) {
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		ctx := setTypeInfo(context.Background(), newTypeInfo())
+		column := Column{Name: "name", Oid: oid.T_text, Format: TextFormat}
+		err := column.Write(ctx, writer, "John")
+		require.NoError(t, err)
+
+		assert.Equal(t, []byte("John"), writer.Bytes()[9:])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewServer(RegisterEncoder(oid.T_text, nil))
+		assert.Error(t, err)
+	})
+}
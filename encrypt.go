@@ -0,0 +1,230 @@
+package wire
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq/oid"
+)
+
+// ErrInvalidCiphertext is returned whenever a encrypted column value could not
+// be decrypted.
+var ErrInvalidCiphertext = errors.New("invalid encrypted column value")
+
+// EncryptedColumns encrypts the values of the columns matching the given
+// column names before they are written to the client. Values are serialized
+// using the text representation of the defined column type and encrypted with
+// AES-256-GCM using the given 32 byte key. The random nonce is prepended to the
+// ciphertext and the result is written base64 encoded as a text column. NULL
+// values are written as NULL. Values could be decrypted using DecryptColumn.
+func EncryptedColumns(key []byte, columnNames ...string) OptionFn {
+	return func(srv *Server) error {
+		if len(key) != 32 {
+			return fmt.Errorf("encryption key must be 32 bytes for AES-256, received %d bytes", len(key))
+		}
+
+		if len(columnNames) == 0 {
+			return errors.New("at least a single encrypted column name has to be defined")
+		}
+
+		aead, err := newColumnCipher(key)
+		if err != nil {
+			return err
+		}
+
+		names := make(map[string]struct{}, len(columnNames))
+		for _, name := range columnNames {
+			names[name] = struct{}{}
+		}
+
+		srv.encryption = &columnEncryption{aead: aead, names: names}
+		return nil
+	}
+}
+
+// DecryptColumn decrypts the given column value encrypted using the
+// EncryptedColumns option with the given key. The text representation of the
+// original value is returned.
+func DecryptColumn(key []byte, value string) (string, error) {
+	aead, err := newColumnCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	bb, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCiphertext, err)
+	}
+
+	size := aead.NonceSize()
+	if len(bb) < size {
+		return "", ErrInvalidCiphertext
+	}
+
+	plaintext, err := aead.Open(nil, bb[:size], bb[size:], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCiphertext, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// newColumnCipher constructs a new AES-GCM cipher using the given key.
+func newColumnCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// columnEncryption contains the cipher and names of the encrypted columns.
+type columnEncryption struct {
+	aead  cipher.AEAD
+	names map[string]struct{}
+}
+
+// encrypt encrypts the given plaintext and returns the base64 encoded nonce
+// and ciphertext.
+func (encryption *columnEncryption) encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, encryption.aead.NonceSize(), encryption.aead.NonceSize()+len(plaintext)+encryption.aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := encryption.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// encryptWriter wraps a data writer and encrypts the values of the configured
+// columns before they are forwarded to the underlying data writer. Encrypted
+// columns are defined as text columns. Raw output (WriteCSV and WriteRaw) is
+// rejected as the values could not be encrypted.
+type encryptWriter struct {
+	wrappedWriter
+	ctx        context.Context
+	encryption *columnEncryption
+	encoders   map[int]*columnEncoder
+}
+
+func (writer *encryptWriter) Define(columns Columns) error {
+	defined := make(Columns, len(columns))
+	encoders := make(map[int]*columnEncoder)
+
+	for index, column := range columns {
+		defined[index] = column
+
+		if _, has := writer.encryption.names[column.Name]; !has {
+			continue
+		}
+
+		// NOTE: values are serialized using the text representation of the
+		// original column type before they are encrypted.
+		original := column
+		original.Format = TextFormat
+		encoders[index] = &columnEncoder{column: original}
+
+		defined[index] = Column{
+			Table:  column.Table,
+			Name:   column.Name,
+			AttrNo: column.AttrNo,
+			Oid:    oid.T_text,
+			Format: column.Format,
+		}
+	}
+
+	err := writer.DataWriter.Define(defined)
+	if err != nil {
+		return err
+	}
+
+	writer.encoders = encoders
+	return nil
+}
+
+func (writer *encryptWriter) Row(values []any) error {
+	values, err := writer.encrypt(values)
+	if err != nil {
+		return err
+	}
+
+	return writer.DataWriter.Row(values)
+}
+
+func (writer *encryptWriter) Batch(rows [][]any) error {
+	encrypted := make([][]any, len(rows))
+	for index, row := range rows {
+		values, err := writer.encrypt(row)
+		if err != nil {
+			return err
+		}
+
+		encrypted[index] = values
+	}
+
+	return Batch(writer.DataWriter, encrypted)
+}
+
+func (writer *encryptWriter) Peek(row []any) error {
+	values, err := writer.encrypt(row)
+	if err != nil {
+		return err
+	}
+
+	return Peek(writer.DataWriter, values)
+}
+
+// mapJSONRow rejects JSON rows containing encrypted columns, the column type
+// used to serialize the value before it is encrypted is unknown.
+func (writer *encryptWriter) mapJSONRow(row map[string]any) (map[string]any, error) {
+	for name := range row {
+		if _, has := writer.encryption.names[name]; has {
+			return nil, fmt.Errorf("%w: column %q is encrypted", ErrJSONResultUnsupported, name)
+		}
+	}
+
+	return row, nil
+}
+
+// encrypt returns a copy of the given row in which the values of the
+// encrypted columns have been replaced with their encrypted representation.
+func (writer *encryptWriter) encrypt(values []any) ([]any, error) {
+	if len(writer.encoders) == 0 {
+		return values, nil
+	}
+
+	encrypted := make([]any, len(values))
+	copy(encrypted, values)
+
+	for index, encoder := range writer.encoders {
+		if index >= len(values) {
+			continue
+		}
+
+		bb, null, err := encoder.encode(writer.ctx, values[index])
+		if err != nil {
+			return nil, err
+		}
+
+		if null {
+			encrypted[index] = nil
+			continue
+		}
+
+		value, err := writer.encryption.encrypt(bb)
+		if err != nil {
+			return nil, err
+		}
+
+		encrypted[index] = value
+	}
+
+	return encrypted, nil
+}
@@ -0,0 +1,165 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptedColumns(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{0x2a}, 32)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{
+			{Name: "id", Oid: oid.T_int4},
+			{Name: "ssn", Oid: oid.T_text},
+			{Name: "balance", Oid: oid.T_int8},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{1, "123-45-6789", int64(1500)})
+		if err != nil {
+			return err
+		}
+
+		err = MapRow(writer, map[string]any{"id": 2, "ssn": nil, "balance": int64(-20)})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 2")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), EncryptedColumns(key, "ssn", "balance"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT * FROM accounts;")
+	require.NoError(t, err)
+
+	fields := rows.FieldDescriptions()
+	require.Len(t, fields, 3)
+	assert.Equal(t, uint32(oid.T_int4), fields[0].DataTypeOID)
+	assert.Equal(t, uint32(oid.T_text), fields[1].DataTypeOID)
+	assert.Equal(t, uint32(oid.T_text), fields[2].DataTypeOID)
+
+	type result struct {
+		id      int32
+		ssn     *string
+		balance string
+	}
+
+	results := []result{}
+	for rows.Next() {
+		row := result{}
+		require.NoError(t, rows.Scan(&row.id, &row.ssn, &row.balance))
+		results = append(results, row)
+	}
+
+	require.NoError(t, rows.Err())
+	require.Len(t, results, 2)
+
+	require.NotNil(t, results[0].ssn)
+	assert.NotContains(t, *results[0].ssn, "123-45-6789")
+	assert.NotEqual(t, "1500", results[0].balance)
+
+	ssn, err := DecryptColumn(key, *results[0].ssn)
+	require.NoError(t, err)
+	assert.Equal(t, "123-45-6789", ssn)
+
+	balance, err := DecryptColumn(key, results[0].balance)
+	require.NoError(t, err)
+	assert.Equal(t, "1500", balance)
+
+	assert.Equal(t, int32(2), results[1].id)
+	assert.Nil(t, results[1].ssn)
+
+	balance, err = DecryptColumn(key, results[1].balance)
+	require.NoError(t, err)
+	assert.Equal(t, "-20", balance)
+
+	t.Run("wrong key", func(t *testing.T) {
+		_, err := DecryptColumn(bytes.Repeat([]byte{0x01}, 32), results[0].balance)
+		assert.ErrorIs(t, err, ErrInvalidCiphertext)
+	})
+}
+
+func TestInvalidEncryptedColumns(t *testing.T) {
+	_, err := NewServer(EncryptedColumns([]byte("short"), "ssn"))
+	assert.Error(t, err)
+
+	_, err = NewServer(EncryptedColumns(bytes.Repeat([]byte{0x2a}, 32)))
+	assert.Error(t, err)
+}
+
+func TestEncryptedColumnsOutputPaths(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{0x2a}, 32)
+	aead, err := newColumnCipher(key)
+	require.NoError(t, err)
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	encryption := &columnEncryption{aead: aead, names: map[string]struct{}{"ssn": {}}}
+
+	wrap := func(inner DataWriter) DataWriter {
+		return &encryptWriter{wrappedWriter: wrappedWriter{inner}, ctx: ctx, encryption: encryption}
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		writer := wrap(inner)
+		require.NoError(t, writer.Define(Columns{
+			{Name: "id", Oid: oid.T_int4},
+			{Name: "ssn", Oid: oid.T_text},
+		}))
+
+		require.NoError(t, writer.Row([]any{1, "111-11-1111"}))
+		require.NoError(t, Batch(writer, [][]any{{2, "222-22-2222"}}))
+		require.NoError(t, MapRow(writer, map[string]any{"id": 3, "ssn": "333-33-3333"}))
+
+		expected := []string{"111-11-1111", "222-22-2222", "333-33-3333"}
+		require.Len(t, inner.rows, len(expected))
+
+		for index, row := range inner.rows {
+			assert.Equal(t, index+1, row[0])
+
+			ssn, err := DecryptColumn(key, row[1].(string))
+			require.NoError(t, err)
+			assert.Equal(t, expected[index], ssn)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		err := JSONResult(wrap(inner), "SELECT 1", []map[string]any{{"id": 1, "ssn": "111-11-1111"}})
+		assert.ErrorIs(t, err, ErrJSONResultUnsupported)
+		assert.Empty(t, inner.rows)
+
+		inner = &recordWriter{ctx: ctx}
+		err = JSONResult(wrap(inner), "SELECT 1", []map[string]any{{"id": 1}})
+		require.NoError(t, err)
+		assert.Equal(t, [][]any{{`[{"id":1}]`}}, inner.rows)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		assertRawRejected(t, wrap)
+	})
+}
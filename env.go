@@ -0,0 +1,132 @@
+package wire
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Environment variables read by ServerFromEnv. The variables are prefixed to
+// avoid conflicts with the libpq client environment variables (ex: PGHOST)
+// which are commonly set inside the same environment.
+const (
+	EnvHost           = "PGWIRE_HOST"
+	EnvPort           = "PGWIRE_PORT"
+	EnvSSL            = "PGWIRE_SSL"
+	EnvSSLCert        = "PGWIRE_SSL_CERT"
+	EnvSSLKey         = "PGWIRE_SSL_KEY"
+	EnvMaxConnections = "PGWIRE_MAX_CONNECTIONS"
+	EnvLogLevel       = "PGWIRE_LOG_LEVEL"
+	EnvServerVersion  = "PGWIRE_SERVER_VERSION"
+	EnvBufferSize     = "PGWIRE_BUFFER_SIZE"
+)
+
+// ServerFromEnv reads the server configuration from environment variables and
+// returns the corresponding server options. Unset environment variables are
+// ignored and result in the server defaults being used. The following
+// environment variables are supported:
+//
+//   - PGWIRE_HOST and PGWIRE_PORT, the address to listen on (defaults to 127.0.0.1:5432)
+//   - PGWIRE_SSL, enables TLS using the key pair inside PGWIRE_SSL_CERT and PGWIRE_SSL_KEY
+//   - PGWIRE_MAX_CONNECTIONS, the maximum number of concurrent client connections
+//   - PGWIRE_LOG_LEVEL, the server log level (debug, info, warn, error)
+//   - PGWIRE_SERVER_VERSION, the server version announced to clients
+//   - PGWIRE_BUFFER_SIZE, the message buffer size in bytes
+func ServerFromEnv() ([]OptionFn, error) {
+	options := []OptionFn{}
+
+	host, hasHost := os.LookupEnv(EnvHost)
+	port, hasPort := os.LookupEnv(EnvPort)
+	if hasHost || hasPort {
+		if host == "" {
+			host = "127.0.0.1"
+		}
+
+		if port == "" {
+			port = "5432"
+		}
+
+		_, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvPort, err)
+		}
+
+		options = append(options, ListenAddress(net.JoinHostPort(host, port)))
+	}
+
+	if value, has := os.LookupEnv(EnvSSL); has {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvSSL, err)
+		}
+
+		if enabled {
+			cert, key := os.Getenv(EnvSSLCert), os.Getenv(EnvSSLKey)
+			if cert == "" || key == "" {
+				return nil, fmt.Errorf("%s and %s are required when %s is enabled", EnvSSLCert, EnvSSLKey, EnvSSL)
+			}
+
+			pair, err := tls.LoadX509KeyPair(cert, key)
+			if err != nil {
+				return nil, err
+			}
+
+			options = append(options, Certificates([]tls.Certificate{pair}))
+		}
+	}
+
+	if value, has := os.LookupEnv(EnvMaxConnections); has {
+		max, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvMaxConnections, err)
+		}
+
+		if max < 0 {
+			return nil, errors.New("max connections could not be negative")
+		}
+
+		options = append(options, MaxConnections(max))
+	}
+
+	if value, has := os.LookupEnv(EnvLogLevel); has {
+		level, err := zapcore.ParseLevel(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvLogLevel, err)
+		}
+
+		config := zap.NewProductionConfig()
+		config.Level = zap.NewAtomicLevelAt(level)
+
+		logger, err := config.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, Logger(logger))
+	}
+
+	if value, has := os.LookupEnv(EnvServerVersion); has {
+		options = append(options, ServerVersion(value))
+	}
+
+	if value, has := os.LookupEnv(EnvBufferSize); has {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvBufferSize, err)
+		}
+
+		if size < 0 {
+			return nil, errors.New("message buffer size could not be negative")
+		}
+
+		options = append(options, MessageBufferSize(size))
+	}
+
+	return options, nil
+}
@@ -0,0 +1,79 @@
+package wire
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestServerFromEnv(t *testing.T) {
+	t.Setenv(EnvPort, "5433")
+	t.Setenv(EnvSSL, "true")
+	t.Setenv(EnvSSLCert, "./examples/tls/psql.crt")
+	t.Setenv(EnvSSLKey, "./examples/tls/psql.key")
+	t.Setenv(EnvMaxConnections, "25")
+	t.Setenv(EnvLogLevel, "debug")
+	t.Setenv(EnvServerVersion, "14.0")
+	t.Setenv(EnvBufferSize, "1024")
+
+	options, err := ServerFromEnv()
+	require.NoError(t, err)
+
+	srv, err := NewServer(options...)
+	require.NoError(t, err)
+
+	assert.Equal(t, "127.0.0.1:5433", srv.Address)
+	assert.Len(t, srv.Certificates, 1)
+	assert.Equal(t, 25, srv.MaxConnections)
+	assert.True(t, srv.logger.Core().Enabled(zapcore.DebugLevel))
+	assert.Equal(t, "14.0", srv.Version)
+	assert.Equal(t, 1024, srv.BufferedMsgSize)
+}
+
+func TestServerFromEmptyEnv(t *testing.T) {
+	for _, key := range []string{EnvHost, EnvPort, EnvSSL, EnvSSLCert, EnvSSLKey, EnvMaxConnections, EnvLogLevel, EnvServerVersion, EnvBufferSize} {
+		// NOTE: t.Setenv restores the original value once the test completes
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	options, err := ServerFromEnv()
+	require.NoError(t, err)
+	assert.Empty(t, options)
+
+	t.Run("client variables", func(t *testing.T) {
+		t.Setenv("PGHOST", "db.example.com")
+		t.Setenv("PGPORT", "6432")
+		t.Setenv("PGSSLCERT", "/nonexistent/client.crt")
+
+		options, err := ServerFromEnv()
+		require.NoError(t, err)
+		assert.Empty(t, options)
+	})
+}
+
+func TestServerFromInvalidEnv(t *testing.T) {
+	tests := map[string]map[string]string{
+		"port":      {EnvPort: "postgres"},
+		"ssl":       {EnvSSL: "maybe"},
+		"ssl files": {EnvSSL: "true"},
+		"max conns": {EnvMaxConnections: "-1"},
+		"log level": {EnvLogLevel: "verbose"},
+		"buffer":    {EnvBufferSize: "-1"},
+	}
+
+	for name, env := range tests {
+		env := env
+		t.Run(name, func(t *testing.T) {
+			for key, value := range env {
+				t.Setenv(key, value)
+			}
+
+			_, err := ServerFromEnv()
+			assert.Error(t, err)
+		})
+	}
+}
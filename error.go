@@ -1,6 +1,11 @@
 package wire
 
 import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
 	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
@@ -18,20 +23,93 @@ const (
 	errFieldSQLState       errFieldType = 'C'
 	errFieldDetail         errFieldType = 'D'
 	errFieldHint           errFieldType = 'H'
+	errFieldWhere          errFieldType = 'W'
 	errFieldSrcFile        errFieldType = 'F'
 	errFieldSrcLine        errFieldType = 'L'
 	errFieldSrcFunction    errFieldType = 'R'
 	errFieldConstraintName errFieldType = 'n'
 )
 
+// ErrTooManyErrors is returned whenever the maximum number of errors written to
+// a single client connection has been reached.
+var ErrTooManyErrors = errors.New("too many errors")
+
+// NewErrTooManyErrors constructs a new fatal error wrapping the
+// ErrTooManyErrors type including the configuration limit exceeded error code.
+func NewErrTooManyErrors(limit int) error {
+	err := fmt.Errorf("%w: connection exceeded the limit of %d errors", ErrTooManyErrors, limit)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.ConfigurationLimitExceeded), psqlerr.LevelFatal)
+}
+
+// errorCounter wraps the given writer and counts the number of error response
+// messages written to it. Writes starting with the error response message type
+// are counted as a single error.
+type errorCounter struct {
+	io.Writer
+	errors int
+}
+
+func (counter *errorCounter) Write(p []byte) (int, error) {
+	if len(p) > 0 && types.ServerMessage(p[0]) == types.ServerErrorResponse {
+		counter.errors++
+	}
+
+	return counter.Writer.Write(p)
+}
+
+// Flush flushes the underlaying writer if it supports flushing.
+func (counter *errorCounter) Flush() error {
+	if flusher, ok := counter.Writer.(flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
 // ErrorCode writes a error message as response to a command with the given
 // severity and error message. A ready for query message is written back to the
 // client once the error has been written indicating the end of a command cycle.
 // https://www.postgresql.org/docs/current/static/protocol-error-fields.html
 func ErrorCode(writer *buffer.Writer, err error) error {
+	err = writeErrorResponse(writer, err)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: we are writing a ready for query message to indicate the end of a
+	// command cycle.
+	return readyForQuery(writer, types.ServerIdle)
+}
+
+// isNotice returns true whenever the given error has a notice severity (ex:
+// WARNING) and should be written as notice instead of error.
+func isNotice(err error) bool {
+	return err != nil && psqlerr.GetSeverity(err).Notice()
+}
+
+// writeErrorResponse writes a error response message to the client containing
+// the given error. Unlike ErrorCode no ready for query message is written
+// allowing errors to be written outside of a command cycle (ex: during the
+// connection startup). Errors with a notice severity are written as notice
+// response message.
+func writeErrorResponse(writer *buffer.Writer, err error) error {
 	desc := psqlerr.Flatten(err)
 
-	writer.Start(types.ServerErrorResponse)
+	typed := types.ServerErrorResponse
+	if desc.Severity.Notice() {
+		typed = types.ServerNoticeResponse
+
+		// NOTE: notices without a error code are written using the warning or
+		// successful completion class.
+		if desc.Code == codes.Uncategorized {
+			desc.Code = codes.SuccessfulCompletion
+			if desc.Severity == psqlerr.LevelWarning {
+				desc.Code = codes.Warning
+			}
+		}
+	}
+
+	writer.Start(typed)
 
 	writer.AddByte(byte(errFieldSeverity))
 	writer.AddString(string(desc.Severity))
@@ -55,6 +133,12 @@ func ErrorCode(writer *buffer.Writer, err error) error {
 		writer.AddNullTerminate()
 	}
 
+	if desc.Where != "" {
+		writer.AddByte(byte(errFieldWhere))
+		writer.AddString(desc.Where)
+		writer.AddNullTerminate()
+	}
+
 	if desc.Source != nil {
 		writer.AddByte(byte(errFieldSrcFile))
 		writer.AddString(desc.Source.File)
@@ -70,12 +154,5 @@ func ErrorCode(writer *buffer.Writer, err error) error {
 	}
 
 	writer.AddNullTerminate()
-	err = writer.End()
-	if err != nil {
-		return err
-	}
-
-	// NOTE: we are writing a ready for query message to indicate the end of a
-	// command cycle.
-	return readyForQuery(writer, types.ServerIdle)
+	return writer.End()
 }
@@ -8,9 +8,12 @@ import (
 	"testing"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jeroenrinzema/psql-wire/codes"
 	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/lib/pq/oid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestErrorCode(t *testing.T) {
@@ -49,3 +52,169 @@ func TestErrorCode(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestMaxErrorsPerConnection(t *testing.T) {
+	limit := 3
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return psqlerr.WithCode(errors.New("syntax error"), codes.Syntax)
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MaxErrorsPerConnection(limit))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgconn.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	for i := 0; i < limit; i++ {
+		_, err = conn.Exec(ctx, "SELECT invalid").ReadAll()
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		assert.Equal(t, string(codes.Syntax), pgErr.Code)
+	}
+
+	_, err = conn.Exec(ctx, "SELECT invalid").ReadAll()
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	assert.Equal(t, string(codes.ConfigurationLimitExceeded), pgErr.Code)
+	assert.Equal(t, "FATAL", pgErr.Severity)
+
+	<-conn.CleanupDone()
+	assert.True(t, conn.IsClosed())
+}
+
+func TestErrorWhere(t *testing.T) {
+	where := "PL/pgSQL function validate_user(text) line 3 at RAISE"
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := psqlerr.WithCode(errors.New("invalid user"), codes.RaiseException)
+		return psqlerr.WithWhere(err, where)
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT validate_user('John');")
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, where, pgerr.Where)
+	assert.Equal(t, string(codes.RaiseException), pgerr.Code)
+}
+
+func TestErrorNoticeSeverity(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		switch query {
+		case "SELECT deprecated;":
+			return psqlerr.WithSeverity(errors.New("function is deprecated"), psqlerr.LevelWarning)
+		case "SELECT debug;":
+			writer.Complete("SELECT 0") //nolint:errcheck
+			return psqlerr.WithSeverity(errors.New("debug output"), psqlerr.LevelDebug1)
+		default:
+			return psqlerr.WithSeverity(errors.New("unexpected query"), psqlerr.LevelError)
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	modes := map[string]pgx.QueryExecMode{
+		"simple":   pgx.QueryExecModeSimpleProtocol,
+		"extended": pgx.QueryExecModeCacheStatement,
+	}
+
+	for name, mode := range modes {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+			require.NoError(t, err)
+
+			notices := make(chan *pgconn.Notice, 2)
+			config.DefaultQueryExecMode = mode
+			config.OnNotice = func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+				notices <- notice
+			}
+
+			conn, err := pgx.ConnectConfig(ctx, config)
+			require.NoError(t, err)
+			defer conn.Close(ctx)
+
+			_, err = conn.Exec(ctx, "SELECT deprecated;")
+			require.NoError(t, err)
+
+			notice := <-notices
+			assert.Equal(t, string(psqlerr.LevelWarning), notice.Severity)
+			assert.Equal(t, string(codes.Warning), notice.Code)
+			assert.Equal(t, "function is deprecated", notice.Message)
+
+			tag, err := conn.Exec(ctx, "SELECT debug;")
+			require.NoError(t, err)
+			assert.Equal(t, "SELECT 0", tag.String())
+
+			notice = <-notices
+			assert.Equal(t, string(psqlerr.LevelDebug1), notice.Severity)
+			assert.Equal(t, string(codes.SuccessfulCompletion), notice.Code)
+
+			_, err = conn.Exec(ctx, "SELECT error;")
+			pgerr := &pgconn.PgError{}
+			require.ErrorAs(t, err, &pgerr)
+			assert.Equal(t, string(psqlerr.LevelError), pgerr.Severity)
+		})
+	}
+}
+
+func TestErrorFromErrNotice(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{{Name: "id", Oid: oid.T_int4}}) //nolint:errcheck
+
+		err := ErrorFromErr(writer, psqlerr.WithSeverity(errors.New("results are truncated"), psqlerr.LevelNotice))
+		if err != nil {
+			return err
+		}
+
+		writer.Row([]any{1}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+	require.NoError(t, err)
+
+	notices := make(chan *pgconn.Notice, 1)
+	config.OnNotice = func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+		notices <- notice
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var id int32
+	err = conn.QueryRow(ctx, "SELECT id;").Scan(&id)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), id)
+
+	notice := <-notices
+	assert.Equal(t, string(psqlerr.LevelNotice), notice.Severity)
+	assert.Equal(t, "results are truncated", notice.Message)
+}
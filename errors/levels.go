@@ -2,12 +2,12 @@ package errors
 
 // Severity represents the severity of a thrown error. The possible error
 // severities are ERROR, FATAL, or PANIC (in an error message), or WARNING,
-// NOTICE, DEBUG, INFO, or LOG (in a notice message)
+// NOTICE, DEBUG, DEBUG1-5, INFO, or LOG (in a notice message)
 type Severity string
 
 // Represents the severity of a thrown error. The possible error severities are
 // ERROR, FATAL, or PANIC (in an error message), or WARNING, NOTICE, DEBUG,
-// INFO, or LOG (in a notice message)
+// DEBUG1-5, INFO, or LOG (in a notice message)
 const (
 	LevelError   Severity = "ERROR"
 	LevelFatal   Severity = "FATAL"
@@ -15,6 +15,24 @@ const (
 	LevelWarning Severity = "WARNING"
 	LevelNotice  Severity = "NOTICE"
 	LevelDebug   Severity = "DEBUG"
+	LevelDebug1  Severity = "DEBUG1"
+	LevelDebug2  Severity = "DEBUG2"
+	LevelDebug3  Severity = "DEBUG3"
+	LevelDebug4  Severity = "DEBUG4"
+	LevelDebug5  Severity = "DEBUG5"
 	LevelInfo    Severity = "INFO"
 	LevelLog     Severity = "LOG"
 )
+
+// Notice returns true whenever errors of the given severity are written to
+// the client inside a notice message instead of a error message. Notices do
+// not abort the executed command.
+func (severity Severity) Notice() bool {
+	switch severity {
+	case LevelWarning, LevelNotice, LevelInfo, LevelLog,
+		LevelDebug, LevelDebug1, LevelDebug2, LevelDebug3, LevelDebug4, LevelDebug5:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,35 @@
+package errors
+
+import "errors"
+
+// WithWhere decorates the error with a Postgres error context, such as the
+// call stack of the procedure in which the error occurred
+func WithWhere(err error, where string) error {
+	if err == nil {
+		return nil
+	}
+
+	return &withWhere{cause: err, where: where}
+}
+
+// GetWhere returns the Postgres error context inside the given error. If no
+// error context is an empty string returned.
+func GetWhere(err error) string {
+	if w, ok := err.(*withWhere); ok {
+		return w.where
+	}
+
+	if n := errors.Unwrap(err); n != nil {
+		return GetWhere(n)
+	}
+
+	return ""
+}
+
+type withWhere struct {
+	cause error
+	where string
+}
+
+func (w *withWhere) Error() string { return w.cause.Error() }
+func (w *withWhere) Unwrap() error { return w.cause }
@@ -0,0 +1,332 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+)
+
+// DescribeFn returns the columns of the rows returned by the given query once
+// executed. Nil is returned for queries which do not return any rows.
+type DescribeFn func(ctx context.Context, query string) (Columns, error)
+
+// Describe sets the given describe function used to describe the columns of
+// prepared statements and portals. Some clients (ex: lib/pq) rely on these
+// descriptions when executing prepared statements. A NoData message is
+// returned to describe messages when no describe function has been set, the
+// row description is written once the portal is executed instead.
+func Describe(fn DescribeFn) OptionFn {
+	return func(srv *Server) error {
+		srv.Describe = fn
+		return nil
+	}
+}
+
+// MaxPreparedStatements sets the maximum number of prepared statements a
+// single client connection is allowed to define. Parse messages defining new
+// prepared statements are rejected once the limit has been reached. Closed
+// prepared statements no longer count towards the limit. No limit is enforced
+// when the given limit is zero.
+func MaxPreparedStatements(n int) OptionFn {
+	return func(srv *Server) error {
+		if n < 0 {
+			return fmt.Errorf("max prepared statements must be positive, received %d", n)
+		}
+
+		srv.MaxPreparedStatements = n
+		return nil
+	}
+}
+
+// NewErrTooManyPreparedStatements is returned whenever the client connection
+// has reached the maximum number of prepared statements.
+func NewErrTooManyPreparedStatements(max int) error {
+	err := fmt.Errorf("too many prepared statements, connections are limited to %d prepared statements", max)
+	return psqlerr.WithCode(err, codes.ProgramLimitExceeded)
+}
+
+// DefaultMaxPendingRows represents the default maximum number of rows kept
+// pending while executing a portal with a row limit, see MaxPendingRows.
+const DefaultMaxPendingRows = 10000
+
+// MaxPendingRows sets the maximum number of rows kept pending while executing
+// a portal with a row limit. Query handlers are not paused once the row limit
+// of a Execute message has been reached, the remaining rows are kept in memory
+// until the portal is resumed by the next Execute message. Rows written beyond
+// the maximum number of pending rows are rejected with a program limit
+// exceeded error, clients executing portals returning large results should
+// therefore fetch them using larger row limits. No limit is enforced when the
+// given limit is zero. DefaultMaxPendingRows is used by default.
+func MaxPendingRows(n int) OptionFn {
+	return func(srv *Server) error {
+		if n < 0 {
+			return fmt.Errorf("max pending rows must be positive, received %d", n)
+		}
+
+		srv.MaxPendingRows = n
+		return nil
+	}
+}
+
+// NewErrTooManyPendingRows is returned whenever the executed portal has
+// reached the maximum number of pending rows.
+func NewErrTooManyPendingRows(max uint64) error {
+	err := fmt.Errorf("too many pending rows, portals are limited to %d rows exceeding the row limit", max)
+	return psqlerr.WithCode(err, codes.ProgramLimitExceeded)
+}
+
+// NewErrUnknownPortal is returned whenever no portal has been bound for the
+// given name.
+func NewErrUnknownPortal(name string) error {
+	err := fmt.Errorf("portal %q does not exist", name)
+	return psqlerr.WithCode(err, codes.InvalidCursorName)
+}
+
+// NewErrUnknownPreparedStatement is returned whenever no prepared statement has
+// been parsed for the given name.
+func NewErrUnknownPreparedStatement(name string) error {
+	err := fmt.Errorf("prepared statement %q does not exist", name)
+	return psqlerr.WithCode(err, codes.InvalidSQLStatementName)
+}
+
+// statementDescription describes a prepared statement parsed by the client.
+type statementDescription struct {
+	query      string
+	parameters []oid.Oid
+	columns    Columns
+}
+
+// portalDescription describes a portal bound by the client.
+type portalDescription struct {
+	statement  *statementDescription
+	parameters []string
+	formats    []FormatCode
+	suspended  *suspendedPortal
+}
+
+// suspendedPortal contains the rows remaining once the execution of a portal
+// has been suspended after reaching the row limit of a Execute message.
+type suspendedPortal struct {
+	columns Columns
+	rows    [][]any
+	tag     string
+}
+
+// extendedQuery keeps track of the prepared statements and portals of a
+// single client connection. Once an error occurred while processing an
+// extended query message all messages are discarded until a Sync message is
+// received.
+type extendedQuery struct {
+	statements map[string]*statementDescription
+	portals    map[string]*portalDescription
+	failed     bool
+}
+
+func newExtendedQuery() *extendedQuery {
+	return &extendedQuery{
+		statements: map[string]*statementDescription{},
+		portals:    map[string]*portalDescription{},
+	}
+}
+
+// setExtendedQuery constructs a new context containing the given extended
+// query state.
+func setExtendedQuery(ctx context.Context, state *extendedQuery) context.Context {
+	return context.WithValue(ctx, ctxExtendedQuery, state)
+}
+
+// getExtendedQuery returns the extended query state if it has been set inside
+// the given context. A new state is returned otherwise.
+func getExtendedQuery(ctx context.Context) *extendedQuery {
+	val := ctx.Value(ctxExtendedQuery)
+	if val == nil {
+		return newExtendedQuery()
+	}
+
+	return val.(*extendedQuery)
+}
+
+// checkPreparedStatementLimit checks whether the given prepared statement
+// could be defined without exceeding the configured prepared statement limit.
+// Redefining an existing prepared statement does not count towards the limit.
+func (srv *Server) checkPreparedStatementLimit(ctx context.Context, name string) error {
+	if srv.MaxPreparedStatements == 0 {
+		return nil
+	}
+
+	state := getExtendedQuery(ctx)
+	if _, has := state.statements[name]; has {
+		return nil
+	}
+
+	if len(state.statements) >= srv.MaxPreparedStatements {
+		return NewErrTooManyPreparedStatements(srv.MaxPreparedStatements)
+	}
+
+	return nil
+}
+
+// isExtendedQueryMessage checks whether the given message type is part of the
+// extended query protocol.
+func isExtendedQueryMessage(t types.ClientMessage) bool {
+	switch t {
+	case types.ClientParse, types.ClientBind, types.ClientDescribe, types.ClientExecute, types.ClientClose, types.ClientFlush:
+		return true
+	}
+
+	return false
+}
+
+// extendedQueryError writes the given error to the client. All following
+// extended query messages are discarded until a Sync message is received.
+// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-EXT-QUERY
+func extendedQueryError(ctx context.Context, writer *buffer.Writer, err error) error {
+	getExtendedQuery(ctx).failed = true
+	return writeErrorResponse(writer, err)
+}
+
+// handleDescribe writes the description of the requested prepared statement
+// or portal. Prepared statements are described using a ParameterDescription
+// followed by a RowDescription or NoData message. Portals are described using
+// a RowDescription or NoData message.
+func (srv *Server) handleDescribe(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+	typed, err := reader.GetPrepareType()
+	if err != nil {
+		return err
+	}
+
+	name, err := reader.GetString()
+	if err != nil {
+		return err
+	}
+
+	state := getExtendedQuery(ctx)
+
+	switch typed {
+	case buffer.PrepareStatement:
+		statement, has := state.statements[name]
+		if !has {
+			return extendedQueryError(ctx, writer, NewErrUnknownPreparedStatement(name))
+		}
+
+		err = writeParameterDescriptions(writer, statement.parameters)
+		if err != nil {
+			return err
+		}
+
+		// NOTE: the result formats are not yet known, the format fields of
+		// the row description are therefore zero.
+		return writeRowDescription(ctx, writer, statement.columns)
+	case buffer.PreparePortal:
+		portal, has := state.portals[name]
+		if !has {
+			return extendedQueryError(ctx, writer, NewErrUnknownPortal(name))
+		}
+
+		columns, err := applyFormats(portal.statement.columns, portal.formats)
+		if err != nil {
+			return extendedQueryError(ctx, writer, err)
+		}
+
+		return writeRowDescription(ctx, writer, columns)
+	}
+
+	return extendedQueryError(ctx, writer, psqlerr.WithCode(fmt.Errorf("unknown describe type %q", typed), codes.ProtocolViolation))
+}
+
+// handleClose closes the requested prepared statement or portal. Closing a
+// prepared statement implicitly closes all portals bound to it. It is not an
+// error to close a non-existent prepared statement or portal.
+func (srv *Server) handleClose(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+	typed, err := reader.GetPrepareType()
+	if err != nil {
+		return err
+	}
+
+	name, err := reader.GetString()
+	if err != nil {
+		return err
+	}
+
+	state := getExtendedQuery(ctx)
+
+	switch typed {
+	case buffer.PrepareStatement:
+		statement := state.statements[name]
+		delete(state.statements, name)
+
+		for key, portal := range state.portals {
+			if statement != nil && portal.statement == statement {
+				delete(state.portals, key)
+			}
+		}
+	case buffer.PreparePortal:
+		delete(state.portals, name)
+	default:
+		return extendedQueryError(ctx, writer, psqlerr.WithCode(fmt.Errorf("unknown close type %q", typed), codes.ProtocolViolation))
+	}
+
+	writer.Start(types.ServerCloseComplete)
+	return writer.End()
+}
+
+// writeRowDescription writes a row description for the given columns or a
+// NoData message if no columns are given.
+func writeRowDescription(ctx context.Context, writer *buffer.Writer, columns Columns) error {
+	if len(columns) == 0 {
+		writer.Start(types.ServerNoData)
+		return writer.End()
+	}
+
+	return columns.Define(ctx, writer)
+}
+
+// resumePortal writes the rows remaining inside the given suspended portal
+// respecting the given row limit.
+func resumePortal(ctx context.Context, writer *buffer.Writer, portal *portalDescription, limit uint32) error {
+	suspended := portal.suspended
+	portal.suspended = nil
+
+	resumed := &dataWriter{
+		ctx:       ctx,
+		client:    writer,
+		columns:   suspended.columns,
+		described: true,
+		extended:  true,
+		limit:     uint64(limit),
+	}
+
+	for _, row := range suspended.rows {
+		err := resumed.Row(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := resumed.Complete(suspended.tag)
+	if err != nil {
+		return err
+	}
+
+	portal.suspended = resumed.suspended()
+	return nil
+}
+
+// suspended returns the suspended portal state if the row limit of the given
+// data writer has been reached.
+func (writer *dataWriter) suspended() *suspendedPortal {
+	if len(writer.pending) == 0 {
+		return nil
+	}
+
+	return &suspendedPortal{
+		columns: writer.columns,
+		rows:    writer.pending,
+		tag:     writer.tag,
+	}
+}
@@ -0,0 +1,391 @@
+package wire
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var extendedTestColumns = Columns{
+	{Name: "id", Oid: oid.T_int4},
+	{Name: "name", Oid: oid.T_text},
+}
+
+func extendedTestHandler(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+	err := writer.Define(extendedTestColumns)
+	if err != nil {
+		return err
+	}
+
+	for index := 0; index < 5; index++ {
+		err = writer.Row([]any{index, fmt.Sprintf("%s %d", parameters[0], index)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Complete("SELECT 5")
+}
+
+func extendedTestDescribe(ctx context.Context, query string) (Columns, error) {
+	return extendedTestColumns, nil
+}
+
+func TestExtendedQueryDescribe(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(SimpleQuery(extendedTestHandler), Describe(extendedTestDescribe))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("jackc/pgx", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		description, err := conn.PgConn().Prepare(ctx, "users", "SELECT * FROM users WHERE name = $1", nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, []uint32{0}, description.ParamOIDs)
+		require.Len(t, description.Fields, 2)
+		assert.Equal(t, "id", description.Fields[0].Name)
+		assert.Equal(t, uint32(oid.T_int4), description.Fields[0].DataTypeOID)
+		assert.Equal(t, "name", description.Fields[1].Name)
+		assert.Equal(t, uint32(oid.T_text), description.Fields[1].DataTypeOID)
+
+		result := conn.PgConn().ExecPrepared(ctx, "users", [][]byte{[]byte("john")}, nil, nil).Read()
+		require.NoError(t, result.Err)
+		assert.Len(t, result.FieldDescriptions, 2)
+		assert.Len(t, result.Rows, 5)
+		assert.Equal(t, "SELECT 5", result.CommandTag.String())
+
+		var id int
+		var name string
+		err = conn.QueryRow(ctx, "SELECT * FROM users WHERE name = $1", "john").Scan(&id, &name)
+		require.NoError(t, err)
+		assert.Equal(t, 0, id)
+		assert.Equal(t, "john 0", name)
+	})
+
+	t.Run("lib/pq", func(t *testing.T) {
+		conn, err := sql.Open("postgres", fmt.Sprintf("host=%s port=%d sslmode=disable", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		statement, err := conn.Prepare("SELECT * FROM users WHERE name = $1")
+		require.NoError(t, err)
+		defer statement.Close()
+
+		rows, err := statement.Query("john")
+		require.NoError(t, err)
+		defer rows.Close()
+
+		count := 0
+		for rows.Next() {
+			var id int
+			var name string
+			err = rows.Scan(&id, &name)
+			require.NoError(t, err)
+
+			assert.Equal(t, count, id)
+			assert.Equal(t, "john "+strconv.Itoa(count), name)
+			count++
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, 5, count)
+	})
+}
+
+func TestExtendedQueryWithoutDescribe(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(SimpleQuery(extendedTestHandler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	description, err := conn.PgConn().Prepare(ctx, "users", "SELECT * FROM users WHERE name = $1", nil)
+	require.NoError(t, err)
+	assert.Empty(t, description.Fields)
+
+	result := conn.PgConn().ExecPrepared(ctx, "users", [][]byte{[]byte("john")}, nil, nil).Read()
+	require.NoError(t, result.Err)
+	assert.Len(t, result.FieldDescriptions, 2)
+	assert.Len(t, result.Rows, 5)
+}
+
+// expectExtendedMessages reads the given message types from the given client.
+func expectExtendedMessages(t *testing.T, client *mock.Client, expected ...types.ServerMessage) {
+	t.Helper()
+
+	for _, message := range expected {
+		typed, _, err := client.ReadTypedMsg()
+		require.NoError(t, err)
+		require.Equal(t, string(message), string(typed))
+	}
+}
+
+func TestExtendedQueryPortalSuspended(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(SimpleQuery(extendedTestHandler), Describe(extendedTestDescribe))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := mock.NewClient(conn)
+	client.Handshake(t)
+	client.Authenticate(t)
+	client.ReadyForQuery(t)
+
+	client.Start(types.ClientParse)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddString("SELECT * FROM users WHERE name = $1")
+	client.AddNullTerminate()
+	client.AddInt16(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientBind)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddInt16(0)
+	client.AddInt16(1)
+	client.AddInt32(4)
+	client.AddBytes([]byte("john"))
+	client.AddInt16(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientDescribe)
+	client.AddByte('P')
+	client.AddString("")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	for index := 0; index < 3; index++ {
+		client.Start(types.ClientExecute)
+		client.AddString("")
+		client.AddNullTerminate()
+		client.AddInt32(2)
+		require.NoError(t, client.End())
+	}
+
+	client.Start(types.ClientClose)
+	client.AddByte('P')
+	client.AddString("")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientSync)
+	require.NoError(t, client.End())
+
+	expectExtendedMessages(t, client,
+		types.ServerParseComplete,
+		types.ServerBindComplete,
+		types.ServerRowDescription,
+		types.ServerDataRow, types.ServerDataRow, types.ServerPortalSuspended,
+		types.ServerDataRow, types.ServerDataRow, types.ServerPortalSuspended,
+		types.ServerDataRow, types.ServerCommandComplete,
+		types.ServerCloseComplete,
+		types.ServerReady,
+	)
+}
+
+func TestExtendedQueryErrorDiscardsUntilSync(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer(SimpleQuery(extendedTestHandler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := mock.NewClient(conn)
+	client.Handshake(t)
+	client.Authenticate(t)
+	client.ReadyForQuery(t)
+
+	client.Start(types.ClientBind)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddString("unknown")
+	client.AddNullTerminate()
+	client.AddInt16(0)
+	client.AddInt16(0)
+	client.AddInt16(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientExecute)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddInt32(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientSync)
+	require.NoError(t, client.End())
+
+	expectExtendedMessages(t, client, types.ServerErrorResponse, types.ServerReady)
+}
+
+func TestMaxPreparedStatements(t *testing.T) {
+	t.Parallel()
+
+	const max = 3
+
+	server, err := NewServer(SimpleQuery(extendedTestHandler), MaxPreparedStatements(max))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	for index := 0; index < max; index++ {
+		_, err = conn.PgConn().Prepare(ctx, fmt.Sprintf("users_%d", index), "SELECT * FROM users WHERE name = $1", nil)
+		require.NoError(t, err)
+	}
+
+	_, err = conn.PgConn().Prepare(ctx, "overflow", "SELECT * FROM users WHERE name = $1", nil)
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.ProgramLimitExceeded), pgerr.Code)
+
+	t.Run("redefine", func(t *testing.T) {
+		_, err = conn.PgConn().Prepare(ctx, "users_0", "SELECT * FROM users WHERE name = $1", nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("other connection", func(t *testing.T) {
+		other, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer other.Close(ctx)
+
+		_, err = other.PgConn().Prepare(ctx, "users_0", "SELECT * FROM users WHERE name = $1", nil)
+		require.NoError(t, err)
+	})
+}
+
+func TestInvalidMaxPreparedStatements(t *testing.T) {
+	_, err := NewServer(MaxPreparedStatements(-1))
+	assert.Error(t, err)
+}
+
+func TestMaxPendingRows(t *testing.T) {
+	t.Parallel()
+
+	const (
+		limit   = 2
+		max     = 10
+		results = 100000
+	)
+
+	produced := make(chan int, 1)
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(extendedTestColumns)
+		if err != nil {
+			return err
+		}
+
+		for index := 0; index < results; index++ {
+			err = writer.Row([]any{index, "john"})
+			if err != nil {
+				produced <- index
+				return err
+			}
+		}
+
+		produced <- results
+		return writer.Complete(fmt.Sprintf("SELECT %d", results))
+	}
+
+	server, err := NewServer(SimpleQuery(handler), Describe(extendedTestDescribe), MaxPendingRows(max))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := mock.NewClient(conn)
+	client.Handshake(t)
+	client.Authenticate(t)
+	client.ReadyForQuery(t)
+
+	client.Start(types.ClientParse)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddString("SELECT * FROM users")
+	client.AddNullTerminate()
+	client.AddInt16(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientBind)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddInt16(0)
+	client.AddInt16(0)
+	client.AddInt16(0)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientExecute)
+	client.AddString("")
+	client.AddNullTerminate()
+	client.AddInt32(limit)
+	require.NoError(t, client.End())
+
+	client.Start(types.ClientSync)
+	require.NoError(t, client.End())
+
+	expectExtendedMessages(t, client,
+		types.ServerParseComplete,
+		types.ServerBindComplete,
+		types.ServerDataRow, types.ServerDataRow,
+	)
+
+	typed, _, err := client.ReadTypedMsg()
+	require.NoError(t, err)
+	require.Equal(t, string(types.ServerErrorResponse), string(typed))
+	assert.Contains(t, string(client.Msg), "C"+string(codes.ProgramLimitExceeded))
+
+	expectExtendedMessages(t, client, types.ServerReady)
+
+	// NOTE: the handler is stopped once the maximum number of pending rows has
+	// been reached instead of buffering the entire result.
+	assert.Equal(t, limit+max, <-produced)
+}
+
+func TestInvalidMaxPendingRows(t *testing.T) {
+	_, err := NewServer(MaxPendingRows(-1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// ErrQueryNotAllowed is returned whenever a incoming query is rejected by the
+// configured query allow/deny patterns.
+var ErrQueryNotAllowed = errors.New("query is not allowed")
+
+// NewErrQueryNotAllowed constructs a new error wrapping the ErrQueryNotAllowed
+// type including the insufficient privilege error code.
+func NewErrQueryNotAllowed(query string) error {
+	err := fmt.Errorf("%w: %s", ErrQueryNotAllowed, query)
+	return psqlerr.WithCode(err, codes.InsufficientPrivilege)
+}
+
+// compileQueryPatterns compiles the given query patterns. Patterns are
+// interpreted as case-insensitive glob patterns where '*' matches any sequence
+// of characters and '?' matches a single character. Patterns wrapped inside
+// slashes (ex: /^select/) are interpreted as regular expressions.
+func compileQueryPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			expr, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid query pattern %q: %w", pattern, err)
+			}
+
+			result = append(result, expr)
+			continue
+		}
+
+		var builder strings.Builder
+		builder.WriteString("(?is)^")
+		for _, char := range pattern {
+			switch char {
+			case '*':
+				builder.WriteString(".*")
+			case '?':
+				builder.WriteString(".")
+			default:
+				builder.WriteString(regexp.QuoteMeta(string(char)))
+			}
+		}
+		builder.WriteString("$")
+
+		result = append(result, regexp.MustCompile(builder.String()))
+	}
+
+	return result, nil
+}
+
+// authorizeQuery checks whether the given query is allowed to be executed.
+// Each statement of a multi-statement query is checked separately. A query is
+// rejected when any of its statements matches any of the denied query
+// patterns or when allowed patterns are defined and none of them matches one
+// of its statements.
+func (srv *Server) authorizeQuery(query string) error {
+	if len(srv.allowedQueries) == 0 && len(srv.deniedQueries) == 0 {
+		return nil
+	}
+
+	for _, statement := range splitStatements(query) {
+		if !srv.authorizeStatement(statement) {
+			return NewErrQueryNotAllowed(strings.TrimSpace(query))
+		}
+	}
+
+	return nil
+}
+
+// authorizeStatement returns whether the given single statement is allowed to
+// be executed.
+func (srv *Server) authorizeStatement(statement string) bool {
+	for _, pattern := range srv.deniedQueries {
+		if pattern.MatchString(statement) {
+			return false
+		}
+	}
+
+	if len(srv.allowedQueries) == 0 {
+		return true
+	}
+
+	for _, pattern := range srv.allowedQueries {
+		if pattern.MatchString(statement) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitStatements splits the given query into its (trimmed) statements.
+// Semicolons inside comments, string constants, quoted identifiers and
+// dollar-quoted string constants do not end a statement. Empty statements are
+// omitted, a single empty statement is returned for empty queries.
+func splitStatements(query string) []string {
+	statements := []string{}
+	start := 0
+
+	appendStatement := func(statement string) {
+		statement = strings.TrimSpace(statement)
+		if statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+
+	scanQuery(query, func(index int) int {
+		if query[index] == ';' {
+			appendStatement(query[start:index])
+			start = index + 1
+		}
+
+		return index
+	})
+
+	appendStatement(query[start:])
+	if len(statements) == 0 {
+		return []string{""}
+	}
+
+	return statements
+}
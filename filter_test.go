@@ -0,0 +1,127 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizeQuery(t *testing.T) {
+	type test struct {
+		options []OptionFn
+		allowed map[string]bool
+	}
+
+	tests := map[string]test{
+		"none": {
+			allowed: map[string]bool{
+				"SELECT 1":          true,
+				"DELETE FROM users": true,
+			},
+		},
+		"allow glob": {
+			options: []OptionFn{AllowQueries("SELECT *")},
+			allowed: map[string]bool{
+				"SELECT 1":            true,
+				"  select * from t\n": true,
+				"DELETE FROM users":   false,
+			},
+		},
+		"deny regex": {
+			options: []OptionFn{DenyQueries(`/(?i)^\s*(drop|truncate)\b/`)},
+			allowed: map[string]bool{
+				"SELECT 1":       true,
+				"drop table t":   false,
+				"TRUNCATE users": false,
+			},
+		},
+		"stacked statements": {
+			options: []OptionFn{AllowQueries("SELECT *"), DenyQueries(`/(?i)^\s*drop\b/`)},
+			allowed: map[string]bool{
+				"SELECT 1; SELECT 2;":                  true,
+				"SELECT ';DROP TABLE x'":               true,
+				"SELECT 1 /* ; DROP TABLE x */":        true,
+				"SELECT $$;DROP TABLE x$$":             true,
+				"SELECT 1; DROP TABLE x":               false,
+				"SELECT 1;DELETE FROM users":           false,
+				"SELECT 1 -- comment\n; DELETE FROM x": false,
+			},
+		},
+		"deny precedence": {
+			options: []OptionFn{AllowQueries("SELECT *"), DenyQueries("SELECT * FROM secrets*")},
+			allowed: map[string]bool{
+				"SELECT * FROM users":   true,
+				"SELECT * FROM secrets": false,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			srv, err := NewServer(test.options...)
+			require.NoError(t, err)
+
+			for query, allowed := range test.allowed {
+				err := srv.authorizeQuery(query)
+				if allowed {
+					assert.NoError(t, err, query)
+					continue
+				}
+
+				assert.ErrorIs(t, err, ErrQueryNotAllowed, query)
+			}
+		})
+	}
+}
+
+func TestInvalidQueryPattern(t *testing.T) {
+	_, err := NewServer(DenyQueries("/(/"))
+	assert.Error(t, err)
+}
+
+func TestAllowQueries(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "value", Oid: oid.T_int4, Format: TextFormat},
+		})
+
+		writer.Row([]any{1}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), AllowQueries("SELECT *"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var value int
+	err = conn.QueryRow(ctx, "SELECT 1;").Scan(&value)
+	require.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	for _, query := range []string{"DELETE FROM users;", "INSERT INTO users VALUES (1);"} {
+		_, err = conn.Exec(ctx, query)
+		require.Error(t, err)
+
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(err, &pgErr))
+		assert.Equal(t, string(codes.InsufficientPrivilege), pgErr.Code)
+	}
+}
@@ -1,9 +1,12 @@
 package wire
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/jackc/pgtype"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 )
 
 // FormatCode represents the encoding format of a given column
@@ -40,3 +43,68 @@ const (
 	// BinaryFormat is an alternative, binary, encoding.
 	BinaryFormat FormatCode = 1
 )
+
+// resultFormats wraps the given prepared statement and encodes the result
+// columns using the given result-column format codes requested by the client.
+// A single format code is applied to all columns, otherwise the format codes
+// are applied to the columns in order.
+func resultFormats(fn PreparedStatementFn, formats []FormatCode) PreparedStatementFn {
+	return func(ctx context.Context, writer DataWriter, parameters []string) error {
+		return fn(ctx, &formatWriter{wrappedWriter: wrappedWriter{writer}, formats: formats}, parameters)
+	}
+}
+
+// formatWriter wraps a data writer and overrides the format of the defined
+// columns with the requested result-column format codes. Rows and raw output
+// are not affected by the format codes and are forwarded as is.
+type formatWriter struct {
+	wrappedWriter
+	formats []FormatCode
+}
+
+func (writer *formatWriter) Define(columns Columns) error {
+	formatted, err := applyFormats(columns, writer.formats)
+	if err != nil {
+		return err
+	}
+
+	return writer.DataWriter.Define(formatted)
+}
+
+func (writer *formatWriter) Batch(rows [][]any) error {
+	return Batch(writer.DataWriter, rows)
+}
+
+func (writer *formatWriter) WriteCSV(rows [][]string, options ...CSVOption) error {
+	return WriteCSV(writer.DataWriter, rows, options...)
+}
+
+func (writer *formatWriter) WriteRaw(messageType byte, payload []byte) error {
+	return WriteRaw(writer.DataWriter, messageType, payload)
+}
+
+// applyFormats returns a copy of the given columns using the given
+// result-column format codes. The columns are returned as is when no format
+// codes are given.
+func applyFormats(columns Columns, formats []FormatCode) (Columns, error) {
+	if len(formats) == 0 {
+		return columns, nil
+	}
+
+	if len(formats) != 1 && len(formats) != len(columns) {
+		err := fmt.Errorf("bind message has %d result formats but query has %d columns", len(formats), len(columns))
+		return nil, psqlerr.WithCode(err, codes.ProtocolViolation)
+	}
+
+	formatted := make(Columns, len(columns))
+	for index, column := range columns {
+		column.Format = formats[0]
+		if len(formats) > 1 {
+			column.Format = formats[index]
+		}
+
+		formatted[index] = column
+	}
+
+	return formatted, nil
+}
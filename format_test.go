@@ -0,0 +1,89 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultFormats(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2022, time.March, 4, 12, 30, 15, 0, time.UTC)
+
+	tests := map[string]struct {
+		oid      oid.Oid
+		value    any
+		expected any
+	}{
+		"int2":      {oid: oid.T_int2, value: int16(42), expected: int16(42)},
+		"int4":      {oid: oid.T_int4, value: int32(-42), expected: int32(-42)},
+		"int8":      {oid: oid.T_int8, value: int64(1 << 40), expected: int64(1 << 40)},
+		"bool":      {oid: oid.T_bool, value: true, expected: true},
+		"float4":    {oid: oid.T_float4, value: float32(1.5), expected: float32(1.5)},
+		"float8":    {oid: oid.T_float8, value: 3.14, expected: 3.14},
+		"text":      {oid: oid.T_text, value: "John", expected: "John"},
+		"bytea":     {oid: oid.T_bytea, value: []byte{0x00, 0xff}, expected: []byte{0x00, 0xff}},
+		"timestamp": {oid: oid.T_timestamp, value: timestamp, expected: timestamp},
+	}
+
+	for name, test := range tests {
+		name, test := name, test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+				err := writer.Define(Columns{{Name: name, Oid: test.oid, Format: TextFormat}})
+				if err != nil {
+					return err
+				}
+
+				err = writer.Row([]any{test.value})
+				if err != nil {
+					return err
+				}
+
+				return writer.Complete("SELECT 1")
+			}
+
+			server, err := NewServer(SimpleQuery(handler))
+			require.NoError(t, err)
+
+			address := TListenAndServe(t, server)
+
+			ctx := context.Background()
+			conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+			require.NoError(t, err)
+			defer conn.Close(ctx)
+
+			rows, err := conn.Query(ctx, "SELECT *;", pgx.QueryResultFormats{pgx.BinaryFormatCode})
+			require.NoError(t, err)
+			defer rows.Close()
+
+			require.True(t, rows.Next())
+			assert.Equal(t, int16(pgx.BinaryFormatCode), rows.FieldDescriptions()[0].Format)
+
+			values, err := rows.Values()
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, values[0])
+
+			rows.Next()
+			require.NoError(t, rows.Err())
+		})
+	}
+}
+
+func TestResultFormatsMismatch(t *testing.T) {
+	t.Parallel()
+
+	writer := &formatWriter{formats: []FormatCode{BinaryFormat, TextFormat}}
+	err := writer.Define(Columns{{Name: "name", Oid: oid.T_text}})
+	assert.Error(t, err)
+}
@@ -2,7 +2,6 @@ package wire
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"net"
 
@@ -24,13 +23,10 @@ func (srv *Server) Handshake(conn net.Conn) (_ net.Conn, version types.Version,
 		return conn, version, reader, nil
 	}
 
-	// TODO: support GSS encryption
-	//
-	// `psql-wire` currently does not support GSS encrypted connections. The GSS
-	// authentication API is supported inside the PostgreSQL wire protocol and
-	// API's should be made available to support these type of connections.
-	// https://www.postgresql.org/docs/current/gssapi-auth.html
-	// https://www.postgresql.org/docs/current/protocol-flow.html#id-1.10.6.7.13
+	conn, reader, version, err = srv.potentialGSSUpgrade(conn, reader, version)
+	if err != nil {
+		return conn, version, reader, err
+	}
 
 	conn, reader, version, err = srv.potentialConnUpgrade(conn, reader, version)
 	if err != nil {
@@ -101,12 +97,19 @@ func (srv *Server) readClientParameters(ctx context.Context, reader *buffer.Read
 // The written parameters will be attached as a value to the given context. A new
 // context containing the written parameters will be returned.
 // https://www.postgresql.org/docs/10/libpq-status.html
-func (srv *Server) writeParameters(ctx context.Context, writer *buffer.Writer, params Parameters) (_ context.Context, err error) {
-	if params == nil {
-		params = make(Parameters, 4)
+func (srv *Server) writeParameters(ctx context.Context, writer *buffer.Writer, global Parameters) (_ context.Context, err error) {
+	srv.logger.Debug("writing server parameters")
+
+	// NOTE: the global parameters are shared between all connections, a new
+	// collection is constructed for each connection.
+	params := make(Parameters, len(DefaultParameters)+len(global)+4)
+	for key, value := range DefaultParameters {
+		params[key] = value
 	}
 
-	srv.logger.Debug("writing server parameters")
+	for key, value := range global {
+		params[key] = value
+	}
 
 	params[ParamServerEncoding] = "UTF8"
 	params[ParamClientEncoding] = "UTF8"
@@ -143,7 +146,8 @@ func (srv *Server) potentialConnUpgrade(conn net.Conn, reader *buffer.Reader, ve
 
 	srv.logger.Debug("attempting to upgrade the client to a TLS connection")
 
-	if len(srv.Certificates) == 0 {
+	tlsConfig := srv.currentTLSConfig()
+	if tlsConfig == nil {
 		srv.logger.Debug("no TLS certificates available continuing with a insecure connection")
 		return srv.sslUnsupported(conn, reader, version)
 	}
@@ -153,15 +157,9 @@ func (srv *Server) potentialConnUpgrade(conn net.Conn, reader *buffer.Reader, ve
 		return conn, reader, version, err
 	}
 
-	tlsConfig := tls.Config{
-		Certificates: srv.Certificates,
-		ClientAuth:   srv.ClientAuth,
-		ClientCAs:    srv.ClientCAs,
-	}
-
 	// NOTE: initialize the TLS connection and construct a new buffered
 	// reader for the constructed TLS connection.
-	conn = tls.Server(conn, &tlsConfig)
+	conn = newTLSConn(conn, tlsConfig)
 	reader = buffer.NewReader(conn, srv.BufferedMsgSize)
 
 	version, err = srv.readVersion(reader)
@@ -173,6 +171,50 @@ func (srv *Server) potentialConnUpgrade(conn net.Conn, reader *buffer.Reader, ve
 	return conn, reader, version, err
 }
 
+// potentialGSSUpgrade potentially upgrades the given connection using GSSAPI
+// encryption if the client requests for it. The GSS encryption request is
+// declined when no GSS encryption upgrader has been configured allowing the
+// client to continue with a SSL or insecure connection.
+// https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-GSSAPI
+func (srv *Server) potentialGSSUpgrade(conn net.Conn, reader *buffer.Reader, version types.Version) (_ net.Conn, _ *buffer.Reader, _ types.Version, err error) {
+	if version != types.VersionGSSENC {
+		return conn, reader, version, nil
+	}
+
+	srv.logger.Debug("attempting to upgrade the client to a GSS encrypted connection")
+
+	if srv.GSSEncryption == nil {
+		srv.logger.Debug("no GSS encryption available continuing without GSS encryption")
+
+		_, err = conn.Write(gssUnsupported)
+		if err != nil {
+			return conn, reader, version, err
+		}
+
+		version, err = srv.readVersion(reader)
+		return conn, reader, version, err
+	}
+
+	_, err = conn.Write(gssSupported)
+	if err != nil {
+		return conn, reader, version, err
+	}
+
+	conn, err = srv.GSSEncryption(conn)
+	if err != nil {
+		return conn, reader, version, err
+	}
+
+	reader = buffer.NewReader(conn, srv.BufferedMsgSize)
+	version, err = srv.readVersion(reader)
+	if err != nil {
+		return conn, reader, version, err
+	}
+
+	srv.logger.Debug("connection has been upgraded to GSS encryption successfully")
+	return conn, reader, version, nil
+}
+
 // sslUnsupported announces to the PostgreSQL client that we are unable to
 // upgrade the connection to a secure connection at this time. The client
 // version is read again once the insecure connection has been announced.
@@ -0,0 +1,230 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeGSSENCRequest writes a GSSENCRequest message to the given connection and
+// returns the single byte response of the server.
+func writeGSSENCRequest(t *testing.T, conn net.Conn) byte {
+	t.Helper()
+
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[:4], 8)
+	binary.BigEndian.PutUint32(request[4:], uint32(types.VersionGSSENC))
+
+	_, err := conn.Write(request)
+	require.NoError(t, err)
+
+	response := make([]byte, 1)
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+
+	return response[0]
+}
+
+func TestGSSENCRequest(t *testing.T) {
+	t.Parallel()
+
+	type test struct {
+		options  []OptionFn
+		expected byte
+	}
+
+	tests := map[string]test{
+		"unsupported": {
+			expected: 'N',
+		},
+		"supported": {
+			options: []OptionFn{
+				GSSEncryption(func(conn net.Conn) (net.Conn, error) {
+					return conn, nil
+				}),
+			},
+			expected: 'G',
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			server, err := NewServer(test.options...)
+			require.NoError(t, err)
+
+			address := TListenAndServe(t, server)
+			conn, err := net.Dial("tcp", address.String())
+			require.NoError(t, err)
+
+			response := writeGSSENCRequest(t, conn)
+			assert.Equal(t, test.expected, response)
+
+			client := mock.NewClient(conn)
+			client.Handshake(t)
+			client.Authenticate(t)
+			client.ReadyForQuery(t)
+			client.Close(t)
+		})
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), HandshakeTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	t.Run("slow client", func(t *testing.T) {
+		conn, err := net.Dial("tcp", address.String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		startup := make([]byte, 8)
+		binary.BigEndian.PutUint32(startup[:4], 8)
+		binary.BigEndian.PutUint32(startup[4:], uint32(types.Version30))
+
+		for _, b := range startup {
+			_, err = conn.Write([]byte{b})
+			if err != nil {
+				break
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		// NOTE: the connection is either closed or reset by the server
+		_, err = conn.Read(make([]byte, 1))
+		require.Error(t, err)
+
+		var nerr net.Error
+		if errors.As(err, &nerr) {
+			assert.False(t, nerr.Timeout(), "connection has not been closed by the server")
+		}
+	})
+
+	t.Run("authenticated", func(t *testing.T) {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		// NOTE: the deadline should be removed once authenticated
+		time.Sleep(100 * time.Millisecond)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		assert.NoError(t, err)
+	})
+}
+
+func TestParameterStatus(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	params := Parameters{
+		ParamTimeZone:      "Europe/Amsterdam",
+		"application_name": "wire",
+	}
+
+	server, err := NewServer(SimpleQuery(handler), GlobalParameters(params), GlobalParameters(Parameters{ParamDateStyle: "ISO, DMY"}), ServerVersion("14.0"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://john@%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	expected := map[string]string{
+		"server_version":              "14.0",
+		"server_encoding":             "UTF8",
+		"client_encoding":             "UTF8",
+		"DateStyle":                   "ISO, DMY",
+		"IntervalStyle":               "postgres",
+		"TimeZone":                    "Europe/Amsterdam",
+		"integer_datetimes":           "on",
+		"standard_conforming_strings": "on",
+		"session_authorization":       "john",
+		"application_name":            "wire",
+	}
+
+	for key, value := range expected {
+		assert.Equal(t, value, conn.PgConn().ParameterStatus(key), key)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		for key, value := range DefaultParameters {
+			assert.Equal(t, value, conn.PgConn().ParameterStatus(string(key)), key)
+		}
+	})
+}
+
+func TestServerVersion(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	connect := func(t *testing.T, options ...OptionFn) *pgx.Conn {
+		server, err := NewServer(append([]OptionFn{SimpleQuery(handler)}, options...)...)
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close(ctx) }) //nolint:errcheck
+
+		return conn
+	}
+
+	t.Run("default", func(t *testing.T) {
+		conn := connect(t)
+		assert.Equal(t, DefaultServerVersion, conn.PgConn().ParameterStatus("server_version"))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		conn := connect(t, ServerVersion("9.6.24"))
+		assert.Equal(t, "9.6.24", conn.PgConn().ParameterStatus("server_version"))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := NewServer(ServerVersion(""))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,63 @@
+package buffer
+
+import (
+	"io"
+
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+// CoalescingWriter coalesces small writes into a single write to the
+// underlaying writer. Buffered data is flushed once the flush threshold has
+// been reached or once a message is written after which the client is
+// expected to respond (ex: ready for query). This reduces the number of
+// syscalls for workloads producing many small messages such as data rows.
+type CoalescingWriter struct {
+	writer    io.Writer
+	buffer    []byte
+	threshold int
+}
+
+// NewCoalescingWriter constructs a new coalescing writer flushing the buffered
+// data to the given writer once the given threshold in bytes has been reached.
+func NewCoalescingWriter(writer io.Writer, threshold int) *CoalescingWriter {
+	return &CoalescingWriter{
+		writer:    writer,
+		buffer:    make([]byte, 0, threshold),
+		threshold: threshold,
+	}
+}
+
+// Write appends the given message to the buffer. The buffered data is flushed
+// once the threshold has been reached or whenever the given message expects a
+// response from the client (ex: ReadyForQuery).
+func (writer *CoalescingWriter) Write(p []byte) (int, error) {
+	writer.buffer = append(writer.buffer, p...)
+
+	if len(writer.buffer) >= writer.threshold || (len(p) > 0 && awaitsClient(types.ServerMessage(p[0]))) {
+		return len(p), writer.Flush()
+	}
+
+	return len(p), nil
+}
+
+// Flush writes all buffered data to the underlaying writer.
+func (writer *CoalescingWriter) Flush() error {
+	if len(writer.buffer) == 0 {
+		return nil
+	}
+
+	_, err := writer.writer.Write(writer.buffer)
+	writer.buffer = writer.buffer[:0]
+	return err
+}
+
+// awaitsClient returns whether the client is expected to respond once the
+// given message type has been received.
+func awaitsClient(t types.ServerMessage) bool {
+	switch t {
+	case types.ServerReady, types.ServerCopyInResponse, types.ServerAuth:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,68 @@
+package buffer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (writer *countingWriter) Write(p []byte) (int, error) {
+	writer.writes++
+	return writer.Buffer.Write(p)
+}
+
+func TestCoalescingWriter(t *testing.T) {
+	sink := &countingWriter{}
+	writer := NewWriter(NewCoalescingWriter(sink, 64))
+
+	for i := 0; i < 4; i++ {
+		writer.Start(types.ServerDataRow)
+		writer.AddString("John Doe")
+		require.NoError(t, writer.End())
+	}
+
+	assert.Equal(t, 0, sink.writes)
+
+	t.Run("threshold", func(t *testing.T) {
+		for i := 0; i < 4; i++ {
+			writer.Start(types.ServerDataRow)
+			writer.AddString("John Doe")
+			require.NoError(t, writer.End())
+		}
+
+		assert.Equal(t, 1, sink.writes)
+		assert.Equal(t, 65, sink.Len())
+	})
+
+	t.Run("ready for query", func(t *testing.T) {
+		writer.Start(types.ServerReady)
+		writer.AddByte(byte(types.ServerIdle))
+		require.NoError(t, writer.End())
+
+		assert.Equal(t, 2, sink.writes)
+		assert.Equal(t, 8*13+6, sink.Len())
+	})
+}
+
+func TestCoalescingWriterFlush(t *testing.T) {
+	sink := &countingWriter{}
+	coalescer := NewCoalescingWriter(sink, 1024)
+	writer := NewWriter(coalescer)
+
+	writer.Start(types.ServerDataRow)
+	writer.AddString("John Doe")
+	require.NoError(t, writer.End())
+
+	require.NoError(t, coalescer.Flush())
+	require.NoError(t, coalescer.Flush())
+	assert.Equal(t, 1, sink.writes)
+	assert.Equal(t, 13, sink.Len())
+}
@@ -189,3 +189,14 @@ func (reader *Reader) GetUint32() (uint32, error) {
 	reader.Msg = reader.Msg[4:]
 	return v, nil
 }
+
+// GetUint64 returns the buffer's contents as a uint64.
+func (reader *Reader) GetUint64() (uint64, error) {
+	if len(reader.Msg) < 8 {
+		return 0, NewInsufficientData(len(reader.Msg))
+	}
+
+	v := binary.BigEndian.Uint64(reader.Msg[:8])
+	reader.Msg = reader.Msg[8:]
+	return v, nil
+}
@@ -236,6 +236,13 @@ func TestGetInsufficientData(t *testing.T) {
 			t.Fatalf("unexpected err %s, expected %s", err, ErrInsufficientData)
 		}
 	})
+
+	t.Run("uint64", func(t *testing.T) {
+		_, err := reader.GetUint64()
+		if !errors.Is(err, ErrInsufficientData) {
+			t.Fatalf("unexpected err %s, expected %s", err, ErrInsufficientData)
+		}
+	})
 }
 
 func TestMsgReset(t *testing.T) {
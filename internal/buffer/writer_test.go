@@ -98,6 +98,13 @@ func TestWriteTypes(t *testing.T) {
 			t.Error(writer.Error())
 		}
 	})
+
+	t.Run("int64", func(t *testing.T) {
+		writer.AddInt64(math.MaxInt64)
+		if writer.Error() != nil {
+			t.Error(writer.Error())
+		}
+	})
 }
 
 func TestWriteTypesErr(t *testing.T) {
@@ -96,7 +96,7 @@ func (client *Client) ReadyForQuery(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		if typed != types.ServerParameterStatus {
+		if typed != types.ServerParameterStatus && typed != types.ServerBackendKeyData {
 			break
 		}
 	}
@@ -134,7 +134,7 @@ func (client *Client) Close(t *testing.T) {
 	t.Log("closing the client!")
 	defer t.Log("client closed")
 
-	client.Start(types.ClientClose)
+	client.Start(types.ClientTerminate)
 	err := client.End()
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,148 @@
+// Package pglz implements the Postgres LZ compression format (pglz) as used
+// by Postgres to compress TOAST values.
+// https://github.com/postgres/postgres/blob/master/src/common/pg_lzcompress.c
+package pglz
+
+import (
+	"errors"
+)
+
+const (
+	// minMatch represents the minimum length of a back reference.
+	minMatch = 3
+	// maxMatch represents the maximum length of a back reference.
+	maxMatch = 273
+	// maxOffset represents the maximum distance of a back reference.
+	maxOffset = 0x0fff
+	// hashSize represents the amount of entries inside the history table.
+	hashSize = 1 << 12
+)
+
+// ErrCorrupt is returned whenever the compressed data could not be
+// decompressed.
+var ErrCorrupt = errors.New("pglz: corrupt input")
+
+// Compress compresses the given source. The compressed data is written as a
+// sequence of control bytes each followed by up to eight literal bytes or back
+// references. A bit set inside the control byte (starting at the least
+// significant bit) indicates a back reference.
+func Compress(src []byte) []byte {
+	dst := make([]byte, 0, len(src)+len(src)/8+1)
+	history := make([]int, hashSize)
+	for index := range history {
+		history[index] = -1
+	}
+
+	var control int
+	var bit byte
+
+	for pos := 0; pos < len(src); {
+		if bit == 0 {
+			control = len(dst)
+			dst = append(dst, 0)
+			bit = 1
+		}
+
+		offset, length := 0, 0
+		if pos+minMatch <= len(src) {
+			key := hash(src[pos:])
+			candidate := history[key]
+			history[key] = pos
+
+			if candidate >= 0 && pos-candidate <= maxOffset {
+				for length < maxMatch && pos+length < len(src) && src[candidate+length] == src[pos+length] {
+					length++
+				}
+
+				offset = pos - candidate
+			}
+		}
+
+		if length < minMatch {
+			dst = append(dst, src[pos])
+			pos++
+			bit <<= 1
+			continue
+		}
+
+		dst[control] |= bit
+		if length > 17 {
+			dst = append(dst, byte((offset&0xf00)>>4)|0x0f, byte(offset&0xff), byte(length-18))
+		} else {
+			dst = append(dst, byte((offset&0xf00)>>4)|byte(length-minMatch), byte(offset&0xff))
+		}
+
+		// NOTE: the history is updated for all positions covered by the back
+		// reference to improve the chance of finding future matches.
+		for end := pos + length; pos+1 < end; {
+			pos++
+			if pos+minMatch <= len(src) {
+				history[hash(src[pos:])] = pos
+			}
+		}
+
+		pos++
+		bit <<= 1
+	}
+
+	return dst
+}
+
+// Decompress decompresses the given source containing data compressed using
+// Compress. The size of the decompressed data should be known upfront.
+func Decompress(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+
+	for pos := 0; pos < len(src); {
+		control := src[pos]
+		pos++
+
+		for bit := 0; bit < 8 && pos < len(src); bit++ {
+			if control&(1<<bit) == 0 {
+				dst = append(dst, src[pos])
+				pos++
+				continue
+			}
+
+			if pos+1 >= len(src) {
+				return nil, ErrCorrupt
+			}
+
+			length := int(src[pos]&0x0f) + minMatch
+			offset := int(src[pos]&0xf0)<<4 | int(src[pos+1])
+			pos += 2
+
+			if length == 18 {
+				if pos >= len(src) {
+					return nil, ErrCorrupt
+				}
+
+				length += int(src[pos])
+				pos++
+			}
+
+			if offset == 0 || offset > len(dst) {
+				return nil, ErrCorrupt
+			}
+
+			// NOTE: back references could overlap with the bytes being
+			// written, bytes are therefore copied one by one.
+			start := len(dst) - offset
+			for index := 0; index < length; index++ {
+				dst = append(dst, dst[start+index])
+			}
+		}
+	}
+
+	if len(dst) != size {
+		return nil, ErrCorrupt
+	}
+
+	return dst, nil
+}
+
+// hash returns the history table index of the first three bytes of the given
+// slice.
+func hash(src []byte) int {
+	return (int(src[0])<<8 ^ int(src[1])<<4 ^ int(src[2])) & (hashSize - 1)
+}
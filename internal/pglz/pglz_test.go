@@ -0,0 +1,47 @@
+package pglz
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(42)).Read(random) //nolint:errcheck
+
+	tests := map[string][]byte{
+		"empty":    {},
+		"short":    []byte("ab"),
+		"text":     []byte("the quick brown fox jumps over the lazy dog, the quick brown fox"),
+		"repeated": bytes.Repeat([]byte("a"), 10000),
+		"rows":     bytes.Repeat([]byte("D\x00\x00\x00\x0b\x00\x01\x00\x00\x00\x011"), 512),
+		"random":   random,
+	}
+
+	for name, src := range tests {
+		src := src
+		t.Run(name, func(t *testing.T) {
+			compressed := Compress(src)
+			result, err := Decompress(compressed, len(src))
+			require.NoError(t, err)
+			assert.Equal(t, src, result)
+		})
+	}
+}
+
+func TestCompressRatio(t *testing.T) {
+	src := bytes.Repeat([]byte("abcdefgh"), 1024)
+	assert.Less(t, len(Compress(src)), len(src)/10)
+}
+
+func TestDecompressCorrupt(t *testing.T) {
+	_, err := Decompress([]byte{0x01, 0x00, 0x10}, 3)
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	_, err = Decompress(Compress([]byte("abc")), 4)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
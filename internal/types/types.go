@@ -23,15 +23,21 @@ const (
 	ClientTerminate   ClientMessage = 'X'
 
 	ServerAuth                 ServerMessage = 'R'
+	ServerBackendKeyData       ServerMessage = 'K'
 	ServerBindComplete         ServerMessage = '2'
 	ServerCommandComplete      ServerMessage = 'C'
 	ServerCloseComplete        ServerMessage = '3'
+	ServerCopyBothResponse     ServerMessage = 'W'
+	ServerCopyData             ServerMessage = 'd'
+	ServerCopyDone             ServerMessage = 'c'
 	ServerCopyInResponse       ServerMessage = 'G'
+	ServerCopyOutResponse      ServerMessage = 'H'
 	ServerDataRow              ServerMessage = 'D'
 	ServerEmptyQuery           ServerMessage = 'I'
 	ServerErrorResponse        ServerMessage = 'E'
 	ServerNoticeResponse       ServerMessage = 'N'
 	ServerNoData               ServerMessage = 'n'
+	ServerNotificationResponse ServerMessage = 'A'
 	ServerParameterDescription ServerMessage = 't'
 	ServerParameterStatus      ServerMessage = 'S'
 	ServerParseComplete        ServerMessage = '1'
@@ -0,0 +1,161 @@
+package wire
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+)
+
+// jsonbVersion represents the version number prepended to the binary format
+// of jsonb values.
+const jsonbVersion = 1
+
+// JSON represents a Postgres json value. The value is encoded as the raw JSON
+// bytes, the binary format is identical to the text format.
+// https://www.postgresql.org/docs/current/datatype-json.html
+type JSON struct {
+	Bytes  []byte
+	Status pgtype.Status
+}
+
+// Set converts and assigns the given source to itself. Strings, byte slices
+// and raw JSON messages are expected to contain JSON and are used as is, any
+// other value is serialized using encoding/json.
+func (dst *JSON) Set(src any) error {
+	if src == nil {
+		*dst = JSON{Status: pgtype.Null}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case string:
+		*dst = JSON{Bytes: []byte(value), Status: pgtype.Present}
+	case *string:
+		if value == nil {
+			*dst = JSON{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = JSON{Bytes: []byte(*value), Status: pgtype.Present}
+	case []byte:
+		if value == nil {
+			*dst = JSON{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = JSON{Bytes: value, Status: pgtype.Present}
+	case json.RawMessage:
+		if value == nil {
+			*dst = JSON{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = JSON{Bytes: value, Status: pgtype.Present}
+	default:
+		bb, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("cannot convert %T to json: %w", src, err)
+		}
+
+		*dst = JSON{Bytes: bb, Status: pgtype.Present}
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value. Present values are
+// returned as decoded JSON.
+func (dst JSON) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		var value any
+		err := json.Unmarshal(dst.Bytes, &value)
+		if err != nil {
+			return dst
+		}
+
+		return value
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination. Destinations other than
+// strings, byte slices and raw JSON messages are decoded using encoding/json.
+func (src *JSON) AssignTo(dst any) error {
+	switch value := dst.(type) {
+	case *string:
+		if src.Status != pgtype.Present {
+			return fmt.Errorf("cannot assign non-present status to %T", dst)
+		}
+
+		*value = string(src.Bytes)
+	case *[]byte:
+		if src.Status != pgtype.Present {
+			*value = nil
+			return nil
+		}
+
+		*value = append([]byte(nil), src.Bytes...)
+	case *json.RawMessage:
+		if src.Status != pgtype.Present {
+			*value = nil
+			return nil
+		}
+
+		*value = append(json.RawMessage(nil), src.Bytes...)
+	default:
+		if src.Status != pgtype.Present {
+			return fmt.Errorf("cannot assign non-present status to %T", dst)
+		}
+
+		return json.Unmarshal(src.Bytes, dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src JSON) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return append(buf, src.Bytes...), nil
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+// The binary format of json is identical to the text format.
+func (src JSON) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return src.EncodeText(ci, buf)
+}
+
+// JSONB represents a Postgres jsonb value. Values are converted and encoded
+// identical to json values, the binary format is prefixed with the jsonb
+// version number.
+// https://www.postgresql.org/docs/current/datatype-json.html
+type JSONB struct {
+	JSON
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+// The binary format consists of the jsonb version number followed by the text
+// format.
+func (src JSONB) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	buf = append(buf, jsonbVersion)
+	return append(buf, src.Bytes...), nil
+}
@@ -0,0 +1,130 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONColumnEncoding(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	tests := map[string]any{
+		"bytes":  []byte(`{"name":"John"}`),
+		"raw":    json.RawMessage(`{"name":"John"}`),
+		"string": `{"name":"John"}`,
+		"struct": user{Name: "John"},
+		"map":    map[string]any{"name": "John"},
+	}
+
+	expected := map[oid.Oid]map[FormatCode][]byte{
+		oid.T_json: {
+			TextFormat:   []byte(`{"name":"John"}`),
+			BinaryFormat: []byte(`{"name":"John"}`),
+		},
+		oid.T_jsonb: {
+			TextFormat:   []byte(`{"name":"John"}`),
+			BinaryFormat: append([]byte{1}, []byte(`{"name":"John"}`)...),
+		},
+	}
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+
+	for name, value := range tests {
+		for typ, formats := range expected {
+			for format, bb := range formats {
+				t.Run(fmt.Sprintf("%s/%d/%d", name, typ, format), func(t *testing.T) {
+					writer := buffer.NewWriter(&bytes.Buffer{})
+					writer.Start(types.ServerDataRow)
+
+					column := Column{Name: "data", Oid: typ, Format: format}
+					err := column.Write(ctx, writer, value)
+					require.NoError(t, err)
+
+					// NOTE: the written message contains the message type (1 byte),
+					// message length (4 bytes) and value length (4 bytes).
+					assert.Equal(t, bb, writer.Bytes()[9:])
+				})
+			}
+		}
+	}
+
+	t.Run("null", func(t *testing.T) {
+		for _, value := range []any{nil, json.RawMessage(nil), []byte(nil)} {
+			writer := buffer.NewWriter(&bytes.Buffer{})
+			writer.Start(types.ServerDataRow)
+
+			column := Column{Name: "data", Oid: oid.T_jsonb, Format: BinaryFormat}
+			err := column.Write(ctx, writer, value)
+			require.NoError(t, err)
+
+			assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff}, writer.Bytes()[5:])
+		}
+	})
+}
+
+func TestJSONColumnScan(t *testing.T) {
+	t.Parallel()
+
+	value := json.RawMessage(`{"name":"John","age":28}`)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "json_text", Oid: oid.T_json, Format: TextFormat},
+			{Name: "json_binary", Oid: oid.T_json, Format: BinaryFormat},
+			{Name: "jsonb_text", Oid: oid.T_jsonb, Format: TextFormat},
+			{Name: "jsonb_binary", Oid: oid.T_jsonb, Format: BinaryFormat},
+		})
+
+		writer.Row([]any{value, value, value, value}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	t.Run("text", func(t *testing.T) {
+		// NOTE: pgx decodes binary json values into text scanners using
+		// encoding/json, only the text formatted columns are scanned.
+		results := make([]pgtype.Text, 2)
+		err := conn.QueryRow(ctx, "SELECT *;").Scan(&results[0], nil, &results[1], nil)
+		require.NoError(t, err)
+
+		for _, result := range results {
+			assert.True(t, result.Valid)
+			assert.JSONEq(t, string(value), result.String)
+		}
+	})
+
+	t.Run("map", func(t *testing.T) {
+		results := make([]map[string]any, 4)
+		err := conn.QueryRow(ctx, "SELECT *;").Scan(&results[0], &results[1], &results[2], &results[3])
+		require.NoError(t, err)
+
+		for _, result := range results {
+			assert.Equal(t, map[string]any{"name": "John", "age": float64(28)}, result)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"go.uber.org/zap"
+)
+
+// ErrTooManyConnections is returned whenever the server has reached the
+// maximum number of concurrent client connections.
+var ErrTooManyConnections = errors.New("sorry, too many clients already")
+
+// NewErrTooManyConnections constructs a new fatal error wrapping the
+// ErrTooManyConnections type including the too many connections error code.
+func NewErrTooManyConnections(max int) error {
+	err := fmt.Errorf("%w: the server is limited to %d connections", ErrTooManyConnections, max)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.TooManyConnections), psqlerr.LevelFatal)
+}
+
+// MaxConnections sets the maximum number of concurrent client connections.
+// Connections exceeding the limit are rejected with a too many connections
+// error once the connection handshake has been performed, before the client
+// is authenticated. Cancel requests are not limited. No limit is enforced when
+// the given limit is zero.
+func MaxConnections(n int) OptionFn {
+	return func(srv *Server) error {
+		if n < 0 {
+			return fmt.Errorf("max connections must be positive, received %d", n)
+		}
+
+		srv.MaxConnections = n
+		return nil
+	}
+}
+
+// acquireConn reserves a connection slot for the given connection. A too
+// many connections error is written to the client whenever no slot is
+// available. A boolean is returned indicating whether a slot has been
+// reserved, the returned function releases the slot.
+func (srv *Server) acquireConn(conn net.Conn) (func(), bool) {
+	if srv.MaxConnections == 0 {
+		return func() {}, true
+	}
+
+	active := srv.conns.Add(1)
+	release := func() { srv.conns.Add(-1) }
+
+	if active <= int64(srv.MaxConnections) {
+		return release, true
+	}
+
+	release()
+
+	srv.logger.Warn("rejecting connection, maximum number of connections reached", zap.Int("max", srv.MaxConnections))
+
+	// NOTE: rejection failures should not prevent the connection from being
+	// closed.
+	writeErrorResponse(buffer.NewWriter(conn), NewErrTooManyConnections(srv.MaxConnections)) //nolint:errcheck
+	return nil, false
+}
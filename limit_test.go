@@ -0,0 +1,117 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	const max = 2
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MaxConnections(max))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+
+	conns := make([]*pgx.Conn, max)
+	for index := range conns {
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		conns[index] = conn
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err = pgx.Connect(timeout, connstr)
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.TooManyConnections), pgerr.Code)
+	assert.Equal(t, "FATAL", pgerr.Severity)
+
+	for _, conn := range conns {
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	}
+
+	t.Run("released", func(t *testing.T) {
+		require.NoError(t, conns[0].Close(ctx))
+
+		// NOTE: the connection slot is released asynchronously once the
+		// server has closed the connection.
+		require.Eventually(t, func() bool {
+			conn, err := pgx.Connect(ctx, connstr)
+			if err != nil {
+				return false
+			}
+
+			conn.Close(ctx) //nolint:errcheck
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestMaxConnectionsCancelRequest(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		started <- struct{}{}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return writer.Complete("OK")
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MaxConnections(1))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	cancelled := make(chan error, 1)
+	go func() {
+		<-started
+		cancelled <- conn.PgConn().CancelRequest(ctx)
+	}()
+
+	_, err = conn.Exec(ctx, "SELECT slow;")
+	require.NoError(t, <-cancelled)
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.QueryCanceled), pgerr.Code)
+}
+
+func TestInvalidMaxConnections(t *testing.T) {
+	_, err := NewServer(MaxConnections(-1))
+	assert.Error(t, err)
+}
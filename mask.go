@@ -0,0 +1,100 @@
+package wire
+
+import "errors"
+
+// Masker masks the values of a column before they are written to the client.
+// This could be used to mask personally identifiable information such as email
+// addresses or credit card numbers.
+type Masker interface {
+	// Mask returns the masked representation of the given value. NULL values
+	// are not passed to the masker.
+	Mask(src any) any
+}
+
+// MaskerFunc is a function implementing the Masker interface.
+type MaskerFunc func(src any) any
+
+// Mask calls the underlying masker function.
+func (fn MaskerFunc) Mask(src any) any {
+	return fn(src)
+}
+
+// MaskColumns masks the values of the columns matching the given column names
+// using the given masker. Values are masked after they have been written by
+// the query handler but before they are encoded. Multiple maskers defined for
+// the same column are applied in the order in which they are defined.
+func MaskColumns(masker Masker, columnNames ...string) OptionFn {
+	return func(srv *Server) error {
+		if masker == nil {
+			return errors.New("masker cannot be nil")
+		}
+
+		if len(columnNames) == 0 {
+			return errors.New("at least a single masked column name has to be defined")
+		}
+
+		names := make(map[string]struct{}, len(columnNames))
+		for _, name := range columnNames {
+			names[name] = struct{}{}
+		}
+
+		srv.masks = append(srv.masks, columnMask{masker: masker, names: names})
+		return nil
+	}
+}
+
+// columnMask contains the masker and names of the masked columns.
+type columnMask struct {
+	masker Masker
+	names  map[string]struct{}
+}
+
+// hook returns a column write hook masking all non NULL values.
+func (mask columnMask) hook(src any) (any, error) {
+	if src == nil {
+		return nil, nil
+	}
+
+	return mask.masker.Mask(src), nil
+}
+
+// maskWriter wraps a data writer and adds a write hook masking the column
+// values to each defined column matching the configured column names. Values
+// of JSON results are masked by their key, raw output (WriteCSV and WriteRaw)
+// is rejected as the values could not be masked.
+type maskWriter struct {
+	wrappedWriter
+	masks []columnMask
+}
+
+func (writer *maskWriter) Define(columns Columns) error {
+	defined := make(Columns, len(columns))
+	for index, column := range columns {
+		for _, mask := range writer.masks {
+			if _, has := mask.names[column.Name]; has {
+				column = column.WithWriteHook(mask.hook)
+			}
+		}
+
+		defined[index] = column
+	}
+
+	return writer.DataWriter.Define(defined)
+}
+
+// mapJSONRow returns a copy of the given row in which the values of the keys
+// matching the configured column names have been masked.
+func (writer *maskWriter) mapJSONRow(row map[string]any) (map[string]any, error) {
+	masked := make(map[string]any, len(row))
+	for name, value := range row {
+		for _, mask := range writer.masks {
+			if _, has := mask.names[name]; has {
+				value, _ = mask.hook(value)
+			}
+		}
+
+		masked[name] = value
+	}
+
+	return masked, nil
+}
@@ -0,0 +1,174 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskColumns(t *testing.T) {
+	t.Parallel()
+
+	email := MaskerFunc(func(src any) any {
+		value, ok := src.(string)
+		if !ok {
+			return src
+		}
+
+		at := strings.IndexByte(value, '@')
+		if at < 0 {
+			return value
+		}
+
+		return value[:at+1] + strings.Repeat("*", len(value)-at-1)
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{
+			{Name: "name", Oid: oid.T_text},
+			{Name: "email", Oid: oid.T_text},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{"John", "john@example.com"})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{"Jane", nil})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 2")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MaskColumns(email, "email"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	for _, mode := range []pgx.QueryExecMode{pgx.QueryExecModeSimpleProtocol, pgx.QueryExecModeCacheStatement} {
+		t.Run(mode.String(), func(t *testing.T) {
+			rows, err := conn.Query(ctx, "SELECT * FROM users;", mode)
+			require.NoError(t, err)
+
+			type user struct {
+				name  string
+				email *string
+			}
+
+			users := []user{}
+			for rows.Next() {
+				row := user{}
+				require.NoError(t, rows.Scan(&row.name, &row.email))
+				users = append(users, row)
+			}
+
+			require.NoError(t, rows.Err())
+			require.Len(t, users, 2)
+
+			assert.Equal(t, "John", users[0].name)
+			require.NotNil(t, users[0].email)
+			assert.Equal(t, "john@***********", *users[0].email)
+
+			assert.Equal(t, "Jane", users[1].name)
+			assert.Nil(t, users[1].email)
+		})
+	}
+}
+
+func TestInvalidMaskColumns(t *testing.T) {
+	_, err := NewServer(MaskColumns(nil, "email"))
+	assert.Error(t, err)
+
+	_, err = NewServer(MaskColumns(MaskerFunc(func(src any) any { return src })))
+	assert.Error(t, err)
+}
+
+func TestMaskColumnsOutputPaths(t *testing.T) {
+	t.Parallel()
+
+	redact := MaskerFunc(func(src any) any {
+		return "redacted"
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT json" {
+			return JSONResult(writer, "SELECT 1", []map[string]any{{"name": "John", "email": "john@example.com"}})
+		}
+
+		err := writer.Define(Columns{
+			{Name: "name", Oid: oid.T_text},
+			{Name: "email", Oid: oid.T_text},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = Batch(writer, [][]any{{"John", "john@example.com"}})
+		if err != nil {
+			return err
+		}
+
+		err = MapRow(writer, map[string]any{"name": "Jane", "email": "jane@example.com"})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 2")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MaskColumns(redact, "email"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	t.Run("rows", func(t *testing.T) {
+		rows, err := conn.Query(ctx, "SELECT rows")
+		require.NoError(t, err)
+
+		emails := []string{}
+		for rows.Next() {
+			var name, email string
+			require.NoError(t, rows.Scan(&name, &email))
+			emails = append(emails, email)
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []string{"redacted", "redacted"}, emails)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var result string
+		err := conn.QueryRow(ctx, "SELECT json", pgx.QueryExecModeSimpleProtocol).Scan(&result)
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"name":"John","email":"redacted"}]`, result)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		assertRawRejected(t, func(inner DataWriter) DataWriter {
+			return &maskWriter{wrappedWriter: wrappedWriter{inner}, masks: server.masks}
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// ErrMemoryLimitExceeded is returned whenever a query allocated more memory
+// than the configured memory limit.
+var ErrMemoryLimitExceeded = errors.New("memory limit exceeded")
+
+// NewErrMemoryLimitExceeded constructs a new error wrapping the
+// ErrMemoryLimitExceeded type including the out of memory error code.
+func NewErrMemoryLimitExceeded(limit int64) error {
+	err := fmt.Errorf("%w: query allocated more than %d bytes", ErrMemoryLimitExceeded, limit)
+	return psqlerr.WithCode(err, codes.OutOfMemory)
+}
+
+// MemoryLimit sets the maximum number of bytes a single query is allowed to
+// allocate. Allocations are accounted by the query handler through
+// TrackMemory. The query context is cancelled and an out of memory error is
+// returned to the client once the limit has been exceeded. Handlers should
+// respect the context cancellation to stop allocating. No limit is enforced
+// when the given number of bytes is zero.
+func MemoryLimit(bytes int64) OptionFn {
+	return func(srv *Server) error {
+		if bytes < 0 {
+			return fmt.Errorf("memory limit must be positive, received %d", bytes)
+		}
+
+		srv.MemoryLimit = bytes
+		return nil
+	}
+}
+
+// TrackMemory accounts the given number of bytes as allocated by the query of
+// the given context. Negative values release previously accounted bytes. The
+// query context is cancelled and a out of memory error is returned once the
+// configured memory limit has been exceeded. Nil is returned when no memory
+// limit has been configured.
+func TrackMemory(ctx context.Context, bytes int64) error {
+	budget, ok := ctx.Value(ctxMemoryBudget).(*memoryBudget)
+	if !ok {
+		return nil
+	}
+
+	return budget.track(bytes)
+}
+
+// memoryBudget represents the memory accounted by a single query.
+type memoryBudget struct {
+	limit    int64
+	used     atomic.Int64
+	exceeded atomic.Bool
+	cancel   context.CancelFunc
+}
+
+func (budget *memoryBudget) track(bytes int64) error {
+	used := budget.used.Add(bytes)
+	if used <= budget.limit {
+		return nil
+	}
+
+	budget.exceeded.Store(true)
+	budget.cancel()
+	return NewErrMemoryLimitExceeded(budget.limit)
+}
+
+// limitMemory executes the given function while accounting the memory
+// allocated by the query through TrackMemory. The context passed to the given
+// function is cancelled once the configured memory limit has been exceeded.
+func (srv *Server) limitMemory(ctx context.Context, fn func(context.Context) error) error {
+	if srv.MemoryLimit == 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	budget := &memoryBudget{limit: srv.MemoryLimit, cancel: cancel}
+	ctx = context.WithValue(ctx, ctxMemoryBudget, budget)
+
+	err := fn(ctx)
+	if budget.exceeded.Load() {
+		return NewErrMemoryLimitExceeded(srv.MemoryLimit)
+	}
+
+	return err
+}
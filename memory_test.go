@@ -0,0 +1,115 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	const limit = 32 << 20 // 32MB
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT small;" {
+			return writer.Complete("OK")
+		}
+
+		chunks := [][]byte{}
+		deadline := time.After(10 * time.Second)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-deadline:
+				return writer.Complete(fmt.Sprintf("ALLOCATED %d", len(chunks)))
+			default:
+			}
+
+			err := TrackMemory(ctx, 1<<20)
+			if err != nil {
+				return err
+			}
+
+			chunks = append(chunks, make([]byte, 1<<20))
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler), MemoryLimit(limit))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT large;")
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.OutOfMemory), pgerr.Code)
+
+	t.Run("within limit", func(t *testing.T) {
+		tag, err := conn.Exec(ctx, "SELECT small;")
+		require.NoError(t, err)
+		assert.Equal(t, "OK", tag.String())
+	})
+}
+
+func TestTrackMemory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unlimited", func(t *testing.T) {
+		assert.NoError(t, TrackMemory(context.Background(), 1<<30))
+	})
+
+	t.Run("release", func(t *testing.T) {
+		server, err := NewServer(MemoryLimit(1024))
+		require.NoError(t, err)
+
+		err = server.limitMemory(context.Background(), func(ctx context.Context) error {
+			for i := 0; i < 10; i++ {
+				err := TrackMemory(ctx, 1000)
+				if err != nil {
+					return err
+				}
+
+				require.NoError(t, TrackMemory(ctx, -1000))
+			}
+
+			return nil
+		})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		server, err := NewServer(MemoryLimit(1024))
+		require.NoError(t, err)
+
+		err = server.limitMemory(context.Background(), func(ctx context.Context) error {
+			assert.NoError(t, TrackMemory(ctx, 1024))
+			assert.ErrorIs(t, TrackMemory(ctx, 1), ErrMemoryLimitExceeded)
+			assert.Error(t, ctx.Err())
+			return ctx.Err()
+		})
+
+		assert.ErrorIs(t, err, ErrMemoryLimitExceeded)
+	})
+}
+
+func TestInvalidMemoryLimit(t *testing.T) {
+	_, err := NewServer(MemoryLimit(-1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,58 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MetricsCollector receives connection and query events of the server. A
+// collector could be used to expose server metrics through a monitoring system
+// of choice. Collectors are called from within the connection handling paths
+// and should therefore return quickly and be safe for concurrent use. The
+// metrics package implements a Prometheus collector.
+type MetricsCollector interface {
+	// ConnOpened is called whenever a new client connection is accepted.
+	ConnOpened()
+	// ConnClosed is called whenever a accepted client connection is closed.
+	ConnClosed()
+	// QueryExecuted is called once a query has been executed. The given error
+	// is nil whenever the query has been executed successfully.
+	QueryExecuted(ctx context.Context, duration time.Duration, err error)
+}
+
+// Collector sets the metrics collector receiving connection and query events.
+func Collector(collector MetricsCollector) OptionFn {
+	return func(srv *Server) error {
+		if collector == nil {
+			return errors.New("metrics collector cannot be nil")
+		}
+
+		srv.collector = collector
+		return nil
+	}
+}
+
+// collectConn reports the opened connection to the metrics collector. The
+// returned function reports the closed connection.
+func (srv *Server) collectConn() func() {
+	if srv.collector == nil {
+		return func() {}
+	}
+
+	srv.collector.ConnOpened()
+	return srv.collector.ConnClosed
+}
+
+// collectQuery returns a function reporting the executed query including its
+// duration and error to the metrics collector.
+func (srv *Server) collectQuery(ctx context.Context) func(err error) {
+	if srv.collector == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	return func(err error) {
+		srv.collector.QueryExecuted(ctx, time.Since(start), err)
+	}
+}
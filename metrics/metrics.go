@@ -0,0 +1,110 @@
+// Package metrics exposes psql-wire server metrics through Prometheus. The
+// package is kept inside a separate Go module to avoid requiring the
+// Prometheus client inside applications not making use of it.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics registers the psql-wire server metrics inside the given registerer
+// and updates them while serving client connections. The following metrics
+// are exposed:
+//
+//   - psqlwire_connections_active: number of currently open client connections
+//   - psqlwire_connections_total: number of accepted client connections
+//   - psqlwire_queries_total: number of executed queries
+//   - psqlwire_query_errors_total: number of queries returning an error
+//   - psqlwire_query_duration_seconds: histogram of query execution durations
+func Metrics(reg prometheus.Registerer) wire.OptionFn {
+	return func(srv *wire.Server) error {
+		collector := NewCollector()
+
+		err := collector.Register(reg)
+		if err != nil {
+			return err
+		}
+
+		return wire.Collector(collector)(srv)
+	}
+}
+
+// Collector is a implementation of the wire.MetricsCollector interface
+// updating Prometheus metrics.
+type Collector struct {
+	connsActive prometheus.Gauge
+	connsTotal  prometheus.Counter
+	queries     prometheus.Counter
+	errors      prometheus.Counter
+	duration    prometheus.Histogram
+}
+
+// NewCollector constructs a new collector. The collector metrics have to be
+// registered using Register before they are exposed.
+func NewCollector() *Collector {
+	return &Collector{
+		connsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "psqlwire_connections_active",
+			Help: "Number of currently open client connections.",
+		}),
+		connsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "psqlwire_connections_total",
+			Help: "Total number of accepted client connections.",
+		}),
+		queries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "psqlwire_queries_total",
+			Help: "Total number of executed queries.",
+		}),
+		errors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "psqlwire_query_errors_total",
+			Help: "Total number of executed queries returning an error.",
+		}),
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "psqlwire_query_duration_seconds",
+			Help:    "Duration of executed queries in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+}
+
+// Register registers all collector metrics inside the given registerer.
+func (collector *Collector) Register(reg prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		collector.connsActive,
+		collector.connsTotal,
+		collector.queries,
+		collector.errors,
+		collector.duration,
+	}
+
+	for _, metric := range metrics {
+		err := reg.Register(metric)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (collector *Collector) ConnOpened() {
+	collector.connsActive.Inc()
+	collector.connsTotal.Inc()
+}
+
+func (collector *Collector) ConnClosed() {
+	collector.connsActive.Dec()
+}
+
+func (collector *Collector) QueryExecuted(ctx context.Context, duration time.Duration, err error) {
+	collector.queries.Inc()
+	collector.duration.Observe(duration.Seconds())
+
+	if err != nil {
+		collector.errors.Inc()
+	}
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer wire.DataWriter, parameters []string) error {
+		if query == "FAIL" {
+			return errors.New("unexpected failure")
+		}
+
+		return writer.Complete("OK")
+	}
+
+	reg := prometheus.NewRegistry()
+	server, err := wire.NewServer(wire.SimpleQuery(handler), Metrics(reg))
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, server.Close())
+	})
+
+	go server.Serve(listener) //nolint:errcheck
+
+	ctx := context.Background()
+	address := listener.Addr().(*net.TCPAddr)
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "FAIL", pgx.QueryExecModeSimpleProtocol)
+	require.Error(t, err)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		metric := family.GetMetric()[0]
+		switch {
+		case metric.GetGauge() != nil:
+			values[family.GetName()] = metric.GetGauge().GetValue()
+		case metric.GetCounter() != nil:
+			values[family.GetName()] = metric.GetCounter().GetValue()
+		case metric.GetHistogram() != nil:
+			values[family.GetName()] = float64(metric.GetHistogram().GetSampleCount())
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"psqlwire_connections_active":     1,
+		"psqlwire_connections_total":      1,
+		"psqlwire_queries_total":          2,
+		"psqlwire_query_errors_total":     1,
+		"psqlwire_query_duration_seconds": 2,
+	}, values)
+
+	require.NoError(t, conn.Close(ctx))
+
+	require.Eventually(t, func() bool {
+		return gaugeValue(t, reg, "psqlwire_connections_active") == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestMetricsRegistered(t *testing.T) {
+	reg := prometheus.NewRegistry()
+
+	_, err := wire.NewServer(Metrics(reg))
+	require.NoError(t, err)
+
+	_, err = wire.NewServer(Metrics(reg))
+	assert.Error(t, err)
+}
+
+func gaugeValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() == name {
+			return family.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+
+	return -1
+}
+
+func BenchmarkQueryExecuted(b *testing.B) {
+	collector := NewCollector()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		collector.QueryExecuted(ctx, time.Millisecond, nil)
+	}
+}
+
+func TestQueryExecutedOverhead(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping benchmark in short mode")
+	}
+
+	result := testing.Benchmark(BenchmarkQueryExecuted)
+	assert.Less(t, result.NsPerOp(), int64(time.Microsecond))
+}
@@ -0,0 +1,101 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingCollector records all received metrics events.
+type recordingCollector struct {
+	mu      sync.Mutex
+	active  int
+	total   int
+	queries int
+	errors  int
+}
+
+func (collector *recordingCollector) ConnOpened() {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.active++
+	collector.total++
+}
+
+func (collector *recordingCollector) ConnClosed() {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.active--
+}
+
+func (collector *recordingCollector) QueryExecuted(ctx context.Context, duration time.Duration, err error) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	collector.queries++
+	if err != nil {
+		collector.errors++
+	}
+}
+
+func (collector *recordingCollector) snapshot() (active, total, queries, errors int) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	return collector.active, collector.total, collector.queries, collector.errors
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "FAIL" {
+			return errors.New("unexpected failure")
+		}
+
+		return writer.Complete("OK")
+	}
+
+	collector := &recordingCollector{}
+	server, err := NewServer(SimpleQuery(handler), Collector(collector))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "FAIL", pgx.QueryExecModeSimpleProtocol)
+	require.Error(t, err)
+
+	active, total, queries, failures := collector.snapshot()
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 3, queries)
+	assert.Equal(t, 1, failures)
+
+	require.NoError(t, conn.Close(ctx))
+
+	require.Eventually(t, func() bool {
+		active, _, _, _ := collector.snapshot()
+		return active == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestInvalidCollector(t *testing.T) {
+	_, err := NewServer(Collector(nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// MoneyColumn constructs a new column definition for a Postgres money value
+// using the given column name and format. Values written to the column are
+// expected to represent an amount of cents.
+func MoneyColumn(name string, format FormatCode) Column {
+	return Column{
+		Name:   name,
+		Oid:    oid.T_money,
+		Width:  8,
+		Format: format,
+	}
+}
+
+// Money represents a Postgres money value. The value is stored as a fixed-point
+// amount of cents. The binary format is a big-endian 8-byte integer and the text
+// format is a dollar formatted string (ex: $1.00).
+// https://www.postgresql.org/docs/current/datatype-money.html
+type Money struct {
+	Cents  int64
+	Status pgtype.Status
+}
+
+// Set converts and assigns the given source to itself. Integer values are
+// interpreted as an amount of cents.
+func (dst *Money) Set(src any) error {
+	if src == nil {
+		*dst = Money{Status: pgtype.Null}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case int64:
+		*dst = Money{Cents: value, Status: pgtype.Present}
+	case int32:
+		*dst = Money{Cents: int64(value), Status: pgtype.Present}
+	case int:
+		*dst = Money{Cents: int64(value), Status: pgtype.Present}
+	case *int64:
+		if value == nil {
+			*dst = Money{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = Money{Cents: *value, Status: pgtype.Present}
+	case Money:
+		*dst = value
+	default:
+		return fmt.Errorf("cannot convert %T to money", src)
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst Money) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		return dst.Cents
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *Money) AssignTo(dst any) error {
+	if src.Status != pgtype.Present {
+		return fmt.Errorf("cannot assign non-present status to %T", dst)
+	}
+
+	switch value := dst.(type) {
+	case *int64:
+		*value = src.Cents
+	case *string:
+		*value = string(src.format(nil))
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src Money) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return src.format(buf), nil
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+func (src Money) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return binary.BigEndian.AppendUint64(buf, uint64(src.Cents)), nil
+}
+
+// format appends the dollar formatted amount to the given buffer.
+func (src Money) format(buf []byte) []byte {
+	cents := src.Cents
+	if cents < 0 {
+		buf = append(buf, '-')
+	}
+
+	// NOTE: the absolute value is computed as unsigned integer to prevent
+	// overflows when formatting the minimal int64 value.
+	abs := uint64(cents)
+	if cents < 0 {
+		abs = -abs
+	}
+
+	buf = append(buf, '$')
+	buf = strconv.AppendUint(buf, abs/100, 10)
+	buf = append(buf, '.')
+	if abs%100 < 10 {
+		buf = append(buf, '0')
+	}
+
+	return strconv.AppendUint(buf, abs%100, 10)
+}
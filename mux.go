@@ -0,0 +1,305 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+// NewErrUnknownDatabase is returned whenever no server has been routed for
+// the database requested by the client.
+func NewErrUnknownDatabase(database string) error {
+	err := fmt.Errorf("database %q does not exist", database)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.InvalidCatalogName), psqlerr.LevelFatal)
+}
+
+// DefaultSniffTimeout represents the default duration in which clients have
+// to send their startup message to the multiplexer.
+const DefaultSniffTimeout = 10 * time.Second
+
+// NewMultiplexer constructs a new connection multiplexer routing incoming
+// connections to the server registered for the database requested inside the
+// client startup message.
+func NewMultiplexer(routes map[string]*Server) *Multiplexer {
+	return &Multiplexer{
+		routes:       routes,
+		unrouted:     newMuxListener(nil),
+		SniffTimeout: DefaultSniffTimeout,
+	}
+}
+
+// Multiplexer accepts incoming Postgres connections and dispatches them to the
+// server matching the database parameter inside the startup message. Each
+// routed server is served using a dedicated net.Listener which only accepts
+// the connections dispatched to it. TLS connections are not supported by the
+// multiplexer, SSL requests are declined before the startup message is read.
+//
+// The multiplexer itself implements net.Listener. Connections requesting a
+// database without a route are rejected with an invalid catalog name error
+// unless Accept is called, in which case these connections are returned by
+// Accept. This allows a fallback server to be served using the multiplexer
+// as listener (ex: fallback.Serve(mux)).
+type Multiplexer struct {
+	// SniffTimeout represents the duration in which clients have to send
+	// their startup message. Connections are closed once the timeout has
+	// been exceeded. No timeout is enforced when the timeout is zero.
+	SniffTimeout time.Duration
+	routes       map[string]*Server
+	listener     net.Listener
+	unrouted     *muxListener
+	accepting    atomic.Bool
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+}
+
+var _ net.Listener = &Multiplexer{}
+
+// ListenAndServe opens a new TCP listener on the given address and starts
+// accepting and routing incoming client connections.
+func (mux *Multiplexer) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return mux.Serve(listener)
+}
+
+// Serve accepts incoming Postgres client connections on the given listener and
+// dispatches them to the routed servers. The routed servers are served for as
+// long as the multiplexer is serving.
+func (mux *Multiplexer) Serve(listener net.Listener) error {
+	mux.mu.Lock()
+	mux.listener = listener
+	listeners := make(map[string]*muxListener, len(mux.routes))
+	for database, srv := range mux.routes {
+		route := newMuxListener(listener.Addr())
+		listeners[database] = route
+
+		mux.wg.Add(1)
+		go func(srv *Server) {
+			defer mux.wg.Done()
+			srv.Serve(route) //nolint:errcheck
+		}(srv)
+	}
+	mux.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go mux.route(conn, listeners) //nolint:errcheck
+	}
+}
+
+// route reads the startup message of the given connection and dispatches the
+// connection to the listener routed for the requested database.
+func (mux *Multiplexer) route(conn net.Conn, listeners map[string]*muxListener) error {
+	// NOTE: a read deadline is set to prevent clients from holding on to
+	// connections without ever sending a startup message.
+	if mux.SniffTimeout > 0 {
+		err := conn.SetReadDeadline(time.Now().Add(mux.SniffTimeout))
+		if err != nil {
+			return conn.Close()
+		}
+	}
+
+	startup, params, err := readStartupMessage(conn)
+	if err != nil {
+		return conn.Close()
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return conn.Close()
+	}
+
+	database := params[string(ParamDatabase)]
+	if database == "" {
+		// NOTE: the database defaults to the user name if not set
+		database = params[string(ParamUsername)]
+	}
+
+	peeked := &peekedConn{
+		Conn:   conn,
+		reader: io.MultiReader(bytes.NewReader(startup), conn),
+	}
+
+	route, has := listeners[database]
+	if has {
+		return route.dispatch(peeked)
+	}
+
+	if mux.accepting.Load() {
+		return mux.unrouted.dispatch(peeked)
+	}
+
+	writeErrorResponse(buffer.NewWriter(conn), NewErrUnknownDatabase(database)) //nolint:errcheck
+	return conn.Close()
+}
+
+// Accept waits for and returns the next connection requesting a database
+// without a route.
+func (mux *Multiplexer) Accept() (net.Conn, error) {
+	mux.accepting.Store(true)
+	return mux.unrouted.Accept()
+}
+
+// Addr returns the address of the listener served by the multiplexer. A zero
+// TCP address is returned when the multiplexer is not serving a listener yet.
+func (mux *Multiplexer) Addr() net.Addr {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.listener == nil {
+		return &net.TCPAddr{}
+	}
+
+	return mux.listener.Addr()
+}
+
+// Close closes the multiplexer listener and all routed servers.
+func (mux *Multiplexer) Close() error {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	var result error
+	if mux.listener != nil {
+		result = mux.listener.Close()
+	}
+
+	mux.unrouted.Close() //nolint:errcheck
+
+	for _, srv := range mux.routes {
+		err := srv.Close()
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+
+	mux.wg.Wait()
+	return result
+}
+
+// readStartupMessage reads the startup message from the given connection. SSL
+// requests are declined before the startup message is read. The raw startup
+// message and the consumed parameters are returned.
+func readStartupMessage(conn net.Conn) ([]byte, map[string]string, error) {
+	for {
+		header := make([]byte, 4)
+		_, err := io.ReadFull(conn, header)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		size := int(binary.BigEndian.Uint32(header))
+		if size < 8 || size > buffer.DefaultBufferSize {
+			return nil, nil, errors.New("invalid startup message size")
+		}
+
+		msg := make([]byte, size)
+		copy(msg, header)
+
+		_, err = io.ReadFull(conn, msg[4:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		version := types.Version(binary.BigEndian.Uint32(msg[4:8]))
+		switch version {
+		case types.VersionSSLRequest, types.VersionGSSENC:
+			_, err = conn.Write(sslUnsupported)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			continue
+		case types.VersionCancel:
+			return nil, nil, errors.New("cancel requests could not be routed")
+		}
+
+		params := map[string]string{}
+		fields := bytes.Split(msg[8:], []byte{0})
+		for index := 0; index+1 < len(fields); index += 2 {
+			if len(fields[index]) == 0 {
+				break
+			}
+
+			params[string(fields[index])] = string(fields[index+1])
+		}
+
+		return msg, params, nil
+	}
+}
+
+// peekedConn represents a connection of which the first bytes have already
+// been consumed. The consumed bytes are replayed before reading from the
+// underlying connection.
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (conn *peekedConn) Read(b []byte) (int, error) {
+	return conn.reader.Read(b)
+}
+
+func newMuxListener(addr net.Addr) *muxListener {
+	return &muxListener{
+		addr:   addr,
+		conns:  make(chan net.Conn),
+		closer: make(chan struct{}),
+	}
+}
+
+// muxListener is a net.Listener implementation accepting the connections
+// dispatched by the multiplexer.
+type muxListener struct {
+	addr   net.Addr
+	conns  chan net.Conn
+	closer chan struct{}
+	once   sync.Once
+}
+
+func (listener *muxListener) dispatch(conn net.Conn) error {
+	select {
+	case listener.conns <- conn:
+		return nil
+	case <-listener.closer:
+		return conn.Close()
+	}
+}
+
+func (listener *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.closer:
+		return nil, net.ErrClosed
+	}
+}
+
+func (listener *muxListener) Close() error {
+	listener.once.Do(func() {
+		close(listener.closer)
+	})
+
+	return nil
+}
+
+func (listener *muxListener) Addr() net.Addr {
+	return listener.addr
+}
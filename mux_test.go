@@ -0,0 +1,138 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiplexer(t *testing.T) {
+	t.Parallel()
+
+	routes := map[string]*Server{}
+	for _, database := range []string{"a", "b", "c"} {
+		database := database
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			writer.Define(Columns{ //nolint:errcheck
+				{Name: "database", Oid: oid.T_text, Format: TextFormat},
+			})
+
+			writer.Row([]any{database}) //nolint:errcheck
+			return writer.Complete("SELECT 1")
+		}
+
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+		routes[database] = server
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewMultiplexer(routes)
+	go mux.Serve(listener) //nolint:errcheck
+	t.Cleanup(func() {
+		mux.Close() //nolint:errcheck
+	})
+
+	address := listener.Addr().(*net.TCPAddr)
+	ctx := context.Background()
+
+	for _, database := range []string{"a", "b", "c", "b", "a"} {
+		connstr := fmt.Sprintf("postgres://%s:%d/%s", address.IP, address.Port, database)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+
+		var result string
+		err = conn.QueryRow(ctx, "SELECT current_database();").Scan(&result)
+		require.NoError(t, err)
+		assert.Equal(t, database, result)
+
+		require.NoError(t, conn.Close(ctx))
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		connstr := fmt.Sprintf("postgres://%s:%d/unknown", address.IP, address.Port)
+		_, err := pgx.Connect(ctx, connstr)
+		require.Error(t, err)
+
+		var pgErr *pgconn.PgError
+		require.True(t, errors.As(err, &pgErr))
+		assert.Equal(t, string(codes.InvalidCatalogName), pgErr.Code)
+	})
+}
+
+func TestMultiplexerListener(t *testing.T) {
+	t.Parallel()
+
+	routed := func(name string) *Server {
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			writer.Define(Columns{ //nolint:errcheck
+				{Name: "server", Oid: oid.T_text, Format: TextFormat},
+			})
+
+			writer.Row([]any{name}) //nolint:errcheck
+			return writer.Complete("SELECT 1")
+		}
+
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+		return server
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mux := NewMultiplexer(map[string]*Server{"a": routed("a")})
+	mux.SniffTimeout = 100 * time.Millisecond
+
+	go mux.Serve(listener) //nolint:errcheck
+	t.Cleanup(func() {
+		mux.Close() //nolint:errcheck
+	})
+
+	fallback := routed("fallback")
+	go fallback.Serve(mux) //nolint:errcheck
+
+	// NOTE: unrouted connections are only returned once Accept is called
+	require.Eventually(t, mux.accepting.Load, time.Second, time.Millisecond)
+
+	address := listener.Addr().(*net.TCPAddr)
+	ctx := context.Background()
+
+	for database, expected := range map[string]string{"a": "a", "unknown": "fallback"} {
+		connstr := fmt.Sprintf("postgres://%s:%d/%s", address.IP, address.Port, database)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+
+		var result string
+		err = conn.QueryRow(ctx, "SELECT server;").Scan(&result)
+		require.NoError(t, err)
+		assert.Equal(t, expected, result)
+
+		require.NoError(t, conn.Close(ctx))
+	}
+
+	t.Run("sniff timeout", func(t *testing.T) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		// NOTE: the multiplexer should close the connection once the client
+		// did not send a startup message within the sniff timeout.
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err = conn.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
@@ -0,0 +1,314 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"go.uber.org/zap"
+)
+
+// listenExpr matches LISTEN and UNLISTEN commands containing a single channel
+// name or a asterisk to unlisten all channels.
+var listenExpr = regexp.MustCompile(`(?is)^\s*(LISTEN|UNLISTEN)\s+("(?:[^"]|"")+"|[^\s;]+)\s*;?\s*$`)
+
+// notification represents a pending asynchronous notification.
+type notification struct {
+	channel string
+	payload string
+}
+
+// subscriber represents the channel subscriptions of a single client
+// connection. Notifications are queued and delivered to the client by a
+// dedicated goroutine, a slow client only delays its own notifications.
+// Notifications are only written in between commands to avoid interleaving
+// them with command responses.
+type subscriber struct {
+	mu       sync.Mutex
+	pending  []notification
+	channels map[string]struct{}
+
+	// NOTE: the write mutex is held while writing to the client and guards
+	// the connection writer shared with the command handlers.
+	writeMu sync.Mutex
+	conn    net.Conn
+	writer  *buffer.Writer
+	pid     int32
+	busy    bool
+	closed  bool
+
+	signal chan struct{}
+	done   chan struct{}
+	logger *zap.Logger
+}
+
+// newSubscriber constructs a new subscriber writing notifications to the
+// given connection writer on behalf of the given backend process ID. The
+// subscriber is busy until end has been called for the first time.
+func newSubscriber(conn net.Conn, writer *buffer.Writer, pid int32, logger *zap.Logger) *subscriber {
+	return &subscriber{
+		conn:     conn,
+		writer:   writer,
+		pid:      pid,
+		busy:     true,
+		channels: map[string]struct{}{},
+		signal:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+}
+
+// listen subscribes the connection to the given channel.
+func (sub *subscriber) listen(channel string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	sub.channels[channel] = struct{}{}
+}
+
+// unlisten removes the subscription to the given channel. All subscriptions
+// are removed whenever a asterisk is given.
+func (sub *subscriber) unlisten(channel string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if channel == "*" {
+		sub.channels = map[string]struct{}{}
+		return
+	}
+
+	delete(sub.channels, channel)
+}
+
+// begin marks the connection as busy. Notifications received while the
+// connection is busy are delivered once the command has been handled.
+func (sub *subscriber) begin() {
+	sub.writeMu.Lock()
+	defer sub.writeMu.Unlock()
+	sub.busy = true
+}
+
+// end marks the connection as idle and flushes all pending notifications.
+func (sub *subscriber) end() error {
+	sub.writeMu.Lock()
+	defer sub.writeMu.Unlock()
+	sub.busy = false
+	return sub.flush()
+}
+
+// notify queues the given notification if the connection is subscribed to
+// the notification channel and signals the delivery goroutine. Notify never
+// blocks on writes to the client.
+func (sub *subscriber) notify(channel string, payload string) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if _, has := sub.channels[channel]; !has {
+		return
+	}
+
+	sub.pending = append(sub.pending, notification{channel: channel, payload: payload})
+
+	select {
+	case sub.signal <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued notifications to the client whenever the
+// connection is idle until the subscriber has been closed.
+func (sub *subscriber) run() {
+	for {
+		select {
+		case <-sub.done:
+			return
+		case <-sub.signal:
+		}
+
+		sub.writeMu.Lock()
+		if !sub.busy && !sub.closed {
+			err := sub.flush()
+			if err != nil {
+				sub.logger.Error("unexpected error while writing a notification", zap.Error(err))
+				sub.closed = true
+			}
+		}
+		sub.writeMu.Unlock()
+	}
+}
+
+// close stops the delivery of notifications. Notifications are never written
+// once close has returned. Notifications blocked on writing to a stalled
+// client are interrupted.
+func (sub *subscriber) close() {
+	close(sub.done)
+
+	if !sub.writeMu.TryLock() {
+		sub.conn.SetWriteDeadline(time.Now()) //nolint:errcheck
+		sub.writeMu.Lock()
+		sub.conn.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	}
+
+	defer sub.writeMu.Unlock()
+	sub.closed = true
+}
+
+// flush writes all pending notifications to the client. The write mutex is
+// expected to be held by the caller.
+func (sub *subscriber) flush() error {
+	sub.mu.Lock()
+	pending := sub.pending
+	sub.pending = nil
+
+	// NOTE: notifications for channels which have been unlistened after
+	// the notification has been queued are discarded.
+	deliver := pending[:0]
+	for _, notification := range pending {
+		if _, has := sub.channels[notification.channel]; has {
+			deliver = append(deliver, notification)
+		}
+	}
+	sub.mu.Unlock()
+
+	if len(deliver) == 0 {
+		return nil
+	}
+
+	for _, notification := range deliver {
+		err := notificationResponse(sub.writer, sub.pid, notification.channel, notification.payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	// NOTE: buffered notifications have to be flushed as the client is not
+	// expected to respond to them.
+	if flusher, ok := sub.writer.Writer.(flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// Notify queues a asynchronous notification containing the given payload for
+// all client connections listening on the given channel. Notifications are
+// delivered in the background once the connection has finished handling its
+// current command, errors while writing a notification are logged and close
+// the delivery to the affected connection. Writes to stalled clients could be
+// bounded using WriteTimeout. Notifications are no longer queued once the
+// given context has been cancelled.
+func (srv *Server) Notify(ctx context.Context, channel string, payload string) error {
+	srv.subscribersMu.RLock()
+	defer srv.subscribersMu.RUnlock()
+
+	for sub := range srv.subscribers {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		sub.notify(channel, payload)
+	}
+
+	return nil
+}
+
+// subscribe registers the given subscriber to receive notifications.
+func (srv *Server) subscribe(sub *subscriber) {
+	srv.subscribersMu.Lock()
+	defer srv.subscribersMu.Unlock()
+
+	if srv.subscribers == nil {
+		srv.subscribers = map[*subscriber]struct{}{}
+	}
+
+	srv.subscribers[sub] = struct{}{}
+}
+
+// unsubscribe removes the given subscriber.
+func (srv *Server) unsubscribe(sub *subscriber) {
+	srv.subscribersMu.Lock()
+	defer srv.subscribersMu.Unlock()
+	delete(srv.subscribers, sub)
+}
+
+// handleListen handles the given query if it contains a LISTEN or UNLISTEN
+// command. A boolean is returned indicating whether the query has been
+// handled.
+func (srv *Server) handleListen(ctx context.Context, writer *buffer.Writer, query string) (bool, error) {
+	matches := listenExpr.FindStringSubmatch(query)
+	if matches == nil {
+		return false, nil
+	}
+
+	sub := getSubscriber(ctx)
+	if sub == nil {
+		return false, nil
+	}
+
+	command := strings.ToUpper(matches[1])
+	channel := channelName(matches[2])
+
+	srv.logger.Debug("incoming channel subscription", zap.String("command", command), zap.String("channel", channel))
+
+	switch command {
+	case "LISTEN":
+		if channel == "*" {
+			return false, nil
+		}
+
+		sub.listen(channel)
+	case "UNLISTEN":
+		sub.unlisten(channel)
+	}
+
+	err := commandComplete(writer, command)
+	if err != nil {
+		return true, err
+	}
+
+	return true, readyForQuery(writer, types.ServerIdle)
+}
+
+// channelName returns the channel name for the given identifier. Unquoted
+// identifiers are folded to lower case.
+func channelName(identifier string) string {
+	if len(identifier) > 1 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+
+	return strings.ToLower(identifier)
+}
+
+// notificationResponse writes a asynchronous notification to the client. The
+// notifications are send on behalf of the given backend process ID.
+// https://www.postgresql.org/docs/current/protocol-message-formats.html
+func notificationResponse(writer *buffer.Writer, pid int32, channel string, payload string) error {
+	writer.Start(types.ServerNotificationResponse)
+	writer.AddInt32(pid)
+	writer.AddString(channel)
+	writer.AddNullTerminate()
+	writer.AddString(payload)
+	writer.AddNullTerminate()
+	return writer.End()
+}
+
+// setSubscriber constructs a new context containing the given subscriber.
+func setSubscriber(ctx context.Context, sub *subscriber) context.Context {
+	return context.WithValue(ctx, ctxSubscriber, sub)
+}
+
+// getSubscriber returns the subscriber if it has been set inside the given
+// context.
+func getSubscriber(ctx context.Context) *subscriber {
+	val := ctx.Value(ctxSubscriber)
+	if val == nil {
+		return nil
+	}
+
+	return val.(*subscriber)
+}
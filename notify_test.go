@@ -0,0 +1,228 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenNotify(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	defer conn.Close(ctx) //nolint:errcheck
+
+	_, err = conn.Exec(ctx, `LISTEN "Events"`)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "LISTEN other")
+	require.NoError(t, err)
+
+	require.NoError(t, server.Notify(ctx, "Events", "created"))
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	notification, err := conn.WaitForNotification(timeout)
+	require.NoError(t, err)
+	assert.Equal(t, "Events", notification.Channel)
+	assert.Equal(t, "created", notification.Payload)
+	assert.Equal(t, conn.PgConn().PID(), notification.PID)
+
+	t.Run("unlisten", func(t *testing.T) {
+		_, err = conn.Exec(ctx, `UNLISTEN "Events"`)
+		require.NoError(t, err)
+
+		require.NoError(t, server.Notify(ctx, "Events", "removed"))
+		require.NoError(t, server.Notify(ctx, "other", "remaining"))
+
+		timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		notification, err := conn.WaitForNotification(timeout)
+		require.NoError(t, err)
+		assert.Equal(t, "other", notification.Channel)
+		assert.Equal(t, "remaining", notification.Payload)
+	})
+
+	t.Run("unlisten all", func(t *testing.T) {
+		_, err = conn.Exec(ctx, "UNLISTEN *")
+		require.NoError(t, err)
+
+		require.NoError(t, server.Notify(ctx, "other", "removed"))
+
+		timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+
+		_, err := conn.WaitForNotification(timeout)
+		assert.Error(t, err)
+	})
+}
+
+func TestChannelName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"events":          "events",
+		"Events":          "events",
+		`"Events"`:        "Events",
+		`"quoted ""id"""`: `quoted "id"`,
+		"*":               "*",
+	}
+
+	for identifier, expected := range tests {
+		assert.Equal(t, expected, channelName(identifier))
+	}
+}
+
+func TestNotifyBroadcast(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+
+	listeners := make([]*pgx.Conn, 2)
+	for index := range listeners {
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx) //nolint:errcheck
+
+		_, err = conn.Exec(ctx, "LISTEN events")
+		require.NoError(t, err)
+
+		listeners[index] = conn
+	}
+
+	idle, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer idle.Close(ctx) //nolint:errcheck
+
+	require.NoError(t, server.Notify(ctx, "events", "created"))
+
+	for _, conn := range listeners {
+		timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+		notification, err := conn.WaitForNotification(timeout)
+		cancel()
+
+		require.NoError(t, err)
+		assert.Equal(t, "events", notification.Channel)
+		assert.Equal(t, "created", notification.Payload)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	_, err = idle.WaitForNotification(timeout)
+	assert.Error(t, err)
+
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		err := server.Notify(cancelled, "events", "removed")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestNotifyStalledClient(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	// NOTE: the stalled client subscribes to the channel but never reads
+	// the notifications written to it.
+	stalled, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	defer stalled.Close()
+
+	client := mock.NewClient(stalled)
+	client.Handshake(t)
+	client.Authenticate(t)
+	client.ReadyForQuery(t)
+
+	client.Start(types.ClientSimpleQuery)
+	client.AddString("LISTEN events")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	typed, _, err := client.ReadTypedMsg()
+	require.NoError(t, err)
+	require.Equal(t, types.ServerCommandComplete, typed)
+	client.ReadyForQuery(t)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx) //nolint:errcheck
+
+	_, err = conn.Exec(ctx, "LISTEN events")
+	require.NoError(t, err)
+
+	payload := strings.Repeat("x", 64*1024)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// NOTE: the notifications exceed the socket buffers of the stalled
+		// client, notifying should never block on writing to it.
+		for i := 0; i < 256; i++ {
+			if err := server.Notify(ctx, "events", payload); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notify blocked on a stalled client")
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	notification, err := conn.WaitForNotification(timeout)
+	require.NoError(t, err)
+	assert.Equal(t, "events", notification.Channel)
+}
@@ -5,12 +5,15 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
+	"fmt"
+	"net"
 	"regexp"
-	"strconv"
+	"time"
 
 	"github.com/jackc/pgtype"
 	"github.com/lib/pq/oid"
 	"go.uber.org/zap"
+	"nhooyr.io/websocket"
 )
 
 // QueryParameters represents a regex which could be used to identify and lookup
@@ -63,40 +66,36 @@ type CloseFn func(ctx context.Context) error
 // PostgreSQL server.
 type OptionFn func(*Server) error
 
+// MiddlewareFn represents a function wrapping the given simple query handler.
+// The returned handler is called instead of the given handler and is expected
+// to call the next handler to continue the execution of the query.
+type MiddlewareFn func(next SimpleQueryFn) SimpleQueryFn
+
 // SimpleQuery sets the simple query handle inside the given server instance.
+// The given handler is wrapped with all middleware defined through the
+// Middleware option.
 func SimpleQuery(fn SimpleQueryFn) OptionFn {
 	return func(srv *Server) error {
 		if srv.Parse != nil {
 			return errors.New("simple query handler could not set if a query parser is set")
 		}
 
+		// NOTE: middleware could be defined after the simple query handler,
+		// the handler is therefore wrapped once the server has been
+		// configured.
+		srv.simpleQuery = fn
+
 		srv.Parse = func(ctx context.Context, query string) (PreparedStatementFn, []oid.Oid, error) {
 			statement := func(ctx context.Context, writer DataWriter, parameters []string) error {
-				return fn(ctx, query, writer, parameters)
+				srv.logger.Debug("executing query", zap.Stringer("query", loggedQuery{query: query, params: parameters}))
+				return srv.simpleQuery(ctx, query, writer, parameters)
 			}
 
 			// NOTE: we have to lookup all parameters within the given query.
 			// Parameters could represent positional parameters or anonymous
-			// parameters. We return a zero parameter oid for each parameter
-			// indicating that the given parameters could contain any type. We
-			// could safely ignore the err check while converting given
-			// parameters since ony matches are returned by the positional
-			// parameter regex.
-			matches := QueryParameters.FindAllStringSubmatch(query, -1)
-			parameters := make([]oid.Oid, 0, len(matches))
-			for _, match := range matches {
-				// NOTE: we have to check whether the returned match is a
-				// positional parameter or an un-positional parameter.
-				// SELECT * FROM users WHERE id = ?
-				if match[1] == "" {
-					parameters = append(parameters, 0)
-				}
-
-				position, _ := strconv.Atoi(match[1]) //nolint:errcheck
-				if position > len(parameters) {
-					parameters = parameters[:position]
-				}
-			}
+			// parameters. Parameters defined inside comments or string
+			// constants are ignored.
+			parameters := queryParameters(query)
 
 			return statement, parameters, nil
 		}
@@ -105,6 +104,31 @@ func SimpleQuery(fn SimpleQueryFn) OptionFn {
 	}
 }
 
+// Middleware wraps the simple query handler with the given middleware. This
+// could be used to add cross-cutting concerns such as logging, metrics or
+// authorization without modifying the query handler. Middleware is called in
+// the order in which it is defined, the first defined middleware is called
+// first. Middleware is only applied to handlers set through SimpleQuery.
+func Middleware(fn MiddlewareFn) OptionFn {
+	return func(srv *Server) error {
+		if fn == nil {
+			return errors.New("middleware is required")
+		}
+
+		srv.middleware = append(srv.middleware, fn)
+		return nil
+	}
+}
+
+// chainMiddleware wraps the given handler with the defined middleware.
+func (srv *Server) chainMiddleware(handler SimpleQueryFn) SimpleQueryFn {
+	for index := len(srv.middleware) - 1; index >= 0; index-- {
+		handler = srv.middleware[index](handler)
+	}
+
+	return handler
+}
+
 // Parse sets the given parse function used to parse queries into prepared statements.
 func Parse(fn ParseFn) OptionFn {
 	return func(srv *Server) error {
@@ -136,6 +160,8 @@ func Portals(cache PortalCache) OptionFn {
 }
 
 // CloseConn sets the close connection handle inside the given server instance.
+// The handle is called once the client closes the connection without sending
+// a terminate message.
 func CloseConn(fn CloseFn) OptionFn {
 	return func(srv *Server) error {
 		srv.CloseConn = fn
@@ -151,6 +177,15 @@ func TerminateConn(fn CloseFn) OptionFn {
 	}
 }
 
+// ListenAddress sets the address on which the server listens for incoming
+// connections when ListenAndServe is called without an address.
+func ListenAddress(address string) OptionFn {
+	return func(srv *Server) error {
+		srv.Address = address
+		return nil
+	}
+}
+
 // MessageBufferSize sets the message buffer size which is allocated once a new
 // connection gets constructed. If a negative value or zero value is provided is
 // the default message buffer size used.
@@ -188,6 +223,32 @@ func ClientAuth(authType tls.ClientAuthType) OptionFn {
 	}
 }
 
+// GSSEncryptionFn upgrades the given connection to a GSSAPI encrypted
+// connection. The returned connection is used to read and write all
+// consecutive messages.
+type GSSEncryptionFn func(conn net.Conn) (net.Conn, error)
+
+// GSSEncryption sets the given GSSAPI encryption upgrader. Clients requesting
+// GSS encryption are accepted and their connection is upgraded using the
+// given function. GSS encryption requests are declined when no upgrader has
+// been configured.
+func GSSEncryption(fn GSSEncryptionFn) OptionFn {
+	return func(srv *Server) error {
+		srv.GSSEncryption = fn
+		return nil
+	}
+}
+
+// WebSocket sets the accept options used to upgrade incoming HTTP connections
+// served through ServeWebSocket. By default are only same origin WebSocket
+// connections accepted.
+func WebSocket(options *websocket.AcceptOptions) OptionFn {
+	return func(srv *Server) error {
+		srv.WebSocket = options
+		return nil
+	}
+}
+
 // SessionAuthStrategy sets the given authentication strategy within the given
 // server. The authentication strategy is called when a handshake is initiated.
 func SessionAuthStrategy(fn AuthStrategy) OptionFn {
@@ -198,10 +259,19 @@ func SessionAuthStrategy(fn AuthStrategy) OptionFn {
 }
 
 // GlobalParameters sets the server parameters which are send back to the
-// front-end (client) once a handshake has been established.
+// front-end (client) once a handshake has been established. The given
+// parameters are merged with the default parameters, the given parameters
+// take precedence over the defaults.
 func GlobalParameters(params Parameters) OptionFn {
 	return func(srv *Server) error {
-		srv.Parameters = params
+		if srv.Parameters == nil {
+			srv.Parameters = make(Parameters, len(params))
+		}
+
+		for key, value := range params {
+			srv.Parameters[key] = value
+		}
+
 		return nil
 	}
 }
@@ -214,8 +284,25 @@ func Logger(logger *zap.Logger) OptionFn {
 	}
 }
 
+// WithLogger sets the given logger as the default logger for the given server.
+// Both *zap.Logger and *slog.Logger values are accepted, slog loggers are
+// supported when build using Go 1.21 or newer without the noslog build tag.
+func WithLogger(logger any) OptionFn {
+	return func(srv *Server) error {
+		l, err := newLogger(logger)
+		if err != nil {
+			return err
+		}
+
+		srv.logger = l
+		return nil
+	}
+}
+
 // Version sets the PostgreSQL version for the server which is send back to the
 // front-end (client) once a handshake has been established.
+//
+// Deprecated: use ServerVersion instead.
 func Version(version string) OptionFn {
 	return func(srv *Server) error {
 		srv.Version = version
@@ -223,6 +310,22 @@ func Version(version string) OptionFn {
 	}
 }
 
+// ServerVersion sets the server_version parameter status broadcasted to the
+// client once a handshake has been established. Clients such as pgx use the
+// server version to decide which features to use, this option could be used
+// to mimic a specific Postgres version for compatibility testing. The
+// DefaultServerVersion is broadcasted whenever no version has been set.
+func ServerVersion(v string) OptionFn {
+	return func(srv *Server) error {
+		if v == "" {
+			return errors.New("server version must not be empty")
+		}
+
+		srv.Version = v
+		return nil
+	}
+}
+
 // ExtendTypes provides the ability to extend the underlying connection types.
 // Types registered inside the given pgtype.ConnInfo are registered to all
 // incoming connections.
@@ -233,6 +336,136 @@ func ExtendTypes(fn func(*pgtype.ConnInfo)) OptionFn {
 	}
 }
 
+// DomainType registers the given domain type as an alias of the given base
+// type. Postgres domain types are user-defined types wrapping a base type
+// with optional constraints. Values written to columns of the given domain
+// type are encoded using the encoder of the base type.
+// https://www.postgresql.org/docs/current/domains.html
+func DomainType(name string, domain oid.Oid, base oid.Oid) OptionFn {
+	return func(srv *Server) error {
+		typed, has := srv.types.DataTypeForOID(uint32(base))
+		if !has {
+			return fmt.Errorf("unknown base type %d for domain type: %s", base, name)
+		}
+
+		srv.types.RegisterDataType(pgtype.DataType{
+			Value: pgtype.NewValue(typed.Value),
+			Name:  name,
+			OID:   uint32(domain),
+		})
+
+		return nil
+	}
+}
+
+// AllowQueries only allows queries matching any of the given patterns to be
+// executed. Patterns are case-insensitive glob patterns (ex: SELECT *) or
+// regular expressions when wrapped inside slashes (ex: /^select\s/). Patterns
+// are matched against each statement of a multi-statement query, all
+// statements have to be allowed for the query to be executed. Rejected
+// queries are not passed to the query handler and return a insufficient
+// privilege error to the client.
+func AllowQueries(patterns ...string) OptionFn {
+	return func(srv *Server) error {
+		compiled, err := compileQueryPatterns(patterns)
+		if err != nil {
+			return err
+		}
+
+		srv.allowedQueries = append(srv.allowedQueries, compiled...)
+		return nil
+	}
+}
+
+// DenyQueries rejects all queries matching any of the given patterns. Denied
+// patterns take precedence over allowed query patterns. See AllowQueries for
+// the supported pattern syntax.
+func DenyQueries(patterns ...string) OptionFn {
+	return func(srv *Server) error {
+		compiled, err := compileQueryPatterns(patterns)
+		if err != nil {
+			return err
+		}
+
+		srv.deniedQueries = append(srv.deniedQueries, compiled...)
+		return nil
+	}
+}
+
+// TransformRows wraps the data writer passed to query handlers and applies the
+// given transformation to each row before it is written to the client. This
+// could be used to filter rows, mask sensitive column values or normalize
+// values without modifying the query handlers. Multiple transformations are
+// applied in the order in which they are defined.
+func TransformRows(transform RowTransformFn) OptionFn {
+	return func(srv *Server) error {
+		srv.transforms = append(srv.transforms, transform)
+		return nil
+	}
+}
+
+// RowFilter wraps the data writer passed to query handlers and calls the given
+// filter for each row before it is written to the client. Rows for which the
+// filter returns false are silently dropped and the query fails whenever the
+// filter returns an error. This could be used to implement row level security
+// based on the session context without modifying the query handlers. Multiple
+// filters are applied in the order in which they are defined and before any
+// row transformations.
+func RowFilter(fn RowFilterFn) OptionFn {
+	return func(srv *Server) error {
+		srv.filters = append(srv.filters, fn)
+		return nil
+	}
+}
+
+// MaxErrorsPerConnection sets the maximum number of error responses written to
+// a single client connection. The connection is closed with a final fatal
+// error once the limit has been reached. This prevents misbehaving clients
+// from flooding the server with invalid queries. No limit is enforced when n
+// is zero.
+func MaxErrorsPerConnection(n int) OptionFn {
+	return func(srv *Server) error {
+		if n < 0 {
+			return fmt.Errorf("max errors per connection must be positive, received %d", n)
+		}
+
+		srv.MaxConnErrors = n
+		return nil
+	}
+}
+
+// WriteCoalescing coalesces small messages written to a client connection
+// into writes of the given threshold in bytes. Buffered messages are flushed
+// once the threshold has been reached or once the server awaits a response of
+// the client. This reduces the number of syscalls for queries returning many
+// small data rows. Writes are not coalesced when the threshold is zero.
+func WriteCoalescing(threshold int) OptionFn {
+	return func(srv *Server) error {
+		if threshold < 0 {
+			return fmt.Errorf("write coalescing threshold must be positive, received %d", threshold)
+		}
+
+		srv.CoalesceSize = threshold
+		return nil
+	}
+}
+
+// HandshakeTimeout sets the maximum duration of the connection startup and
+// authentication phase. Connections which have not been authenticated within
+// the given duration are closed, preventing slow clients from holding
+// connections open indefinitely. The deadline is removed once the connection
+// has been authenticated. No timeout is enforced when the duration is zero.
+func HandshakeTimeout(d time.Duration) OptionFn {
+	return func(srv *Server) error {
+		if d < 0 {
+			return fmt.Errorf("handshake timeout must be positive, received %s", d)
+		}
+
+		srv.StartupTimeout = d
+		return nil
+	}
+}
+
 // Session sets the given session handler within the underlying server. The
 // session handler is called when a new connection is opened and authenticated
 // allowing for additional metadata to be wrapped around the connection context.
@@ -258,3 +491,24 @@ func Session(fn SessionHandler) OptionFn {
 		return nil
 	}
 }
+
+// OnStartup registers the given function to be called once the server starts
+// serving incoming connections, before the first connection is accepted.
+// Functions are called once in the order in which they have been registered.
+func OnStartup(fn func()) OptionFn {
+	return func(srv *Server) error {
+		srv.startup = append(srv.startup, fn)
+		return nil
+	}
+}
+
+// OnShutdown registers the given function to be called once the server has
+// been gracefully closed and all connections have been served. Functions are
+// called once in the order in which they have been registered, even when the
+// server is closed multiple times.
+func OnShutdown(fn func()) OptionFn {
+	return func(srv *Server) error {
+		srv.shutdown = append(srv.shutdown, fn)
+		return nil
+	}
+}
@@ -2,11 +2,18 @@ package wire
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/lib/pq/oid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestInvalidOptions(t *testing.T) {
@@ -47,6 +54,30 @@ func TestSimpleQueryParameters(t *testing.T) {
 			query:      "SELECT * FROM users WHERE id = ? AND age > ?",
 			parameters: []oid.Oid{0, 0},
 		},
+		"unordered": {
+			query:      "SELECT * FROM users WHERE id = $3",
+			parameters: []oid.Oid{0, 0, 0},
+		},
+		"inline comment": {
+			query:      "SELECT * FROM users WHERE id = $1 -- AND age > $2 OR name = ?\n AND name = $2",
+			parameters: []oid.Oid{0, 0},
+		},
+		"block comment": {
+			query:      "SELECT * FROM users /* filter on $2\n and ? across\n multiple /* nested $3 */ lines */ WHERE id = $1",
+			parameters: []oid.Oid{0},
+		},
+		"string constants": {
+			query:      `SELECT '$2 ?', E'\' $3', "col?$4" FROM users WHERE id = $1`,
+			parameters: []oid.Oid{0},
+		},
+		"dollar quoted": {
+			query:      "SELECT $fn$ BEGIN RETURN $body$ $2 ? $body$; END $fn$, $$ $3 $$ WHERE id = $1",
+			parameters: []oid.Oid{0},
+		},
+		"identifier": {
+			query:      "SELECT foo$2 FROM users WHERE id = $1",
+			parameters: []oid.Oid{0},
+		},
 	}
 
 	for name, test := range tests {
@@ -116,3 +147,253 @@ func TestSessionHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestSessionStartupParameters(t *testing.T) {
+	t.Parallel()
+
+	sessions := make(chan map[string]string, 1)
+	session := Session(func(ctx context.Context) (context.Context, error) {
+		sessions <- StartupParameters(ctx)
+		return ctx, nil
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete(StartupParameters(ctx)["application_name"])
+	}
+
+	server, err := NewServer(SimpleQuery(handler), session)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://john@%s:%d/users?application_name=reports&search_path=analytics", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	params := <-sessions
+	assert.Equal(t, "users", params["database"])
+	assert.Equal(t, "john", params["user"])
+	assert.Equal(t, "reports", params["application_name"])
+	assert.Equal(t, "analytics", params["search_path"])
+
+	tag, err := conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+	assert.Equal(t, "reports", tag.String())
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Nil(t, StartupParameters(context.Background()))
+	})
+}
+
+func TestDomainType(t *testing.T) {
+	t.Parallel()
+
+	email := oid.Oid(16400)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{
+				Name:   "email",
+				Oid:    email,
+				Format: TextFormat,
+			},
+		})
+
+		writer.Row([]any{"john@example.com"}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), DomainType("email", email, oid.T_text))
+	assert.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	assert.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var result string
+	err = conn.QueryRow(ctx, "SELECT email FROM users;").Scan(&result)
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", result)
+}
+
+func TestDomainTypeUnknownBase(t *testing.T) {
+	_, err := NewServer(DomainType("unknown", oid.Oid(16400), oid.Oid(16401)))
+	assert.Error(t, err)
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	const rows = 1000
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{{Name: "id", Oid: oid.T_int4, Format: TextFormat}})
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < rows; i++ {
+			err = writer.Row([]any{i})
+			if err != nil {
+				return err
+			}
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", rows))
+	}
+
+	server, err := NewServer(SimpleQuery(handler), WriteCoalescing(4096))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	for attempt := 0; attempt < 3; attempt++ {
+		result, err := conn.Query(ctx, "SELECT * FROM generate_series(0, 999);")
+		require.NoError(t, err)
+
+		count := 0
+		for result.Next() {
+			var id int32
+			require.NoError(t, result.Scan(&id))
+			assert.Equal(t, int32(count), id)
+			count++
+		}
+
+		require.NoError(t, result.Err())
+		assert.Equal(t, rows, count)
+	}
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := NewServer(WriteCoalescing(-1))
+		assert.Error(t, err)
+	})
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	events := []string{}
+	hook := func(event string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		}
+	}
+
+	started := make(chan struct{})
+
+	server, err := NewServer(
+		OnStartup(hook("startup")),
+		OnShutdown(hook("shutdown")),
+		OnStartup(hook("configured")),
+		OnStartup(func() { close(started) }),
+	)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		server.Serve(listener) //nolint:errcheck
+	}()
+
+	<-started
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	require.NoError(t, server.Close())
+	<-served
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"startup", "configured", "shutdown"}, events)
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type key string
+	tenant := key("tenant")
+
+	var mu sync.Mutex
+	calls := []string{}
+
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	first := Middleware(func(next SimpleQueryFn) SimpleQueryFn {
+		return func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			record("first")
+			return next(context.WithValue(ctx, tenant, "acme"), query, writer, parameters)
+		}
+	})
+
+	second := Middleware(func(next SimpleQueryFn) SimpleQueryFn {
+		return func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			record(fmt.Sprintf("second:%v", ctx.Value(tenant)))
+			return next(ctx, query, writer, parameters)
+		}
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		record(fmt.Sprintf("handler:%v", ctx.Value(tenant)))
+		return writer.Complete("OK")
+	}
+
+	// NOTE: middleware defined after the simple query handler should still be
+	// applied.
+	server, err := NewServer(first, SimpleQuery(handler), second)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"first", "second:acme", "handler:acme"}, calls)
+}
+
+func TestInvalidMiddleware(t *testing.T) {
+	_, err := NewServer(Middleware(nil))
+	assert.Error(t, err)
+}
+
+func TestWithLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+
+	server, err := NewServer(WithLogger(zap.New(core)))
+	require.NoError(t, err)
+
+	server.logger.Debug("hello")
+	assert.Equal(t, 1, logs.FilterMessage("hello").Len())
+}
+
+func TestInvalidWithLogger(t *testing.T) {
+	_, err := NewServer(WithLogger("logger"))
+	assert.Error(t, err)
+}
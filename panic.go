@@ -0,0 +1,38 @@
+package wire
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"go.uber.org/zap"
+)
+
+// ErrPanic is returned whenever a panic has been recovered while executing a
+// query handler.
+var ErrPanic = errors.New("query handler panicked")
+
+// NewErrPanic constructs a new error wrapping the ErrPanic type including the
+// internal error code and the recovered value.
+func NewErrPanic(value any) error {
+	err := fmt.Errorf("%w: %v", ErrPanic, value)
+	return psqlerr.WithCode(err, codes.Internal)
+}
+
+// panicSafe executes the given function and recovers any panic. Recovered
+// panics are returned as error allowing a error response to be written to the
+// client, preventing the client from waiting for a command to be completed.
+func (srv *Server) panicSafe(fn func() error) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		srv.logger.Error("recovered panic while executing query handler", zap.Any("panic", r), zap.Stack("stack"))
+		err = NewErrPanic(r)
+	}()
+
+	return fn()
+}
@@ -0,0 +1,56 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicSafe(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT 1;" {
+			return writer.Complete("OK")
+		}
+
+		err := writer.Define(Columns{{Name: "name", Oid: oid.T_text, Format: TextFormat}})
+		if err != nil {
+			return err
+		}
+
+		panic("unexpected handler failure")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	modes := []pgx.QueryExecMode{pgx.QueryExecModeSimpleProtocol, pgx.QueryExecModeExec}
+	for _, mode := range modes {
+		_, err = conn.Exec(ctx, "SELECT name FROM users;", mode)
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.Internal), pgerr.Code)
+		assert.Contains(t, pgerr.Message, "unexpected handler failure")
+
+		// NOTE: the connection should remain usable after a recovered panic
+		tag, err := conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+		require.NoError(t, err)
+		assert.Equal(t, "OK", tag.String())
+	}
+}
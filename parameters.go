@@ -0,0 +1,293 @@
+package wire
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq/oid"
+)
+
+// queryParameters looks up all positional ($1) and un-positional (?)
+// parameters defined inside the given query. Parameters defined inside
+// comments, string constants, quoted identifiers and dollar-quoted string
+// constants are ignored. A zero parameter oid is returned for each parameter
+// indicating that the given parameter could contain any type.
+// https://www.postgresql.org/docs/current/sql-syntax-lexical.html
+func queryParameters(query string) []oid.Oid {
+	parameters := []oid.Oid{}
+
+	scanParameters(query, func(start, end, position int) {
+		if query[start] == '?' {
+			parameters = append(parameters, 0)
+			return
+		}
+
+		for len(parameters) < position {
+			parameters = append(parameters, 0)
+		}
+	})
+
+	return parameters
+}
+
+// scanParameters calls the given function for each parameter placeholder
+// defined inside the given query. The start and end offset of the placeholder
+// are passed together with the (one-based) parameter position. Un-positional
+// parameters are numbered in order of appearance.
+func scanParameters(query string, fn func(start, end, position int)) {
+	unpositional := 0
+
+	scanQuery(query, func(index int) int {
+		switch query[index] {
+		case '?':
+			unpositional++
+			fn(index, index+1, unpositional)
+		case '$':
+			// NOTE: dollar signs are allowed inside identifiers (ex: foo$1)
+			// in which case they do not represent a parameter.
+			if index > 0 && isIdentifierChar(query[index-1]) {
+				return index
+			}
+
+			end := index + 1
+			for end < len(query) && query[end] >= '0' && query[end] <= '9' {
+				end++
+			}
+
+			if end > index+1 {
+				position, _ := strconv.Atoi(query[index+1 : end]) //nolint:errcheck
+				fn(index, end, position)
+				return end - 1
+			}
+		}
+
+		return index
+	})
+}
+
+// scanQuery calls the given function for each character of the given query
+// which is not part of a comment, string constant, quoted identifier or
+// dollar-quoted string constant. The function returns the index of the last
+// character it has consumed allowing tokens spanning multiple characters to be
+// skipped.
+func scanQuery(query string, fn func(index int) int) {
+	for index := 0; index < len(query); index++ {
+		char := query[index]
+
+		switch {
+		case char == '-' && strings.HasPrefix(query[index:], "--"):
+			end := strings.IndexByte(query[index:], '\n')
+			if end == -1 {
+				return
+			}
+
+			index += end
+		case char == '/' && strings.HasPrefix(query[index:], "/*"):
+			index = skipBlockComment(query, index)
+		case char == '\'':
+			escapes := index > 0 && (query[index-1] == 'E' || query[index-1] == 'e')
+			index = skipQuoted(query, index, '\'', escapes)
+		case char == '"':
+			index = skipQuoted(query, index, '"', false)
+		case char == '$' && !isDollarParameter(query, index):
+			end := skipDollarQuoted(query, index)
+			if end == index {
+				end = fn(index)
+			}
+
+			index = end
+		default:
+			index = fn(index)
+		}
+	}
+}
+
+// isDollarParameter returns true whenever the dollar sign at the given index
+// is part of a identifier or positional parameter (ex: foo$1 or $1) and does
+// not start a dollar-quoted string constant.
+func isDollarParameter(query string, index int) bool {
+	if index > 0 && isIdentifierChar(query[index-1]) {
+		return true
+	}
+
+	return index+1 < len(query) && query[index+1] >= '0' && query[index+1] <= '9'
+}
+
+// skipBlockComment returns the index of the last character of the (possibly
+// nested) block comment starting at the given index.
+func skipBlockComment(query string, index int) int {
+	depth := 0
+
+	for ; index < len(query)-1; index++ {
+		switch query[index : index+2] {
+		case "/*":
+			depth++
+			index++
+		case "*/":
+			depth--
+			index++
+			if depth == 0 {
+				return index
+			}
+		}
+	}
+
+	return len(query)
+}
+
+// skipQuoted returns the index of the closing quote of the quoted string or
+// identifier starting at the given index. Repeated quote characters are
+// interpreted as escaped quotes. Backslash escapes are only interpreted when
+// escapes is set.
+func skipQuoted(query string, index int, quote byte, escapes bool) int {
+	for index++; index < len(query); index++ {
+		switch query[index] {
+		case '\\':
+			if escapes {
+				index++
+			}
+		case quote:
+			if index+1 < len(query) && query[index+1] == quote {
+				index++
+				continue
+			}
+
+			return index
+		}
+	}
+
+	return len(query)
+}
+
+// skipDollarQuoted returns the index of the last character of the
+// dollar-quoted string constant starting at the given index. The given index
+// is returned whenever the dollar sign does not start a dollar-quoted string.
+func skipDollarQuoted(query string, index int) int {
+	end := index + 1
+	for end < len(query) && isIdentifierChar(query[end]) {
+		end++
+	}
+
+	if end >= len(query) || query[end] != '$' {
+		return index
+	}
+
+	tag := query[index : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing == -1 {
+		return len(query)
+	}
+
+	return end + closing + len(tag)
+}
+
+func isIdentifierChar(char byte) bool {
+	return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char >= 0x80
+}
+
+// SensitiveColumns contains the (lower case) column names of which parameter
+// values are redacted by LogQueryWithParams and the slow query log.
+var SensitiveColumns = []string{"password", "passwd", "secret", "token", "api_key", "apikey"}
+
+// MaxLoggedParamLength represents the maximum length of a parameter value
+// substituted by LogQueryWithParams. Longer values are truncated.
+var MaxLoggedParamLength = 64
+
+// LogQueryWithParams substitutes the given parameter values into the
+// placeholders of the given query. The returned query is intended to be used
+// for logging purposes only and should never be executed. Values are quoted
+// as string constants, values exceeding MaxLoggedParamLength are truncated and
+// values compared to or assigned to one of the SensitiveColumns are redacted.
+func LogQueryWithParams(query string, params []string) string {
+	if len(params) == 0 {
+		return query
+	}
+
+	params = redactParams(query, params)
+
+	result := strings.Builder{}
+	offset := 0
+
+	scanParameters(query, func(start, end, position int) {
+		if position < 1 || position > len(params) {
+			return
+		}
+
+		result.WriteString(query[offset:start])
+		offset = end
+
+		result.WriteString("'" + strings.ReplaceAll(params[position-1], "'", "''") + "'")
+	})
+
+	result.WriteString(query[offset:])
+	return result.String()
+}
+
+// redactParams returns a copy of the given parameter values which is safe to
+// be logged. Values exceeding MaxLoggedParamLength are truncated and values
+// compared to or assigned to one of the SensitiveColumns inside the given
+// query are redacted.
+func redactParams(query string, params []string) []string {
+	result := make([]string, len(params))
+	for index, value := range params {
+		if len(value) > MaxLoggedParamLength {
+			value = value[:MaxLoggedParamLength] + "..."
+		}
+
+		result[index] = value
+	}
+
+	scanParameters(query, func(start, end, position int) {
+		if position < 1 || position > len(params) {
+			return
+		}
+
+		if isSensitiveColumn(precedingIdentifier(query[:start])) {
+			result[position-1] = "[REDACTED]"
+		}
+	})
+
+	return result
+}
+
+// loggedQuery lazily formats a query and its parameters using
+// LogQueryWithParams once it is written to the log.
+type loggedQuery struct {
+	query  string
+	params []string
+}
+
+func (logged loggedQuery) String() string {
+	return LogQueryWithParams(logged.query, logged.params)
+}
+
+// precedingIdentifier returns the (lower case) identifier preceding the
+// comparison or assignment operator at the end of the given query. An empty
+// string is returned whenever no identifier has been found.
+func precedingIdentifier(query string) string {
+	query = strings.TrimRight(query, " \t\r\n")
+	query = strings.TrimRight(query, "=<>!")
+	query = strings.TrimRight(query, " \t\r\n")
+	query = strings.TrimSuffix(query, `"`)
+
+	start := len(query)
+	for start > 0 && isIdentifierChar(query[start-1]) {
+		start--
+	}
+
+	return strings.ToLower(query[start:])
+}
+
+func isSensitiveColumn(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, column := range SensitiveColumns {
+		if name == column {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,62 @@
+package wire
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogQueryWithParams(t *testing.T) {
+	type test struct {
+		query    string
+		params   []string
+		expected string
+	}
+
+	long := strings.Repeat("a", MaxLoggedParamLength+10)
+
+	tests := map[string]test{
+		"positional": {
+			query:    "SELECT * FROM users WHERE id = $1 AND name = $2 AND age > $3",
+			params:   []string{"1", "John", "21"},
+			expected: "SELECT * FROM users WHERE id = '1' AND name = 'John' AND age > '21'",
+		},
+		"unpositional": {
+			query:    "SELECT * FROM users WHERE id = ? AND name = ? AND age > ?",
+			params:   []string{"1", "John", "21"},
+			expected: "SELECT * FROM users WHERE id = '1' AND name = 'John' AND age > '21'",
+		},
+		"reordered": {
+			query:    "SELECT * FROM users WHERE age > $3 AND id = $1 OR parent = $1",
+			params:   []string{"1", "John", "21"},
+			expected: "SELECT * FROM users WHERE age > '21' AND id = '1' OR parent = '1'",
+		},
+		"quotes": {
+			query:    "UPDATE users SET name = $1 WHERE id = $2 -- $3",
+			params:   []string{"O'Brien", "1", "ignored"},
+			expected: "UPDATE users SET name = 'O''Brien' WHERE id = '1' -- $3",
+		},
+		"redacted": {
+			query:    `UPDATE users SET "Password" = $1, token=$2 WHERE id = $3`,
+			params:   []string{"hunter2", "abc", "1"},
+			expected: `UPDATE users SET "Password" = '[REDACTED]', token='[REDACTED]' WHERE id = '1'`,
+		},
+		"truncated": {
+			query:    "INSERT INTO blobs VALUES ($1, $2, $3)",
+			params:   []string{"1", long, "3"},
+			expected: "INSERT INTO blobs VALUES ('1', '" + long[:MaxLoggedParamLength] + "...', '3')",
+		},
+		"missing": {
+			query:    "SELECT * FROM users WHERE id = $1 AND name = $2",
+			params:   []string{"1"},
+			expected: "SELECT * FROM users WHERE id = '1' AND name = $2",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, LogQueryWithParams(test.query, test.params))
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package wire
+
+import (
+	"context"
+	"errors"
+
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"go.uber.org/zap"
+)
+
+// QueryPlanFn returns a human readable execution plan for the given query and
+// parameters.
+type QueryPlanFn func(ctx context.Context, query string, parameters []string) string
+
+// QueryPlan sets the given query plan function. The plan of every executed
+// query is logged alongside the query and send as NOTICE to the client,
+// allowing clients such as psql to display the plan. Empty plans are ignored.
+func QueryPlan(fn QueryPlanFn) OptionFn {
+	return func(srv *Server) error {
+		srv.Plan = fn
+		return nil
+	}
+}
+
+// planQuery wraps the given statement writing the query plan of the given
+// query before the statement is executed.
+func (srv *Server) planQuery(query string, statement PreparedStatementFn) PreparedStatementFn {
+	return func(ctx context.Context, writer DataWriter, parameters []string) error {
+		plan := srv.Plan(ctx, query, parameters)
+		if plan == "" {
+			return statement(ctx, writer, parameters)
+		}
+
+		srv.logger.Info("query plan", zap.String("query", query), zap.Strings("parameters", parameters), zap.String("plan", plan))
+
+		notice := psqlerr.WithSeverity(errors.New(plan), psqlerr.LevelNotice)
+		err := ErrorFromErr(writer, notice)
+		if err != nil {
+			return err
+		}
+
+		return statement(ctx, writer, parameters)
+	}
+}
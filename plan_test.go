@@ -0,0 +1,68 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPlan(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	planner := func(ctx context.Context, query string, parameters []string) string {
+		if strings.Contains(query, "unplanned") {
+			return ""
+		}
+
+		return fmt.Sprintf("Seq Scan on users (parameters: %s)", strings.Join(parameters, ","))
+	}
+
+	server, err := NewServer(SimpleQuery(handler), QueryPlan(planner))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+	require.NoError(t, err)
+
+	notices := make(chan *pgconn.Notice, 10)
+	config.OnNotice = func(conn *pgconn.PgConn, notice *pgconn.Notice) {
+		notices <- notice
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT * FROM users;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+
+	notice := <-notices
+	assert.Equal(t, "NOTICE", notice.Severity)
+	assert.Equal(t, "Seq Scan on users (parameters: )", notice.Message)
+
+	t.Run("parameters", func(t *testing.T) {
+		_, err := conn.Exec(ctx, "SELECT * FROM users WHERE id = $1;", "42")
+		require.NoError(t, err)
+
+		notice := <-notices
+		assert.Equal(t, "Seq Scan on users (parameters: 42)", notice.Message)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := conn.Exec(ctx, "SELECT unplanned;", pgx.QueryExecModeSimpleProtocol)
+		require.NoError(t, err)
+		assert.Empty(t, notices)
+	})
+}
@@ -0,0 +1,197 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// ErrConnQuotaExceeded is returned whenever a user has reached the maximum
+// number of connections allowed by its quota.
+var ErrConnQuotaExceeded = errors.New("connection quota exceeded")
+
+// ErrQueryQuotaExceeded is returned whenever a user has reached the maximum
+// number of queries per second allowed by its quota.
+var ErrQueryQuotaExceeded = errors.New("query rate quota exceeded")
+
+// NewErrConnQuotaExceeded constructs a new fatal error wrapping the
+// ErrConnQuotaExceeded type including the too many connections error code.
+func NewErrConnQuotaExceeded(user, database string, max int) error {
+	err := fmt.Errorf("%w: user %q is limited to %d connections to database %q", ErrConnQuotaExceeded, user, max, database)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.TooManyConnections), psqlerr.LevelFatal)
+}
+
+// NewErrQueryQuotaExceeded constructs a new error wrapping the
+// ErrQueryQuotaExceeded type including the configuration limit exceeded error
+// code.
+func NewErrQueryQuotaExceeded(user, database string, max int) error {
+	err := fmt.Errorf("%w: user %q is limited to %d queries per second on database %q", ErrQueryQuotaExceeded, user, max, database)
+	return psqlerr.WithCode(err, codes.ConfigurationLimitExceeded)
+}
+
+// QuotaStore resolves the resource quota of the given user and database pair.
+// The maximum number of concurrent connections and the maximum number of
+// queries per second are returned. A zero value indicates that no limit is
+// enforced.
+type QuotaStore interface {
+	Check(user, database string) (maxConn, maxQueryRate int, err error)
+}
+
+// ResourceQuota sets the given quota store. The quota of the connecting user
+// and database pair is checked for every new connection and incoming query.
+// Connections and queries exceeding the quota are rejected.
+func ResourceQuota(store QuotaStore) OptionFn {
+	return func(srv *Server) error {
+		srv.Quota = store
+		srv.quotas = &quotaTracker{usage: map[quotaKey]*quotaUsage{}}
+		return nil
+	}
+}
+
+// Quota represents the resource quota of a single user and database pair.
+type Quota struct {
+	MaxConn      int
+	MaxQueryRate int
+}
+
+// InMemoryQuotaStore is a quota store keeping all quotas in memory. No limits
+// are enforced for user and database pairs without a quota.
+type InMemoryQuotaStore struct {
+	mu     sync.RWMutex
+	quotas map[quotaKey]Quota
+}
+
+// NewInMemoryQuotaStore constructs a new empty in memory quota store.
+func NewInMemoryQuotaStore() *InMemoryQuotaStore {
+	return &InMemoryQuotaStore{quotas: map[quotaKey]Quota{}}
+}
+
+// Set sets the quota of the given user and database pair.
+func (store *InMemoryQuotaStore) Set(user, database string, quota Quota) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.quotas[quotaKey{user: user, database: database}] = quota
+}
+
+func (store *InMemoryQuotaStore) Check(user, database string) (maxConn, maxQueryRate int, err error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	quota := store.quotas[quotaKey{user: user, database: database}]
+	return quota.MaxConn, quota.MaxQueryRate, nil
+}
+
+type quotaKey struct {
+	user     string
+	database string
+}
+
+// quotaUsage represents the resources currently used by a single user and
+// database pair. Queries are counted within a fixed window of a second.
+type quotaUsage struct {
+	conns   int
+	window  time.Time
+	queries int
+}
+
+// quotaTracker keeps track of the resources used by all user and database
+// pairs.
+type quotaTracker struct {
+	mu    sync.Mutex
+	usage map[quotaKey]*quotaUsage
+}
+
+func (tracker *quotaTracker) get(key quotaKey) *quotaUsage {
+	usage, has := tracker.usage[key]
+	if !has {
+		usage = &quotaUsage{}
+		tracker.usage[key] = usage
+	}
+
+	return usage
+}
+
+// quotaKeyFromContext returns the quota key of the client connection of the
+// given context. The database defaults to the username if not set.
+func quotaKeyFromContext(ctx context.Context) quotaKey {
+	params := ClientParameters(ctx)
+	key := quotaKey{user: params[ParamUsername], database: params[ParamDatabase]}
+	if key.database == "" {
+		key.database = key.user
+	}
+
+	return key
+}
+
+// acquireConnQuota checks whether the client connection of the given context
+// is allowed by the configured quota. The returned function releases the
+// acquired connection and should be called once the connection is closed.
+func (srv *Server) acquireConnQuota(ctx context.Context) (release func(), err error) {
+	if srv.Quota == nil {
+		return func() {}, nil
+	}
+
+	key := quotaKeyFromContext(ctx)
+	max, _, err := srv.Quota.Check(key.user, key.database)
+	if err != nil {
+		return nil, err
+	}
+
+	srv.quotas.mu.Lock()
+	defer srv.quotas.mu.Unlock()
+
+	usage := srv.quotas.get(key)
+	if max > 0 && usage.conns >= max {
+		return nil, NewErrConnQuotaExceeded(key.user, key.database, max)
+	}
+
+	usage.conns++
+
+	release = func() {
+		srv.quotas.mu.Lock()
+		defer srv.quotas.mu.Unlock()
+
+		usage.conns--
+		if usage.conns == 0 {
+			delete(srv.quotas.usage, key)
+		}
+	}
+
+	return release, nil
+}
+
+// checkQueryQuota checks whether a new query is allowed to be executed by the
+// client connection of the given context.
+func (srv *Server) checkQueryQuota(ctx context.Context) error {
+	if srv.Quota == nil {
+		return nil
+	}
+
+	key := quotaKeyFromContext(ctx)
+	_, max, err := srv.Quota.Check(key.user, key.database)
+	if err != nil || max <= 0 {
+		return err
+	}
+
+	srv.quotas.mu.Lock()
+	defer srv.quotas.mu.Unlock()
+
+	usage := srv.quotas.get(key)
+
+	now := time.Now()
+	if now.Sub(usage.window) >= time.Second {
+		usage.window = now
+		usage.queries = 0
+	}
+
+	if usage.queries >= max {
+		return NewErrQueryQuotaExceeded(key.user, key.database, max)
+	}
+
+	usage.queries++
+	return nil
+}
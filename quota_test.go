@@ -0,0 +1,75 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceQuota(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	store := NewInMemoryQuotaStore()
+	store.Set("john", "users", Quota{MaxConn: 1, MaxQueryRate: 2})
+
+	server, err := NewServer(SimpleQuery(handler), ResourceQuota(store))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://john@%s:%d/users", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	t.Run("connections", func(t *testing.T) {
+		_, err := pgx.Connect(ctx, connstr)
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.TooManyConnections), pgerr.Code)
+
+		// NOTE: other user and database pairs are not limited
+		other, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john@%s:%d/other", address.IP, address.Port))
+		require.NoError(t, err)
+		other.Close(ctx)
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+			require.NoError(t, err)
+		}
+
+		_, err := conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.ConfigurationLimitExceeded), pgerr.Code)
+	})
+
+	t.Run("release", func(t *testing.T) {
+		require.NoError(t, conn.Close(ctx))
+
+		// NOTE: the connection is released asynchronously once the server
+		// notices that the connection has been closed.
+		assert.Eventually(t, func() bool {
+			conn, err := pgx.Connect(ctx, connstr)
+			if err != nil {
+				return false
+			}
+
+			conn.Close(ctx)
+			return true
+		}, time.Second, 10*time.Millisecond)
+	})
+}
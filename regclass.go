@@ -0,0 +1,147 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// RegisterClass registers the given (schema-qualified) relation name for the
+// given relation OID. Values written to regclass columns are encoded as the
+// registered relation name inside the text format. Unregistered OIDs are
+// encoded as numeric OIDs, equal to Postgres.
+func RegisterClass(id uint32, name string) OptionFn {
+	return func(srv *Server) error {
+		if srv.classes == nil {
+			srv.classes = map[uint32]string{}
+			srv.types.RegisterDataType(pgtype.DataType{Value: &RegClass{classes: srv.classes}, Name: "regclass", OID: uint32(oid.T_regclass)})
+		}
+
+		srv.classes[id] = name
+		return nil
+	}
+}
+
+// RegClass represents a Postgres regclass value. The value is stored as a
+// relation OID. The binary format is a big-endian 4-byte unsigned integer and
+// the text format is the registered relation name of the OID.
+// https://www.postgresql.org/docs/current/datatype-oid.html
+type RegClass struct {
+	OID    uint32
+	Status pgtype.Status
+
+	classes map[uint32]string
+}
+
+// NewTypeValue constructs a new regclass value sharing the registered
+// relation names.
+func (src *RegClass) NewTypeValue() pgtype.Value {
+	return &RegClass{classes: src.classes}
+}
+
+// TypeName returns the Postgres type name.
+func (src *RegClass) TypeName() string {
+	return "regclass"
+}
+
+// Set converts and assigns the given source to itself. Integer values are
+// interpreted as relation OIDs.
+func (dst *RegClass) Set(src any) error {
+	classes := dst.classes
+	if src == nil {
+		*dst = RegClass{Status: pgtype.Null, classes: classes}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case uint32:
+		*dst = RegClass{OID: value, Status: pgtype.Present, classes: classes}
+	case oid.Oid:
+		*dst = RegClass{OID: uint32(value), Status: pgtype.Present, classes: classes}
+	case int:
+		if value < 0 {
+			return fmt.Errorf("cannot convert negative value %d to regclass", value)
+		}
+
+		*dst = RegClass{OID: uint32(value), Status: pgtype.Present, classes: classes}
+	case *uint32:
+		if value == nil {
+			*dst = RegClass{Status: pgtype.Null, classes: classes}
+			return nil
+		}
+
+		*dst = RegClass{OID: *value, Status: pgtype.Present, classes: classes}
+	default:
+		return fmt.Errorf("cannot convert %T to regclass", src)
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst RegClass) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		return dst.OID
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *RegClass) AssignTo(dst any) error {
+	if src.Status != pgtype.Present {
+		return fmt.Errorf("cannot assign non-present status to %T", dst)
+	}
+
+	switch value := dst.(type) {
+	case *uint32:
+		*value = src.OID
+	case *string:
+		*value = string(src.format(nil))
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src RegClass) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return src.format(buf), nil
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+func (src RegClass) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return binary.BigEndian.AppendUint32(buf, src.OID), nil
+}
+
+// format appends the relation name of the value to the given buffer.
+func (src RegClass) format(buf []byte) []byte {
+	name, has := src.classes[src.OID]
+	if !has {
+		return strconv.AppendUint(buf, uint64(src.OID), 10)
+	}
+
+	return append(buf, name...)
+}
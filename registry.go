@@ -0,0 +1,108 @@
+package wire
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq/oid"
+)
+
+// FirstUserTypeOID represents the first OID assigned to user-defined types by
+// the type registry. OIDs below this value are reserved for builtin types.
+const FirstUserTypeOID oid.Oid = 10000
+
+// TypeRegistry assigns stable OIDs to user-defined types. OIDs are assigned
+// sequentially starting from FirstUserTypeOID. The registry could be encoded
+// as JSON allowing the assigned OIDs to survive server restarts. A registry is
+// safe for concurrent use.
+type TypeRegistry struct {
+	types map[string]oid.Oid
+	next  oid.Oid
+	mu    sync.RWMutex
+}
+
+// AutoAssign returns the OID assigned to the given type name. A new OID is
+// assigned whenever no OID has been assigned to the given type name yet.
+func (registry *TypeRegistry) AutoAssign(name string) oid.Oid {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if id, has := registry.types[name]; has {
+		return id
+	}
+
+	if registry.types == nil {
+		registry.types = map[string]oid.Oid{}
+	}
+
+	if registry.next < FirstUserTypeOID {
+		registry.next = FirstUserTypeOID
+	}
+
+	id := registry.next
+	registry.types[name] = id
+	registry.next++
+
+	return id
+}
+
+// Lookup returns the OID assigned to the given type name. A boolean is
+// returned indicating whether a OID has been assigned.
+func (registry *TypeRegistry) Lookup(name string) (oid.Oid, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	id, has := registry.types[name]
+	return id, has
+}
+
+// MarshalJSON encodes the assigned OIDs as a JSON object mapping the type
+// names to their OIDs.
+func (registry *TypeRegistry) MarshalJSON() ([]byte, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	types := registry.types
+	if types == nil {
+		types = map[string]oid.Oid{}
+	}
+
+	return json.Marshal(types)
+}
+
+// UnmarshalJSON decodes the assigned OIDs from the given JSON object. Any
+// previously assigned OIDs are overridden. New OIDs are assigned after the
+// highest decoded OID.
+func (registry *TypeRegistry) UnmarshalJSON(bb []byte) error {
+	types := map[string]oid.Oid{}
+	err := json.Unmarshal(bb, &types)
+	if err != nil {
+		return err
+	}
+
+	next := FirstUserTypeOID
+	assigned := make(map[oid.Oid]string, len(types))
+
+	for name, id := range types {
+		if id < FirstUserTypeOID {
+			return fmt.Errorf("type %q has a reserved oid %d, user-defined types should have a oid of at least %d", name, id, FirstUserTypeOID)
+		}
+
+		if previous, has := assigned[id]; has {
+			return fmt.Errorf("types %q and %q have the same oid %d", previous, name, id)
+		}
+
+		assigned[id] = name
+		if id >= next {
+			next = id + 1
+		}
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.types = types
+	registry.next = next
+	return nil
+}
@@ -0,0 +1,74 @@
+package wire
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeRegistryAutoAssign(t *testing.T) {
+	t.Parallel()
+
+	registry := &TypeRegistry{}
+
+	email := registry.AutoAssign("email")
+	assert.Equal(t, FirstUserTypeOID, email)
+	assert.Equal(t, email, registry.AutoAssign("email"))
+
+	money := registry.AutoAssign("eur")
+	assert.Equal(t, FirstUserTypeOID+1, money)
+
+	id, has := registry.Lookup("eur")
+	assert.True(t, has)
+	assert.Equal(t, money, id)
+
+	_, has = registry.Lookup("unknown")
+	assert.False(t, has)
+}
+
+func TestTypeRegistryJSON(t *testing.T) {
+	t.Parallel()
+
+	registry := &TypeRegistry{}
+	email := registry.AutoAssign("email")
+	eur := registry.AutoAssign("eur")
+
+	for cycle := 0; cycle < 3; cycle++ {
+		bb, err := json.Marshal(registry)
+		require.NoError(t, err)
+
+		registry = &TypeRegistry{}
+		require.NoError(t, json.Unmarshal(bb, registry))
+
+		assert.Equal(t, email, registry.AutoAssign("email"))
+		assert.Equal(t, eur, registry.AutoAssign("eur"))
+	}
+
+	// NOTE: new types are assigned after the highest loaded OID.
+	assert.Equal(t, eur+1, registry.AutoAssign("usd"))
+
+	t.Run("empty", func(t *testing.T) {
+		bb, err := json.Marshal(&TypeRegistry{})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{}`, string(bb))
+	})
+
+	t.Run("reserved oid", func(t *testing.T) {
+		err := json.Unmarshal([]byte(`{"email": 25}`), &TypeRegistry{})
+		assert.Error(t, err)
+	})
+
+	t.Run("duplicate oid", func(t *testing.T) {
+		err := json.Unmarshal([]byte(`{"email": 10000, "eur": 10000}`), &TypeRegistry{})
+		assert.Error(t, err)
+	})
+
+	t.Run("gaps", func(t *testing.T) {
+		registry := &TypeRegistry{}
+		require.NoError(t, json.Unmarshal([]byte(`{"email": 10000, "eur": 10005}`), registry))
+		assert.Equal(t, oid.Oid(10006), registry.AutoAssign("usd"))
+	})
+}
@@ -0,0 +1,322 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"go.uber.org/zap"
+)
+
+// ErrReplicationUnsupported is returned whenever a client requests a
+// replication connection while no replication handler has been configured.
+var ErrReplicationUnsupported = errors.New("replication connections are not supported")
+
+// NewErrReplicationUnsupported constructs a new fatal error wrapping the
+// ErrReplicationUnsupported type including the feature not supported error
+// code.
+func NewErrReplicationUnsupported() error {
+	err := psqlerr.WithCode(ErrReplicationUnsupported, codes.FeatureNotSupported)
+	return psqlerr.WithSeverity(err, psqlerr.LevelFatal)
+}
+
+// ReplicationMode represents the replication mode requested by the client
+// through the replication startup parameter.
+type ReplicationMode int
+
+const (
+	// ReplicationNone indicates that no replication connection is requested.
+	ReplicationNone ReplicationMode = iota
+	// ReplicationPhysical indicates that a physical replication connection
+	// is requested (replication=true).
+	ReplicationPhysical
+	// ReplicationLogical indicates that a logical replication connection is
+	// requested (replication=database).
+	ReplicationLogical
+)
+
+// GetReplicationMode returns the replication mode requested by the client
+// through the replication startup parameter.
+func GetReplicationMode(ctx context.Context) ReplicationMode {
+	switch strings.ToLower(ClientParameters(ctx)[ParamReplication]) {
+	case "database":
+		return ReplicationLogical
+	case "true", "on", "yes", "1":
+		return ReplicationPhysical
+	default:
+		return ReplicationNone
+	}
+}
+
+// ReplicationHandlerFn represents a callback function called for each
+// replication command (ex: IDENTIFY_SYSTEM or START_REPLICATION) send by a
+// client which has requested a replication connection.
+type ReplicationHandlerFn func(ctx context.Context, command string, conn ReplicationConn) error
+
+// ReplicationHandler sets the handler of replication connections. Clients
+// setting the replication startup parameter are routed to the given handler
+// instead of the query handler once authenticated. Replication connections are
+// rejected whenever no replication handler has been configured.
+// https://www.postgresql.org/docs/current/protocol-replication.html
+func ReplicationHandler(fn ReplicationHandlerFn) OptionFn {
+	return func(srv *Server) error {
+		srv.Replication = fn
+		return nil
+	}
+}
+
+// LSN represents a position inside the write-ahead log.
+type LSN uint64
+
+// String returns the LSN formatted as used by Postgres (ex: 16/B374D848).
+func (lsn LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(lsn>>32), uint32(lsn))
+}
+
+// StandbyStatus represents a standby status update send by the client
+// reporting its replication progress.
+type StandbyStatus struct {
+	WALWrite       LSN
+	WALFlush       LSN
+	WALApply       LSN
+	ClientTime     time.Time
+	ReplyRequested bool
+}
+
+// ReplicationConn represents a replication connection. Results of replication
+// commands such as IDENTIFY_SYSTEM could be written using the embedded data
+// writer. WAL data is streamed to the client once the replication stream has
+// been started.
+type ReplicationConn interface {
+	DataWriter
+
+	// StartReplication announces to the client that the server starts
+	// streaming WAL data. WAL data and keepalive messages could be written
+	// once the stream has been started.
+	StartReplication() error
+
+	// WriteXLogData writes the given WAL data starting at the given position
+	// to the client. The end position represents the current end of the WAL
+	// on the server.
+	WriteXLogData(start LSN, end LSN, data []byte) error
+
+	// WriteKeepalive writes a keepalive message containing the current end of
+	// the WAL on the server. The client is requested to reply with a standby
+	// status update whenever reply is set.
+	WriteKeepalive(end LSN, reply bool) error
+
+	// ReadStandbyStatus reads the next standby status update send by the
+	// client. Hot standby feedback messages are ignored. io.EOF is returned
+	// once the client has ended the replication stream, the replication
+	// stream is ended by the server as well in which case the command should
+	// be completed.
+	ReadStandbyStatus() (StandbyStatus, error)
+}
+
+// replicationEpoch represents the epoch of the timestamps send inside
+// replication messages.
+var replicationEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// replicationConn is the implementation of the ReplicationConn interface
+// writing replication messages to the client.
+type replicationConn struct {
+	*dataWriter
+	reader    *buffer.Reader
+	client    *buffer.Writer
+	streaming bool
+	now       func() time.Time
+}
+
+func (conn *replicationConn) StartReplication() error {
+	if conn.streaming {
+		return errors.New("replication stream has already been started")
+	}
+
+	conn.client.Start(types.ServerCopyBothResponse)
+	conn.client.AddByte(byte(TextCopyFormat))
+	conn.client.AddInt16(0)
+
+	err := conn.client.End()
+	if err != nil {
+		return err
+	}
+
+	conn.streaming = true
+	return nil
+}
+
+func (conn *replicationConn) WriteXLogData(start LSN, end LSN, data []byte) error {
+	if !conn.streaming {
+		return errors.New("replication stream has not been started")
+	}
+
+	conn.client.Start(types.ServerCopyData)
+	conn.client.AddByte('w')
+	conn.client.AddInt64(int64(start))
+	conn.client.AddInt64(int64(end))
+	conn.client.AddInt64(replicationTimestamp(conn.now()))
+	conn.client.AddBytes(data)
+	return conn.client.End()
+}
+
+func (conn *replicationConn) WriteKeepalive(end LSN, reply bool) error {
+	if !conn.streaming {
+		return errors.New("replication stream has not been started")
+	}
+
+	conn.client.Start(types.ServerCopyData)
+	conn.client.AddByte('k')
+	conn.client.AddInt64(int64(end))
+	conn.client.AddInt64(replicationTimestamp(conn.now()))
+	if reply {
+		conn.client.AddByte(1)
+	} else {
+		conn.client.AddByte(0)
+	}
+
+	return conn.client.End()
+}
+
+func (conn *replicationConn) ReadStandbyStatus() (StandbyStatus, error) {
+	if !conn.streaming {
+		return StandbyStatus{}, errors.New("replication stream has not been started")
+	}
+
+	for {
+		t, _, err := conn.reader.ReadTypedMsg()
+		if err != nil {
+			return StandbyStatus{}, err
+		}
+
+		switch t {
+		case types.ClientCopyData:
+		case types.ClientCopyDone:
+			conn.streaming = false
+
+			conn.client.Start(types.ServerCopyDone)
+			err = conn.client.End()
+			if err != nil {
+				return StandbyStatus{}, err
+			}
+
+			return StandbyStatus{}, io.EOF
+		default:
+			err := fmt.Errorf("unexpected message type %q during replication", t)
+			return StandbyStatus{}, psqlerr.WithCode(err, codes.ProtocolViolation)
+		}
+
+		// NOTE: hot standby feedback messages are ignored
+		if len(conn.reader.Msg) == 0 || conn.reader.Msg[0] != 'r' {
+			continue
+		}
+
+		conn.reader.Msg = conn.reader.Msg[1:]
+		return readStandbyStatus(conn.reader)
+	}
+}
+
+// readStandbyStatus reads the standby status update inside the given reader.
+// https://www.postgresql.org/docs/current/protocol-replication.html
+func readStandbyStatus(reader *buffer.Reader) (status StandbyStatus, err error) {
+	positions := []*LSN{&status.WALWrite, &status.WALFlush, &status.WALApply}
+	for _, position := range positions {
+		value, err := reader.GetUint64()
+		if err != nil {
+			return status, err
+		}
+
+		*position = LSN(value)
+	}
+
+	timestamp, err := reader.GetUint64()
+	if err != nil {
+		return status, err
+	}
+
+	reply, err := reader.GetBytes(1)
+	if err != nil {
+		return status, err
+	}
+
+	status.ClientTime = replicationEpoch.Add(time.Duration(int64(timestamp)) * time.Microsecond)
+	status.ReplyRequested = reply[0] == 1
+	return status, nil
+}
+
+// replicationTimestamp returns the given time as the number of microseconds
+// since the replication epoch.
+func replicationTimestamp(t time.Time) int64 {
+	return t.Sub(replicationEpoch).Microseconds()
+}
+
+// consumeReplicationCommands consumes the replication commands send by the
+// client and passes them to the replication handler until the connection has
+// been terminated.
+func (srv *Server) consumeReplicationCommands(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) error {
+	srv.logger.Debug("ready for replication commands")
+
+	err := readyForQuery(writer, types.ServerIdle)
+	if err != nil {
+		return err
+	}
+
+	for {
+		t, _, err := reader.ReadTypedMsg()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch t {
+		case types.ClientSimpleQuery:
+			command, err := reader.GetString()
+			if err != nil {
+				return err
+			}
+
+			srv.logger.Debug("incoming replication command", zap.String("command", command))
+
+			conn := &replicationConn{
+				dataWriter: newDataWriter(ctx, reader, writer),
+				reader:     reader,
+				client:     writer,
+				now:        time.Now,
+			}
+
+			err = srv.panicSafe(func() error {
+				return srv.Replication(ctx, command, conn)
+			})
+
+			if err != nil {
+				err = ErrorCode(writer, err)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			err = readyForQuery(writer, types.ServerIdle)
+			if err != nil {
+				return err
+			}
+		case types.ClientTerminate:
+			return nil
+		default:
+			err = ErrorCode(writer, NewErrUnimplementedMessageType(t))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
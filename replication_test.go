@@ -0,0 +1,163 @@
+package wire
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	t.Parallel()
+
+	statuses := make(chan StandbyStatus, 1)
+
+	handler := func(ctx context.Context, command string, conn ReplicationConn) error {
+		switch command {
+		case "IDENTIFY_SYSTEM":
+			conn.Define(Columns{ //nolint:errcheck
+				{Name: "systemid", Oid: oid.T_text, Format: TextFormat},
+				{Name: "timeline", Oid: oid.T_int4, Format: TextFormat},
+				{Name: "xlogpos", Oid: oid.T_text, Format: TextFormat},
+				{Name: "dbname", Oid: oid.T_text, Format: TextFormat},
+			})
+
+			conn.Row([]any{"7000000000000000000", 1, LSN(0x16B374D848).String(), ClientParameters(ctx)[ParamDatabase]}) //nolint:errcheck
+			return conn.Complete("IDENTIFY_SYSTEM")
+		case "START_REPLICATION SLOT wire LOGICAL 0/0":
+			err := conn.StartReplication()
+			if err != nil {
+				return err
+			}
+
+			err = conn.WriteXLogData(LSN(1), LSN(5), []byte("data"))
+			if err != nil {
+				return err
+			}
+
+			for {
+				status, err := conn.ReadStandbyStatus()
+				if err == io.EOF {
+					return conn.Complete("START_STREAMING")
+				}
+
+				if err != nil {
+					return err
+				}
+
+				statuses <- status
+			}
+		default:
+			return fmt.Errorf("unexpected replication command %q", command)
+		}
+	}
+
+	server, err := NewServer(ReplicationHandler(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d/wire?replication=database", address.IP, address.Port)
+	conn, err := pgconn.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	t.Run("identify system", func(t *testing.T) {
+		results, err := conn.Exec(ctx, "IDENTIFY_SYSTEM").ReadAll()
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		require.Len(t, results[0].Rows, 1)
+
+		row := results[0].Rows[0]
+		assert.Equal(t, "16/B374D848", string(row[2]))
+		assert.Equal(t, "wire", string(row[3]))
+	})
+
+	t.Run("start replication", func(t *testing.T) {
+		frontend := conn.Frontend()
+		frontend.Send(&pgproto3.Query{String: "START_REPLICATION SLOT wire LOGICAL 0/0"})
+		require.NoError(t, frontend.Flush())
+
+		msg, err := conn.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		require.IsType(t, &pgproto3.CopyBothResponse{}, msg)
+
+		msg, err = conn.ReceiveMessage(ctx)
+		require.NoError(t, err)
+		data, ok := msg.(*pgproto3.CopyData)
+		require.True(t, ok)
+
+		require.Equal(t, byte('w'), data.Data[0])
+		assert.Equal(t, uint64(1), binary.BigEndian.Uint64(data.Data[1:]))
+		assert.Equal(t, uint64(5), binary.BigEndian.Uint64(data.Data[9:]))
+		assert.Equal(t, "data", string(data.Data[25:]))
+
+		update := []byte{'r'}
+		for _, position := range []uint64{5, 4, 3} {
+			update = binary.BigEndian.AppendUint64(update, position)
+		}
+
+		update = binary.BigEndian.AppendUint64(update, uint64(replicationTimestamp(time.Now())))
+		update = append(update, 1)
+
+		// NOTE: pending messages are written to the server once the next
+		// message is received.
+		frontend.Send(&pgproto3.CopyData{Data: update})
+		frontend.Send(&pgproto3.CopyDone{})
+		require.NoError(t, frontend.Flush())
+
+		expected := []pgproto3.BackendMessage{
+			&pgproto3.CopyDone{},
+			&pgproto3.CommandComplete{CommandTag: []byte("START_STREAMING")},
+			&pgproto3.ReadyForQuery{TxStatus: 'I'},
+		}
+
+		for _, message := range expected {
+			msg, err = conn.ReceiveMessage(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, message, msg)
+		}
+
+		status := <-statuses
+		assert.Equal(t, LSN(5), status.WALWrite)
+		assert.Equal(t, LSN(4), status.WALFlush)
+		assert.Equal(t, LSN(3), status.WALApply)
+		assert.True(t, status.ReplyRequested)
+	})
+}
+
+func TestReplicationUnsupported(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?replication=database", address.IP, address.Port)
+	_, err = pgconn.Connect(ctx, connstr)
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.FeatureNotSupported), pgerr.Code)
+}
+
+func TestLSN(t *testing.T) {
+	assert.Equal(t, "0/0", LSN(0).String())
+	assert.Equal(t, "16/B374D848", LSN(0x16B374D848).String())
+}
@@ -0,0 +1,107 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"go.uber.org/zap"
+)
+
+// ConnectionRouterFn resolves the upstream connection for the given user and
+// database pair. The returned connection is expected to be a Postgres server
+// connection which has not yet received a startup message.
+type ConnectionRouterFn func(user, database string) (net.Conn, error)
+
+// ConnectionRouter sets the given connection router. Once the client startup
+// message has been read is the client connection routed to the resolved
+// upstream connection. The client startup message is forwarded to the
+// upstream after which all protocol messages are forwarded raw between the
+// client and upstream connection. Authentication and query handling is left
+// to the upstream server.
+func ConnectionRouter(fn ConnectionRouterFn) OptionFn {
+	return func(srv *Server) error {
+		srv.Router = fn
+		return nil
+	}
+}
+
+// routeConn resolves the upstream connection for the given client connection
+// and forwards all messages between both connections until either of them
+// has been closed.
+func (srv *Server) routeConn(ctx context.Context, conn net.Conn, reader *buffer.Reader, writer *buffer.Writer) error {
+	params := ClientParameters(ctx)
+	user := params[ParamUsername]
+	database := params[ParamDatabase]
+	if database == "" {
+		database = user
+	}
+
+	srv.logger.Debug("routing client connection", zap.String("user", user), zap.String("database", database))
+
+	upstream, err := srv.Router(user, database)
+	if err != nil {
+		err = psqlerr.WithSeverity(psqlerr.WithCode(err, codes.ConnectionFailure), psqlerr.LevelFatal)
+		return writeErrorResponse(writer, err)
+	}
+
+	defer upstream.Close()
+
+	_, err = upstream.Write(startupMessage(params))
+	if err != nil {
+		return err
+	}
+
+	// NOTE: the client reader could already contain buffered data which is
+	// why the client messages are read from the reader buffer instead of
+	// the client connection.
+	errs := make(chan error, 2)
+	forward := func(dst net.Conn, src io.Reader) {
+		_, err := io.Copy(dst, src)
+		errs <- err
+	}
+
+	go forward(upstream, reader.Buffer)
+	go forward(conn, upstream)
+
+	err = <-errs
+
+	// NOTE: closing both connections unblocks the remaining forwarder
+	conn.Close()
+	upstream.Close()
+	<-errs
+
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// startupMessage encodes a protocol version 3.0 startup message containing the
+// given client parameters.
+// https://www.postgresql.org/docs/current/protocol-message-formats.html
+func startupMessage(params Parameters) []byte {
+	body := &bytes.Buffer{}
+	binary.Write(body, binary.BigEndian, uint32(types.Version30)) //nolint:errcheck
+
+	for key, value := range params {
+		body.WriteString(string(key))
+		body.WriteByte(0)
+		body.WriteString(value)
+		body.WriteByte(0)
+	}
+
+	body.WriteByte(0)
+
+	message := make([]byte, 4, 4+body.Len())
+	binary.BigEndian.PutUint32(message, uint32(4+body.Len()))
+	return append(message, body.Bytes()...)
+}
@@ -0,0 +1,71 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionRouter(t *testing.T) {
+	t.Parallel()
+
+	upstream := func(tag string) *net.TCPAddr {
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			return writer.Complete(tag)
+		}
+
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+
+		return TListenAndServe(t, server)
+	}
+
+	upstreams := map[string]*net.TCPAddr{
+		"tenant_a": upstream("TENANT A"),
+		"tenant_b": upstream("TENANT B"),
+	}
+
+	router := func(user, database string) (net.Conn, error) {
+		address, has := upstreams[database]
+		if !has {
+			return nil, errors.New("unknown database")
+		}
+
+		return net.Dial("tcp", address.String())
+	}
+
+	server, err := NewServer(ConnectionRouter(router))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	for database, expected := range map[string]string{"tenant_a": "TENANT A", "tenant_b": "TENANT B"} {
+		database, expected := database, expected
+
+		t.Run(database, func(t *testing.T) {
+			ctx := context.Background()
+			connstr := fmt.Sprintf("postgres://postgres@%s:%d/%s", address.IP, address.Port, database)
+			conn, err := pgx.Connect(ctx, connstr)
+			require.NoError(t, err)
+
+			defer conn.Close(ctx)
+
+			tag, err := conn.Exec(ctx, "SELECT 1;")
+			require.NoError(t, err)
+			assert.Equal(t, expected, tag.String())
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://postgres@%s:%d/unknown", address.IP, address.Port)
+		_, err := pgx.Connect(ctx, connstr)
+		require.Error(t, err)
+	})
+}
@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/jackc/pgtype"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
 	"github.com/lib/pq/oid"
@@ -51,6 +54,120 @@ func (columns Columns) Write(ctx context.Context, writer *buffer.Writer, srcs []
 	return writer.End()
 }
 
+// validate validates the given column values against the column definitions
+// without writing them. Each value is encoded to ensure that the value could
+// be converted into the column type.
+func (columns Columns) validate(ctx context.Context, srcs []any) error {
+	if len(srcs) != len(columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given table but %d were given", len(columns), len(srcs))
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	for index, column := range columns {
+		encoder := columnEncoder{column: column}
+		_, null, err := encoder.encode(ctx, srcs[index])
+		if err != nil {
+			err = fmt.Errorf("invalid value for column %q: %w", column.Name, err)
+			return psqlerr.WithCode(err, codes.DatatypeMismatch)
+		}
+
+		if null && column.NotNull {
+			return NewErrNotNullViolation(column.Name)
+		}
+	}
+
+	return nil
+}
+
+// encoders returns a new column encoder for each of the columns. The returned
+// encoders could be reused to write multiple rows.
+func (columns Columns) encoders() []*columnEncoder {
+	encoders := make([]*columnEncoder, len(columns))
+	for index, column := range columns {
+		encoders[index] = &columnEncoder{column: column}
+	}
+
+	return encoders
+}
+
+// writeRow writes the given column values as a single data row using the
+// given column encoders.
+func (columns Columns) writeRow(ctx context.Context, writer *buffer.Writer, encoders []*columnEncoder, srcs []any) (err error) {
+	if len(srcs) != len(columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given table but %d were given", len(columns), len(srcs))
+	}
+
+	writer.Start(types.ServerDataRow)
+	writer.AddInt16(int16(len(columns)))
+
+	for index, encoder := range encoders {
+		err = encoder.write(ctx, writer, srcs[index])
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.End()
+}
+
+// Filter returns a new collection containing only the columns for which the
+// given predicate returns true. The order of the columns is preserved.
+func (columns Columns) Filter(pred func(Column) bool) Columns {
+	result := make(Columns, 0, len(columns))
+	for _, column := range columns {
+		if pred(column) {
+			result = append(result, column)
+		}
+	}
+
+	return result
+}
+
+// Rename returns a new collection in which all columns with the given old
+// name have been renamed to the given new name. The original collection is
+// left untouched.
+func (columns Columns) Rename(oldName, newName string) Columns {
+	result := make(Columns, len(columns))
+	copy(result, columns)
+
+	for index := range result {
+		if result[index].Name == oldName {
+			result[index].Name = newName
+		}
+	}
+
+	return result
+}
+
+// WithTable returns a new collection in which the table id of all columns
+// has been set to the given table id. The original collection is left
+// untouched.
+func (columns Columns) WithTable(id int32) Columns {
+	result := make(Columns, len(columns))
+	copy(result, columns)
+
+	for index := range result {
+		result[index].Table = id
+	}
+
+	return result
+}
+
+// Map returns a new collection containing the result of applying the given
+// function to every column. The original collection is left untouched.
+func (columns Columns) Map(fn func(Column) Column) Columns {
+	result := make(Columns, len(columns))
+	for index, column := range columns {
+		result[index] = fn(column)
+	}
+
+	return result
+}
+
 // Column represents a table column and its attributes such as name, type and
 // encode formatter.
 // https://www.postgresql.org/docs/8.3/catalog-pg-attribute.html
@@ -60,8 +177,66 @@ type Column struct {
 	AttrNo       int16  // column attribute no (optional)
 	Oid          oid.Oid
 	Width        int16
-	TypeModifier int32
+	TypeModifier *int32 // type modifier (see pg_attribute.atttypmod), nil when unset
 	Format       FormatCode
+	NotNull      bool // rejects NULL values when validated using DataWriter.Peek
+	hook         ColumnWriteHook
+}
+
+// VarcharTypeModifier returns the type modifier of a varchar or bpchar column
+// with the given maximum length.
+func VarcharTypeModifier(length int32) *int32 {
+	return TypeModifier(length + typeModifierHeader)
+}
+
+// NumericTypeModifier returns the type modifier of a numeric column with the
+// given precision and scale.
+func NumericTypeModifier(precision, scale int32) *int32 {
+	return TypeModifier((precision<<16 | scale&0xffff) + typeModifierHeader)
+}
+
+// PrecisionTypeModifier returns the type modifier of a time, timestamp or
+// interval column with the given fractional seconds precision. A precision of
+// zero is a valid type modifier.
+func PrecisionTypeModifier(precision int32) *int32 {
+	return TypeModifier(precision)
+}
+
+// TypeModifier returns the given raw type modifier value which could be
+// assigned to a column.
+func TypeModifier(value int32) *int32 {
+	return &value
+}
+
+// typeModifierHeader represents the header size (VARHDRSZ) included inside
+// the type modifiers of variable length types.
+const typeModifierHeader = 4
+
+// ColumnWriteHook transforms the given column value before it is encoded. The
+// returned value is encoded instead of the given value.
+type ColumnWriteHook func(src any) (any, error)
+
+// WithWriteHook returns a copy of the column in which all values are passed
+// to the given hook before being encoded. This could be used to mask,
+// encrypt or normalize column values without changing the handler logic.
+// Hooks are applied in the order in which they have been added.
+func (column Column) WithWriteHook(fn ColumnWriteHook) Column {
+	previous := column.hook
+	if previous == nil {
+		column.hook = fn
+		return column
+	}
+
+	column.hook = func(src any) (any, error) {
+		src, err := previous(src)
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(src)
+	}
+
+	return column
 }
 
 // Define writes the column header values to the given writer.
@@ -74,48 +249,45 @@ func (column Column) Define(ctx context.Context, writer *buffer.Writer) {
 	writer.AddInt16(column.AttrNo)
 	writer.AddInt32(int32(column.Oid))
 	writer.AddInt16(column.Width)
-	// TODO: Support type for type modifiers
-	//
-	// Some types could be overridden using the type modifier field within a RowDescription.
-	// Type modifier (see pg_attribute.atttypmod). The meaning of the
-	// modifier is type-specific.
-	// Atttypmod records type-specific data supplied at table creation time (for
-	// example, the maximum length of a varchar column). It is passed to
-	// type-specific input functions and length coercion functions. The value
-	// will generally be -1 for types that do not need atttypmod.
-	//
-	// https://www.postgresql.org/docs/current/protocol-message-formats.html
-	// https://www.postgresql.org/docs/current/catalog-pg-attribute.html
-
-	writer.AddInt32(-1)
+
+	writer.AddInt32(column.typeModifier())
 	writer.AddInt16(int16(column.Format))
 }
 
+// typeModifier returns the type modifier written to the client. The type
+// modifier records type-specific data such as the maximum length of a varchar
+// column. The value is -1 for types that do not need a modifier.
+// https://www.postgresql.org/docs/current/catalog-pg-attribute.html
+func (column Column) typeModifier() int32 {
+	if column.TypeModifier == nil {
+		return -1
+	}
+
+	return *column.TypeModifier
+}
+
 // Write encodes the given source value using the column type definition and connection
 // info. The encoded byte buffer is added to the given write buffer. This method
 // Is used to encode values and return them inside a DataRow message.
 func (column Column) Write(ctx context.Context, writer *buffer.Writer, src any) (err error) {
-	if ctx.Err() != nil {
-		return ctx.Err()
-	}
-
-	ci := TypeInfo(ctx)
-	if ci == nil {
-		return errors.New("postgres connection info has not been defined inside the given context")
-	}
-
-	typed, has := ci.DataTypeForOID(uint32(column.Oid))
-	if !has {
-		return fmt.Errorf("unknown data type: %T", column)
-	}
+	encoder := columnEncoder{column: column}
+	return encoder.write(ctx, writer, src)
+}
 
-	err = typed.Value.Set(src)
-	if err != nil {
-		return err
-	}
+// columnEncoder encodes the values of a single column. The type value and
+// encode buffer are reused between values allowing multiple rows to be
+// encoded without allocating a new type value for each row.
+type columnEncoder struct {
+	column Column
+	ci     *pgtype.ConnInfo
+	typed  pgtype.DataType
+	buf    []byte
+	// element encodes the elements of array columns
+	element *arrayElement
+}
 
-	encoder := column.Format.Encoder(typed)
-	bb, err := encoder(ci, nil)
+func (encoder *columnEncoder) write(ctx context.Context, writer *buffer.Writer, src any) error {
+	bb, null, err := encoder.encode(ctx, src)
 	if err != nil {
 		return err
 	}
@@ -124,7 +296,7 @@ func (column Column) Write(ctx context.Context, writer *buffer.Writer, src any)
 	// not include itself). Can be zero. As a special case, -1 indicates a NULL
 	// column value. No value bytes follow in the NULL case.
 	length := int32(len(bb))
-	if src == nil {
+	if null {
 		length = -1
 	}
 
@@ -133,3 +305,75 @@ func (column Column) Write(ctx context.Context, writer *buffer.Writer, src any)
 
 	return nil
 }
+
+// encode encodes the given source value using the column type definition and
+// format. A boolean is returned indicating whether the value is NULL. The
+// returned buffer is only valid until the next value is encoded.
+func (encoder *columnEncoder) encode(ctx context.Context, src any) (_ []byte, null bool, err error) {
+	if ctx.Err() != nil {
+		return nil, false, ctx.Err()
+	}
+
+	column := encoder.column
+	if column.hook != nil {
+		src, err = column.hook(src)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// NOTE: Go slices and arrays written to array columns are encoded element
+	// by element. pgtype array values are encoded using their own encoders.
+	if element, has := arrayElements[column.Oid]; has {
+		if value, ok := arraySource(src); ok {
+			return encoder.encodeArray(ctx, element, value)
+		}
+
+		switch src.(type) {
+		case pgtype.TextEncoder, pgtype.BinaryEncoder:
+			return encoder.encodeValue(ctx, src)
+		}
+	}
+
+	if encoder.ci == nil {
+		ci := TypeInfo(ctx)
+		if ci == nil {
+			return nil, false, errors.New("postgres connection info has not been defined inside the given context")
+		}
+
+		typed, has := ci.DataTypeForOID(uint32(column.Oid))
+		if !has {
+			return nil, false, fmt.Errorf("unknown data type: %d", column.Oid)
+		}
+
+		// NOTE: the connection info (and its type values) is shared between all
+		// connections. A new value is constructed to allow rows to be written
+		// concurrently.
+		encoder.ci = ci
+		encoder.typed = pgtype.DataType{Value: pgtype.NewValue(typed.Value), Name: typed.Name, OID: typed.OID}
+
+		// NOTE: NULL values are encoded as a nil buffer. A non nil buffer is
+		// used to distinguish empty values from NULL values.
+		encoder.buf = make([]byte, 0, 64)
+	}
+
+	err = encoder.typed.Value.Set(src)
+	if err != nil {
+		return nil, false, err
+	}
+
+	bb, err := column.Format.Encoder(&encoder.typed)(encoder.ci, encoder.buf[:0])
+	if err != nil {
+		return nil, false, err
+	}
+
+	// NOTE: typed nil values (ex: a nil byte slice) are encoded as NULL.
+	if bb == nil {
+		return nil, true, nil
+	}
+
+	// NOTE: the encoded value is copied into the write buffer, the encode
+	// buffer could therefore be reused for the next value.
+	encoder.buf = bb
+	return bb, false, nil
+}
@@ -0,0 +1,189 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnsFilter(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4},
+		{Name: "_created_by", Oid: oid.T_text},
+		{Name: "name", Oid: oid.T_text},
+		{Name: "_audit", Oid: oid.T_jsonb},
+	}
+
+	t.Run("oid", func(t *testing.T) {
+		result := columns.Filter(func(column Column) bool {
+			return column.Oid == oid.T_text
+		})
+
+		assert.Equal(t, Columns{columns[1], columns[2]}, result)
+	})
+
+	t.Run("name", func(t *testing.T) {
+		result := columns.Filter(func(column Column) bool {
+			return column.Name[0] != '_'
+		})
+
+		assert.Equal(t, Columns{columns[0], columns[2]}, result)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		result := columns.Filter(func(Column) bool { return false })
+		assert.Empty(t, result)
+		assert.Len(t, columns, 4)
+	})
+}
+
+func TestColumnsRename(t *testing.T) {
+	columns := Columns{
+		{Name: "internal_id", Oid: oid.T_int4},
+		{Name: "name", Oid: oid.T_text},
+	}
+
+	result := columns.Rename("internal_id", "id")
+	assert.Equal(t, Columns{{Name: "id", Oid: oid.T_int4}, columns[1]}, result)
+	assert.Equal(t, "internal_id", columns[0].Name)
+
+	t.Run("unknown", func(t *testing.T) {
+		result := columns.Rename("unknown", "id")
+		assert.Equal(t, columns, result)
+	})
+}
+
+func TestColumnsWithTable(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4},
+		{Name: "name", Oid: oid.T_text, Table: 1},
+		{Name: "email", Oid: oid.T_text},
+	}
+
+	result := columns.WithTable(16384)
+	assert.Len(t, result, 3)
+
+	for index, column := range result {
+		assert.Equal(t, int32(16384), column.Table)
+		assert.Equal(t, columns[index].Name, column.Name)
+	}
+
+	assert.Equal(t, int32(0), columns[0].Table)
+	assert.Equal(t, int32(1), columns[1].Table)
+}
+
+func TestColumnsMap(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4},
+		{Name: "name", Oid: oid.T_text},
+	}
+
+	result := columns.Map(func(column Column) Column {
+		column.Name = "col_" + column.Name
+		return column
+	})
+
+	assert.Equal(t, "col_id", result[0].Name)
+	assert.Equal(t, "col_name", result[1].Name)
+	assert.Equal(t, oid.T_text, result[1].Oid)
+
+	assert.Equal(t, "id", columns[0].Name)
+	assert.Equal(t, "name", columns[1].Name)
+}
+
+func TestColumnWriteHook(t *testing.T) {
+	mask := func(src any) (any, error) {
+		email, ok := src.(string)
+		if !ok {
+			return nil, errors.New("unexpected email value")
+		}
+
+		local, domain, _ := strings.Cut(email, "@")
+		return local[:1] + "***@" + domain, nil
+	}
+
+	columns := Columns{
+		{Name: "name", Oid: oid.T_text, Format: TextFormat},
+		Column{Name: "email", Oid: oid.T_text, Format: TextFormat}.WithWriteHook(mask),
+	}
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	sink := bytes.NewBuffer([]byte{})
+	err := columns.Write(ctx, buffer.NewWriter(sink), []any{"John", "john@example.com"})
+	require.NoError(t, err)
+
+	reader := buffer.NewReader(sink, buffer.DefaultBufferSize)
+	ty, _, err := reader.ReadTypedMsg()
+	require.NoError(t, err)
+	assert.Equal(t, types.ClientMessage(types.ServerDataRow), ty)
+
+	count, err := reader.GetUint16()
+	require.NoError(t, err)
+
+	values := make([]string, count)
+	for index := range values {
+		length, err := reader.GetUint32()
+		require.NoError(t, err)
+
+		value, err := reader.GetBytes(int(length))
+		require.NoError(t, err)
+		values[index] = string(value)
+	}
+
+	assert.Equal(t, []string{"John", "j***@example.com"}, values)
+
+	t.Run("error", func(t *testing.T) {
+		err := columns.Write(ctx, buffer.NewWriter(sink), []any{"John", 42})
+		assert.Error(t, err)
+	})
+}
+
+func TestColumnTypeModifier(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "name", Oid: oid.T_varchar, TypeModifier: VarcharTypeModifier(32), Format: TextFormat},
+		{Name: "price", Oid: oid.T_numeric, TypeModifier: NumericTypeModifier(10, 2), Format: TextFormat},
+		{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+		{Name: "created", Oid: oid.T_timestamp, TypeModifier: PrecisionTypeModifier(0), Format: TextFormat},
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(columns) //nolint:errcheck
+		return writer.Complete("SELECT 0")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT name, price, id, created FROM products;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+
+	fields := rows.FieldDescriptions()
+	rows.Close()
+	require.NoError(t, rows.Err())
+
+	require.Len(t, fields, 4)
+	assert.Equal(t, int32(36), fields[0].TypeModifier)
+	assert.Equal(t, int32(10<<16|2+4), fields[1].TypeModifier)
+	assert.Equal(t, int32(-1), fields[2].TypeModifier)
+	assert.Equal(t, int32(0), fields[3].TypeModifier)
+}
@@ -0,0 +1,454 @@
+package wire
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	pgerror "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+const (
+	// scramSHA256 is the name of the SCRAM-SHA-256 SASL mechanism.
+	scramSHA256 = "SCRAM-SHA-256"
+	// scramSHA256Plus is the name of the SCRAM-SHA-256 SASL mechanism using
+	// channel binding.
+	scramSHA256Plus = "SCRAM-SHA-256-PLUS"
+	// scramChannelBinding is the only supported channel binding type.
+	scramChannelBinding = "tls-server-end-point"
+	// scramIterations is the number of iterations used to construct new
+	// verifiers. This matches the Postgres default (scram_iterations).
+	scramIterations = 4096
+	// scramSaltSize is the size of the salt used to construct new verifiers.
+	scramSaltSize = 16
+	// scramNonceSize is the size of the random server nonce.
+	scramNonceSize = 18
+)
+
+// ScramSHA256 announces to the client to authenticate using SCRAM-SHA-256
+// (RFC 5802 and RFC 7677) and validates the proof send by the client. The
+// given function should return the stored verifier of the given username
+// (see NewScramSHA256Verifier). Verifiers are formatted as stored by Postgres
+// inside pg_authid: SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>.
+// SCRAM-SHA-256-PLUS is announced as well for TLS connections, clients
+// selecting it bind the authentication to the TLS connection using the
+// tls-server-end-point channel binding type (RFC 5929).
+func ScramSHA256(verifier func(username string) (string, error)) AuthStrategy {
+	return func(ctx context.Context, writer *buffer.Writer, reader *buffer.Reader) (err error) {
+		username := ClientParameters(ctx)[ParamUsername]
+		binding := getChannelBinding(ctx)
+
+		mechanisms := []string{scramSHA256}
+		if binding != nil {
+			mechanisms = []string{scramSHA256Plus, scramSHA256}
+		}
+
+		writer.Start(types.ServerAuth)
+		writer.AddInt32(int32(authSASL))
+		for _, mechanism := range mechanisms {
+			writer.AddString(mechanism)
+			writer.AddNullTerminate()
+		}
+		writer.AddNullTerminate()
+		err = writer.End()
+		if err != nil {
+			return err
+		}
+
+		mechanism, clientFirst, err := readSASLInitialResponse(reader)
+		if err != nil {
+			return err
+		}
+
+		if mechanism != scramSHA256 && (mechanism != scramSHA256Plus || binding == nil) {
+			return fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+		}
+
+		// NOTE: the username inside the message is ignored, the username
+		// send inside the startup message is used instead.
+		header, clientFirstBare, err := scramGS2Header(clientFirst, mechanism, binding)
+		if err != nil {
+			return err
+		}
+
+		clientNonce := scramAttributes(clientFirstBare)["r"]
+		if clientNonce == "" {
+			return errors.New("missing client nonce in SCRAM client-first-message")
+		}
+
+		// NOTE: a mock exchange is performed for unknown users (or users
+		// without a SCRAM verifier) to prevent clients from enumerating users.
+		// The exchange fails once the client proof has been received.
+		secret, err := lookupScramSecret(verifier, username)
+		if err != nil {
+			secret = scramMockSecret(username)
+		}
+
+		nonce := make([]byte, scramNonceSize)
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return err
+		}
+
+		combined := clientNonce + base64.StdEncoding.EncodeToString(nonce)
+		serverFirst := fmt.Sprintf("r=%s,s=%s,i=%d", combined, base64.StdEncoding.EncodeToString(secret.salt), secret.iterations)
+
+		writer.Start(types.ServerAuth)
+		writer.AddInt32(int32(authSASLContinue))
+		writer.AddBytes([]byte(serverFirst))
+		err = writer.End()
+		if err != nil {
+			return err
+		}
+
+		clientFinal, err := readSASLResponse(reader)
+		if err != nil {
+			return err
+		}
+
+		index := strings.LastIndex(clientFinal, ",p=")
+		if index == -1 {
+			return errors.New("missing proof in SCRAM client-final-message")
+		}
+
+		withoutProof := clientFinal[:index]
+		attributes := scramAttributes(withoutProof)
+		if attributes["r"] != combined {
+			return errors.New("invalid SCRAM client-final-message")
+		}
+
+		// NOTE: the channel binding attribute contains the gs2 header followed
+		// by the channel binding data whenever channel binding is used.
+		cbind := []byte(header)
+		if mechanism == scramSHA256Plus {
+			cbind = append(cbind, binding...)
+		}
+
+		if attributes["c"] != base64.StdEncoding.EncodeToString(cbind) {
+			err = pgerror.WithCode(errors.New("SCRAM channel binding check failed"), codes.ProtocolViolation)
+			err = pgerror.WithSeverity(err, pgerror.LevelFatal)
+
+			werr := writeErrorResponse(writer, err)
+			if werr != nil {
+				return werr
+			}
+
+			return err
+		}
+
+		proof, err := base64.StdEncoding.DecodeString(clientFinal[index+3:])
+		if err != nil {
+			return err
+		}
+
+		message := []byte(clientFirstBare + "," + serverFirst + "," + withoutProof)
+		if secret.mock || !secret.verify(message, proof) {
+			err = pgerror.WithCode(fmt.Errorf("password authentication failed for user %q", username), codes.InvalidPassword)
+			err = pgerror.WithSeverity(err, pgerror.LevelFatal)
+
+			werr := writeErrorResponse(writer, err)
+			if werr != nil {
+				return werr
+			}
+
+			return err
+		}
+
+		writer.Start(types.ServerAuth)
+		writer.AddInt32(int32(authSASLFinal))
+		writer.AddBytes([]byte("v=" + base64.StdEncoding.EncodeToString(scramHMAC(secret.serverKey, message))))
+		err = writer.End()
+		if err != nil {
+			return err
+		}
+
+		return writeAuthType(writer, authOK)
+	}
+}
+
+// scramGS2Header splits the given client-first-message into the gs2 header
+// and the client-first-message-bare. The channel binding flag inside the
+// header is validated against the selected mechanism.
+func scramGS2Header(clientFirst string, mechanism string, binding []byte) (header string, bare string, err error) {
+	parts := strings.SplitN(clientFirst, ",", 3)
+	if len(parts) != 3 {
+		return "", "", errors.New("invalid SCRAM client-first-message")
+	}
+
+	flag := parts[0]
+	header = parts[0] + "," + parts[1] + ","
+
+	switch {
+	case flag == "n" && mechanism == scramSHA256:
+	case flag == "y" && mechanism == scramSHA256:
+		// NOTE: the client supports channel binding but thinks the server
+		// does not. This could indicate a downgrade attack whenever the
+		// server announced SCRAM-SHA-256-PLUS.
+		if binding != nil {
+			return "", "", errors.New("SCRAM channel binding negotiation failed")
+		}
+	case strings.HasPrefix(flag, "p=") && mechanism == scramSHA256Plus:
+		if flag[2:] != scramChannelBinding {
+			return "", "", fmt.Errorf("unsupported SCRAM channel binding type %q", flag[2:])
+		}
+	default:
+		return "", "", errors.New("unsupported SCRAM channel binding")
+	}
+
+	return header, parts[2], nil
+}
+
+// tlsServerEndPoint computes the tls-server-end-point channel binding data of
+// the given certificate as defined in RFC 5929. The certificate is hashed
+// using the hash function of its signature algorithm, SHA-256 is used for
+// certificates signed using MD5 or SHA-1.
+func tlsServerEndPoint(certificate *tls.Certificate) ([]byte, error) {
+	if certificate == nil || len(certificate.Certificate) == 0 {
+		return nil, errors.New("no server certificate available")
+	}
+
+	leaf := certificate.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(certificate.Certificate[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch leaf.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		sum := sha512.Sum384(leaf.Raw)
+		return sum[:], nil
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		sum := sha512.Sum512(leaf.Raw)
+		return sum[:], nil
+	default:
+		sum := sha256.Sum256(leaf.Raw)
+		return sum[:], nil
+	}
+}
+
+// NewScramSHA256Verifier constructs a new SCRAM-SHA-256 verifier for the given
+// password using a random salt. The returned verifier is formatted as stored
+// by Postgres and could be returned to the ScramSHA256 authentication
+// strategy.
+func NewScramSHA256Verifier(password string) (string, error) {
+	salt := make([]byte, scramSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	salted := scramHi([]byte(password), salt, scramIterations)
+	clientKey := scramHMAC(salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := scramHMAC(salted, []byte("Server Key"))
+
+	encoding := base64.StdEncoding
+	return fmt.Sprintf("%s$%d:%s$%s:%s", scramSHA256, scramIterations, encoding.EncodeToString(salt), encoding.EncodeToString(storedKey[:]), encoding.EncodeToString(serverKey)), nil
+}
+
+// scramSecret represents a parsed SCRAM-SHA-256 verifier.
+type scramSecret struct {
+	iterations int
+	salt       []byte
+	storedKey  []byte
+	serverKey  []byte
+	mock       bool
+}
+
+// scramMockNonce is used to derive the salts of mock secrets. The nonce is
+// generated once per process ensuring that the same salt is announced for
+// repeated attempts of the same unknown user.
+var scramMockNonce = func() []byte {
+	nonce := make([]byte, scramSaltSize)
+	_, _ = rand.Read(nonce)
+	return nonce
+}()
+
+// lookupScramSecret looks up and parses the verifier of the given username.
+func lookupScramSecret(verifier func(username string) (string, error), username string) (*scramSecret, error) {
+	stored, err := verifier(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseScramVerifier(stored)
+}
+
+// scramMockSecret constructs a mock secret for the given username which could
+// not be used to authenticate. The salt is derived from the username and
+// the default number of iterations is used, making the server-first-message
+// indistinguishable from the message of a known user.
+func scramMockSecret(username string) *scramSecret {
+	return &scramSecret{
+		iterations: scramIterations,
+		salt:       scramHMAC(scramMockNonce, []byte(username))[:scramSaltSize],
+		mock:       true,
+	}
+}
+
+// verify checks whether the given client proof is valid for the given
+// authentication message.
+func (secret *scramSecret) verify(message []byte, proof []byte) bool {
+	signature := scramHMAC(secret.storedKey, message)
+	if len(proof) != len(signature) {
+		return false
+	}
+
+	clientKey := make([]byte, len(proof))
+	for index := range proof {
+		clientKey[index] = proof[index] ^ signature[index]
+	}
+
+	stored := sha256.Sum256(clientKey)
+	return subtle.ConstantTimeCompare(stored[:], secret.storedKey) == 1
+}
+
+// parseScramVerifier parses the given SCRAM-SHA-256 verifier.
+func parseScramVerifier(verifier string) (*scramSecret, error) {
+	invalid := errors.New("invalid SCRAM-SHA-256 verifier")
+
+	parts := strings.Split(verifier, "$")
+	if len(parts) != 3 || parts[0] != scramSHA256 {
+		return nil, invalid
+	}
+
+	iterations, salt, has := strings.Cut(parts[1], ":")
+	if !has {
+		return nil, invalid
+	}
+
+	storedKey, serverKey, has := strings.Cut(parts[2], ":")
+	if !has {
+		return nil, invalid
+	}
+
+	secret := &scramSecret{}
+	var err error
+
+	secret.iterations, err = strconv.Atoi(iterations)
+	if err != nil {
+		return nil, invalid
+	}
+
+	for _, field := range []struct {
+		value string
+		dest  *[]byte
+	}{
+		{salt, &secret.salt},
+		{storedKey, &secret.storedKey},
+		{serverKey, &secret.serverKey},
+	} {
+		*field.dest, err = base64.StdEncoding.DecodeString(field.value)
+		if err != nil {
+			return nil, invalid
+		}
+	}
+
+	return secret, nil
+}
+
+// scramAttributes parses the comma separated key/value attributes of the
+// given SCRAM message.
+func scramAttributes(message string) map[string]string {
+	attributes := map[string]string{}
+	for _, attribute := range strings.Split(message, ",") {
+		key, value, has := strings.Cut(attribute, "=")
+		if has {
+			attributes[key] = value
+		}
+	}
+
+	return attributes
+}
+
+// scramHi computes the salted password using the Hi function defined in
+// RFC 5802 (PBKDF2 using HMAC-SHA-256).
+func scramHi(password, salt []byte, iterations int) []byte {
+	u := scramHMAC(password, append(append([]byte{}, salt...), 0, 0, 0, 1))
+	result := append([]byte{}, u...)
+
+	for i := 1; i < iterations; i++ {
+		u = scramHMAC(password, u)
+		for index := range result {
+			result[index] ^= u[index]
+		}
+	}
+
+	return result
+}
+
+func scramHMAC(key, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// readSASLInitialResponse reads the SASL mechanism and initial client
+// response from the given reader.
+func readSASLInitialResponse(reader *buffer.Reader) (mechanism string, response string, err error) {
+	t, _, err := reader.ReadTypedMsg()
+	if err != nil {
+		return "", "", err
+	}
+
+	if t != types.ClientPassword {
+		return "", "", errors.New("unexpected SASL initial response message")
+	}
+
+	mechanism, err = reader.GetString()
+	if err != nil {
+		return "", "", err
+	}
+
+	length, err := reader.GetUint32()
+	if err != nil {
+		return "", "", err
+	}
+
+	// NOTE: a length of -1 indicates that no initial response is included
+	if int32(length) == -1 {
+		return mechanism, "", nil
+	}
+
+	bb, err := reader.GetBytes(int(length))
+	if err != nil {
+		return "", "", err
+	}
+
+	return mechanism, string(bb), nil
+}
+
+// readSASLResponse reads the SASL client response from the given reader.
+func readSASLResponse(reader *buffer.Reader) (string, error) {
+	t, _, err := reader.ReadTypedMsg()
+	if err != nil {
+		return "", err
+	}
+
+	if t != types.ClientPassword {
+		return "", errors.New("unexpected SASL response message")
+	}
+
+	bb, err := reader.GetBytes(len(reader.Msg))
+	if err != nil {
+		return "", err
+	}
+
+	return string(bb), nil
+}
@@ -0,0 +1,379 @@
+package wire
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScramSHA256(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := NewScramSHA256Verifier("secret")
+	require.NoError(t, err)
+
+	lookup := func(username string) (string, error) {
+		if username != "john" {
+			return "", errors.New("unknown user")
+		}
+
+		return verifier, nil
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(ScramSHA256(lookup)))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:secret@%s:%d", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:wrong@%s:%d", address.IP, address.Port))
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.InvalidPassword), pgerr.Code)
+	})
+}
+
+// scramClient performs a SCRAM-SHA-256 exchange without channel binding over
+// a plain connection. The announced salt and the message received after
+// sending the client-final-message are returned together with the frontend.
+func scramClient(t *testing.T, address *net.TCPAddr, username, password string) (string, pgproto3.BackendMessage, *pgproto3.Frontend) {
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	frontend := pgproto3.NewFrontend(conn, conn)
+	frontend.Send(&pgproto3.StartupMessage{
+		ProtocolVersion: pgproto3.ProtocolVersionNumber,
+		Parameters:      map[string]string{"user": username},
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err := frontend.Receive()
+	require.NoError(t, err)
+	require.IsType(t, &pgproto3.AuthenticationSASL{}, msg)
+
+	clientFirstBare := "n=,r=rOprNGfwEbeRWgbNEkqO"
+	frontend.Send(&pgproto3.SASLInitialResponse{
+		AuthMechanism: scramSHA256,
+		Data:          []byte("n,," + clientFirstBare),
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err = frontend.Receive()
+	require.NoError(t, err)
+	first, ok := msg.(*pgproto3.AuthenticationSASLContinue)
+	require.True(t, ok)
+
+	serverFirst := string(first.Data)
+	attributes := scramAttributes(serverFirst)
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	require.NoError(t, err)
+	iterations, err := strconv.Atoi(attributes["i"])
+	require.NoError(t, err)
+
+	salted := scramHi([]byte(password), salt, iterations)
+	clientKey := scramHMAC(salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString([]byte("n,,")) + ",r=" + attributes["r"]
+	signature := scramHMAC(storedKey[:], []byte(clientFirstBare+","+serverFirst+","+withoutProof))
+
+	proof := make([]byte, len(clientKey))
+	for index := range clientKey {
+		proof[index] = clientKey[index] ^ signature[index]
+	}
+
+	frontend.Send(&pgproto3.SASLResponse{
+		Data: []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)),
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err = frontend.Receive()
+	require.NoError(t, err)
+	return attributes["s"], msg, frontend
+}
+
+func TestScramSHA256Rejected(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := NewScramSHA256Verifier("secret")
+	require.NoError(t, err)
+
+	lookup := func(username string) (string, error) {
+		if username != "john" {
+			return "", errors.New("unknown user")
+		}
+
+		return verifier, nil
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(ScramSHA256(lookup)))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	t.Run("valid", func(t *testing.T) {
+		_, msg, _ := scramClient(t, address, "john", "secret")
+		assert.IsType(t, &pgproto3.AuthenticationSASLFinal{}, msg)
+	})
+
+	// NOTE: the connection should be closed by the server after the fatal
+	// error without announcing that the connection is ready for queries.
+	t.Run("invalid proof", func(t *testing.T) {
+		_, msg, frontend := scramClient(t, address, "john", "wrong")
+		failure, ok := msg.(*pgproto3.ErrorResponse)
+		require.True(t, ok)
+		assert.Equal(t, string(codes.InvalidPassword), failure.Code)
+
+		_, err := frontend.Receive()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		salt, msg, frontend := scramClient(t, address, "jane", "secret")
+		failure, ok := msg.(*pgproto3.ErrorResponse)
+		require.True(t, ok)
+		assert.Equal(t, string(codes.InvalidPassword), failure.Code)
+
+		_, err := frontend.Receive()
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+		// NOTE: the same salt should be announced for repeated attempts
+		// preventing clients from detecting mock exchanges.
+		again, _, _ := scramClient(t, address, "jane", "secret")
+		assert.Equal(t, salt, again)
+
+		other, _, _ := scramClient(t, address, "jack", "secret")
+		assert.NotEqual(t, salt, other)
+	})
+}
+
+func TestScramSecretVerify(t *testing.T) {
+	// NOTE: test vector as defined in RFC 7677
+	salt, err := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	require.NoError(t, err)
+
+	salted := scramHi([]byte("pencil"), salt, 4096)
+	clientKey := scramHMAC(salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+
+	secret := &scramSecret{
+		iterations: 4096,
+		salt:       salt,
+		storedKey:  storedKey[:],
+		serverKey:  scramHMAC(salted, []byte("Server Key")),
+	}
+
+	message := []byte("n=user,r=rOprNGfwEbeRWgbNEkqO," +
+		"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096," +
+		"c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0")
+
+	proof, err := base64.StdEncoding.DecodeString("dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=")
+	require.NoError(t, err)
+
+	assert.True(t, secret.verify(message, proof))
+	assert.Equal(t, "6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", base64.StdEncoding.EncodeToString(scramHMAC(secret.serverKey, message)))
+
+	proof[0] ^= 0xff
+	assert.False(t, secret.verify(message, proof))
+}
+
+func TestParseScramVerifier(t *testing.T) {
+	verifier, err := NewScramSHA256Verifier("secret")
+	require.NoError(t, err)
+
+	secret, err := parseScramVerifier(verifier)
+	require.NoError(t, err)
+	assert.Equal(t, scramIterations, secret.iterations)
+	assert.Len(t, secret.salt, scramSaltSize)
+
+	_, err = parseScramVerifier("md5abcdef")
+	assert.Error(t, err)
+}
+
+// scramPlusClient performs a SCRAM-SHA-256-PLUS authentication over a TLS
+// connection with the given address. The given tamper function is able to
+// modify the channel binding data send by the client. The final message
+// received from the server is returned.
+func scramPlusClient(t *testing.T, address *net.TCPAddr, password string, tamper func([]byte) []byte) pgproto3.BackendMessage {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", address.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() }) //nolint:errcheck
+
+	_, err = conn.Write((&pgproto3.SSLRequest{}).Encode(nil))
+	require.NoError(t, err)
+
+	response := make([]byte, 1)
+	_, err = io.ReadFull(conn, response)
+	require.NoError(t, err)
+	require.Equal(t, byte('S'), response[0])
+
+	secure := tls.Client(conn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+	require.NoError(t, secure.Handshake())
+
+	binding := sha256.Sum256(secure.ConnectionState().PeerCertificates[0].Raw)
+	cbind := tamper(append([]byte("p=tls-server-end-point,,"), binding[:]...))
+
+	frontend := pgproto3.NewFrontend(secure, secure)
+	frontend.Send(&pgproto3.StartupMessage{
+		ProtocolVersion: pgproto3.ProtocolVersionNumber,
+		Parameters:      map[string]string{"user": "john"},
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err := frontend.Receive()
+	require.NoError(t, err)
+	sasl, ok := msg.(*pgproto3.AuthenticationSASL)
+	require.True(t, ok)
+	assert.Equal(t, []string{scramSHA256Plus, scramSHA256}, sasl.AuthMechanisms)
+
+	clientFirstBare := "n=,r=fyko+d2lbbFgONRv9qkxdawL"
+	frontend.Send(&pgproto3.SASLInitialResponse{
+		AuthMechanism: scramSHA256Plus,
+		Data:          []byte("p=tls-server-end-point,," + clientFirstBare),
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err = frontend.Receive()
+	require.NoError(t, err)
+	first, ok := msg.(*pgproto3.AuthenticationSASLContinue)
+	require.True(t, ok)
+
+	serverFirst := string(first.Data)
+	attributes := scramAttributes(serverFirst)
+	salt, err := base64.StdEncoding.DecodeString(attributes["s"])
+	require.NoError(t, err)
+	iterations, err := strconv.Atoi(attributes["i"])
+	require.NoError(t, err)
+
+	salted := scramHi([]byte(password), salt, iterations)
+	clientKey := scramHMAC(salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString(cbind) + ",r=" + attributes["r"]
+	signature := scramHMAC(storedKey[:], []byte(clientFirstBare+","+serverFirst+","+withoutProof))
+
+	proof := make([]byte, len(clientKey))
+	for index := range clientKey {
+		proof[index] = clientKey[index] ^ signature[index]
+	}
+
+	frontend.Send(&pgproto3.SASLResponse{
+		Data: []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)),
+	})
+	require.NoError(t, frontend.Flush())
+
+	msg, err = frontend.Receive()
+	require.NoError(t, err)
+	return msg
+}
+
+func TestScramSHA256Plus(t *testing.T) {
+	t.Parallel()
+
+	verifier, err := NewScramSHA256Verifier("secret")
+	require.NoError(t, err)
+
+	lookup := func(username string) (string, error) {
+		return verifier, nil
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	certificate := selfSignedCertificate(t, "localhost")
+	server, err := NewServer(SimpleQuery(handler), SessionAuthStrategy(ScramSHA256(lookup)), Certificates([]tls.Certificate{certificate}))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	t.Run("valid", func(t *testing.T) {
+		msg := scramPlusClient(t, address, "secret", func(cbind []byte) []byte { return cbind })
+		assert.IsType(t, &pgproto3.AuthenticationSASLFinal{}, msg)
+	})
+
+	t.Run("binding mismatch", func(t *testing.T) {
+		msg := scramPlusClient(t, address, "secret", func(cbind []byte) []byte {
+			cbind[len(cbind)-1] ^= 0xff
+			return cbind
+		})
+
+		failure, ok := msg.(*pgproto3.ErrorResponse)
+		require.True(t, ok)
+		assert.Equal(t, string(codes.ProtocolViolation), failure.Code)
+	})
+
+	t.Run("without channel binding", func(t *testing.T) {
+		ctx := context.Background()
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://john:secret@%s:%d?sslmode=require", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+}
+
+func TestScramGS2Header(t *testing.T) {
+	binding := []byte("binding")
+
+	header, bare, err := scramGS2Header("n,,n=,r=nonce", scramSHA256, binding)
+	require.NoError(t, err)
+	assert.Equal(t, "n,,", header)
+	assert.Equal(t, "n=,r=nonce", bare)
+
+	_, _, err = scramGS2Header("y,,n=,r=nonce", scramSHA256, nil)
+	assert.NoError(t, err)
+
+	// NOTE: the client should not claim that the server does not support
+	// channel binding once it has been announced.
+	_, _, err = scramGS2Header("y,,n=,r=nonce", scramSHA256, binding)
+	assert.Error(t, err)
+
+	_, _, err = scramGS2Header("p=tls-unique,,n=,r=nonce", scramSHA256Plus, binding)
+	assert.Error(t, err)
+
+	_, _, err = scramGS2Header("n,,n=,r=nonce", scramSHA256Plus, binding)
+	assert.Error(t, err)
+
+	_, _, err = scramGS2Header("p=tls-server-end-point,,n=,r=nonce", scramSHA256, binding)
+	assert.Error(t, err)
+}
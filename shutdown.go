@@ -0,0 +1,81 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// ErrServerShutdown is returned to clients whenever their connection is
+// terminated because the server is shutting down.
+var ErrServerShutdown = errors.New("terminating connection due to administrator command")
+
+// ErrServerClosed is returned when the server is attempting to serve a new
+// connection after it has been closed.
+var ErrServerClosed = errors.New("server closed")
+
+// NewErrServerShutdown constructs a new error wrapping the ErrServerShutdown
+// type including the admin shutdown error code.
+func NewErrServerShutdown() error {
+	return psqlerr.WithSeverity(psqlerr.WithCode(ErrServerShutdown, codes.AdminShutdown), psqlerr.LevelFatal)
+}
+
+// Shutdown gracefully shuts down the server. The server stops accepting new
+// connections and the context of all active connections is cancelled allowing
+// query handlers to finish cleanly. Connections are terminated once their
+// current command has been handled. Shutdown waits until all connections have
+// been closed or until the given context is done, in which case the context
+// error is returned. Use Close to close the server without notifying the
+// active connections.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.signalClose()
+	srv.drainOnce.Do(func() { close(srv.draining) })
+
+	done := make(chan struct{})
+	go func() {
+		srv.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	srv.stop()
+	return nil
+}
+
+// drainConn returns a new context which is cancelled once the server is
+// shutting down. Pending reads of the given connection are interrupted once
+// the server is shutting down to terminate idle connections. The returned
+// function should be called once the connection has been closed.
+func (srv *Server) drainConn(ctx context.Context, conn net.Conn) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-srv.draining:
+			cancel()
+			conn.SetReadDeadline(time.Now()) //nolint:errcheck
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// isDraining checks whether the server is shutting down.
+func (srv *Server) isDraining() bool {
+	select {
+	case <-srv.draining:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT fast;" {
+			return writer.Complete("OK")
+		}
+
+		started <- struct{}{}
+		<-ctx.Done()
+
+		// NOTE: simulate a handler taking longer to clean up than the
+		// shutdown timeout.
+		time.Sleep(500 * time.Millisecond)
+		return ctx.Err()
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+
+	idle, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer idle.Close(ctx)
+
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, "SELECT slow;")
+		result <- err
+	}()
+
+	<-started
+
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	err = server.Shutdown(timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = <-result
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Contains(t, pgerr.Message, context.Canceled.Error())
+
+	timeout, cancel = context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	err = server.Shutdown(timeout)
+	require.NoError(t, err)
+
+	t.Run("idle connection terminated", func(t *testing.T) {
+		_, err := idle.Exec(ctx, "SELECT fast;")
+		assert.Error(t, err)
+	})
+
+	t.Run("new connections refused", func(t *testing.T) {
+		_, err := pgx.Connect(ctx, connstr)
+		assert.Error(t, err)
+	})
+}
+
+func TestShutdownIdle(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, server.Shutdown(timeout))
+
+	// NOTE: the admin shutdown error written to the idle connection is
+	// received once the client attempts to execute a new query.
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.AdminShutdown), pgerr.Code)
+}
@@ -0,0 +1,103 @@
+//go:build go1.21 && !noslog
+
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SlogLogger sets the given slog logger as the default logger for the given
+// server. Log entries written by the server are forwarded to the handler of
+// the given logger.
+func SlogLogger(logger *slog.Logger) OptionFn {
+	return func(srv *Server) error {
+		if logger == nil {
+			return errors.New("slog logger cannot be nil")
+		}
+
+		srv.logger = zap.New(&slogCore{handler: logger.Handler()})
+		return nil
+	}
+}
+
+// newLogger returns the zap logger used to write the log entries of the
+// server to the given logger.
+func newLogger(logger any) (*zap.Logger, error) {
+	switch logger := logger.(type) {
+	case *zap.Logger:
+		return logger, nil
+	case *slog.Logger:
+		return zap.New(&slogCore{handler: logger.Handler()}), nil
+	default:
+		return nil, fmt.Errorf("unsupported logger type %T, expected a *zap.Logger or *slog.Logger", logger)
+	}
+}
+
+// slogCore is a zapcore.Core implementation forwarding the written log
+// entries to a slog handler.
+type slogCore struct {
+	handler slog.Handler
+}
+
+func (core *slogCore) Enabled(level zapcore.Level) bool {
+	return core.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+func (core *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	return &slogCore{handler: core.handler.WithAttrs(slogAttrs(fields))}
+}
+
+func (core *slogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !core.Enabled(entry.Level) {
+		return checked
+	}
+
+	return checked.AddCore(entry, core)
+}
+
+func (core *slogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	record := slog.NewRecord(entry.Time, slogLevel(entry.Level), entry.Message, 0)
+	record.AddAttrs(slogAttrs(fields)...)
+	return core.handler.Handle(context.Background(), record)
+}
+
+func (core *slogCore) Sync() error {
+	return nil
+}
+
+// slogLevel returns the slog level matching the given zap level. Levels above
+// the error level are mapped to the error level.
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// slogAttrs converts the given zap fields into slog attributes. The order of
+// the given fields is preserved.
+func slogAttrs(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, field := range fields {
+		encoder := zapcore.NewMapObjectEncoder()
+		field.AddTo(encoder)
+
+		for key, value := range encoder.Fields {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+
+	return attrs
+}
@@ -0,0 +1,20 @@
+//go:build !go1.21 || noslog
+
+package wire
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// newLogger returns the zap logger used to write the log entries of the
+// server to the given logger.
+func newLogger(logger any) (*zap.Logger, error) {
+	switch logger := logger.(type) {
+	case *zap.Logger:
+		return logger, nil
+	default:
+		return nil, fmt.Errorf("unsupported logger type %T, expected a *zap.Logger", logger)
+	}
+}
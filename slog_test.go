@@ -0,0 +1,80 @@
+//go:build go1.21 && !noslog
+
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSlogLogger(t *testing.T) {
+	t.Parallel()
+
+	output := &syncBuffer{}
+	logger := slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), SlogLogger(logger))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT 1;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+
+	found := false
+	for _, line := range output.Lines() {
+		record := map[string]any{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+
+		if record["msg"] != "incoming simple query" {
+			continue
+		}
+
+		found = true
+		assert.Equal(t, "DEBUG", record["level"])
+		assert.Equal(t, "SELECT 1;", record["query"])
+	}
+
+	assert.True(t, found, "simple query has not been logged")
+}
+
+func TestSlogLoggerLevels(t *testing.T) {
+	output := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	server, err := NewServer(WithLogger(logger))
+	require.NoError(t, err)
+
+	server.logger.Debug("hidden")
+	server.logger.With(zap.String("component", "test")).Error("failure", zap.Error(errors.New("unexpected")))
+
+	assert.NotContains(t, output.String(), "hidden")
+	assert.Contains(t, output.String(), "level=ERROR")
+	assert.Contains(t, output.String(), "component=test")
+	assert.Contains(t, output.String(), "error=unexpected")
+}
+
+func TestInvalidSlogLogger(t *testing.T) {
+	_, err := NewServer(SlogLogger(nil))
+	assert.Error(t, err)
+}
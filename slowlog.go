@@ -0,0 +1,90 @@
+package wire
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// SlowQueryLog logs every query taking longer than the given threshold to
+// execute to the given writer. Each query is logged as a single JSON encoded
+// line including the timestamp, query, parameters, duration, username,
+// database and remote address of the client. Parameters are truncated and
+// redacted similar to LogQueryWithParams.
+func SlowQueryLog(threshold time.Duration, w io.Writer) OptionFn {
+	return func(srv *Server) error {
+		if threshold < 0 {
+			return fmt.Errorf("slow query threshold must be positive, received %s", threshold)
+		}
+
+		srv.slowQueries = &slowQueryLogger{threshold: threshold, writer: w}
+		return nil
+	}
+}
+
+// slowQuery represents a single slow query log line.
+type slowQuery struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Query      string    `json:"query"`
+	Parameters []string  `json:"parameters"`
+	Duration   float64   `json:"duration_ms"`
+	Username   string    `json:"username"`
+	Database   string    `json:"database"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+// slowQueryLogger writes queries exceeding the threshold to the configured
+// writer.
+type slowQueryLogger struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	writer    io.Writer
+}
+
+// log writes the given query if the given duration exceeds the threshold.
+func (logger *slowQueryLogger) log(ctx context.Context, query string, parameters []string, duration time.Duration) {
+	if duration <= logger.threshold {
+		return
+	}
+
+	params := ClientParameters(ctx)
+	line := slowQuery{
+		Timestamp:  time.Now().UTC(),
+		Query:      query,
+		Parameters: redactParams(query, parameters),
+		Duration:   float64(duration) / float64(time.Millisecond),
+		Username:   params[ParamUsername],
+		Database:   params[ParamDatabase],
+	}
+
+	if addr := RemoteAddress(ctx); addr != nil {
+		line.RemoteAddr = addr.String()
+	}
+
+	bb, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	// NOTE: log failures should not prevent queries from being executed
+	logger.writer.Write(append(bb, '\n')) //nolint:errcheck
+}
+
+// logSlowQuery returns a function logging the given query once it has been
+// executed if it exceeded the configured slow query threshold.
+func (srv *Server) logSlowQuery(ctx context.Context, query string, parameters []string) func() {
+	if srv.slowQueries == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+	return func() {
+		srv.slowQueries.log(ctx, query, parameters, time.Since(start))
+	}
+}
@@ -0,0 +1,80 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if strings.Contains(query, "slow") {
+			time.Sleep(200 * time.Millisecond)
+		}
+
+		return writer.Complete("OK")
+	}
+
+	logs := &syncBuffer{}
+	server, err := NewServer(SimpleQuery(handler), SlowQueryLog(100*time.Millisecond, logs))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://john@%s:%d/users", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT fast;")
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, logs.Lines())
+
+	_, err = conn.Exec(ctx, "SELECT slow;")
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT slow WHERE name = $1;", "jane")
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT slow WHERE name = $1 AND password = $2;", "jane", "hunter2")
+	require.NoError(t, err)
+
+	lines := logs.Lines()
+	require.Len(t, lines, 3)
+
+	entry := slowQuery{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "SELECT slow;", entry.Query)
+	assert.Empty(t, entry.Parameters)
+	assert.GreaterOrEqual(t, entry.Duration, float64(200))
+	assert.Equal(t, "john", entry.Username)
+	assert.Equal(t, "users", entry.Database)
+	assert.NotEmpty(t, entry.RemoteAddr)
+	assert.False(t, entry.Timestamp.IsZero())
+
+	entry = slowQuery{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "SELECT slow WHERE name = $1;", entry.Query)
+	assert.Equal(t, []string{"jane"}, entry.Parameters)
+
+	entry = slowQuery{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &entry))
+	assert.Equal(t, []string{"jane", "[REDACTED]"}, entry.Parameters)
+	assert.NotContains(t, lines[2], "hunter2")
+}
+
+func TestInvalidSlowQueryLog(t *testing.T) {
+	_, err := NewServer(SlowQueryLog(-1, &bytes.Buffer{}))
+	assert.Error(t, err)
+}
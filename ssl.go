@@ -8,3 +8,12 @@ var (
 	sslSupported   sslIdentifier = []byte{'S'}
 	sslUnsupported sslIdentifier = []byte{'N'}
 )
+
+// gssIdentifier represents the bytes identifying whether the given connection
+// supports GSS encryption.
+type gssIdentifier []byte
+
+var (
+	gssSupported   gssIdentifier = []byte{'G'}
+	gssUnsupported gssIdentifier = []byte{'N'}
+)
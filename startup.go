@@ -0,0 +1,91 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"go.uber.org/zap"
+)
+
+// ErrMissingStartupParameter is returned whenever a client connection does not
+// include a required startup parameter.
+var ErrMissingStartupParameter = errors.New("missing required startup parameter")
+
+// NewErrMissingStartupParameter constructs a new fatal error wrapping the
+// ErrMissingStartupParameter type including the protocol violation error
+// code.
+func NewErrMissingStartupParameter(param string) error {
+	err := psqlerr.WithCode(fmt.Errorf("%w: %s", ErrMissingStartupParameter, param), codes.ProtocolViolation)
+	return psqlerr.WithSeverity(err, psqlerr.LevelFatal)
+}
+
+// StartupValidatorFn validates the key/value parameters send by the client
+// inside the startup message. The connection is rejected whenever an error is
+// returned.
+type StartupValidatorFn func(params map[string]string) error
+
+// StartupValidator sets the given function used to validate the startup
+// message parameters of incoming connections before they are authenticated.
+// This could be used to require non-standard parameters such as a secret token
+// for specialized wire clients. Rejected connections receive a fatal
+// invalid password error unless the returned error contains a Postgres error
+// code.
+func StartupValidator(fn StartupValidatorFn) OptionFn {
+	return func(srv *Server) error {
+		srv.startupValidator = fn
+		return nil
+	}
+}
+
+// RequireStartupParams rejects client connections which do not include all of
+// the given startup parameters, such as the application_name used for
+// auditing. Rejected connections receive a fatal protocol violation error.
+// Empty parameter values are considered missing.
+func RequireStartupParams(params ...string) OptionFn {
+	return func(srv *Server) error {
+		for _, param := range params {
+			if param == "" {
+				return errors.New("required startup parameter names cannot be empty")
+			}
+		}
+
+		srv.requiredParams = append(srv.requiredParams, params...)
+		return nil
+	}
+}
+
+// validateStartup validates the startup parameters of the client connection
+// using the required startup parameters and configured startup validator.
+func (srv *Server) validateStartup(ctx context.Context) error {
+	params := StartupParameters(ctx)
+	if params == nil {
+		params = map[string]string{}
+	}
+
+	for _, param := range srv.requiredParams {
+		if params[param] == "" {
+			srv.logger.Debug("required startup parameter missing", zap.String("param", param))
+			return NewErrMissingStartupParameter(param)
+		}
+	}
+
+	if srv.startupValidator == nil {
+		return nil
+	}
+
+	err := srv.startupValidator(params)
+	if err == nil {
+		return nil
+	}
+
+	srv.logger.Debug("startup parameters rejected", zap.Error(err))
+
+	if psqlerr.GetCode(err) == codes.Uncategorized {
+		err = psqlerr.WithCode(err, codes.InvalidPassword)
+	}
+
+	return psqlerr.WithSeverity(err, psqlerr.LevelFatal)
+}
@@ -0,0 +1,127 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartupValidator(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	validator := StartupValidator(func(params map[string]string) error {
+		if params["service_token"] != "secret" {
+			return errors.New("invalid service token")
+		}
+
+		return nil
+	})
+
+	server, err := NewServer(SimpleQuery(handler), validator)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("valid", func(t *testing.T) {
+		connstr := fmt.Sprintf("postgres://%s:%d?service_token=secret", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		tag, err := conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+		assert.Equal(t, "OK", tag.String())
+	})
+
+	tests := map[string]string{
+		"missing": "",
+		"invalid": "?service_token=unknown",
+	}
+
+	for name, params := range tests {
+		t.Run(name, func(t *testing.T) {
+			connstr := fmt.Sprintf("postgres://%s:%d%s", address.IP, address.Port, params)
+			_, err := pgx.Connect(ctx, connstr)
+
+			pgerr := &pgconn.PgError{}
+			require.ErrorAs(t, err, &pgerr)
+			assert.Equal(t, string(codes.InvalidPassword), pgerr.Code)
+			assert.Equal(t, string(psqlerr.LevelFatal), pgerr.Severity)
+		})
+	}
+}
+
+func TestStartupValidatorErrorCode(t *testing.T) {
+	t.Parallel()
+
+	validator := StartupValidator(func(params map[string]string) error {
+		return psqlerr.WithCode(errors.New("unknown database"), codes.InvalidCatalogName)
+	})
+
+	server, err := NewServer(validator)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	_, err = pgx.Connect(context.Background(), fmt.Sprintf("postgres://%s:%d", address.IP, address.Port))
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.InvalidCatalogName), pgerr.Code)
+}
+
+func TestRequireStartupParams(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), RequireStartupParams(string(ParamApplicationName)))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	t.Run("present", func(t *testing.T) {
+		connstr := fmt.Sprintf("postgres://%s:%d?application_name=audit", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		tag, err := conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+		assert.Equal(t, "OK", tag.String())
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		_, err := pgx.Connect(ctx, connstr)
+
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.ProtocolViolation), pgerr.Code)
+		assert.Equal(t, string(psqlerr.LevelFatal), pgerr.Severity)
+		assert.Contains(t, pgerr.Message, string(ParamApplicationName))
+	})
+}
+
+func TestInvalidRequireStartupParams(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewServer(RequireStartupParams(""))
+	assert.Error(t, err)
+}
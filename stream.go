@@ -0,0 +1,244 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// NewJSONStreamWriter constructs a new data writer encoding each written row
+// as a single newline-delimited JSON object. Object keys are the defined
+// column names in the order in which they have been defined. Errors written
+// through ErrorFromErr are encoded as a JSON object containing a single
+// "error" key. Rows are flushed immediately whenever the given writer
+// implements http.Flusher, allowing query results to be streamed through a
+// http.ResponseWriter. Authenticating and authorizing HTTP requests is up to
+// the caller.
+func NewJSONStreamWriter(w io.Writer) DataWriter {
+	writer := &jsonStreamWriter{sink: w}
+	if flusher, ok := w.(http.Flusher); ok {
+		writer.flusher = flusher
+	}
+
+	return writer
+}
+
+// jsonStreamError represents the JSON encoded error written to a JSON stream.
+type jsonStreamError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Detail  string `json:"detail,omitempty"`
+		Hint    string `json:"hint,omitempty"`
+	} `json:"error"`
+}
+
+// jsonStreamWriter is a implementation of the DataWriter interface encoding
+// rows as newline-delimited JSON objects.
+type jsonStreamWriter struct {
+	sink    io.Writer
+	flusher http.Flusher
+	columns Columns
+	keys    [][]byte
+	buf     bytes.Buffer
+	closed  bool
+	written uint64
+}
+
+func (writer *jsonStreamWriter) Define(columns Columns) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns != nil {
+		return ErrColumnsDefined
+	}
+
+	writer.keys = make([][]byte, len(columns))
+	for index, column := range columns {
+		key, err := json.Marshal(column.Name)
+		if err != nil {
+			return err
+		}
+
+		writer.keys[index] = key
+	}
+
+	writer.columns = columns
+	return nil
+}
+
+func (writer *jsonStreamWriter) Row(values []any) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	if len(values) != len(writer.columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given data row but %d were expected", len(values), len(writer.columns))
+	}
+
+	writer.buf.Reset()
+	writer.buf.WriteByte('{')
+
+	for index, value := range values {
+		if index > 0 {
+			writer.buf.WriteByte(',')
+		}
+
+		bb, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		writer.buf.Write(writer.keys[index])
+		writer.buf.WriteByte(':')
+		writer.buf.Write(bb)
+	}
+
+	writer.buf.WriteString("}\n")
+
+	err := writer.line(writer.buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	writer.written++
+	return nil
+}
+
+func (writer *jsonStreamWriter) Written() uint64 {
+	return writer.written
+}
+
+func (writer *jsonStreamWriter) Empty() error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	if writer.written != 0 {
+		return ErrDataWritten
+	}
+
+	writer.closed = true
+	return nil
+}
+
+func (writer *jsonStreamWriter) Complete(description string) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	writer.closed = true
+	return nil
+}
+
+// SendCommandComplete resets the defined columns allowing the rows of the
+// next statement to be written to the same JSON stream.
+func (writer *jsonStreamWriter) SendCommandComplete(tag string) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	writer.columns = nil
+	writer.keys = nil
+	writer.written = 0
+	return nil
+}
+
+func (writer *jsonStreamWriter) ErrorFromErr(err error) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if psqlerr.GetCode(err) == codes.Uncategorized {
+		err = psqlerr.WithCode(err, codes.Internal)
+	}
+
+	writer.closed = true
+	return writeJSONStreamError(writer.sink, err)
+}
+
+func (writer *jsonStreamWriter) ColumnNames() []string {
+	if writer.columns == nil {
+		return nil
+	}
+
+	names := make([]string, len(writer.columns))
+	for index, column := range writer.columns {
+		names[index] = column.Name
+	}
+
+	return names
+}
+
+// Peek validates the number of values, NULL values inside NOT NULL columns
+// and whether the values could be encoded as JSON.
+func (writer *jsonStreamWriter) Peek(row []any) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	if len(row) != len(writer.columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given data row but %d were expected", len(row), len(writer.columns))
+	}
+
+	for index, value := range row {
+		column := writer.columns[index]
+		if value == nil && column.NotNull {
+			return NewErrNotNullViolation(column.Name)
+		}
+
+		_, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for column %q: %w", column.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// line writes the given encoded line to the underlaying writer and flushes
+// the line to the client whenever possible.
+func (writer *jsonStreamWriter) line(bb []byte) error {
+	_, err := writer.sink.Write(bb)
+	if err != nil {
+		return err
+	}
+
+	if writer.flusher != nil {
+		writer.flusher.Flush()
+	}
+
+	return nil
+}
+
+// writeJSONStreamError writes the given error as a single JSON encoded line
+// to the given writer.
+func writeJSONStreamError(w io.Writer, err error) error {
+	desc := psqlerr.Flatten(err)
+
+	result := jsonStreamError{}
+	result.Error.Code = string(desc.Code)
+	result.Error.Message = desc.Message
+	result.Error.Detail = desc.Detail
+	result.Error.Hint = desc.Hint
+
+	return json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,108 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONStreamWriterHTTP(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT broken;" {
+			writer.Define(Columns{{Name: "id", Oid: oid.T_int4, Format: TextFormat}}) //nolint:errcheck
+			writer.Row([]any{1})                                                      //nolint:errcheck
+			return errors.New("unexpected failure")
+		}
+
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "name", Oid: oid.T_text, Format: TextFormat},
+			{Name: "age", Oid: oid.T_int4, Format: TextFormat},
+		})
+
+		writer.Row([]any{"John", 32})                     //nolint:errcheck
+		writer.Row([]any{parameters[0], nil})             //nolint:errcheck
+		Batch(writer, [][]any{{"Jane", 28}, {"Bob", 45}}) //nolint:errcheck
+		return writer.Complete("SELECT 4")
+	}
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		writer := NewJSONStreamWriter(w)
+		err := handler(r.Context(), r.URL.Query().Get("query"), writer, r.URL.Query()["param"])
+		if err != nil {
+			ErrorFromErr(writer, err) //nolint:errcheck
+		}
+	}))
+	defer proxy.Close()
+
+	get := func(t *testing.T, query url.Values) (*http.Response, []map[string]any) {
+		res, err := http.Get(proxy.URL + "?" + query.Encode())
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		lines := []map[string]any{}
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			line := map[string]any{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+
+		require.NoError(t, scanner.Err())
+		return res, lines
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		res, lines := get(t, url.Values{"query": {"SELECT name, age FROM users;"}, "param": {"Marry"}})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "application/x-ndjson", res.Header.Get("Content-Type"))
+
+		expected := []map[string]any{
+			{"name": "John", "age": float64(32)},
+			{"name": "Marry", "age": nil},
+			{"name": "Jane", "age": float64(28)},
+			{"name": "Bob", "age": float64(45)},
+		}
+
+		assert.Equal(t, expected, lines)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		res, lines := get(t, url.Values{"query": {"SELECT broken;"}})
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		require.Len(t, lines, 2)
+		assert.Equal(t, map[string]any{"id": float64(1)}, lines[0])
+
+		failure, ok := lines[1]["error"].(map[string]any)
+		require.True(t, ok)
+		assert.Equal(t, "XX000", failure["code"])
+		assert.Equal(t, "unexpected failure", failure["message"])
+	})
+}
+
+func TestJSONStreamWriterColumnOrder(t *testing.T) {
+	t.Parallel()
+
+	sink := &bytes.Buffer{}
+	writer := NewJSONStreamWriter(sink)
+
+	require.NoError(t, writer.Define(Columns{{Name: "z"}, {Name: "a"}}))
+	require.NoError(t, MapRow(writer, map[string]any{"a": "first", "z": true}))
+	require.NoError(t, writer.Complete("SELECT 1"))
+
+	assert.Equal(t, "{\"z\":true,\"a\":\"first\"}\n", sink.String())
+	assert.ErrorIs(t, writer.Row([]any{true, "second"}), ErrClosedWriter)
+}
@@ -0,0 +1,36 @@
+package wire
+
+import (
+	"fmt"
+)
+
+// TableFunctionResult represents the result of a function returning a table
+// (ex: RETURNS TABLE(a int, b text)). Instead of encoding the result as a
+// single anonymous composite record column are the inner columns described
+// to the client and each yielded row written as a separate data row.
+type TableFunctionResult struct {
+	// Columns represent the columns defined inside the returned table.
+	Columns Columns
+	// Rows is called to produce the table rows. Each yielded row should
+	// contain a value for each of the defined columns. Yield returns an error
+	// whenever the row could not be written to the client.
+	Rows func(yield func(row []any) error) error
+}
+
+// Write defines the table columns, writes all rows yielded by the table
+// function and completes the command using a SELECT command tag.
+func (result TableFunctionResult) Write(writer DataWriter) error {
+	err := writer.Define(result.Columns)
+	if err != nil {
+		return err
+	}
+
+	if result.Rows != nil {
+		err = result.Rows(writer.Row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+}
@@ -0,0 +1,68 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableFunctionResult(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		result := TableFunctionResult{
+			Columns: Columns{
+				{Name: "a", Oid: oid.T_int4, Format: TextFormat},
+				{Name: "b", Oid: oid.T_text, Format: TextFormat},
+			},
+			Rows: func(yield func(row []any) error) error {
+				for index, name := range []string{"John", "Marry"} {
+					err := yield([]any{index + 1, name + " " + parameters[0]})
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		}
+
+		return result.Write(writer)
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	pool, err := pgxpool.New(ctx, connstr)
+	require.NoError(t, err)
+	defer pool.Close()
+
+	var a int32
+	var b string
+	err = pool.QueryRow(ctx, "SELECT * FROM users_table($1);", "Doe").Scan(&a, &b)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), a)
+	assert.Equal(t, "John Doe", b)
+
+	rows, err := pool.Query(ctx, "SELECT * FROM users_table($1);", "Doe")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		count++
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "SELECT 2", rows.CommandTag().String())
+}
@@ -0,0 +1,248 @@
+// Package testkit provides a suite of Postgres wire protocol conformance
+// checks which could be run against any psql-wire server.
+package testkit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultTimeout represents the maximum duration of a single conformance check.
+var DefaultTimeout = 10 * time.Second
+
+// PGConformanceTest runs a suite of protocol conformance checks against the
+// Postgres server listening on the given address. The address is either a
+// host:port pair or a Postgres connection string. The checks only validate
+// the protocol flow, errors returned by the server handlers are accepted as
+// long as the connection remains usable afterwards.
+func PGConformanceTest(t *testing.T, addr string) {
+	checks := []struct {
+		name string
+		fn   func(*testing.T, string)
+	}{
+		{"startup", testStartup},
+		{"authentication", testAuthentication},
+		{"simple query", testSimpleQuery},
+		{"extended query", testExtendedQuery},
+		{"copy", testCopy},
+		{"cancellation", testCancellation},
+		{"error handling", testErrorHandling},
+		{"null values", testNullValues},
+	}
+
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			check.fn(t, addr)
+		})
+	}
+}
+
+// connString returns a Postgres connection string for the given address.
+func connString(addr string) string {
+	if strings.Contains(addr, "://") || strings.Contains(addr, "=") {
+		return addr
+	}
+
+	return fmt.Sprintf("postgres://postgres@%s/postgres?sslmode=disable", addr)
+}
+
+// dialAddr returns the host:port pair for the given address.
+func dialAddr(t *testing.T, addr string) string {
+	t.Helper()
+
+	if !strings.Contains(addr, "://") && !strings.Contains(addr, "=") {
+		return addr
+	}
+
+	config, err := pgconn.ParseConfig(addr)
+	require.NoError(t, err)
+
+	return net.JoinHostPort(config.Host, fmt.Sprint(config.Port))
+}
+
+// connect opens a new client connection to the given address. The connection
+// is closed once the test has been completed.
+func connect(t *testing.T, addr string) *pgconn.PgConn {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+
+	conn, err := pgconn.Connect(ctx, connString(addr))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close(context.Background()) //nolint:errcheck
+	})
+
+	return conn
+}
+
+// timeout constructs a new context which is cancelled once the default
+// timeout has been reached.
+func timeout(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// acceptable fails the test whenever the given error is not returned by the
+// server as a error response.
+func acceptable(t *testing.T, err error) {
+	t.Helper()
+
+	if err == nil {
+		return
+	}
+
+	var pgerr *pgconn.PgError
+	if !errors.As(err, &pgerr) {
+		t.Fatalf("unexpected protocol error: %s", err)
+	}
+
+	t.Logf("server returned a error response: %s", pgerr)
+}
+
+// alive ensures that the given connection is still able to process queries.
+func alive(t *testing.T, conn *pgconn.PgConn) {
+	t.Helper()
+
+	_, err := conn.Exec(timeout(t), "").ReadAll()
+	require.NoError(t, err, "connection is no longer usable")
+	assert.Equal(t, byte('I'), conn.TxStatus())
+}
+
+func testStartup(t *testing.T, addr string) {
+	conn := connect(t, addr)
+	assert.False(t, conn.IsClosed())
+	assert.Equal(t, byte('I'), conn.TxStatus())
+}
+
+func testAuthentication(t *testing.T, addr string) {
+	conn, err := net.DialTimeout("tcp", dialAddr(t, addr), DefaultTimeout)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(DefaultTimeout)))
+
+	client := mock.NewClient(conn)
+	client.Handshake(t)
+
+	typed, _, err := client.ReadTypedMsg()
+	require.NoError(t, err)
+	require.Contains(t, []types.ServerMessage{types.ServerAuth, types.ServerErrorResponse}, typed, "unexpected response to the startup message")
+
+	if typed == types.ServerErrorResponse {
+		t.Log("server rejected the startup message")
+		return
+	}
+
+	status, err := client.GetUint32()
+	require.NoError(t, err)
+
+	if status != 0 {
+		t.Logf("server requested authentication method %d", status)
+		return
+	}
+
+	client.ReadyForQuery(t)
+	client.Close(t)
+}
+
+func testSimpleQuery(t *testing.T, addr string) {
+	conn := connect(t, addr)
+
+	results, err := conn.Exec(timeout(t), "SELECT 1").ReadAll()
+	acceptable(t, err)
+
+	for _, result := range results {
+		for _, row := range result.Rows {
+			assert.Len(t, row, len(result.FieldDescriptions))
+		}
+	}
+
+	alive(t, conn)
+}
+
+func testExtendedQuery(t *testing.T, addr string) {
+	conn := connect(t, addr)
+
+	result := conn.ExecParams(timeout(t), "SELECT $1", [][]byte{[]byte("1")}, nil, nil, nil).Read()
+	acceptable(t, result.Err)
+
+	alive(t, conn)
+}
+
+func testCopy(t *testing.T, addr string) {
+	conn := connect(t, addr)
+
+	_, err := conn.CopyFrom(timeout(t), strings.NewReader("1\tJohn\n2\tMarry\n"), "COPY conformance FROM STDIN")
+	acceptable(t, err)
+
+	alive(t, conn)
+}
+
+func testCancellation(t *testing.T, addr string) {
+	conn := connect(t, addr)
+
+	err := conn.CancelRequest(timeout(t))
+	require.NoError(t, err)
+
+	alive(t, conn)
+}
+
+func testErrorHandling(t *testing.T, addr string) {
+	conn, err := net.DialTimeout("tcp", dialAddr(t, addr), DefaultTimeout)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(DefaultTimeout)))
+
+	client := mock.NewClient(conn)
+	client.Handshake(t)
+	client.Authenticate(t)
+	client.ReadyForQuery(t)
+
+	// NOTE: unknown message types should result in a error response without
+	// breaking the connection state.
+	client.Start(types.ClientMessage('z'))
+	require.NoError(t, client.End())
+
+	client.Error(t)
+	client.ReadyForQuery(t)
+	client.Close(t)
+}
+
+func testNullValues(t *testing.T, addr string) {
+	conn := connect(t, addr)
+
+	// NOTE: values are read directly from the result reader since copied rows
+	// do not preserve the difference between NULL and empty values.
+	results := conn.Exec(timeout(t), "SELECT NULL")
+	for results.NextResult() {
+		reader := results.ResultReader()
+		for reader.NextRow() {
+			for _, value := range reader.Values() {
+				assert.Nil(t, value, "expected a NULL value")
+			}
+		}
+
+		_, err := reader.Close()
+		acceptable(t, err)
+	}
+
+	acceptable(t, results.Close())
+	alive(t, conn)
+}
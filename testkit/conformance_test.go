@@ -0,0 +1,79 @@
+package testkit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/lib/pq/oid"
+)
+
+func handler(ctx context.Context, query string, writer wire.DataWriter, parameters []string) error {
+	switch {
+	case strings.HasPrefix(query, "COPY"):
+		reader, err := wire.AcceptCopy(writer, wire.TextCopyFormat)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(io.Discard, reader)
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("COPY 2")
+	case query == "SELECT NULL":
+		err := writer.Define(wire.Columns{{Name: "null", Oid: oid.T_text}})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{nil})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	case query == "SELECT 1" || query == "SELECT $1":
+		err := writer.Define(wire.Columns{{Name: "value", Oid: oid.T_int4}})
+		if err != nil {
+			return err
+		}
+
+		err = writer.Row([]any{1})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	return errors.New("unsupported query")
+}
+
+func TestPGConformance(t *testing.T) {
+	server, err := wire.NewServer(wire.SimpleQuery(handler))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		err := server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	go server.Serve(listener) //nolint:errcheck
+
+	PGConformanceTest(t, listener.Addr().String())
+}
@@ -0,0 +1,230 @@
+package testkit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+)
+
+// FuzzConn constructs a new connection replaying the given data as if it has
+// been send by a client. All data written to the connection is discarded.
+// io.EOF is returned once all data has been read.
+func FuzzConn(data []byte) net.Conn {
+	return &fuzzConn{
+		reader: bytes.NewReader(data),
+		closed: make(chan struct{}),
+	}
+}
+
+type fuzzConn struct {
+	reader *bytes.Reader
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (conn *fuzzConn) Read(p []byte) (int, error) {
+	select {
+	case <-conn.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	return conn.reader.Read(p)
+}
+
+func (conn *fuzzConn) Write(p []byte) (int, error) {
+	select {
+	case <-conn.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	return len(p), nil
+}
+
+func (conn *fuzzConn) Close() error {
+	conn.once.Do(func() {
+		close(conn.closed)
+	})
+
+	return nil
+}
+
+func (conn *fuzzConn) LocalAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5432}
+}
+
+func (conn *fuzzConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func (conn *fuzzConn) SetDeadline(time.Time) error      { return nil }
+func (conn *fuzzConn) SetReadDeadline(time.Time) error  { return nil }
+func (conn *fuzzConn) SetWriteDeadline(time.Time) error { return nil }
+
+// fuzzListener accepts the given connection once. Accept blocks afterwards
+// until the listener is closed.
+type fuzzListener struct {
+	conns  chan net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newFuzzListener(conn net.Conn) *fuzzListener {
+	conns := make(chan net.Conn, 1)
+	conns <- conn
+
+	return &fuzzListener{
+		conns:  conns,
+		closed: make(chan struct{}),
+	}
+}
+
+func (listener *fuzzListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-listener.conns:
+		return conn, nil
+	case <-listener.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (listener *fuzzListener) Close() error {
+	listener.once.Do(func() {
+		close(listener.closed)
+	})
+
+	return nil
+}
+
+func (listener *fuzzListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5432}
+}
+
+// FuzzServer registers a corpus of client messages and fuzzes a new server,
+// constructed using the given options, for every fuzzer generated input. The
+// input is replayed as client connection. Panics are reported by the fuzzer
+// and the server is expected to close the connection once all input has been
+// consumed.
+//
+//	func FuzzServer(f *testing.F) {
+//		testkit.FuzzServer(f, wire.SimpleQuery(handler))
+//	}
+func FuzzServer(f *testing.F, options ...wire.OptionFn) {
+	for _, seed := range FuzzCorpus() {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		server, err := wire.NewServer(options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conn := FuzzConn(data).(*fuzzConn)
+		go server.Serve(newFuzzListener(conn)) //nolint:errcheck
+
+		select {
+		case <-conn.closed:
+		case <-time.After(DefaultTimeout):
+			t.Errorf("connection has not been closed after consuming input: %q", data)
+		}
+
+		err = server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// FuzzCorpus returns a corpus of interesting client inputs including startup,
+// SSL and GSS encryption requests followed by various message types.
+func FuzzCorpus() [][]byte {
+	startup := startupMessage(types.Version30, "user", "postgres", "database", "postgres")
+
+	return [][]byte{
+		startup,
+		concat(startup, message(types.ClientSimpleQuery, cstring("SELECT 1;")), message(types.ClientTerminate)),
+		concat(startup, message(types.ClientSimpleQuery, cstring(""))),
+		concat(startupMessage(types.VersionSSLRequest), startup, message(types.ClientTerminate)),
+		concat(startupMessage(types.VersionGSSENC), startup, message(types.ClientTerminate)),
+		concat(startupMessage(types.VersionCancel), uint32s(1, 2)),
+		concat(
+			startup,
+			message(types.ClientParse, cstring(""), cstring("SELECT $1;"), uint16s(1), uint32s(25)),
+			message(types.ClientBind, cstring(""), cstring(""), uint16s(1, 0, 1), uint32s(4), []byte("john"), uint16s(0)),
+			message(types.ClientDescribe, []byte{'P'}, cstring("")),
+			message(types.ClientExecute, cstring(""), uint32s(0)),
+			message(types.ClientSync),
+			message(types.ClientTerminate),
+		),
+		concat(
+			startup,
+			message(types.ClientSimpleQuery, cstring("COPY users FROM STDIN;")),
+			message(types.ClientCopyData, []byte("1\tjohn\n")),
+			message(types.ClientCopyDone),
+		),
+		concat(startup, message(types.ClientCopyFail, cstring("aborted"))),
+		concat(startup, message(types.ClientFlush), message(types.ClientClose)),
+		concat(startup, []byte{'Z', 0, 0, 0, 4}),
+		concat(startup, []byte{'Q', 0xff, 0xff, 0xff, 0xff}),
+	}
+}
+
+// startupMessage constructs a untyped startup message containing the given
+// version and key/value parameters.
+func startupMessage(version types.Version, params ...string) []byte {
+	body := uint32s(uint32(version))
+	if len(params) > 0 {
+		for _, param := range params {
+			body = append(body, cstring(param)...)
+		}
+
+		body = append(body, 0)
+	}
+
+	return append(uint32s(uint32(len(body)+4)), body...)
+}
+
+// message constructs a typed client message containing the given body.
+func message(t types.ClientMessage, body ...[]byte) []byte {
+	payload := concat(body...)
+	return concat([]byte{byte(t)}, uint32s(uint32(len(payload)+4)), payload)
+}
+
+func concat(parts ...[]byte) []byte {
+	result := []byte{}
+	for _, part := range parts {
+		result = append(result, part...)
+	}
+
+	return result
+}
+
+func cstring(value string) []byte {
+	return append([]byte(value), 0)
+}
+
+func uint16s(values ...uint16) []byte {
+	result := []byte{}
+	for _, value := range values {
+		result = binary.BigEndian.AppendUint16(result, value)
+	}
+
+	return result
+}
+
+func uint32s(values ...uint32) []byte {
+	result := []byte{}
+	for _, value := range values {
+		result = binary.BigEndian.AppendUint32(result, value)
+	}
+
+	return result
+}
@@ -0,0 +1,11 @@
+package testkit
+
+import (
+	"testing"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+)
+
+func FuzzDefaultServer(f *testing.F) {
+	FuzzServer(f, wire.SimpleQuery(handler))
+}
@@ -0,0 +1,170 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// WireMessage represents a single Postgres wire protocol message consisting
+// out of a message type and the payload following the message length.
+type WireMessage interface {
+	MessageType() byte
+	Payload() []byte
+}
+
+// AssertMessages reads messages from the given connection one by one and
+// fails the test whenever the type or payload of a message does not match the
+// expected message. Messages are read without buffering allowing the
+// connection to be reused once all expected messages have been read.
+func AssertMessages(t *testing.T, conn net.Conn, expected []WireMessage) {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(DefaultTimeout)))
+	defer conn.SetReadDeadline(time.Time{}) //nolint:errcheck
+
+	for index, expected := range expected {
+		typed, payload, err := readMessage(conn)
+		require.NoError(t, err, "unable to read message %d", index)
+
+		if typed != expected.MessageType() {
+			t.Fatalf("unexpected message type %s at message %d, expected %s", strconv.QuoteRune(rune(typed)), index, strconv.QuoteRune(rune(expected.MessageType())))
+		}
+
+		if !assert.Equal(t, expected.Payload(), payload, "unexpected payload of message %d (%s)", index, strconv.QuoteRune(rune(typed))) {
+			t.FailNow()
+		}
+	}
+}
+
+// readMessage reads a single typed message from the given reader.
+func readMessage(reader io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(reader, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// NOTE: the message length includes the length itself
+	size := int(binary.BigEndian.Uint32(header[1:])) - 4
+	if size < 0 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	payload := make([]byte, size)
+	_, err = io.ReadFull(reader, payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], payload, nil
+}
+
+type wireMessage struct {
+	typed   byte
+	payload []byte
+}
+
+func (msg wireMessage) MessageType() byte { return msg.typed }
+func (msg wireMessage) Payload() []byte   { return msg.payload }
+
+// Message constructs a new wire message containing the given message type
+// and raw payload.
+func Message(typed byte, payload []byte) WireMessage {
+	return wireMessage{typed: typed, payload: payload}
+}
+
+// encode constructs a new wire message from the message written by the
+// given function.
+func encode(fn func(writer *buffer.Writer) error) WireMessage {
+	sink := bytes.NewBuffer([]byte{})
+	err := fn(buffer.NewWriter(sink))
+	if err != nil {
+		panic(err)
+	}
+
+	typed, payload, err := readMessage(sink)
+	if err != nil {
+		panic(err)
+	}
+
+	return wireMessage{typed: typed, payload: payload}
+}
+
+// RowDescription constructs the expected row description message for the
+// given columns.
+func RowDescription(columns wire.Columns) WireMessage {
+	return encode(func(writer *buffer.Writer) error {
+		return columns.Define(context.Background(), writer)
+	})
+}
+
+// DataRow constructs the expected data row message containing the given
+// encoded column values. A nil value represents a NULL value.
+func DataRow(values ...[]byte) WireMessage {
+	return encode(func(writer *buffer.Writer) error {
+		writer.Start(types.ServerDataRow)
+		writer.AddInt16(int16(len(values)))
+
+		for _, value := range values {
+			if value == nil {
+				writer.AddInt32(-1)
+				continue
+			}
+
+			writer.AddInt32(int32(len(value)))
+			writer.AddBytes(value)
+		}
+
+		return writer.End()
+	})
+}
+
+// CommandComplete constructs the expected command complete message containing
+// the given command tag.
+func CommandComplete(tag string) WireMessage {
+	return encode(func(writer *buffer.Writer) error {
+		writer.Start(types.ServerCommandComplete)
+		writer.AddString(tag)
+		writer.AddNullTerminate()
+		return writer.End()
+	})
+}
+
+// ErrorResponse constructs the expected error response message containing
+// the given severity, code and message. Optional error fields such as hints
+// and details are expected to be absent.
+func ErrorResponse(severity psqlerr.Severity, code codes.Code, msg string) WireMessage {
+	return encode(func(writer *buffer.Writer) error {
+		writer.Start(types.ServerErrorResponse)
+
+		for _, field := range []struct {
+			key   byte
+			value string
+		}{
+			{'S', string(severity)},
+			{'C', string(code)},
+			{'M', msg},
+		} {
+			writer.AddByte(field.key)
+			writer.AddString(field.value)
+			writer.AddNullTerminate()
+		}
+
+		writer.AddNullTerminate()
+		return writer.End()
+	})
+}
@@ -0,0 +1,81 @@
+package testkit
+
+import (
+	"net"
+	"testing"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertMessages(t *testing.T) {
+	server, err := wire.NewServer(wire.SimpleQuery(handler))
+	require.NoError(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		require.NoError(t, server.Close())
+	})
+
+	go server.Serve(listener) //nolint:errcheck
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := mock.NewWriter(conn)
+	mock.NewClient(conn).Handshake(t)
+
+	// NOTE: skip all startup messages until the server is ready for query
+	for {
+		typed, _, err := readMessage(conn)
+		require.NoError(t, err)
+
+		if typed == byte(types.ServerReady) {
+			break
+		}
+	}
+
+	ready := Message(byte(types.ServerReady), []byte{byte(types.ServerIdle)})
+
+	client.Start(types.ClientSimpleQuery)
+	client.AddString("SELECT 1")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	AssertMessages(t, conn, []WireMessage{
+		RowDescription(wire.Columns{{Name: "value", Oid: oid.T_int4}}),
+		DataRow([]byte("1")),
+		CommandComplete("SELECT 1"),
+		ready,
+	})
+
+	client.Start(types.ClientSimpleQuery)
+	client.AddString("SELECT NULL")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	AssertMessages(t, conn, []WireMessage{
+		RowDescription(wire.Columns{{Name: "null", Oid: oid.T_text}}),
+		DataRow(nil),
+		CommandComplete("SELECT 1"),
+		ready,
+	})
+
+	client.Start(types.ClientSimpleQuery)
+	client.AddString("DELETE")
+	client.AddNullTerminate()
+	require.NoError(t, client.End())
+
+	AssertMessages(t, conn, []WireMessage{
+		ErrorResponse(psqlerr.LevelError, codes.Uncategorized, "unsupported query"),
+		ready,
+	})
+}
@@ -0,0 +1,185 @@
+package testkit
+
+import (
+	"fmt"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+)
+
+// MockDataWriter is a implementation of the wire.DataWriter interface
+// recording all defined columns and written rows. This could be used to call
+// query handlers and middleware inside unit tests without a client connection
+// and to inspect the written rows afterwards using ColumnValues.
+type MockDataWriter struct {
+	columns wire.Columns
+	rows    [][]any
+	tag     string
+	tags    []string
+	err     error
+	closed  bool
+}
+
+// NewMockDataWriter constructs a new mock data writer.
+func NewMockDataWriter() *MockDataWriter {
+	return &MockDataWriter{}
+}
+
+func (writer *MockDataWriter) Define(columns wire.Columns) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.columns != nil {
+		return wire.ErrColumnsDefined
+	}
+
+	writer.columns = columns
+	return nil
+}
+
+func (writer *MockDataWriter) Row(values []any) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	row := make([]any, len(values))
+	copy(row, values)
+	writer.rows = append(writer.rows, row)
+	return nil
+}
+
+func (writer *MockDataWriter) Written() uint64 {
+	return uint64(len(writer.rows))
+}
+
+func (writer *MockDataWriter) Empty() error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	if len(writer.rows) != 0 {
+		return wire.ErrDataWritten
+	}
+
+	writer.closed = true
+	return nil
+}
+
+func (writer *MockDataWriter) Complete(description string) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	writer.tag = description
+	writer.closed = true
+	return nil
+}
+
+// SendCommandComplete records the given command tag and resets the defined
+// columns allowing the next statement to be defined. Rows of all statements
+// are recorded and returned by ColumnValues.
+func (writer *MockDataWriter) SendCommandComplete(tag string) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	writer.tags = append(writer.tags, tag)
+	writer.columns = nil
+	return nil
+}
+
+// ErrorFromErr records the given error and closes the writer. The recorded
+// error could be inspected using Err. Errors with a notice severity (ex:
+// WARNING) are recorded without closing the writer.
+func (writer *MockDataWriter) ErrorFromErr(err error) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	writer.err = err
+	if psqlerr.GetSeverity(err).Notice() {
+		return nil
+	}
+
+	writer.closed = true
+	return nil
+}
+
+func (writer *MockDataWriter) ColumnNames() []string {
+	if writer.columns == nil {
+		return nil
+	}
+
+	names := make([]string, len(writer.columns))
+	for index, column := range writer.columns {
+		names[index] = column.Name
+	}
+
+	return names
+}
+
+// ColumnValues returns all rows written so far. Rows are copied when
+// written, modifications of the given slices after writing are not recorded.
+func (writer *MockDataWriter) ColumnValues() [][]any {
+	return writer.rows
+}
+
+// Peek validates the number of values and NULL values inside NOT NULL
+// columns. Value types are not validated as no type information is available
+// to the mock data writer.
+func (writer *MockDataWriter) Peek(row []any) error {
+	if writer.closed {
+		return wire.ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return wire.ErrUndefinedColumns
+	}
+
+	if len(row) != len(writer.columns) {
+		return fmt.Errorf("unexpected columns, %d columns are defined inside the given data row but %d were expected", len(row), len(writer.columns))
+	}
+
+	for index, value := range row {
+		if value == nil && writer.columns[index].NotNull {
+			return wire.NewErrNotNullViolation(writer.columns[index].Name)
+		}
+	}
+
+	return nil
+}
+
+// Columns returns the columns defined through Define.
+func (writer *MockDataWriter) Columns() wire.Columns {
+	return writer.columns
+}
+
+// Tag returns the command tag passed to Complete. An empty string is
+// returned whenever the command has not been completed.
+func (writer *MockDataWriter) Tag() string {
+	return writer.tag
+}
+
+// Tags returns the command tags passed to SendCommandComplete followed by the
+// command tag passed to Complete.
+func (writer *MockDataWriter) Tags() []string {
+	if writer.tag == "" {
+		return writer.tags
+	}
+
+	return append(writer.tags[:len(writer.tags):len(writer.tags)], writer.tag)
+}
+
+// Err returns the error written through ErrorFromErr.
+func (writer *MockDataWriter) Err() error {
+	return writer.err
+}
@@ -0,0 +1,128 @@
+package testkit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	wire "github.com/jeroenrinzema/psql-wire"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockDataWriter(t *testing.T) {
+	handler := func(ctx context.Context, query string, writer wire.DataWriter, parameters []string) error {
+		err := writer.Define(wire.Columns{
+			{Name: "name", Oid: oid.T_text, Format: wire.TextFormat},
+			{Name: "email", Oid: oid.T_text, Format: wire.TextFormat},
+		})
+		if err != nil {
+			return err
+		}
+
+		values := []any{"John", "john@example.com"}
+		err = writer.Row(values)
+		if err != nil {
+			return err
+		}
+
+		// NOTE: modifications after writing should not be recorded
+		values[0] = "Jane"
+
+		err = wire.MapRow(writer, map[string]any{"name": "Marry", "email": "marry@example.com"})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 2")
+	}
+
+	// NOTE: the middleware masks the email addresses of all written rows
+	middleware := func(next wire.SimpleQueryFn) wire.SimpleQueryFn {
+		return func(ctx context.Context, query string, writer wire.DataWriter, parameters []string) error {
+			return next(ctx, query, &maskWriter{DataWriter: writer}, parameters)
+		}
+	}
+
+	writer := NewMockDataWriter()
+	err := middleware(handler)(context.Background(), "SELECT * FROM users;", writer, nil)
+	require.NoError(t, err)
+
+	expected := [][]any{
+		{"John", "****@example.com"},
+		{"Marry", "*****@example.com"},
+	}
+
+	assert.Equal(t, expected, wire.ColumnValues(writer))
+	assert.Equal(t, []string{"name", "email"}, wire.ColumnNames(writer))
+	assert.Equal(t, uint64(2), writer.Written())
+	assert.Equal(t, "SELECT 2", writer.Tag())
+
+	assert.ErrorIs(t, writer.Row([]any{"Bob", "bob@example.com"}), wire.ErrClosedWriter)
+}
+
+func TestMockDataWriterError(t *testing.T) {
+	writer := NewMockDataWriter()
+	assert.ErrorIs(t, writer.Row([]any{"John"}), wire.ErrUndefinedColumns)
+	assert.Nil(t, wire.ColumnValues(writer))
+
+	failure := errors.New("unexpected failure")
+	require.NoError(t, wire.ErrorFromErr(writer, failure))
+	assert.Equal(t, failure, writer.Err())
+}
+
+func TestMockDataWriterNotice(t *testing.T) {
+	writer := NewMockDataWriter()
+	require.NoError(t, writer.Define(wire.Columns{{Name: "name", Oid: oid.T_text}}))
+
+	notice := psqlerr.WithSeverity(errors.New("results are truncated"), psqlerr.LevelWarning)
+	require.NoError(t, wire.ErrorFromErr(writer, notice))
+	assert.Equal(t, notice, writer.Err())
+
+	require.NoError(t, writer.Row([]any{"John"}))
+	require.NoError(t, writer.Complete("SELECT 1"))
+}
+
+func TestMockDataWriterSendCommandComplete(t *testing.T) {
+	writer := NewMockDataWriter()
+	require.NoError(t, writer.Define(wire.Columns{{Name: "name", Oid: oid.T_text}}))
+	require.NoError(t, writer.Row([]any{"John"}))
+	require.NoError(t, wire.SendCommandComplete(writer, "SELECT 1"))
+
+	require.NoError(t, writer.Define(wire.Columns{{Name: "email", Oid: oid.T_text}}))
+	require.NoError(t, writer.Row([]any{"john@example.com"}))
+	require.NoError(t, writer.Complete("SELECT 1"))
+
+	assert.Equal(t, []string{"SELECT 1", "SELECT 1"}, writer.Tags())
+	assert.Equal(t, "SELECT 1", writer.Tag())
+	assert.ErrorIs(t, wire.SendCommandComplete(writer, "SELECT 0"), wire.ErrClosedWriter)
+}
+
+// maskWriter masks the email address column of all written rows.
+type maskWriter struct {
+	wire.DataWriter
+}
+
+func (writer *maskWriter) Row(values []any) error {
+	email := values[1].(string)
+	at := strings.Index(email, "@")
+	return writer.DataWriter.Row([]any{values[0], strings.Repeat("*", at) + email[at:]})
+}
+
+func (writer *maskWriter) Unwrap() wire.DataWriter {
+	return writer.DataWriter
+}
+
+func TestMockDataWriterPeek(t *testing.T) {
+	writer := NewMockDataWriter()
+	assert.ErrorIs(t, wire.Peek(writer, []any{1}), wire.ErrUndefinedColumns)
+
+	require.NoError(t, writer.Define(wire.Columns{{Name: "id", Oid: oid.T_int4, NotNull: true}}))
+	assert.NoError(t, wire.Peek(writer, []any{1}))
+	assert.ErrorIs(t, wire.Peek(writer, []any{nil}), wire.ErrNotNullViolation)
+	assert.Error(t, wire.Peek(writer, []any{1, 2}))
+	assert.Empty(t, wire.ColumnValues(writer))
+}
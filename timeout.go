@@ -0,0 +1,156 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"go.uber.org/zap"
+)
+
+// ErrHardQueryTimeout is returned whenever a query has been running for longer
+// than the configured hard query timeout.
+var ErrHardQueryTimeout = errors.New("hard query timeout exceeded")
+
+// NewErrHardQueryTimeout constructs a new error wrapping the
+// ErrHardQueryTimeout type including the admin shutdown error code.
+func NewErrHardQueryTimeout(timeout time.Duration) error {
+	err := fmt.Errorf("%w: terminating connection, query has been running for more than %s", ErrHardQueryTimeout, timeout)
+	return psqlerr.WithSeverity(psqlerr.WithCode(err, codes.AdminShutdown), psqlerr.LevelFatal)
+}
+
+// hardQueryTimeoutGrace represents the duration a pending write of a query
+// handler is awaited once the hard query timeout has been reached.
+const hardQueryTimeoutGrace = time.Second
+
+// HardQueryTimeout sets the maximum duration of a single query. The
+// connection is forcibly terminated once a query has been running for longer
+// than the given duration, even if the query handler does not respect the
+// context cancellation. A admin shutdown error is written to the client before
+// the connection is closed. This is intended as last resort protection against
+// zombie queries, the query handler is not interrupted and keeps running in
+// the background until it returns. Writes of the query handler are rejected
+// with ErrHardQueryTimeout once the timeout has been reached. No timeout is enforced when the given
+// duration is zero.
+func HardQueryTimeout(timeout time.Duration) OptionFn {
+	return func(srv *Server) error {
+		if timeout < 0 {
+			return fmt.Errorf("hard query timeout must be positive, received %s", timeout)
+		}
+
+		srv.HardQueryTimeout = timeout
+		return nil
+	}
+}
+
+// enforceHardQueryTimeout executes the given function and terminates the given
+// connection once the configured hard query timeout has been reached before
+// the function returned. The context passed to the given function is cancelled
+// once the connection has been terminated. Writes of the given function to the
+// given writer are rejected once the timeout has been reached.
+func (srv *Server) enforceHardQueryTimeout(ctx context.Context, conn net.Conn, writer *buffer.Writer, fn func(context.Context) error) error {
+	if srv.HardQueryTimeout == 0 {
+		return fn(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	gate := &queryGate{Writer: writer.Writer}
+	writer.Writer = gate
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	timer := time.NewTimer(srv.HardQueryTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		writer.Writer = gate.Writer
+		return err
+	case <-timer.C:
+	}
+
+	srv.logger.Warn("terminating connection, hard query timeout exceeded", zap.Duration("timeout", srv.HardQueryTimeout))
+
+	err := NewErrHardQueryTimeout(srv.HardQueryTimeout)
+
+	// NOTE: the query handler keeps running in the background and could be
+	// writing to the client. A write blocked on a stalled client is
+	// interrupted, the connection is closed without writing the error as the
+	// interrupted message has been partially written.
+	locked := make(chan struct{})
+	go func() {
+		gate.mu.Lock()
+		close(locked)
+	}()
+
+	interrupted := false
+	select {
+	case <-locked:
+	case <-time.After(hardQueryTimeoutGrace):
+		interrupted = true
+		conn.SetWriteDeadline(time.Now()) //nolint:errcheck
+		<-locked
+	}
+
+	gate.closed = true
+	gate.mu.Unlock()
+
+	if !interrupted {
+		writeErrorResponse(buffer.NewWriter(gate.Writer), err) //nolint:errcheck
+		if flusher, ok := gate.Writer.(flusher); ok {
+			flusher.Flush() //nolint:errcheck
+		}
+	}
+
+	conn.Close() //nolint:errcheck
+	return err
+}
+
+// queryGate forwards the writes of a query handler to the underlaying writer
+// until the gate has been closed. Writes are rejected once the hard query
+// timeout has been reached, preventing zombie queries from writing to the
+// client.
+type queryGate struct {
+	io.Writer
+	mu     sync.Mutex
+	closed bool
+}
+
+func (gate *queryGate) Write(p []byte) (int, error) {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	if gate.closed {
+		return 0, ErrHardQueryTimeout
+	}
+
+	return gate.Writer.Write(p)
+}
+
+// Flush flushes the underlaying writer if it supports flushing.
+func (gate *queryGate) Flush() error {
+	gate.mu.Lock()
+	defer gate.mu.Unlock()
+
+	if gate.closed {
+		return ErrHardQueryTimeout
+	}
+
+	if flusher, ok := gate.Writer.(flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
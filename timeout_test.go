@@ -0,0 +1,111 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardQueryTimeout(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		if query == "SELECT fast;" {
+			return writer.Complete("OK")
+		}
+
+		// NOTE: the context cancellation is ignored on purpose to simulate a
+		// zombie query.
+		time.Sleep(5 * time.Second)
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), HardQueryTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	tag, err := conn.Exec(ctx, "SELECT fast;")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", tag.String())
+
+	start := time.Now()
+	_, err = conn.Exec(ctx, "SELECT slow;")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.AdminShutdown), pgerr.Code)
+	assert.Equal(t, "FATAL", pgerr.Severity)
+
+	assert.True(t, conn.IsClosed())
+}
+
+func TestHardQueryTimeoutStreaming(t *testing.T) {
+	t.Parallel()
+
+	stopped := make(chan error, 1)
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{{Name: "id", Oid: oid.T_int4, Format: TextFormat}})
+		if err != nil {
+			return err
+		}
+
+		// NOTE: the context cancellation is ignored on purpose, rows are
+		// written until the writer is rejected.
+		for {
+			err = writer.Row([]any{1})
+			if err != nil {
+				stopped <- err
+				return err
+			}
+		}
+	}
+
+	server, err := NewServer(SimpleQuery(handler), HardQueryTimeout(100*time.Millisecond))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT id FROM numbers;", pgx.QueryExecModeSimpleProtocol)
+	require.NoError(t, err)
+
+	for rows.Next() {
+	}
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, rows.Err(), &pgerr)
+	assert.Equal(t, string(codes.AdminShutdown), pgerr.Code)
+
+	select {
+	case err := <-stopped:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("query handler has not been stopped")
+	}
+}
+
+func TestInvalidHardQueryTimeout(t *testing.T) {
+	_, err := NewServer(HardQueryTimeout(-1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,301 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+var (
+	// TimestampInfinity represents the Postgres infinity timestamp. Time
+	// values equal to TimestampInfinity are written as infinity. The value is
+	// located just after the latest timestamp supported by Postgres.
+	TimestampInfinity = time.Date(294277, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// TimestampNegativeInfinity represents the Postgres -infinity timestamp.
+	// Time values equal to TimestampNegativeInfinity are written as
+	// -infinity. The value is located just before the earliest timestamp
+	// supported by Postgres (4713 BC).
+	TimestampNegativeInfinity = time.Date(-4713, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// ErrTimestampOutOfRange is returned whenever a time value could not be
+// represented as a Postgres timestamp.
+var ErrTimestampOutOfRange = errors.New("timestamp out of range")
+
+// postgresEpoch represents the number of seconds between the Unix epoch and
+// the Postgres epoch (2000-01-01 00:00:00 UTC).
+const postgresEpoch = 946684800
+
+// Timestamptz represents a Postgres timestamp with time zone value. Time
+// values are converted to UTC before they are encoded. The binary format
+// contains the number of microseconds since 2000-01-01 00:00:00 UTC, the text
+// format is written using the ISO date style. Nanoseconds are truncated.
+// https://www.postgresql.org/docs/current/datatype-datetime.html
+type Timestamptz struct {
+	Time             time.Time
+	InfinityModifier pgtype.InfinityModifier
+	Status           pgtype.Status
+}
+
+// Set converts and assigns the given source to itself. Time values,
+// pointers to time values and infinity modifiers are supported.
+func (dst *Timestamptz) Set(src any) error {
+	value, modifier, status, err := timestampSource(src)
+	if err != nil {
+		return fmt.Errorf("cannot convert %v to timestamptz: %w", src, err)
+	}
+
+	*dst = Timestamptz{Time: value, InfinityModifier: modifier, Status: status}
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst Timestamptz) Get() any {
+	return timestampValue(dst.Time, dst.InfinityModifier, dst.Status)
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *Timestamptz) AssignTo(dst any) error {
+	return assignTimestamp(src.Time, src.InfinityModifier, src.Status, dst)
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src Timestamptz) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return encodeTimestampText(buf, src.Time.UTC(), src.InfinityModifier, src.Status, true)
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+func (src Timestamptz) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return encodeTimestampBinary(buf, src.Time, src.InfinityModifier, src.Status)
+}
+
+// Timestamp represents a Postgres timestamp without time zone value. The
+// time zone of time values is discarded, the wall clock time is encoded as
+// is. The binary format contains the number of microseconds since 2000-01-01
+// 00:00:00, the text format is written using the ISO date style. Nanoseconds
+// are truncated.
+// https://www.postgresql.org/docs/current/datatype-datetime.html
+type Timestamp struct {
+	Time             time.Time
+	InfinityModifier pgtype.InfinityModifier
+	Status           pgtype.Status
+}
+
+// Set converts and assigns the given source to itself. Time values,
+// pointers to time values and infinity modifiers are supported.
+func (dst *Timestamp) Set(src any) error {
+	value, modifier, status, err := timestampSource(src)
+	if err != nil {
+		return fmt.Errorf("cannot convert %v to timestamp: %w", src, err)
+	}
+
+	// NOTE: the time zone is discarded, the wall clock time is preserved.
+	value = time.Date(value.Year(), value.Month(), value.Day(), value.Hour(), value.Minute(), value.Second(), value.Nanosecond(), time.UTC)
+	*dst = Timestamp{Time: value, InfinityModifier: modifier, Status: status}
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst Timestamp) Get() any {
+	return timestampValue(dst.Time, dst.InfinityModifier, dst.Status)
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *Timestamp) AssignTo(dst any) error {
+	return assignTimestamp(src.Time, src.InfinityModifier, src.Status, dst)
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src Timestamp) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return encodeTimestampText(buf, src.Time, src.InfinityModifier, src.Status, false)
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+func (src Timestamp) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return encodeTimestampBinary(buf, src.Time, src.InfinityModifier, src.Status)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timestampSource converts the given source into a time value. Time values
+// equal to the infinity sentinels are converted into infinity modifiers.
+func timestampSource(src any) (time.Time, pgtype.InfinityModifier, pgtype.Status, error) {
+	switch value := src.(type) {
+	case nil:
+		return time.Time{}, pgtype.None, pgtype.Null, nil
+	case time.Time:
+		switch {
+		case value.Equal(TimestampInfinity):
+			return time.Time{}, pgtype.Infinity, pgtype.Present, nil
+		case value.Equal(TimestampNegativeInfinity):
+			return time.Time{}, pgtype.NegativeInfinity, pgtype.Present, nil
+		}
+
+		return value, pgtype.None, pgtype.Present, nil
+	case *time.Time:
+		if value == nil {
+			return time.Time{}, pgtype.None, pgtype.Null, nil
+		}
+
+		return timestampSource(*value)
+	case pgtype.InfinityModifier:
+		return time.Time{}, value, pgtype.Present, nil
+	case interface{ Get() any }:
+		// NOTE: pgtype values (ex: pgtype.Timestamptz) are converted into
+		// their simplest representation.
+		return timestampSource(value.Get())
+	}
+
+	value := reflect.ValueOf(src)
+	if value.Type().ConvertibleTo(timeType) {
+		return timestampSource(value.Convert(timeType).Interface())
+	}
+
+	return time.Time{}, pgtype.None, pgtype.Undefined, fmt.Errorf("unsupported type %T", src)
+}
+
+// timestampValue returns the simplest representation of the given timestamp.
+func timestampValue(value time.Time, modifier pgtype.InfinityModifier, status pgtype.Status) any {
+	switch status {
+	case pgtype.Present:
+		if modifier != pgtype.None {
+			return modifier
+		}
+
+		return value
+	case pgtype.Null:
+		return nil
+	default:
+		return status
+	}
+}
+
+// assignTimestamp assigns the given timestamp to the given destination.
+// Infinite timestamps are assigned as infinity sentinels.
+func assignTimestamp(value time.Time, modifier pgtype.InfinityModifier, status pgtype.Status, dst any) error {
+	switch modifier {
+	case pgtype.Infinity:
+		value = TimestampInfinity
+	case pgtype.NegativeInfinity:
+		value = TimestampNegativeInfinity
+	}
+
+	switch dst := dst.(type) {
+	case *time.Time:
+		if status != pgtype.Present {
+			return fmt.Errorf("cannot assign non-present status to %T", dst)
+		}
+
+		*dst = value
+	case **time.Time:
+		if status != pgtype.Present {
+			*dst = nil
+			return nil
+		}
+
+		*dst = &value
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// encodeTimestampText appends the ISO formatted timestamp to the given
+// buffer. Years before 1 AD are written using the BC notation. Timestamps with
+// time zone are expected to be given in UTC.
+func encodeTimestampText(buf []byte, value time.Time, modifier pgtype.InfinityModifier, status pgtype.Status, tz bool) ([]byte, error) {
+	switch status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	switch modifier {
+	case pgtype.Infinity:
+		return append(buf, "infinity"...), nil
+	case pgtype.NegativeInfinity:
+		return append(buf, "-infinity"...), nil
+	}
+
+	_, err := timestampMicroseconds(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: Postgres does not have a year zero, the year before 1 AD is 1 BC.
+	year := value.Year()
+	bc := year <= 0
+	if bc {
+		year = 1 - year
+	}
+
+	buf = fmt.Appendf(buf, "%04d-%02d-%02d %02d:%02d:%02d", year, value.Month(), value.Day(), value.Hour(), value.Minute(), value.Second())
+
+	if micro := value.Nanosecond() / 1000; micro > 0 {
+		fraction := strconv.AppendInt(nil, int64(micro)+1000000, 10)
+		for fraction[len(fraction)-1] == '0' {
+			fraction = fraction[:len(fraction)-1]
+		}
+
+		// NOTE: the leading one ensures that leading zeros are preserved.
+		buf = append(buf, '.')
+		buf = append(buf, fraction[1:]...)
+	}
+
+	if tz {
+		buf = append(buf, "+00"...)
+	}
+
+	if bc {
+		buf = append(buf, " BC"...)
+	}
+
+	return buf, nil
+}
+
+// encodeTimestampBinary appends the number of microseconds since the Postgres
+// epoch as a 8 byte big-endian integer to the given buffer.
+func encodeTimestampBinary(buf []byte, value time.Time, modifier pgtype.InfinityModifier, status pgtype.Status) ([]byte, error) {
+	switch status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	var micro int64
+	switch modifier {
+	case pgtype.Infinity:
+		micro = math.MaxInt64
+	case pgtype.NegativeInfinity:
+		micro = math.MinInt64
+	default:
+		var err error
+		micro, err = timestampMicroseconds(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return binary.BigEndian.AppendUint64(buf, uint64(micro)), nil
+}
+
+// timestampMicroseconds returns the number of microseconds between the
+// Postgres epoch and the given time. Nanoseconds are truncated towards the
+// past. An error is returned whenever the time could not be represented.
+func timestampMicroseconds(value time.Time) (int64, error) {
+	seconds := value.Unix() - postgresEpoch
+	if seconds >= math.MaxInt64/1000000 || seconds <= math.MinInt64/1000000 {
+		return 0, fmt.Errorf("%w: %s", ErrTimestampOutOfRange, value)
+	}
+
+	return seconds*1000000 + int64(value.Nanosecond()/1000), nil
+}
@@ -0,0 +1,259 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampColumnEncoding(t *testing.T) {
+	t.Parallel()
+
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	require.NoError(t, err)
+
+	type test struct {
+		oid    oid.Oid
+		value  any
+		text   string
+		binary int64
+	}
+
+	tests := map[string]test{
+		"epoch": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC),
+			text:   "2000-01-01 00:00:00+00",
+			binary: 0,
+		},
+		"truncated": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(2000, time.January, 1, 0, 0, 0, 1999, time.UTC),
+			text:   "2000-01-01 00:00:00.000001+00",
+			binary: 1,
+		},
+		"truncated before epoch": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(1999, time.December, 31, 23, 59, 59, 999999500, time.UTC),
+			text:   "1999-12-31 23:59:59.999999+00",
+			binary: -1,
+		},
+		"fraction": {
+			oid:    oid.T_timestamp,
+			value:  time.Date(2000, time.January, 1, 0, 0, 0, 120000000, time.UTC),
+			text:   "2000-01-01 00:00:00.12",
+			binary: 120000,
+		},
+		"bc": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC),
+			text:   "0001-01-01 00:00:00+00 BC",
+			binary: -63113904000000000,
+		},
+		"bc timestamp": {
+			oid:    oid.T_timestamp,
+			value:  time.Date(-43, time.March, 15, 12, 0, 0, 0, time.UTC),
+			text:   "0044-03-15 12:00:00 BC",
+			binary: -64464465600000000,
+		},
+		"before dst": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(2021, time.March, 28, 1, 59, 59, 0, amsterdam),
+			text:   "2021-03-28 00:59:59+00",
+			binary: 670208399000000,
+		},
+		"after dst": {
+			oid:    oid.T_timestamptz,
+			value:  time.Date(2021, time.March, 28, 3, 0, 0, 0, amsterdam),
+			text:   "2021-03-28 01:00:00+00",
+			binary: 670208400000000,
+		},
+		"dst wall clock": {
+			oid:    oid.T_timestamp,
+			value:  time.Date(2021, time.March, 28, 3, 0, 0, 0, amsterdam),
+			text:   "2021-03-28 03:00:00",
+			binary: 670215600000000,
+		},
+		"infinity": {
+			oid:    oid.T_timestamptz,
+			value:  TimestampInfinity,
+			text:   "infinity",
+			binary: math.MaxInt64,
+		},
+		"negative infinity": {
+			oid:    oid.T_timestamp,
+			value:  TimestampNegativeInfinity,
+			text:   "-infinity",
+			binary: math.MinInt64,
+		},
+	}
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+
+	for name, test := range tests {
+		expected := map[FormatCode][]byte{
+			TextFormat:   []byte(test.text),
+			BinaryFormat: binary.BigEndian.AppendUint64(nil, uint64(test.binary)),
+		}
+
+		for format, bb := range expected {
+			test := test
+			bb := bb
+
+			t.Run(fmt.Sprintf("%s/%d", name, format), func(t *testing.T) {
+				writer := buffer.NewWriter(&bytes.Buffer{})
+				writer.Start(types.ServerDataRow)
+
+				column := Column{Name: "at", Oid: test.oid, Format: format}
+				err := column.Write(ctx, writer, test.value)
+				require.NoError(t, err)
+
+				// NOTE: the written message contains the message type (1 byte),
+				// message length (4 bytes) and value length (4 bytes).
+				assert.Equal(t, bb, writer.Bytes()[9:])
+			})
+		}
+	}
+
+	t.Run("out of range", func(t *testing.T) {
+		writer := buffer.NewWriter(&bytes.Buffer{})
+		writer.Start(types.ServerDataRow)
+
+		column := Column{Name: "at", Oid: oid.T_timestamptz, Format: BinaryFormat}
+		err := column.Write(ctx, writer, time.Date(300000, time.January, 1, 0, 0, 0, 0, time.UTC))
+		assert.ErrorIs(t, err, ErrTimestampOutOfRange)
+	})
+}
+
+func TestTimestampColumnScan(t *testing.T) {
+	t.Parallel()
+
+	expected := []time.Time{
+		time.Date(2023, time.June, 1, 12, 30, 15, 123456000, time.UTC),
+		time.Date(1969, time.July, 20, 20, 17, 40, 0, time.UTC),
+		time.Date(-43, time.March, 15, 12, 0, 0, 0, time.UTC),
+	}
+
+	serve := func(t *testing.T, columns Columns) *net.TCPAddr {
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			writer.Define(columns) //nolint:errcheck
+
+			for _, value := range expected {
+				row := make([]any, len(columns))
+				for index := range row {
+					row[index] = value
+				}
+
+				writer.Row(row) //nolint:errcheck
+			}
+
+			return writer.Complete(fmt.Sprintf("SELECT %d", len(expected)))
+		}
+
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+
+		return TListenAndServe(t, server)
+	}
+
+	t.Run("lib/pq", func(t *testing.T) {
+		// NOTE: lib/pq does not support binary formatted timestamps, only text
+		// formatted columns are written.
+		address := serve(t, Columns{
+			{Name: "text", Oid: oid.T_timestamptz, Format: TextFormat},
+		})
+
+		connstr := fmt.Sprintf("host=%s port=%d sslmode=disable", address.IP, address.Port)
+		conn, err := sql.Open("postgres", connstr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rows, err := conn.Query("SELECT *;")
+		require.NoError(t, err)
+
+		result := []time.Time{}
+		for rows.Next() {
+			var value time.Time
+			require.NoError(t, rows.Scan(&value))
+			result = append(result, value)
+		}
+
+		require.NoError(t, rows.Err())
+		require.Len(t, result, len(expected))
+		for index, value := range expected {
+			assert.True(t, value.Equal(result[index]), "expected %s, received %s", value, result[index])
+		}
+	})
+
+	t.Run("jackc/pgx", func(t *testing.T) {
+		address := serve(t, Columns{
+			{Name: "text", Oid: oid.T_timestamptz, Format: TextFormat},
+			{Name: "binary", Oid: oid.T_timestamptz, Format: BinaryFormat},
+		})
+
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		rows, err := conn.Query(ctx, "SELECT *;")
+		require.NoError(t, err)
+
+		index := 0
+		for rows.Next() {
+			var text, binary time.Time
+			require.NoError(t, rows.Scan(&text, &binary))
+			assert.True(t, expected[index].Equal(text), "expected %s, received %s", expected[index], text)
+			assert.True(t, expected[index].Equal(binary), "expected %s, received %s", expected[index], binary)
+			index++
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, len(expected), index)
+	})
+
+	t.Run("infinity", func(t *testing.T) {
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			writer.Define(Columns{ //nolint:errcheck
+				{Name: "text", Oid: oid.T_timestamptz, Format: TextFormat},
+				{Name: "binary", Oid: oid.T_timestamptz, Format: BinaryFormat},
+			})
+
+			writer.Row([]any{TimestampInfinity, TimestampNegativeInfinity}) //nolint:errcheck
+			return writer.Complete("SELECT 1")
+		}
+
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		var text, binary pgtype.Timestamptz
+		err = conn.QueryRow(ctx, "SELECT *;").Scan(&text, &binary)
+		require.NoError(t, err)
+
+		assert.Equal(t, pgtype.Infinity, text.InfinityModifier)
+		assert.Equal(t, pgtype.NegativeInfinity, binary.InfinityModifier)
+	})
+}
@@ -0,0 +1,162 @@
+package wire
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+
+	"go.uber.org/zap"
+)
+
+// TLS sets the given TLS config used to upgrade client connections requesting
+// SSL. The server replies to SSL requests with N and continues over the plain
+// text connection when no TLS config nor certificates are configured.
+func TLS(config *tls.Config) OptionFn {
+	return func(srv *Server) error {
+		if config == nil {
+			return errors.New("TLS config is required")
+		}
+
+		srv.tlsConfig.Store(config)
+		return nil
+	}
+}
+
+// ReloadTLS replaces the TLS certificates used to upgrade new client
+// connections. All other settings of the current TLS config, such as the
+// minimum TLS version and cipher suites, are preserved. Existing connections
+// are not affected and remain open. This could be used to rotate certificates
+// without restarting the server.
+func (srv *Server) ReloadTLS(certs []tls.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("at least a single TLS certificate is required")
+	}
+
+	for {
+		current := srv.tlsConfig.Load()
+
+		base := current
+		if base == nil {
+			base = srv.defaultTLSConfig()
+		}
+
+		config := base.Clone()
+		config.Certificates = certs
+
+		if srv.tlsConfig.CompareAndSwap(current, config) {
+			return nil
+		}
+	}
+}
+
+// currentTLSConfig returns the TLS config used to upgrade new client
+// connections. Nil is returned when no TLS certificates are available.
+func (srv *Server) currentTLSConfig() *tls.Config {
+	if config := srv.tlsConfig.Load(); config != nil {
+		return config
+	}
+
+	if len(srv.Certificates) == 0 {
+		return nil
+	}
+
+	return srv.defaultTLSConfig()
+}
+
+// defaultTLSConfig constructs the TLS config of the configured certificates
+// and client authentication settings.
+func (srv *Server) defaultTLSConfig() *tls.Config {
+	return &tls.Config{
+		Certificates: srv.Certificates,
+		ClientAuth:   srv.ClientAuth,
+		ClientCAs:    srv.ClientCAs,
+	}
+}
+
+// tlsConn represents a TLS server connection recording the certificate
+// presented to the client during the TLS handshake.
+type tlsConn struct {
+	*tls.Conn
+	certificate *tls.Certificate
+}
+
+// newTLSConn constructs a new TLS server connection using the given config.
+// The certificate presented to the client is recorded once the TLS handshake
+// has been performed.
+func newTLSConn(conn net.Conn, config *tls.Config) *tlsConn {
+	wrapped := &tlsConn{}
+
+	certificates := config.Certificates
+	get := config.GetCertificate
+
+	// NOTE: the certificates are cleared to ensure that the certificate is
+	// always selected through GetCertificate.
+	config = config.Clone()
+	config.Certificates = nil
+	config.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		certificate, err := selectCertificate(hello, certificates, get)
+		if err != nil {
+			return nil, err
+		}
+
+		wrapped.certificate = certificate
+		return certificate, nil
+	}
+
+	wrapped.Conn = tls.Server(conn, config)
+	return wrapped
+}
+
+// selectCertificate selects the certificate presented to the client. The
+// given get function is consulted first, the first certificate supported by
+// the client is selected otherwise.
+func selectCertificate(hello *tls.ClientHelloInfo, certificates []tls.Certificate, get func(*tls.ClientHelloInfo) (*tls.Certificate, error)) (*tls.Certificate, error) {
+	if get != nil {
+		certificate, err := get(hello)
+		if err != nil || certificate != nil {
+			return certificate, err
+		}
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("no TLS certificates configured")
+	}
+
+	for index := range certificates {
+		if hello.SupportsCertificate(&certificates[index]) == nil {
+			return &certificates[index], nil
+		}
+	}
+
+	return &certificates[0], nil
+}
+
+// setChannelBinding constructs a new context containing the
+// tls-server-end-point channel binding data of the given connection. The
+// given context is returned whenever the connection is not a TLS connection.
+func (srv *Server) setChannelBinding(ctx context.Context, conn net.Conn) context.Context {
+	secure, ok := conn.(*tlsConn)
+	if !ok || secure.certificate == nil {
+		return ctx
+	}
+
+	binding, err := tlsServerEndPoint(secure.certificate)
+	if err != nil {
+		srv.logger.Warn("unable to compute the TLS channel binding", zap.Error(err))
+		return ctx
+	}
+
+	return context.WithValue(ctx, ctxChannelBinding, binding)
+}
+
+// getChannelBinding returns the channel binding data if it has been set
+// inside the given context.
+func getChannelBinding(ctx context.Context) []byte {
+	val := ctx.Value(ctxChannelBinding)
+	if val == nil {
+		return nil
+	}
+
+	return val.([]byte)
+}
@@ -0,0 +1,191 @@
+package wire
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedCertificate generates a new self-signed certificate for the given
+// common name.
+func selfSignedCertificate(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestReloadTLS(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), Certificates([]tls.Certificate{selfSignedCertificate(t, "first")}))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+
+	// connect opens a new TLS connection and returns the common name of the
+	// certificate presented by the server.
+	connect := func(t *testing.T) (*pgx.Conn, string) {
+		config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d?sslmode=require", address.IP, address.Port))
+		require.NoError(t, err)
+
+		var name string
+		config.TLSConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			name = state.PeerCertificates[0].Subject.CommonName
+			return nil
+		}
+
+		conn, err := pgx.ConnectConfig(ctx, config)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close(ctx) })
+
+		return conn, name
+	}
+
+	existing, name := connect(t)
+	assert.Equal(t, "first", name)
+
+	err = server.ReloadTLS([]tls.Certificate{selfSignedCertificate(t, "second")})
+	require.NoError(t, err)
+
+	_, name = connect(t)
+	assert.Equal(t, "second", name)
+
+	// NOTE: existing connections should remain open
+	_, err = existing.Exec(ctx, "SELECT 1;")
+	assert.NoError(t, err)
+
+	t.Run("empty", func(t *testing.T) {
+		assert.Error(t, server.ReloadTLS(nil))
+	})
+}
+
+func TestReloadTLSPreservesConfig(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCertificate(t, "first")},
+		MinVersion:   tls.VersionTLS13,
+	}
+
+	server, err := NewServer(SimpleQuery(handler), TLS(config))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	err = server.ReloadTLS([]tls.Certificate{selfSignedCertificate(t, "second")})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	connect := func(version uint16) (string, error) {
+		config, err := pgx.ParseConfig(fmt.Sprintf("postgres://%s:%d?sslmode=require", address.IP, address.Port))
+		require.NoError(t, err)
+
+		var name string
+		config.TLSConfig.MaxVersion = version
+		config.TLSConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			name = state.PeerCertificates[0].Subject.CommonName
+			return nil
+		}
+
+		conn, err := pgx.ConnectConfig(ctx, config)
+		if err != nil {
+			return "", err
+		}
+
+		conn.Close(ctx)
+		return name, nil
+	}
+
+	name, err := connect(tls.VersionTLS13)
+	require.NoError(t, err)
+	assert.Equal(t, "second", name)
+
+	_, err = connect(tls.VersionTLS12)
+	assert.Error(t, err)
+}
+
+func TestTLS(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	ctx := context.Background()
+
+	t.Run("accepted", func(t *testing.T) {
+		config := &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t, "wire")}}
+		server, err := NewServer(SimpleQuery(handler), TLS(config))
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d?sslmode=require", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+
+	t.Run("declined", func(t *testing.T) {
+		server, err := NewServer(SimpleQuery(handler))
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+
+		_, err = pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d?sslmode=require", address.IP, address.Port))
+		assert.Error(t, err)
+
+		// NOTE: clients preferring SSL continue over the plain text connection
+		conn, err := pgx.Connect(ctx, fmt.Sprintf("postgres://%s:%d?sslmode=prefer", address.IP, address.Port))
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT 1;")
+		require.NoError(t, err)
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		_, err := NewServer(TLS(nil))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,108 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName represents the instrumentation name of the tracer used to create
+// connection and query spans.
+const tracerName = "github.com/jeroenrinzema/psql-wire"
+
+// traceParent matches a W3C trace context traceparent value.
+// https://www.w3.org/TR/trace-context/#traceparent-header
+var traceParent = regexp.MustCompile(`[0-9a-f]{2}-[0-9a-f]{32}-[0-9a-f]{16}-[0-9a-f]{2}`)
+
+// Tracing creates a OpenTelemetry span for each client connection using the
+// given tracer provider. A child span is created for each executed simple or
+// extended query, the query span is passed through the context to the query
+// handlers. Whenever the client embeds a W3C traceparent inside the
+// application_name startup parameter is the connection span linked to the
+// given trace.
+func Tracing(tp trace.TracerProvider) OptionFn {
+	return func(srv *Server) error {
+		if tp == nil {
+			return errors.New("tracer provider cannot be nil")
+		}
+
+		srv.tracer = tp.Tracer(tracerName)
+		return nil
+	}
+}
+
+// traceAttributes returns the span attributes describing the client
+// connection of the given context.
+func traceAttributes(ctx context.Context) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{attribute.String("db.system", "postgresql")}
+	if addr := RemoteAddress(ctx); addr != nil {
+		attributes = append(attributes, attribute.String("net.peer.addr", addr.String()))
+	}
+
+	return attributes
+}
+
+// traceConn starts a new root span for the client connection of the given
+// context. The span is linked to the trace context embedded inside the
+// application name whenever present. The returned function ends the span.
+func (srv *Server) traceConn(ctx context.Context) (context.Context, func()) {
+	if srv.tracer == nil {
+		return ctx, func() {}
+	}
+
+	options := []trace.SpanStartOption{
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(traceAttributes(ctx)...),
+	}
+
+	if remote := applicationTraceContext(ctx); remote.IsValid() {
+		options = append(options, trace.WithLinks(trace.Link{SpanContext: remote}))
+	}
+
+	ctx, span := srv.tracer.Start(ctx, "postgres.connection", options...)
+	return ctx, func() { span.End() }
+}
+
+// traceQuery starts a new child span for the given query. The returned
+// function ends the span and marks the span as failed whenever a error is
+// given.
+func (srv *Server) traceQuery(ctx context.Context, query string) (context.Context, func(err error)) {
+	if srv.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	attributes := append(traceAttributes(ctx), attribute.String("db.statement", query))
+	ctx, span := srv.tracer.Start(ctx, "postgres.query",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attributes...),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+// applicationTraceContext extracts the W3C trace context embedded inside the
+// application name of the client connection of the given context. A invalid
+// span context is returned whenever no trace context has been embedded.
+func applicationTraceContext(ctx context.Context) trace.SpanContext {
+	parent := traceParent.FindString(ClientParameters(ctx)[ParamApplicationName])
+	if parent == "" {
+		return trace.SpanContext{}
+	}
+
+	carrier := propagation.MapCarrier{"traceparent": parent}
+	remote := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(remote)
+}
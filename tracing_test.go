@@ -0,0 +1,99 @@
+package wire
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	handled := make(chan trace.SpanContext, 2)
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		handled <- trace.SpanContextFromContext(ctx)
+
+		if query == "FAIL" {
+			return errors.New("unexpected failure")
+		}
+
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), Tracing(provider))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID := "00f067aa0ba902b7"
+	application := fmt.Sprintf("00-%s-%s-01", traceID, spanID)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d?sslmode=disable&application_name=%s", address.IP, address.Port, application)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+
+	_, err = conn.Exec(ctx, "FAIL", pgx.QueryExecModeSimpleProtocol)
+	require.Error(t, err)
+
+	require.NoError(t, conn.Close(ctx))
+
+	var spans []sdktrace.ReadOnlySpan
+	require.Eventually(t, func() bool {
+		spans = recorder.Ended()
+		return len(spans) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	queries, connection := spans[:2], spans[2]
+	assert.Equal(t, "postgres.connection", connection.Name())
+	assert.False(t, connection.Parent().IsValid())
+	require.Len(t, connection.Links(), 1)
+	assert.Equal(t, traceID, connection.Links()[0].SpanContext.TraceID().String())
+	assert.Equal(t, spanID, connection.Links()[0].SpanContext.SpanID().String())
+	assert.Contains(t, connection.Attributes(), attribute.String("db.system", "postgresql"))
+
+	expected := []struct {
+		statement string
+		status    otelcodes.Code
+	}{
+		{statement: "SELECT 1;", status: otelcodes.Unset},
+		{statement: "FAIL", status: otelcodes.Error},
+	}
+
+	for index, query := range queries {
+		assert.Equal(t, "postgres.query", query.Name())
+		assert.Equal(t, connection.SpanContext().SpanID(), query.Parent().SpanID())
+		assert.Equal(t, connection.SpanContext().TraceID(), query.SpanContext().TraceID())
+		assert.Equal(t, expected[index].status, query.Status().Code)
+
+		attributes := query.Attributes()
+		assert.Contains(t, attributes, attribute.String("db.system", "postgresql"))
+		assert.Contains(t, attributes, attribute.String("db.statement", expected[index].statement))
+		assert.Contains(t, attributes, attribute.String("net.peer.addr", conn.PgConn().Conn().LocalAddr().String()))
+
+		assert.Equal(t, query.SpanContext().SpanID(), (<-handled).SpanID())
+	}
+}
+
+func TestInvalidTracing(t *testing.T) {
+	_, err := NewServer(Tracing(nil))
+	assert.Error(t, err)
+}
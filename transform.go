@@ -0,0 +1,163 @@
+package wire
+
+import (
+	"context"
+	"fmt"
+)
+
+// RowTransformFn represents a function transforming a data row before it is
+// written to the client. The returned values are written instead of the given
+// values. Returning a nil row drops the row entirely.
+type RowTransformFn func(row []any) ([]any, error)
+
+// transformWriter wraps a data writer and transforms all rows before they are
+// forwarded to the underlying data writer. JSON results and raw output
+// (WriteCSV and WriteRaw) are rejected as the transformation could not be
+// applied to them.
+type transformWriter struct {
+	wrappedWriter
+	transform RowTransformFn
+}
+
+func (writer *transformWriter) Row(values []any) error {
+	values, err := writer.transform(values)
+	if err != nil {
+		return err
+	}
+
+	if values == nil {
+		return nil
+	}
+
+	return writer.DataWriter.Row(values)
+}
+
+func (writer *transformWriter) Batch(rows [][]any) error {
+	transformed := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values, err := writer.transform(row)
+		if err != nil {
+			return err
+		}
+
+		if values == nil {
+			continue
+		}
+
+		transformed = append(transformed, values)
+	}
+
+	return Batch(writer.DataWriter, transformed)
+}
+
+// Peek validates the transformed row. Rows dropped by the transformation are
+// considered valid.
+func (writer *transformWriter) Peek(row []any) error {
+	values, err := writer.transform(row)
+	if err != nil {
+		return err
+	}
+
+	if values == nil {
+		return nil
+	}
+
+	return Peek(writer.DataWriter, values)
+}
+
+// mapJSONRow rejects JSON results, the transformation expects rows ordered by
+// the defined columns.
+func (writer *transformWriter) mapJSONRow(map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("%w: rows are transformed", ErrJSONResultUnsupported)
+}
+
+// RowFilterFn represents a function deciding whether the given data row is
+// allowed to be written to the client. Rows are silently dropped whenever
+// false is returned. Returning an error fails the query.
+type RowFilterFn func(ctx context.Context, row []any) (bool, error)
+
+// filterWriter wraps a data writer and drops all rows rejected by the filter
+// before they are forwarded to the underlying data writer. JSON results and
+// raw output (WriteCSV and WriteRaw) are rejected as the filter could not be
+// applied to them.
+type filterWriter struct {
+	wrappedWriter
+	ctx    context.Context
+	filter RowFilterFn
+}
+
+func (writer *filterWriter) Row(values []any) error {
+	allowed, err := writer.filter(writer.ctx, values)
+	if err != nil {
+		return err
+	}
+
+	if !allowed {
+		return nil
+	}
+
+	return writer.DataWriter.Row(values)
+}
+
+func (writer *filterWriter) Batch(rows [][]any) error {
+	filtered := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		allowed, err := writer.filter(writer.ctx, row)
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			continue
+		}
+
+		filtered = append(filtered, row)
+	}
+
+	return Batch(writer.DataWriter, filtered)
+}
+
+// mapJSONRow rejects JSON results, the filter expects rows ordered by the
+// defined columns.
+func (writer *filterWriter) mapJSONRow(map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("%w: rows are filtered", ErrJSONResultUnsupported)
+}
+
+// wrapDataWriter wraps the given data writer with the configured row filters,
+// transformations, column masks and column encryption. Rows are filtered
+// before they are transformed, filters and transformations are applied in the
+// order in which they have been defined. Columns are masked before they are
+// encrypted.
+func (srv *Server) wrapDataWriter(ctx context.Context, writer DataWriter) DataWriter {
+	if srv.encryption != nil {
+		writer = &encryptWriter{
+			wrappedWriter: wrappedWriter{writer},
+			ctx:           ctx,
+			encryption:    srv.encryption,
+		}
+	}
+
+	if len(srv.masks) > 0 {
+		writer = &maskWriter{
+			wrappedWriter: wrappedWriter{writer},
+			masks:         srv.masks,
+		}
+	}
+
+	for index := len(srv.transforms) - 1; index >= 0; index-- {
+		writer = &transformWriter{
+			wrappedWriter: wrappedWriter{writer},
+			transform:     srv.transforms[index],
+		}
+	}
+
+	for index := len(srv.filters) - 1; index >= 0; index-- {
+		writer = &filterWriter{
+			wrappedWriter: wrappedWriter{writer},
+			ctx:           ctx,
+			filter:        srv.filters[index],
+		}
+	}
+
+	return writer
+}
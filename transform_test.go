@@ -0,0 +1,271 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransformRows(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "name", Oid: oid.T_text, Format: TextFormat},
+			{Name: "email", Oid: oid.T_text, Format: TextFormat},
+		})
+
+		writer.Row([]any{"John", "john@example.com"})   //nolint:errcheck
+		writer.Row([]any{"admin", "root@example.com"})  //nolint:errcheck
+		writer.Row([]any{"Marry", "marry@example.com"}) //nolint:errcheck
+		return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+	}
+
+	filter := TransformRows(func(row []any) ([]any, error) {
+		if row[0] == "admin" {
+			return nil, nil
+		}
+
+		return row, nil
+	})
+
+	mask := TransformRows(func(row []any) ([]any, error) {
+		email, ok := row[1].(string)
+		if !ok {
+			return nil, errors.New("unexpected email value")
+		}
+
+		at := strings.Index(email, "@")
+		return []any{row[0], strings.Repeat("*", at) + email[at:]}, nil
+	})
+
+	server, err := NewServer(SimpleQuery(handler), filter, mask)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT name, email FROM users;")
+	require.NoError(t, err)
+
+	result := [][]string{}
+	for rows.Next() {
+		var name, email string
+		require.NoError(t, rows.Scan(&name, &email))
+		result = append(result, []string{name, email})
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, "SELECT 2", rows.CommandTag().String())
+
+	expected := [][]string{
+		{"John", "****@example.com"},
+		{"Marry", "*****@example.com"},
+	}
+
+	assert.Equal(t, expected, result)
+}
+
+func TestRowFilter(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "user", Oid: oid.T_text, Format: TextFormat},
+			{Name: "balance", Oid: oid.T_int4, Format: TextFormat},
+		})
+
+		writer.Row([]any{"john", 10})                                  //nolint:errcheck
+		Batch(writer, [][]any{{"marry", 20}, {"john", 30}})            //nolint:errcheck
+		MapRow(writer, map[string]any{"user": "marry", "balance": 40}) //nolint:errcheck
+		return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+	}
+
+	rls := RowFilter(func(ctx context.Context, row []any) (bool, error) {
+		return row[0] == ClientParameters(ctx)[ParamUsername], nil
+	})
+
+	server, err := NewServer(SimpleQuery(handler), rls)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+	ctx := context.Background()
+
+	query := func(t *testing.T, username string) ([]int32, string) {
+		connstr := fmt.Sprintf("postgres://%s@%s:%d", username, address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		rows, err := conn.Query(ctx, "SELECT user, balance FROM accounts;")
+		require.NoError(t, err)
+
+		result := []int32{}
+		for rows.Next() {
+			var user string
+			var balance int32
+			require.NoError(t, rows.Scan(&user, &balance))
+			assert.Equal(t, username, user)
+			result = append(result, balance)
+		}
+
+		require.NoError(t, rows.Err())
+		return result, rows.CommandTag().String()
+	}
+
+	t.Run("john", func(t *testing.T) {
+		result, tag := query(t, "john")
+		assert.Equal(t, []int32{10, 30}, result)
+		assert.Equal(t, "SELECT 2", tag)
+	})
+
+	t.Run("marry", func(t *testing.T) {
+		result, tag := query(t, "marry")
+		assert.Equal(t, []int32{20, 40}, result)
+		assert.Equal(t, "SELECT 2", tag)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		result, tag := query(t, "unknown")
+		assert.Empty(t, result)
+		assert.Equal(t, "SELECT 0", tag)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+			writer.Define(Columns{{Name: "user", Oid: oid.T_text, Format: TextFormat}}) //nolint:errcheck
+
+			err := writer.Row([]any{"john"})
+			if err != nil {
+				return err
+			}
+
+			return writer.Complete("SELECT 1")
+		}
+
+		failing := RowFilter(func(ctx context.Context, row []any) (bool, error) {
+			return false, errors.New("unexpected row")
+		})
+
+		server, err := NewServer(SimpleQuery(handler), failing)
+		require.NoError(t, err)
+
+		address := TListenAndServe(t, server)
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		_, err = conn.Exec(ctx, "SELECT user, balance FROM accounts;")
+		assert.Error(t, err)
+	})
+}
+
+func TestRowFilterOutputPaths(t *testing.T) {
+	t.Parallel()
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	columns := Columns{
+		{Name: "user", Oid: oid.T_text, Format: TextFormat},
+		{Name: "balance", Oid: oid.T_int4, Format: TextFormat},
+	}
+
+	filter := func(ctx context.Context, row []any) (bool, error) {
+		return row[0] == "john", nil
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		writer := &filterWriter{wrappedWriter: wrappedWriter{inner}, ctx: ctx, filter: filter}
+		require.NoError(t, writer.Define(columns))
+
+		require.NoError(t, writer.Row([]any{"marry", 10}))
+		require.NoError(t, Batch(writer, [][]any{{"john", 20}, {"marry", 30}}))
+		require.NoError(t, MapRow(writer, map[string]any{"user": "marry", "balance": 40}))
+		require.NoError(t, MapRow(writer, map[string]any{"user": "john", "balance": 50}))
+
+		assert.Equal(t, [][]any{{"john", 20}, {"john", 50}}, ColumnValues(writer))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		writer := &filterWriter{wrappedWriter: wrappedWriter{inner}, ctx: ctx, filter: filter}
+
+		err := JSONResult(writer, "SELECT 1", []map[string]any{{"user": "marry", "balance": 10}})
+		assert.ErrorIs(t, err, ErrJSONResultUnsupported)
+		assert.Nil(t, inner.columns)
+		assert.Empty(t, inner.rows)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		assertRawRejected(t, func(inner DataWriter) DataWriter {
+			return &filterWriter{wrappedWriter: wrappedWriter{inner}, ctx: ctx, filter: filter}
+		})
+	})
+}
+
+func TestTransformRowsOutputPaths(t *testing.T) {
+	t.Parallel()
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+	upper := func(row []any) ([]any, error) {
+		return []any{strings.ToUpper(row[0].(string))}, nil
+	}
+
+	wrap := func(inner DataWriter) DataWriter {
+		return &transformWriter{wrappedWriter: wrappedWriter{inner}, transform: upper}
+	}
+
+	t.Run("rows", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		writer := wrap(inner)
+		require.NoError(t, writer.Define(Columns{{Name: "user", Oid: oid.T_text, Format: TextFormat}}))
+
+		require.NoError(t, writer.Row([]any{"john"}))
+		require.NoError(t, Batch(writer, [][]any{{"marry"}}))
+		require.NoError(t, MapRow(writer, map[string]any{"user": "jane"}))
+
+		assert.Equal(t, [][]any{{"JOHN"}, {"MARRY"}, {"JANE"}}, ColumnValues(writer))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		inner := &recordWriter{ctx: ctx}
+		err := JSONResult(wrap(inner), "SELECT 1", []map[string]any{{"user": "john"}})
+		assert.ErrorIs(t, err, ErrJSONResultUnsupported)
+		assert.Empty(t, inner.rows)
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		assertRawRejected(t, wrap)
+	})
+}
+
+// assertRawRejected asserts that the data writer constructed by the given
+// function rejects raw output without writing it to the wrapped data writer.
+func assertRawRejected(t *testing.T, wrap func(DataWriter) DataWriter) {
+	sink := &bytes.Buffer{}
+	inner := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(sink))
+	writer := wrap(inner)
+
+	require.NoError(t, writer.Define(Columns{{Name: "user", Oid: oid.T_text, Format: TextFormat}}))
+	written := sink.Len()
+
+	assert.ErrorIs(t, WriteCSV(writer, [][]string{{"marry"}}), ErrCopyUnsupported)
+	assert.ErrorIs(t, WriteRaw(writer, byte(types.ServerDataRow), []byte{0, 0}), ErrRawUnsupported)
+	assert.Equal(t, written, sink.Len())
+}
@@ -0,0 +1,472 @@
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// TSWeight represents the weight of a full text search lexeme position. The
+// weight D is the default weight and is omitted in the text format.
+type TSWeight uint8
+
+// Full text search weights as defined by Postgres.
+// https://www.postgresql.org/docs/current/textsearch-controls.html
+const (
+	TSWeightD TSWeight = iota
+	TSWeightC
+	TSWeightB
+	TSWeightA
+)
+
+// String returns the textual representation of the given weight.
+func (weight TSWeight) String() string {
+	switch weight {
+	case TSWeightA:
+		return "A"
+	case TSWeightB:
+		return "B"
+	case TSWeightC:
+		return "C"
+	default:
+		return "D"
+	}
+}
+
+// TSPosition represents a single lexeme position within a document. Positions
+// range between 1 and 16383.
+type TSPosition struct {
+	Position uint16
+	Weight   TSWeight
+}
+
+// TSLexeme represents a normalized word inside a tsvector including the
+// (optional) positions at which the word occurs.
+type TSLexeme struct {
+	Word      string
+	Positions []TSPosition
+}
+
+// TSVector represents a Postgres tsvector value. A tsvector is a sorted list
+// of distinct lexemes.
+// https://www.postgresql.org/docs/current/datatype-textsearch.html
+type TSVector struct {
+	Lexemes []TSLexeme
+	Status  pgtype.Status
+}
+
+// Set converts and assigns the given source to itself.
+func (dst *TSVector) Set(src any) error {
+	if src == nil {
+		*dst = TSVector{Status: pgtype.Null}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case TSVector:
+		*dst = value
+	case *TSVector:
+		if value == nil {
+			*dst = TSVector{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = *value
+	case []TSLexeme:
+		*dst = TSVector{Lexemes: value, Status: pgtype.Present}
+	default:
+		return fmt.Errorf("cannot convert %T to tsvector", src)
+	}
+
+	if dst.Status == pgtype.Undefined {
+		dst.Status = pgtype.Present
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst TSVector) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		return dst
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *TSVector) AssignTo(dst any) error {
+	if src.Status != pgtype.Present {
+		return fmt.Errorf("cannot assign non-present status to %T", dst)
+	}
+
+	switch value := dst.(type) {
+	case *TSVector:
+		*value = *src
+	case *string:
+		*value = string(src.format(nil))
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer. The
+// text format consists out of space separated quoted lexemes followed by
+// their positions ('fat':2 'rat':3A).
+func (src TSVector) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return src.format(buf), nil
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+// https://github.com/postgres/postgres/blob/master/src/backend/utils/adt/tsvector.c
+func (src TSVector) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(src.Lexemes)))
+	for _, lexeme := range src.Lexemes {
+		buf = append(buf, lexeme.Word...)
+		buf = append(buf, 0)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(lexeme.Positions)))
+
+		for _, position := range lexeme.Positions {
+			// NOTE: the weight is stored inside the two most significant bits
+			// of the position.
+			buf = binary.BigEndian.AppendUint16(buf, uint16(position.Weight)<<14|position.Position&0x3fff)
+		}
+	}
+
+	return buf, nil
+}
+
+func (src TSVector) format(buf []byte) []byte {
+	for index, lexeme := range src.Lexemes {
+		if index > 0 {
+			buf = append(buf, ' ')
+		}
+
+		buf = appendTSLexeme(buf, lexeme.Word)
+
+		for index, position := range lexeme.Positions {
+			if index == 0 {
+				buf = append(buf, ':')
+			} else {
+				buf = append(buf, ',')
+			}
+
+			buf = strconv.AppendUint(buf, uint64(position.Position), 10)
+			if position.Weight != TSWeightD {
+				buf = append(buf, position.Weight.String()...)
+			}
+		}
+	}
+
+	return buf
+}
+
+// TSOperator represents a full text search query operator.
+type TSOperator uint8
+
+// Full text search query operators. The values match the operator codes used
+// inside the Postgres binary format.
+const (
+	TSOperand TSOperator = iota
+	TSNot
+	TSAnd
+	TSOr
+	TSPhrase
+)
+
+// priority returns the operator priority used to determine whether
+// parentheses are required when formatting nested operators.
+func (operator TSOperator) priority() int {
+	switch operator {
+	case TSOr:
+		return 1
+	case TSAnd:
+		return 2
+	case TSPhrase:
+		return 3
+	case TSNot:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// TSQueryNode represents a single node inside a full text search query tree.
+// Operand nodes define the lexeme to be matched. Binary operators use both the
+// left and right nodes while the not operator only uses the left node.
+type TSQueryNode struct {
+	Operator TSOperator
+	Lexeme   string
+	Weights  []TSWeight
+	Prefix   bool
+	Distance int16 // phrase distance, defaults to 1 (<->)
+	Left     *TSQueryNode
+	Right    *TSQueryNode
+}
+
+// TSQuery represents a Postgres tsquery value.
+// https://www.postgresql.org/docs/current/datatype-textsearch.html
+type TSQuery struct {
+	Root   *TSQueryNode
+	Status pgtype.Status
+}
+
+// Set converts and assigns the given source to itself.
+func (dst *TSQuery) Set(src any) error {
+	if src == nil {
+		*dst = TSQuery{Status: pgtype.Null}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case TSQuery:
+		*dst = value
+	case *TSQuery:
+		if value == nil {
+			*dst = TSQuery{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = *value
+	case *TSQueryNode:
+		*dst = TSQuery{Root: value, Status: pgtype.Present}
+	default:
+		return fmt.Errorf("cannot convert %T to tsquery", src)
+	}
+
+	if dst.Status == pgtype.Undefined {
+		dst.Status = pgtype.Present
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst TSQuery) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		return dst
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *TSQuery) AssignTo(dst any) error {
+	if src.Status != pgtype.Present {
+		return fmt.Errorf("cannot assign non-present status to %T", dst)
+	}
+
+	switch value := dst.(type) {
+	case *TSQuery:
+		*value = *src
+	case *string:
+		bb, err := src.EncodeText(nil, nil)
+		if err != nil {
+			return err
+		}
+
+		*value = string(bb)
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer
+// ('fat' & ( 'rat' | 'cat' )).
+func (src TSQuery) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	if src.Root == nil {
+		return buf, nil
+	}
+
+	return appendTSQueryNode(buf, src.Root, 0)
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer. The
+// query tree is written in prefix notation where binary operators are followed
+// by their right and left operands.
+// https://github.com/postgres/postgres/blob/master/src/backend/utils/adt/tsquery.c
+func (src TSQuery) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	items := flattenTSQuery(nil, src.Root)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(items)))
+
+	for _, item := range items {
+		if item.Operator == TSOperand {
+			buf = append(buf, 1, item.weights())
+			if item.Prefix {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+
+			buf = append(buf, item.Lexeme...)
+			buf = append(buf, 0)
+			continue
+		}
+
+		buf = append(buf, 2, byte(item.Operator))
+		if item.Operator == TSPhrase {
+			buf = binary.BigEndian.AppendUint16(buf, uint16(item.distance()))
+		}
+	}
+
+	return buf, nil
+}
+
+// weights returns the operand weights as bitmask.
+func (node *TSQueryNode) weights() (mask byte) {
+	for _, weight := range node.Weights {
+		mask |= 1 << weight
+	}
+
+	return mask
+}
+
+func (node *TSQueryNode) distance() int16 {
+	if node.Distance == 0 {
+		return 1
+	}
+
+	return node.Distance
+}
+
+// flattenTSQuery flattens the given query tree into prefix notation.
+func flattenTSQuery(items []*TSQueryNode, node *TSQueryNode) []*TSQueryNode {
+	if node == nil {
+		return items
+	}
+
+	items = append(items, node)
+
+	switch node.Operator {
+	case TSOperand:
+		return items
+	case TSNot:
+		return flattenTSQuery(items, node.Left)
+	default:
+		items = flattenTSQuery(items, node.Right)
+		return flattenTSQuery(items, node.Left)
+	}
+}
+
+func appendTSQueryNode(buf []byte, node *TSQueryNode, parent int) ([]byte, error) {
+	if node == nil {
+		return nil, errors.New("unexpected empty tsquery node")
+	}
+
+	if node.Operator == TSOperand {
+		buf = appendTSLexeme(buf, node.Lexeme)
+		if node.Prefix || len(node.Weights) > 0 {
+			buf = append(buf, ':')
+		}
+
+		if node.Prefix {
+			buf = append(buf, '*')
+		}
+
+		mask := node.weights()
+		for _, weight := range []TSWeight{TSWeightA, TSWeightB, TSWeightC, TSWeightD} {
+			if mask&(1<<weight) != 0 {
+				buf = append(buf, weight.String()...)
+			}
+		}
+
+		return buf, nil
+	}
+
+	priority := node.Operator.priority()
+	nested := priority < parent
+	if nested {
+		buf = append(buf, "( "...)
+	}
+
+	var err error
+	switch node.Operator {
+	case TSNot:
+		buf = append(buf, '!')
+		buf, err = appendTSQueryNode(buf, node.Left, priority)
+	default:
+		buf, err = appendTSQueryNode(buf, node.Left, priority)
+		if err != nil {
+			return nil, err
+		}
+
+		switch node.Operator {
+		case TSAnd:
+			buf = append(buf, " & "...)
+		case TSOr:
+			buf = append(buf, " | "...)
+		case TSPhrase:
+			if node.distance() == 1 {
+				buf = append(buf, " <-> "...)
+			} else {
+				buf = append(buf, " <"...)
+				buf = strconv.AppendInt(buf, int64(node.distance()), 10)
+				buf = append(buf, "> "...)
+			}
+		default:
+			return nil, fmt.Errorf("unknown tsquery operator: %d", node.Operator)
+		}
+
+		buf, err = appendTSQueryNode(buf, node.Right, priority)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nested {
+		buf = append(buf, " )"...)
+	}
+
+	return buf, nil
+}
+
+// appendTSLexeme appends the given lexeme as a quoted string. Single quotes and
+// backslashes inside the lexeme are escaped.
+func appendTSLexeme(buf []byte, lexeme string) []byte {
+	buf = append(buf, '\'')
+	buf = append(buf, strings.NewReplacer(`'`, `''`, `\`, `\\`).Replace(lexeme)...)
+	return append(buf, '\'')
+}
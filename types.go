@@ -0,0 +1,29 @@
+package wire
+
+import (
+	"github.com/jackc/pgtype"
+	"github.com/lib/pq/oid"
+)
+
+// newTypeInfo constructs the default Postgres type connection info used by the
+// server. Data types which are not supported by pgtype out of the box are
+// registered on top of the pgtype defaults.
+func newTypeInfo() *pgtype.ConnInfo {
+	ci := pgtype.NewConnInfo()
+	ci.RegisterDataType(pgtype.DataType{Value: &XML{}, Name: "xml", OID: uint32(oid.T_xml)})
+	ci.RegisterDataType(pgtype.DataType{Value: &JSON{}, Name: "json", OID: uint32(oid.T_json)})
+	ci.RegisterDataType(pgtype.DataType{Value: &JSONB{}, Name: "jsonb", OID: uint32(oid.T_jsonb)})
+	ci.RegisterDataType(pgtype.DataType{Value: &Timestamp{}, Name: "timestamp", OID: uint32(oid.T_timestamp)})
+	ci.RegisterDataType(pgtype.DataType{Value: &Timestamptz{}, Name: "timestamptz", OID: uint32(oid.T_timestamptz)})
+	ci.RegisterDataType(pgtype.DataType{Value: &Money{}, Name: "money", OID: uint32(oid.T_money)})
+	ci.RegisterDataType(pgtype.DataType{Value: &TSVector{}, Name: "tsvector", OID: uint32(oid.T_tsvector)})
+	ci.RegisterDataType(pgtype.DataType{Value: &TSQuery{}, Name: "tsquery", OID: uint32(oid.T_tsquery)})
+	ci.RegisterDataType(pgtype.DataType{Value: &RegClass{}, Name: "regclass", OID: uint32(oid.T_regclass)})
+	ci.RegisterDataType(pgtype.DataType{Value: pgtype.NewArrayType("_oid", uint32(oid.T_oid), newOIDValue), Name: "_oid", OID: uint32(oid.T__oid)})
+	return ci
+}
+
+// newOIDValue constructs a new oid value used as array element.
+func newOIDValue() pgtype.ValueTranscoder {
+	return &pgtype.OIDValue{}
+}
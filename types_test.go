@@ -0,0 +1,480 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXMLColumn(t *testing.T) {
+	t.Parallel()
+
+	type book struct {
+		Title string `xml:"title"`
+	}
+
+	expected := []string{
+		"<book><title>Dune</title></book>",
+		"<book><title>Solaris</title></book>",
+		"<book><title>Neuromancer</title></book>",
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{
+				Name:   "book",
+				Oid:    oid.T_xml,
+				Format: TextFormat,
+			},
+		})
+
+		writer.Row([]any{expected[0]})                //nolint:errcheck
+		writer.Row([]any{[]byte(expected[1])})        //nolint:errcheck
+		writer.Row([]any{book{Title: "Neuromancer"}}) //nolint:errcheck
+		return writer.Complete("SELECT 3")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	t.Run("lib/pq", func(t *testing.T) {
+		connstr := fmt.Sprintf("host=%s port=%d sslmode=disable", address.IP, address.Port)
+		conn, err := sql.Open("postgres", connstr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rows, err := conn.Query("SELECT *;")
+		require.NoError(t, err)
+
+		result := []string{}
+		for rows.Next() {
+			var value string
+			require.NoError(t, rows.Scan(&value))
+			result = append(result, value)
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, expected, result)
+	})
+
+	t.Run("jackc/pgx", func(t *testing.T) {
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		rows, err := conn.Query(ctx, "SELECT *;")
+		require.NoError(t, err)
+
+		result := []string{}
+		for rows.Next() {
+			var value string
+			require.NoError(t, rows.Scan(&value))
+			result = append(result, value)
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, expected, result)
+	})
+}
+
+func TestXMLBinaryEncoding(t *testing.T) {
+	value := &XML{}
+	require.NoError(t, value.Set("<a/>"))
+
+	text, err := value.EncodeText(nil, nil)
+	require.NoError(t, err)
+
+	binary, err := value.EncodeBinary(nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("<a/>"), text)
+	assert.Equal(t, text, binary)
+}
+
+func TestMoneyColumn(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			MoneyColumn("text", TextFormat),
+			MoneyColumn("binary", BinaryFormat),
+		})
+
+		writer.Row([]any{int64(100), int64(100)})       //nolint:errcheck
+		writer.Row([]any{int64(-1205), int64(-1205)})   //nolint:errcheck
+		writer.Row([]any{int64(123456), int64(123456)}) //nolint:errcheck
+		return writer.Complete("SELECT 3")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	// NOTE: pgx does not ship with a money type, the money type is registered
+	// as a 8-byte integer which matches the binary format.
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "money", OID: uint32(oid.T_money), Codec: pgtype.Int8Codec{}})
+
+	rows, err := conn.Query(ctx, "SELECT *;")
+	require.NoError(t, err)
+
+	type row struct {
+		text  string
+		cents int64
+	}
+
+	expected := []row{
+		{text: "$1.00", cents: 100},
+		{text: "-$12.05", cents: -1205},
+		{text: "$1234.56", cents: 123456},
+	}
+
+	result := []row{}
+	for rows.Next() {
+		var value row
+		require.NoError(t, rows.Scan(&value.text, &value.cents))
+		result = append(result, value)
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, expected, result)
+}
+
+func TestTSVectorColumn(t *testing.T) {
+	t.Parallel()
+
+	vector := TSVector{
+		Lexemes: []TSLexeme{
+			{Word: "cat", Positions: []TSPosition{{Position: 3}}},
+			{Word: "fat", Positions: []TSPosition{{Position: 2, Weight: TSWeightA}, {Position: 4}}},
+			{Word: "it's"},
+		},
+	}
+
+	query := &TSQueryNode{
+		Operator: TSAnd,
+		Left:     &TSQueryNode{Lexeme: "fat", Weights: []TSWeight{TSWeightB, TSWeightA}},
+		Right: &TSQueryNode{
+			Operator: TSOr,
+			Left:     &TSQueryNode{Lexeme: "rat", Prefix: true},
+			Right:    &TSQueryNode{Operator: TSNot, Left: &TSQueryNode{Lexeme: "cat"}},
+		},
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "document", Oid: oid.T_tsvector, Format: TextFormat},
+		})
+
+		writer.Row([]any{vector}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var document string
+	err = conn.QueryRow(ctx, "SELECT *;").Scan(&document)
+	require.NoError(t, err)
+	assert.Equal(t, `'cat':3 'fat':2A,4 'it''s'`, document)
+
+	t.Run("binary", func(t *testing.T) {
+		value := &TSVector{}
+		require.NoError(t, value.Set(vector))
+
+		bb, err := value.EncodeBinary(nil, nil)
+		require.NoError(t, err)
+
+		expected := []byte{
+			0, 0, 0, 3,
+			'c', 'a', 't', 0, 0, 1, 0, 3,
+			'f', 'a', 't', 0, 0, 2, 0xc0, 2, 0, 4,
+			'i', 't', '\'', 's', 0, 0, 0,
+		}
+
+		assert.Equal(t, expected, bb)
+	})
+
+	t.Run("query", func(t *testing.T) {
+		value := &TSQuery{}
+		require.NoError(t, value.Set(query))
+
+		text, err := value.EncodeText(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, `'fat':AB & ( 'rat':* | !'cat' )`, string(text))
+
+		bb, err := value.EncodeBinary(nil, nil)
+		require.NoError(t, err)
+
+		expected := []byte{
+			0, 0, 0, 6,
+			2, byte(TSAnd),
+			2, byte(TSOr),
+			2, byte(TSNot),
+			1, 0, 0, 'c', 'a', 't', 0,
+			1, 0, 1, 'r', 'a', 't', 0,
+			1, 12, 0, 'f', 'a', 't', 0,
+		}
+
+		assert.Equal(t, expected, bb)
+	})
+}
+
+func TestJSONBColumn(t *testing.T) {
+	t.Parallel()
+
+	value := map[string]any{"name": "John", "age": float64(28)}
+
+	t.Run("version", func(t *testing.T) {
+		tests := map[FormatCode][]byte{
+			TextFormat:   []byte(`{"age":28,"name":"John"}`),
+			BinaryFormat: append([]byte{1}, []byte(`{"age":28,"name":"John"}`)...),
+		}
+
+		for format, expected := range tests {
+			ctx := setTypeInfo(context.Background(), newTypeInfo())
+			writer := buffer.NewWriter(&bytes.Buffer{})
+			writer.Start(types.ServerDataRow)
+
+			column := Column{Name: "data", Oid: oid.T_jsonb, Format: format}
+			err := column.Write(ctx, writer, value)
+			require.NoError(t, err)
+
+			// NOTE: the written message contains the message type (1 byte),
+			// message length (4 bytes) and value length (4 bytes).
+			assert.Equal(t, expected, writer.Bytes()[9:])
+		}
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "text", Oid: oid.T_jsonb, Format: TextFormat},
+			{Name: "binary", Oid: oid.T_jsonb, Format: BinaryFormat},
+		})
+
+		writer.Row([]any{value, value}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var text, binary map[string]any
+	err = conn.QueryRow(ctx, "SELECT *;").Scan(&text, &binary)
+	require.NoError(t, err)
+
+	assert.Equal(t, value, text)
+	assert.Equal(t, value, binary)
+}
+
+func TestRegClassColumn(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "registered", Oid: oid.T_regclass, Format: TextFormat},
+			{Name: "unknown", Oid: oid.T_regclass, Format: TextFormat},
+			{Name: "binary", Oid: oid.T_regclass, Format: BinaryFormat},
+		})
+
+		writer.Row([]any{uint32(16384), uint32(99), uint32(16384)}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler), RegisterClass(16384, "public.users"))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	// NOTE: pgx does not ship with a regclass type, the regclass type is
+	// registered as a 4-byte unsigned integer which matches the binary format.
+	conn.TypeMap().RegisterType(&pgtype.Type{Name: "regclass", OID: uint32(oid.T_regclass), Codec: pgtype.Uint32Codec{}})
+
+	var registered, unknown string
+	var binary uint32
+	err = conn.QueryRow(ctx, "SELECT 'users'::regclass, 99::regclass, 'users'::regclass;").Scan(&registered, &unknown, &binary)
+	require.NoError(t, err)
+
+	assert.Equal(t, "public.users", registered)
+	assert.Equal(t, "99", unknown)
+	assert.Equal(t, uint32(16384), binary)
+}
+
+func TestOIDArrayColumn(t *testing.T) {
+	t.Parallel()
+
+	expected := []uint32{uint32(oid.T_int4), uint32(oid.T_text), uint32(oid.T_bool)}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "text", Oid: oid.T__oid, Format: TextFormat},
+			{Name: "binary", Oid: oid.T__oid, Format: BinaryFormat},
+		})
+
+		writer.Row([]any{expected, expected}) //nolint:errcheck
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var text, binary []uint32
+	err = conn.QueryRow(ctx, "SELECT array_agg(oid) FROM pg_type;").Scan(&text, &binary)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, text)
+	assert.Equal(t, expected, binary)
+}
+
+func TestUUIDColumn(t *testing.T) {
+	t.Parallel()
+
+	expected := "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+	id := uuid.MustParse(expected)
+
+	sources := map[string]any{
+		"array":    [16]byte(id),
+		"google":   id,
+		"stripped": strings.ReplaceAll(expected, "-", ""),
+	}
+
+	ctx := setTypeInfo(context.Background(), newTypeInfo())
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			tests := map[FormatCode][]byte{
+				TextFormat:   []byte(expected),
+				BinaryFormat: id[:],
+			}
+
+			for format, bb := range tests {
+				writer := buffer.NewWriter(&bytes.Buffer{})
+				writer.Start(types.ServerDataRow)
+
+				column := Column{Name: "id", Oid: oid.T_uuid, Format: format}
+				err := column.Write(ctx, writer, src)
+				require.NoError(t, err)
+
+				// NOTE: the written message contains the message type (1 byte),
+				// message length (4 bytes) and value length (4 bytes).
+				assert.Equal(t, bb, writer.Bytes()[9:])
+			}
+		})
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		writer.Define(Columns{ //nolint:errcheck
+			{Name: "text", Oid: oid.T_uuid, Format: TextFormat},
+			{Name: "binary", Oid: oid.T_uuid, Format: BinaryFormat},
+		})
+
+		for _, src := range sources {
+			writer.Row([]any{src, src}) //nolint:errcheck
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", len(sources)))
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	t.Run("lib/pq", func(t *testing.T) {
+		connstr := fmt.Sprintf("host=%s port=%d sslmode=disable", address.IP, address.Port)
+		conn, err := sql.Open("postgres", connstr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		rows, err := conn.Query("SELECT *;")
+		require.NoError(t, err)
+
+		count := 0
+		for rows.Next() {
+			var text, binary string
+			require.NoError(t, rows.Scan(&text, &binary))
+			assert.Equal(t, expected, text)
+			assert.Equal(t, expected, binary)
+			count++
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, len(sources), count)
+	})
+
+	t.Run("jackc/pgx", func(t *testing.T) {
+		ctx := context.Background()
+		connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+		conn, err := pgx.Connect(ctx, connstr)
+		require.NoError(t, err)
+		defer conn.Close(ctx)
+
+		rows, err := conn.Query(ctx, "SELECT *;")
+		require.NoError(t, err)
+
+		count := 0
+		for rows.Next() {
+			var text string
+			var binary [16]byte
+			require.NoError(t, rows.Scan(&text, &binary))
+			assert.Equal(t, expected, text)
+			assert.Equal(t, [16]byte(id), binary)
+			count++
+		}
+
+		require.NoError(t, rows.Err())
+		assert.Equal(t, len(sources), count)
+	})
+}
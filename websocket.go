@@ -0,0 +1,34 @@
+package wire
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+	"nhooyr.io/websocket"
+)
+
+// ServeWebSocket upgrades the given HTTP connection to a WebSocket and serves
+// the Postgres client connection over it. Postgres messages are expected to be
+// send as binary WebSocket messages. This method blocks until the client
+// connection has been closed and could be used inside a HTTP handler to serve
+// Postgres connections through HTTP proxies. Connections are rejected with
+// ErrServerClosed once the server has been closed.
+func (srv *Server) ServeWebSocket(w http.ResponseWriter, r *http.Request) error {
+	if !srv.trackConn() {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return ErrServerClosed
+	}
+
+	defer srv.wg.Done()
+	srv.start()
+
+	ws, err := websocket.Accept(w, r, srv.WebSocket)
+	if err != nil {
+		return err
+	}
+
+	srv.logger.Debug("serving a new websocket connection", zap.String("remote", r.RemoteAddr))
+
+	conn := websocket.NetConn(r.Context(), ws, websocket.MessageBinary)
+	return srv.serve(r.Context(), conn)
+}
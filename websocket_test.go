@@ -0,0 +1,78 @@
+package wire
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+func TestServeWebSocket(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return writer.Complete("OK")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := server.ServeWebSocket(w, r)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+
+	defer proxy.Close()
+
+	ctx := context.Background()
+	config, err := pgx.ParseConfig("postgres://postgres@127.0.0.1:5432/postgres?sslmode=disable")
+	require.NoError(t, err)
+
+	config.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ws, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return websocket.NetConn(context.Background(), ws, websocket.MessageBinary), nil
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	require.NoError(t, err)
+
+	tag, err := conn.Exec(ctx, "SELECT 1;")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", tag.String())
+
+	require.NoError(t, conn.Close(ctx))
+}
+
+func TestServeWebSocketClosed(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewServer()
+	require.NoError(t, err)
+	require.NoError(t, server.Close())
+
+	served := make(chan error, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served <- server.ServeWebSocket(w, r)
+	}))
+
+	defer proxy.Close()
+
+	_, resp, err := websocket.Dial(context.Background(), "ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.ErrorIs(t, <-served, ErrServerClosed)
+}
@@ -6,12 +6,17 @@ import (
 	"crypto/x509"
 	"fmt"
 	"net"
+	"regexp"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgtype"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"nhooyr.io/websocket"
 )
 
 // ListenAndServe opens a new Postgres server using the given address and
@@ -30,12 +35,14 @@ func ListenAndServe(address string, handler SimpleQueryFn) error {
 // NewServer constructs a new Postgres server using the given address and server options.
 func NewServer(options ...OptionFn) (*Server, error) {
 	srv := &Server{
-		logger:     zap.NewNop(),
-		closer:     make(chan struct{}),
-		types:      pgtype.NewConnInfo(),
-		Statements: &DefaultStatementCache{},
-		Portals:    &DefaultPortalCache{},
-		Session:    func(ctx context.Context) (context.Context, error) { return ctx, nil },
+		logger:         zap.NewNop(),
+		closer:         make(chan struct{}),
+		draining:       make(chan struct{}),
+		types:          newTypeInfo(),
+		Statements:     &DefaultStatementCache{},
+		Portals:        &DefaultPortalCache{},
+		MaxPendingRows: DefaultMaxPendingRows,
+		Session:        func(ctx context.Context) (context.Context, error) { return ctx, nil },
 	}
 
 	for _, option := range options {
@@ -45,33 +52,98 @@ func NewServer(options ...OptionFn) (*Server, error) {
 		}
 	}
 
+	if srv.simpleQuery != nil {
+		srv.simpleQuery = srv.chainMiddleware(srv.simpleQuery)
+	}
+
 	return srv, nil
 }
 
 // Server contains options for listening to an address.
 type Server struct {
-	wg              sync.WaitGroup
-	logger          *zap.Logger
-	types           *pgtype.ConnInfo
-	Auth            AuthStrategy
-	BufferedMsgSize int
-	Parameters      Parameters
-	Certificates    []tls.Certificate
-	ClientCAs       *x509.CertPool
-	ClientAuth      tls.ClientAuthType
-	Parse           ParseFn
-	Session         SessionHandler
-	Statements      StatementCache
-	Portals         PortalCache
-	CloseConn       CloseFn
-	TerminateConn   CloseFn
-	Version         string
-	closer          chan struct{}
+	wg                    sync.WaitGroup
+	logger                *zap.Logger
+	types                 *pgtype.ConnInfo
+	Address               string
+	Auth                  AuthStrategy
+	BufferedMsgSize       int
+	Parameters            Parameters
+	Certificates          []tls.Certificate
+	ClientCAs             *x509.CertPool
+	ClientAuth            tls.ClientAuthType
+	CoalesceSize          int
+	Copy                  CopyHandler
+	DDL                   DDLHandlerFn
+	Describe              DescribeFn
+	GSSEncryption         GSSEncryptionFn
+	HardQueryTimeout      time.Duration
+	MaxConnErrors         int
+	MaxConnections        int
+	MaxPreparedStatements int
+	MaxPendingRows        int
+	MemoryLimit           int64
+	Parse                 ParseFn
+	Plan                  QueryPlanFn
+	Router                ConnectionRouterFn
+	Replication           ReplicationHandlerFn
+	Session               SessionHandler
+	StartupTimeout        time.Duration
+	Statements            StatementCache
+	Portals               PortalCache
+	Quota                 QuotaStore
+	ReadTimeout           time.Duration
+	WriteTimeout          time.Duration
+	CloseConn             CloseFn
+	TerminateConn         CloseFn
+	Version               string
+	WebSocket             *websocket.AcceptOptions
+	allowedQueries        []*regexp.Regexp
+	authAudit             *authAuditor
+	deniedQueries         []*regexp.Regexp
+	transforms            []RowTransformFn
+	filters               []RowFilterFn
+	middleware            []MiddlewareFn
+	simpleQuery           SimpleQueryFn
+	encryption            *columnEncryption
+	masks                 []columnMask
+	backpressure          *backpressure
+	classes               map[uint32]string
+	tables                map[uint32]Columns
+	coalescer             *coalescer
+	compression           CompressionAlg
+	quotas                *quotaTracker
+	slowQueries           *slowQueryLogger
+	collector             MetricsCollector
+	tracer                trace.Tracer
+	startupValidator      StartupValidatorFn
+	requiredParams        []string
+	tlsConfig             atomic.Pointer[tls.Config]
+	subscribers           map[*subscriber]struct{}
+	subscribersMu         sync.RWMutex
+	cancelers             map[backendKey]*canceler
+	cancelersMu           sync.RWMutex
+	pids                  int32
+	conns                 atomic.Int64
+	startup               []func()
+	startupOnce           sync.Once
+	shutdown              []func()
+	shutdownOnce          sync.Once
+	closer                chan struct{}
+	closeOnce             sync.Once
+	closeMu               sync.Mutex
+	draining              chan struct{}
+	drainOnce             sync.Once
 }
 
 // ListenAndServe opens a new Postgres server on the preconfigured address and
-// starts accepting and serving incoming client connections.
+// starts accepting and serving incoming client connections. The address
+// configured through the ListenAddress option is used when the given address
+// is empty.
 func (srv *Server) ListenAndServe(address string) error {
+	if address == "" {
+		address = srv.Address
+	}
+
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
 		return err
@@ -89,7 +161,11 @@ func (srv *Server) Serve(listener net.Listener) error {
 
 	srv.logger.Info("serving incoming connections", zap.String("addr", listener.Addr().String()))
 
-	srv.wg.Add(1)
+	if !srv.trackConn() {
+		return ErrServerClosed
+	}
+
+	srv.start()
 
 	// NOTE: handle graceful shutdowns
 	go func() {
@@ -103,12 +179,17 @@ func (srv *Server) Serve(listener net.Listener) error {
 	}()
 
 	for {
+		srv.awaitBackpressure()
+
 		conn, err := listener.Accept()
 		if err != nil {
 			return err
 		}
 
-		srv.wg.Add(1)
+		if !srv.trackConn() {
+			conn.Close()
+			return ErrServerClosed
+		}
 
 		go func() {
 			defer srv.wg.Done()
@@ -123,19 +204,47 @@ func (srv *Server) Serve(listener net.Listener) error {
 
 func (srv *Server) serve(ctx context.Context, conn net.Conn) error {
 	ctx = setTypeInfo(ctx, srv.types)
+	ctx = setRemoteAddress(ctx, conn.RemoteAddr())
 	defer conn.Close()
 
+	defer srv.collectConn()()
+
+	ctx, drained := srv.drainConn(ctx, conn)
+	defer drained()
+
+	conn, deadlines := srv.newDeadlineConn(conn)
+	ctx = setDeadlineConn(ctx, deadlines)
+
 	srv.logger.Debug("serving a new client connection")
 
+	if srv.StartupTimeout > 0 {
+		err := conn.SetDeadline(time.Now().Add(srv.StartupTimeout))
+		if err != nil {
+			return err
+		}
+	}
+
 	conn, version, reader, err := srv.Handshake(conn)
 	if err != nil {
 		return err
 	}
 
 	if version == types.VersionCancel {
-		return conn.Close()
+		return srv.handleCancelRequest(reader)
 	}
 
+	// NOTE: cancel requests are handled before a connection slot is
+	// reserved, allowing queries to be cancelled while the maximum number of
+	// connections has been reached.
+	releaseConn, acquired := srv.acquireConn(conn)
+	if !acquired {
+		return nil
+	}
+
+	defer releaseConn()
+
+	ctx = srv.setChannelBinding(ctx, conn)
+
 	srv.logger.Debug("handshake successfull, validating authentication")
 
 	writer := buffer.NewWriter(conn)
@@ -144,11 +253,45 @@ func (srv *Server) serve(ctx context.Context, conn net.Conn) error {
 		return err
 	}
 
+	ctx, endTrace := srv.traceConn(ctx)
+	defer endTrace()
+
+	err = srv.validateStartup(ctx)
+	if err != nil {
+		return writeErrorResponse(writer, err)
+	}
+
+	if srv.Router != nil {
+		err = srv.resetStartupDeadline(conn)
+		if err != nil {
+			return err
+		}
+
+		return srv.routeConn(ctx, conn, reader, writer)
+	}
+
+	replication := GetReplicationMode(ctx) != ReplicationNone
+	if replication && srv.Replication == nil {
+		return writeErrorResponse(writer, NewErrReplicationUnsupported())
+	}
+
 	err = srv.handleAuth(ctx, reader, writer)
 	if err != nil {
 		return err
 	}
 
+	err = srv.resetStartupDeadline(conn)
+	if err != nil {
+		return err
+	}
+
+	release, err := srv.acquireConnQuota(ctx)
+	if err != nil {
+		return writeErrorResponse(writer, err)
+	}
+
+	defer release()
+
 	srv.logger.Debug("connection authenticated, writing server parameters")
 
 	ctx, err = srv.writeParameters(ctx, writer, srv.Parameters)
@@ -156,17 +299,91 @@ func (srv *Server) serve(ctx context.Context, conn net.Conn) error {
 		return err
 	}
 
+	ctx, err = srv.writeBackendKeyData(ctx, writer)
+	if err != nil {
+		return err
+	}
+
+	conn, err = srv.negotiateCompression(ctx, conn, writer)
+	if err != nil {
+		return err
+	}
+
 	ctx, err = srv.Session(ctx)
 	if err != nil {
 		return err
 	}
 
+	if replication {
+		return srv.consumeReplicationCommands(ctx, reader, writer)
+	}
+
 	return srv.consumeCommands(ctx, conn, reader, writer)
 }
 
-// Close gracefully closes the underlaying Postgres server.
+// resetStartupDeadline removes the connection deadline set during the startup
+// phase once the connection has been authenticated.
+func (srv *Server) resetStartupDeadline(conn net.Conn) error {
+	if srv.StartupTimeout == 0 {
+		return nil
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+// Close closes the underlaying Postgres server. The server stops accepting
+// new connections and waits until all active connections have been closed by
+// their clients. Use Shutdown to terminate the active connections.
 func (srv *Server) Close() error {
-	close(srv.closer)
+	srv.signalClose()
 	srv.wg.Wait()
+	srv.stop()
 	return nil
 }
+
+// signalClose signals all listeners and connections that the server is
+// closing. Connections could no longer be tracked once the server is closing.
+func (srv *Server) signalClose() {
+	srv.closeMu.Lock()
+	defer srv.closeMu.Unlock()
+
+	srv.closeOnce.Do(func() { close(srv.closer) })
+}
+
+// trackConn registers a new active connection which has to be served before
+// the server is closed. False is returned when the server is closing, the
+// connection should be rejected in that case. The caller is expected to call
+// srv.wg.Done once the connection has been served.
+func (srv *Server) trackConn() bool {
+	srv.closeMu.Lock()
+	defer srv.closeMu.Unlock()
+
+	select {
+	case <-srv.closer:
+		return false
+	default:
+	}
+
+	srv.wg.Add(1)
+	return true
+}
+
+// start calls the registered startup hooks. The hooks are only called once,
+// even when the server is serving multiple listeners.
+func (srv *Server) start() {
+	srv.startupOnce.Do(func() {
+		for _, fn := range srv.startup {
+			fn()
+		}
+	})
+}
+
+// stop calls the registered shutdown hooks. The hooks are only called once,
+// even when the server is closed multiple times.
+func (srv *Server) stop() {
+	srv.shutdownOnce.Do(func() {
+		for _, fn := range srv.shutdown {
+			fn()
+		}
+	})
+}
@@ -20,7 +20,7 @@ import (
 // the local network. The newly created listener is passed to the given server to
 // start serving PostgreSQL connections. The full listener address is returned
 // for clients to interact with the newly created server.
-func TListenAndServe(t *testing.T, server *Server) *net.TCPAddr {
+func TListenAndServe(t testing.TB, server *Server) *net.TCPAddr {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		t.Fatal(err)
@@ -2,19 +2,32 @@ package wire
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"strconv"
 
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
 	"github.com/jeroenrinzema/psql-wire/internal/buffer"
 	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
 )
 
 // DataWriter represents a writer interface for writing columns and data rows
-// using the Postgres wire to the connected client.
+// using the Postgres wire to the connected client. Additional capabilities
+// (ex: BatchWriter or ErrorWriter) are implemented by data writers through
+// optional interfaces and should be used through the package level helper
+// functions (ex: Batch or ErrorFromErr) which fall back to the methods of this
+// interface whenever possible. Data writers wrapping another data writer
+// should implement Unwrapper.
 type DataWriter interface {
 	// Define writes the column headers containing their type definitions, width
 	// type oid, etc. to the underlaying Postgres client. The column headers
-	// could only be written once. An error will be returned whenever this
-	// method is called twice.
+	// could only be written once per command. ErrColumnsDefined is returned
+	// whenever this method is called twice. A new data writer is constructed
+	// for each command allowing the columns of the next command to be defined.
 	Define(Columns) error
 	// Row writes a single data row containing the values inside the given slice to
 	// the underlaying Postgres client. The column headers have to be written before
@@ -35,6 +48,344 @@ type DataWriter interface {
 	Complete(description string) error
 }
 
+// BatchWriter is implemented by data writers able to write multiple data rows
+// more efficiently than writing each row separately.
+type BatchWriter interface {
+	// Batch writes the given data rows to the underlaying Postgres client. The
+	// writer state is only validated once for the entire batch.
+	Batch(rows [][]any) error
+}
+
+// StatementCompleter is implemented by data writers able to complete the
+// individual statements of a multi-statement query.
+type StatementCompleter interface {
+	// SendCommandComplete announces to the client that a single statement has
+	// been completed using the given command tag without closing the writer.
+	SendCommandComplete(tag string) error
+}
+
+// CopyAcceptor is implemented by data writers able to receive COPY FROM STDIN
+// data from the client.
+type CopyAcceptor interface {
+	// AcceptCopy announces to the client that the server is ready to receive
+	// COPY FROM STDIN data in the given format.
+	AcceptCopy(format CopyFormat) (CopyInReader, error)
+}
+
+// CSVWriter is implemented by data writers able to write COPY TO STDOUT CSV
+// data to the client.
+type CSVWriter interface {
+	// WriteCSV writes the given rows as RFC 4180 encoded CSV to the client
+	// using a COPY TO STDOUT text response.
+	WriteCSV(rows [][]string, options ...CSVOption) error
+}
+
+// RawWriter is implemented by data writers able to write raw Postgres backend
+// messages to the client.
+type RawWriter interface {
+	// WriteRaw writes a fully-formed Postgres backend message of the given type
+	// and payload to the client.
+	WriteRaw(messageType byte, payload []byte) error
+}
+
+// ErrorWriter is implemented by data writers able to write errors to the
+// client.
+type ErrorWriter interface {
+	// ErrorFromErr writes the given error to the client and closes the writer.
+	ErrorFromErr(err error) error
+}
+
+// ColumnNamer is implemented by data writers exposing the names of the
+// defined columns.
+type ColumnNamer interface {
+	// ColumnNames returns the names of the defined columns in the order in
+	// which they have been defined.
+	ColumnNames() []string
+}
+
+// RowRecorder is implemented by data writers recording the written rows (ex:
+// testkit.MockDataWriter). Data writers writing rows directly to the client
+// do not store the written rows and do not implement this interface.
+type RowRecorder interface {
+	// ColumnValues returns the values of all data rows written so far.
+	ColumnValues() [][]any
+}
+
+// Peeker is implemented by data writers able to validate rows without writing
+// them.
+type Peeker interface {
+	// Peek validates the given row against the defined columns without
+	// writing it.
+	Peek(row []any) error
+}
+
+// Batch writes the given data rows to the given data writer. The column
+// headers have to be written before sending rows. Rows are written using a
+// single Batch call whenever the writer implements BatchWriter, otherwise each
+// row is written using Row. Rows written before an error occurred are not
+// rolled back.
+func Batch(writer DataWriter, rows [][]any) error {
+	if batch, ok := writer.(BatchWriter); ok {
+		return batch.Batch(rows)
+	}
+
+	for _, row := range rows {
+		err := writer.Row(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MapRow writes a single data row containing the values of the given map to
+// the given data writer. Values are looked up using the names of the defined
+// columns. Missing values are encoded as NULL values and map keys not matching
+// any of the defined columns are ignored. ErrUndefinedColumns is returned
+// whenever the column names could not be determined.
+func MapRow(writer DataWriter, values map[string]any) error {
+	names := ColumnNames(writer)
+	if names == nil {
+		return ErrUndefinedColumns
+	}
+
+	row := make([]any, len(names))
+	for index, name := range names {
+		row[index] = values[name]
+	}
+
+	return writer.Row(row)
+}
+
+// JSONResult writes the given rows as a single JSON encoded array inside a
+// single text column named "result" and completes the command using the given
+// command tag. This could be used by gateways expecting the query result as
+// JSON. Rows are passed through the row filters, transformations and column
+// masks of the (wrapped) data writers before they are encoded, an error is
+// returned whenever a data writer is unable to process JSON rows.
+func JSONResult(writer DataWriter, tag string, rows []map[string]any) error {
+	if rows == nil {
+		rows = []map[string]any{}
+	}
+
+	rows, err := mapJSONRows(writer, rows)
+	if err != nil {
+		return err
+	}
+
+	bb, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Define(JSONResultColumns)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Row([]any{string(bb)})
+	if err != nil {
+		return err
+	}
+
+	return writer.Complete(tag)
+}
+
+// jsonRowMapper is implemented by data writers modifying the written rows (ex:
+// column masks). JSON rows are encoded inside a single column and would
+// otherwise bypass the modifications.
+type jsonRowMapper interface {
+	// mapJSONRow returns the given row as it should be written to the client.
+	mapJSONRow(row map[string]any) (map[string]any, error)
+}
+
+// mapJSONRows passes the given rows through all data writers inside the chain
+// of wrapped data writers implementing jsonRowMapper.
+func mapJSONRows(writer DataWriter, rows []map[string]any) ([]map[string]any, error) {
+	for {
+		if mapper, ok := writer.(jsonRowMapper); ok {
+			mapped := make([]map[string]any, len(rows))
+			for index, row := range rows {
+				row, err := mapper.mapJSONRow(row)
+				if err != nil {
+					return nil, err
+				}
+
+				mapped[index] = row
+			}
+
+			rows = mapped
+		}
+
+		wrapper, ok := writer.(Unwrapper)
+		if !ok {
+			return rows, nil
+		}
+
+		writer = wrapper.Unwrap()
+	}
+}
+
+// SendCommandComplete announces to the client that a single statement of a
+// multi-statement query has been completed using the given command tag.
+// Unlike Complete the writer is not closed, the defined columns and the
+// number of written rows are reset allowing the columns and rows of the next
+// statement to be written. The query should still be completed using Complete
+// once the last statement has been executed. ErrStatementsUnsupported is
+// returned whenever the writer does not implement StatementCompleter.
+// ErrStatementsExtendedQuery is returned when executing a portal using the
+// extended query protocol, a portal only contains a single statement.
+func SendCommandComplete(writer DataWriter, tag string) error {
+	if completer, ok := unwrapWriter[StatementCompleter](writer); ok {
+		return completer.SendCommandComplete(tag)
+	}
+
+	return ErrStatementsUnsupported
+}
+
+// CompleteCopy announces to the client that the COPY operation has been
+// completed. The command is completed with a "COPY N" tag containing the
+// given number of copied rows.
+func CompleteCopy(writer DataWriter, rows int64) error {
+	return writer.Complete("COPY " + strconv.FormatInt(rows, 10))
+}
+
+// AcceptCopy announces to the client that the server is ready to receive COPY
+// FROM STDIN data in the given format. The returned reader reads the incoming
+// copy data until the client has completed the copy operation. The command
+// should be completed once all copy data has been consumed.
+// ErrCopyUnsupported is returned whenever the writer does not implement
+// CopyAcceptor.
+func AcceptCopy(writer DataWriter, format CopyFormat) (CopyInReader, error) {
+	if acceptor, ok := unwrapWriter[CopyAcceptor](writer); ok {
+		return acceptor.AcceptCopy(format)
+	}
+
+	return nil, ErrCopyUnsupported
+}
+
+// WriteCSV writes the given rows as RFC 4180 encoded CSV to the client using a
+// COPY TO STDOUT text response. A header row could be included using the
+// CSVHeader option. The command should be completed once all rows have been
+// written. ErrCopyUnsupported is returned whenever the writer does not
+// implement CSVWriter.
+func WriteCSV(writer DataWriter, rows [][]string, options ...CSVOption) error {
+	if csv, ok := writer.(CSVWriter); ok {
+		return csv.WriteCSV(rows, options...)
+	}
+
+	return ErrCopyUnsupported
+}
+
+// WriteRaw writes a fully-formed Postgres backend message of the given type
+// and payload to the client. The message is not validated and bypasses all
+// data writer state checks except for closed writers. This could be used to
+// relay pre-encoded messages without re-encoding them. ErrRawUnsupported is
+// returned whenever the writer does not implement RawWriter.
+func WriteRaw(writer DataWriter, messageType byte, payload []byte) error {
+	if raw, ok := writer.(RawWriter); ok {
+		return raw.WriteRaw(messageType, payload)
+	}
+
+	return ErrRawUnsupported
+}
+
+// ErrorFromErr writes the given error as error response to the client and
+// closes the writer. Postgres error fields (code, hint, detail, etc.) are
+// extracted from the (wrapped) error. Errors without a Postgres error code are
+// written as internal errors. The handler could return without an error once
+// the error has been written. The given error is returned as is whenever the
+// writer does not implement ErrorWriter, allowing the handler to return it.
+func ErrorFromErr(writer DataWriter, err error) error {
+	if errs, ok := unwrapWriter[ErrorWriter](writer); ok {
+		return errs.ErrorFromErr(err)
+	}
+
+	return err
+}
+
+// ColumnNames returns the names of the columns defined through Define in the
+// order in which they have been defined. Nil is returned whenever no columns
+// have been defined yet or whenever the writer does not implement
+// ColumnNamer.
+func ColumnNames(writer DataWriter) []string {
+	if namer, ok := unwrapWriter[ColumnNamer](writer); ok {
+		return namer.ColumnNames()
+	}
+
+	return nil
+}
+
+// ColumnValues returns the values of all data rows written so far. Nil is
+// returned whenever the writer does not implement RowRecorder. This is
+// intended to inspect the written rows inside tests using a mock data writer.
+func ColumnValues(writer DataWriter) [][]any {
+	if recorder, ok := unwrapWriter[RowRecorder](writer); ok {
+		return recorder.ColumnValues()
+	}
+
+	return nil
+}
+
+// Peek validates the given row against the defined columns without writing
+// it. The number of values, NULL values inside NOT NULL columns and the value
+// types are validated. This allows handlers to validate a sample of rows
+// before any rows are written to the client. ErrPeekUnsupported is returned
+// whenever the writer does not implement Peeker.
+func Peek(writer DataWriter, row []any) error {
+	if peeker, ok := writer.(Peeker); ok {
+		return peeker.Peek(row)
+	}
+
+	return ErrPeekUnsupported
+}
+
+// Unwrapper is implemented by data writers wrapping another data writer (ex:
+// middleware masking column values). Capabilities which do not write data
+// rows (ErrorFromErr, SendCommandComplete, AcceptCopy, ColumnNames and
+// ColumnValues) are looked up through the wrapped writers. Capabilities
+// writing data rows or raw messages are never unwrapped, these have to be
+// implemented by the wrapping writer itself to be available.
+type Unwrapper interface {
+	// Unwrap returns the wrapped data writer.
+	Unwrap() DataWriter
+}
+
+// unwrapWriter returns the first data writer implementing the requested
+// capability by walking the chain of wrapped data writers.
+func unwrapWriter[T any](writer DataWriter) (T, bool) {
+	for {
+		capability, ok := writer.(T)
+		if ok {
+			return capability, true
+		}
+
+		wrapper, ok := writer.(Unwrapper)
+		if !ok {
+			return capability, false
+		}
+
+		writer = wrapper.Unwrap()
+	}
+}
+
+// wrappedWriter is embedded by data writers wrapping another data writer (ex:
+// the configured row filters and column masks). Batch and MapRow fall back to
+// Row of the wrapping writer, raw output (WriteCSV and WriteRaw) is
+// unsupported unless implemented by the wrapping writer.
+type wrappedWriter struct {
+	DataWriter
+}
+
+func (writer wrappedWriter) Unwrap() DataWriter {
+	return writer.DataWriter
+}
+
+func (writer wrappedWriter) Peek(row []any) error {
+	return Peek(writer.DataWriter, row)
+}
+
 // ErrUndefinedColumns is thrown when the columns inside the data writer have not
 // yet been defined.
 var ErrUndefinedColumns = errors.New("columns have not been defined")
@@ -43,9 +394,55 @@ var ErrUndefinedColumns = errors.New("columns have not been defined")
 // client while data has already been written.
 var ErrDataWritten = errors.New("data has already been written")
 
+// ErrColumnsDefined is thrown when the columns inside the data writer are
+// attempted to be defined while they have already been defined.
+var ErrColumnsDefined = errors.New("columns have already been defined")
+
 // ErrClosedWriter is thrown when the data writer has been closed
 var ErrClosedWriter = errors.New("closed writer")
 
+// ErrPendingRows is thrown when a statement is attempted to be completed while
+// rows exceeding the row limit of the portal are still pending.
+var ErrPendingRows = errors.New("statement could not be completed while rows are pending")
+
+// ErrStatementsUnsupported is returned when a single statement is attempted to
+// be completed using a data writer which does not implement
+// StatementCompleter.
+var ErrStatementsUnsupported = errors.New("completing individual statements is not supported by the given data writer")
+
+// ErrStatementsExtendedQuery is returned when a single statement is attempted
+// to be completed while executing a portal using the extended query protocol.
+var ErrStatementsExtendedQuery = errors.New("individual statements could not be completed using the extended query protocol")
+
+// ErrRawUnsupported is returned when a raw Postgres message is attempted to be
+// written using a data writer which does not implement RawWriter.
+var ErrRawUnsupported = errors.New("raw messages are not supported by the given data writer")
+
+// ErrJSONResultUnsupported is returned when a JSON result is attempted to be
+// written through a data writer unable to process JSON rows (ex: row
+// filters).
+var ErrJSONResultUnsupported = errors.New("JSON results are not supported by the given data writer")
+
+// ErrPeekUnsupported is returned when a row is attempted to be validated using
+// a data writer which does not implement Peeker.
+var ErrPeekUnsupported = errors.New("validating rows is not supported by the given data writer")
+
+// ErrNotNullViolation is thrown when a NULL value is given for a column which
+// does not accept NULL values.
+var ErrNotNullViolation = errors.New("null value violates not-null constraint")
+
+// NewErrNotNullViolation constructs a new error wrapping the
+// ErrNotNullViolation type including the not null violation error code.
+func NewErrNotNullViolation(column string) error {
+	err := fmt.Errorf("%w of column %q", ErrNotNullViolation, column)
+	return psqlerr.WithCode(err, codes.NotNullViolation)
+}
+
+// JSONResultColumns represent the columns written by JSONResult.
+var JSONResultColumns = Columns{
+	{Name: "result", Oid: oid.T_text, Format: TextFormat},
+}
+
 // NewDataWriter constructs a new data writer using the given context and
 // buffer. The returned writer should be handled with caution as it is not safe
 // for concurrent use. Concurrent access to the same data without proper
@@ -57,13 +454,55 @@ func NewDataWriter(ctx context.Context, writer *buffer.Writer) DataWriter {
 	}
 }
 
+// newDataWriter constructs a new data writer which is able to read incoming
+// client messages from the given reader during copy operations.
+func newDataWriter(ctx context.Context, reader *buffer.Reader, writer *buffer.Writer) *dataWriter {
+	return &dataWriter{
+		ctx:    ctx,
+		reader: reader,
+		client: writer,
+	}
+}
+
+// NewIoDataWriter constructs a new data writer writing Postgres encoded
+// messages to the given io.Writer instead of a client connection. Rows written
+// to the returned writer are encoded using the given columns and the default
+// type info. This writer could be used to test encoders in isolation or to
+// write Postgres encoded rows to files.
+func NewIoDataWriter(w io.Writer, columns Columns) DataWriter {
+	return &dataWriter{
+		ctx:     setTypeInfo(context.Background(), newTypeInfo()),
+		client:  buffer.NewWriter(w),
+		columns: columns,
+	}
+}
+
 // dataWriter is a implementation of the DataWriter interface.
 type dataWriter struct {
 	columns Columns
 	ctx     context.Context
+	reader  *buffer.Reader
 	client  *buffer.Writer
 	closed  bool
+	defined bool
 	written uint64
+	// described indicates that the columns have already been described to
+	// the client, the row description is therefore not written on Define.
+	described bool
+	// extended indicates that a portal is executed using the extended query
+	// protocol. A portal executes a single statement and could therefore not
+	// complete individual statements.
+	extended bool
+	// limit represents the maximum number of rows written to the client.
+	// Rows exceeding the limit are kept as pending rows and the command is
+	// suspended instead of completed. Zero denotes no limit.
+	limit   uint64
+	pending [][]any
+	// maxPending represents the maximum number of pending rows. Rows exceeding
+	// the maximum are rejected as the handler is not paused once the limit
+	// has been reached. Zero denotes no maximum.
+	maxPending uint64
+	tag        string
 }
 
 func (writer *dataWriter) Define(columns Columns) error {
@@ -71,7 +510,16 @@ func (writer *dataWriter) Define(columns Columns) error {
 		return ErrClosedWriter
 	}
 
+	if writer.defined {
+		return ErrColumnsDefined
+	}
+
+	writer.defined = true
 	writer.columns = columns
+	if writer.described {
+		return nil
+	}
+
 	return writer.columns.Define(writer.ctx, writer.client)
 }
 
@@ -84,11 +532,71 @@ func (writer *dataWriter) Row(values []any) error {
 		return ErrUndefinedColumns
 	}
 
+	// NOTE: stop writing rows once the query context has been cancelled (ex:
+	// when the query exceeded its memory limit).
+	err := writer.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if writer.limit > 0 && writer.written >= writer.limit {
+		if writer.maxPending > 0 && uint64(len(writer.pending)) >= writer.maxPending {
+			return NewErrTooManyPendingRows(writer.maxPending)
+		}
+
+		row := make([]any, len(values))
+		copy(row, values)
+		writer.pending = append(writer.pending, row)
+		return nil
+	}
+
 	writer.written++
 
 	return writer.columns.Write(writer.ctx, writer.client, values)
 }
 
+func (writer *dataWriter) Batch(rows [][]any) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	err := writer.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	// NOTE: rows exceeding the row limit have to be kept as pending rows
+	// which is handled by Row.
+	if writer.limit > 0 {
+		for _, row := range rows {
+			err = writer.Row(row)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// NOTE: the column encoders are shared between all rows inside the
+	// batch to avoid allocating new type values for each row.
+	encoders := writer.columns.encoders()
+	for _, row := range rows {
+		err = writer.columns.writeRow(writer.ctx, writer.client, encoders, row)
+		if err != nil {
+			return err
+		}
+
+		writer.written++
+	}
+
+	return nil
+}
+
 func (writer *dataWriter) Empty() error {
 	if writer.closed {
 		return ErrClosedWriter
@@ -123,9 +631,147 @@ func (writer *dataWriter) Complete(description string) error {
 	}
 
 	defer writer.close()
+
+	if len(writer.pending) > 0 {
+		writer.tag = description
+		return portalSuspended(writer.client)
+	}
+
 	return commandComplete(writer.client, description)
 }
 
+func (writer *dataWriter) SendCommandComplete(tag string) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.extended {
+		return ErrStatementsExtendedQuery
+	}
+
+	if len(writer.pending) > 0 {
+		return ErrPendingRows
+	}
+
+	err := commandComplete(writer.client, tag)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: the columns of the next statement have not been described yet.
+	writer.columns = nil
+	writer.defined = false
+	writer.described = false
+	writer.written = 0
+	return nil
+}
+
+func (writer *dataWriter) AcceptCopy(format CopyFormat) (CopyInReader, error) {
+	if writer.closed {
+		return nil, ErrClosedWriter
+	}
+
+	if writer.reader == nil {
+		return nil, ErrCopyUnsupported
+	}
+
+	err := copyInResponse(writer.client, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &copyReader{client: writer.reader, deadlines: getDeadlineConn(writer.ctx)}, nil
+}
+
+func (writer *dataWriter) WriteCSV(rows [][]string, options ...CSVOption) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	config := csvOptions{}
+	for _, option := range options {
+		option(&config)
+	}
+
+	err := writeCSV(writer.client, rows, config)
+	if err != nil {
+		return err
+	}
+
+	writer.written += uint64(len(rows))
+	return nil
+}
+
+func (writer *dataWriter) WriteRaw(messageType byte, payload []byte) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	writer.client.Start(types.ServerMessage(messageType))
+	writer.client.AddBytes(payload)
+	return writer.client.End()
+}
+
+func (writer *dataWriter) ErrorFromErr(err error) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	// NOTE: notices (ex: warnings) are written without closing the writer
+	// allowing the command to be continued.
+	if isNotice(err) {
+		return writeErrorResponse(writer.client, err)
+	}
+
+	if psqlerr.GetCode(err) == codes.Uncategorized {
+		err = psqlerr.WithCode(err, codes.Internal)
+	}
+
+	defer writer.close()
+	return writeErrorResponse(writer.client, err)
+}
+
+func (writer *dataWriter) ColumnNames() []string {
+	if writer.columns == nil {
+		return nil
+	}
+
+	names := make([]string, len(writer.columns))
+	for index, column := range writer.columns {
+		names[index] = column.Name
+	}
+
+	return names
+}
+
+func (writer *dataWriter) Peek(row []any) error {
+	if writer.closed {
+		return ErrClosedWriter
+	}
+
+	if writer.columns == nil {
+		return ErrUndefinedColumns
+	}
+
+	return writer.columns.validate(writer.ctx, row)
+}
+
+// notice writes the given notice returned by a query handler to the client.
+// The command is completed using a empty tag whenever the handler has not
+// completed the command.
+func (writer *dataWriter) notice(err error) error {
+	err = writeErrorResponse(writer.client, err)
+	if err != nil {
+		return err
+	}
+
+	if writer.closed {
+		return nil
+	}
+
+	return writer.Complete("")
+}
+
 func (writer *dataWriter) close() {
 	writer.closed = true
 }
@@ -139,3 +785,10 @@ func commandComplete(writer *buffer.Writer, description string) error {
 	writer.AddNullTerminate()
 	return writer.End()
 }
+
+// portalSuspended announces that the row limit of the executed portal has been
+// reached. The portal could be resumed by executing it again.
+func portalSuspended(writer *buffer.Writer) error {
+	writer.Start(types.ServerPortalSuspended)
+	return writer.End()
+}
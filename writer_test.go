@@ -0,0 +1,655 @@
+package wire
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jeroenrinzema/psql-wire/codes"
+	psqlerr "github.com/jeroenrinzema/psql-wire/errors"
+	"github.com/jeroenrinzema/psql-wire/internal/buffer"
+	"github.com/jeroenrinzema/psql-wire/internal/mock"
+	"github.com/jeroenrinzema/psql-wire/internal/types"
+	"github.com/lib/pq/oid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIoDataWriter(t *testing.T) {
+	sink := &bytes.Buffer{}
+	columns := Columns{
+		{Name: "name", Oid: oid.T_text, Format: TextFormat},
+		{Name: "age", Oid: oid.T_int4, Format: TextFormat},
+	}
+
+	expected := [][]any{
+		{"John", "28"},
+		{"Marry", "21"},
+		{nil, "42"},
+	}
+
+	writer := NewIoDataWriter(sink, columns)
+	require.NoError(t, writer.Row([]any{"John", 28}))
+	require.NoError(t, writer.Row([]any{"Marry", 21}))
+	require.NoError(t, writer.Row([]any{nil, 42}))
+	require.NoError(t, writer.Complete("SELECT 3"))
+	assert.Equal(t, uint64(3), writer.Written())
+
+	reader := mock.NewReader(sink)
+
+	for _, row := range expected {
+		typed, _, err := reader.ReadTypedMsg()
+		require.NoError(t, err)
+		require.Equal(t, types.ServerDataRow, typed)
+
+		length, err := reader.GetUint16()
+		require.NoError(t, err)
+		require.Equal(t, len(columns), int(length))
+
+		for _, value := range row {
+			size, err := reader.GetUint32()
+			require.NoError(t, err)
+
+			if value == nil {
+				assert.Equal(t, int32(-1), int32(size))
+				continue
+			}
+
+			bb, err := reader.GetBytes(int(size))
+			require.NoError(t, err)
+			assert.Equal(t, value, string(bb))
+		}
+	}
+
+	typed, _, err := reader.ReadTypedMsg()
+	require.NoError(t, err)
+	require.Equal(t, types.ServerCommandComplete, typed)
+
+	tag, err := reader.GetString()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT 3", tag)
+}
+
+func TestWriteRaw(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		// NOTE: the row description and data rows are encoded by hand to be
+		// relayed as raw messages.
+		description := buffer.NewWriter(io.Discard)
+		description.Start(types.ServerRowDescription)
+		description.AddInt16(1)
+		description.AddString("name")
+		description.AddNullTerminate()
+		description.AddInt32(0)
+		description.AddInt16(0)
+		description.AddInt32(int32(oid.T_text))
+		description.AddInt16(-1)
+		description.AddInt32(-1)
+		description.AddInt16(int16(TextFormat))
+
+		err := WriteRaw(writer, byte(types.ServerRowDescription), description.Bytes()[5:])
+		if err != nil {
+			return err
+		}
+
+		row := []byte{0, 1, 0, 0, 0, 4, 'J', 'o', 'h', 'n'}
+		err = WriteRaw(writer, byte(types.ServerDataRow), row)
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var name string
+	err = conn.QueryRow(ctx, "SELECT name FROM users;").Scan(&name)
+	require.NoError(t, err)
+	assert.Equal(t, "John", name)
+}
+
+func TestColumnNames(t *testing.T) {
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+		{Name: "name", Oid: oid.T_text, Format: TextFormat},
+		{Name: "created", Oid: oid.T_timestamp, Format: TextFormat},
+	}
+
+	writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+	assert.Nil(t, ColumnNames(writer))
+
+	require.NoError(t, writer.Define(columns))
+	assert.Equal(t, []string{"id", "name", "created"}, ColumnNames(writer))
+}
+
+func TestMapRow(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{
+			{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+			{Name: "name", Oid: oid.T_text, Format: TextFormat},
+			{Name: "email", Oid: oid.T_text, Format: TextFormat},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = MapRow(writer, map[string]any{
+			"id":       int32(1),
+			"name":     "John",
+			"password": "secret",
+		})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	var id int32
+	var name string
+	var email *string
+	err = conn.QueryRow(ctx, "SELECT * FROM users;").Scan(&id, &name, &email)
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), id)
+	assert.Equal(t, "John", name)
+	assert.Nil(t, email)
+
+	t.Run("undefined", func(t *testing.T) {
+		writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+		assert.ErrorIs(t, MapRow(writer, map[string]any{}), ErrUndefinedColumns)
+	})
+}
+
+func TestJSONResult(t *testing.T) {
+	t.Parallel()
+
+	expected := []map[string]any{
+		{"id": float64(1), "name": "John", "tags": []any{"admin"}},
+		{"id": float64(2), "name": "Jane", "tags": nil},
+	}
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		return JSONResult(writer, "SELECT 2", expected)
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT * FROM users;")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	require.True(t, rows.Next())
+	assert.Equal(t, "result", string(rows.FieldDescriptions()[0].Name))
+
+	var result string
+	require.NoError(t, rows.Scan(&result))
+	require.True(t, json.Valid([]byte(result)))
+
+	decoded := []map[string]any{}
+	require.NoError(t, json.Unmarshal([]byte(result), &decoded))
+	assert.Equal(t, expected, decoded)
+
+	assert.False(t, rows.Next())
+	assert.Equal(t, "SELECT 2", rows.CommandTag().String())
+}
+
+func TestErrorFromErr(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		var err error
+		switch query {
+		case "SELECT wrapped;":
+			err = psqlerr.WithCode(errors.New("unique violation"), codes.UniqueViolation)
+			err = psqlerr.WithHint(err, "use another name")
+			err = fmt.Errorf("unable to insert user: %w", err)
+		default:
+			err = errors.New("unexpected failure")
+		}
+
+		return ErrorFromErr(writer, err)
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT wrapped;")
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, err, &pgerr)
+	assert.Equal(t, string(codes.UniqueViolation), pgerr.Code)
+	assert.Equal(t, "use another name", pgerr.Hint)
+	assert.Equal(t, "unable to insert user: unique violation", pgerr.Message)
+
+	t.Run("internal", func(t *testing.T) {
+		_, err = conn.Exec(ctx, "SELECT plain;")
+		pgerr := &pgconn.PgError{}
+		require.ErrorAs(t, err, &pgerr)
+		assert.Equal(t, string(codes.Internal), pgerr.Code)
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		writer := NewIoDataWriter(io.Discard, nil)
+		require.NoError(t, ErrorFromErr(writer, errors.New("unexpected")))
+		assert.ErrorIs(t, ErrorFromErr(writer, errors.New("unexpected")), ErrClosedWriter)
+	})
+}
+
+func TestDefineTwice(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+	}
+
+	t.Run("writer", func(t *testing.T) {
+		writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+		require.NoError(t, writer.Define(columns))
+		assert.ErrorIs(t, writer.Define(columns), ErrColumnsDefined)
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(columns)
+		if err != nil {
+			return err
+		}
+
+		if query == "SELECT twice;" {
+			err = writer.Define(columns)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = writer.Row([]any{int32(1)})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete("SELECT 1")
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, "SELECT twice;")
+	assert.Error(t, err)
+
+	// NOTE: the columns of each command should be defined once using a new
+	// data writer.
+	for index := 0; index < 2; index++ {
+		var id int32
+		err = conn.QueryRow(ctx, "SELECT once;").Scan(&id)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), id)
+	}
+}
+
+func TestSendCommandComplete(t *testing.T) {
+	t.Parallel()
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		statements := strings.Split(strings.TrimSuffix(query, ";"), ";")
+		for index, statement := range statements {
+			err := writer.Define(Columns{
+				{Name: "value", Oid: oid.T_text, Format: TextFormat},
+			})
+			if err != nil {
+				return err
+			}
+
+			err = writer.Row([]any{strings.TrimSpace(statement)})
+			if err != nil {
+				return err
+			}
+
+			if index == len(statements)-1 {
+				return writer.Complete("SELECT 1")
+			}
+
+			err = SendCommandComplete(writer, "SELECT 1")
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	results, err := conn.PgConn().Exec(ctx, "SELECT a; SELECT b; SELECT c;").ReadAll()
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	for index, expected := range []string{"SELECT a", "SELECT b", "SELECT c"} {
+		require.NoError(t, results[index].Err)
+		assert.Equal(t, "SELECT 1", results[index].CommandTag.String())
+		require.Len(t, results[index].FieldDescriptions, 1)
+		assert.Equal(t, [][][]byte{{[]byte(expected)}}, results[index].Rows)
+	}
+
+	// NOTE: the connection should be ready for the next query once the last
+	// statement has been completed.
+	var value string
+	err = conn.QueryRow(ctx, "SELECT d;").Scan(&value)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT d", value)
+
+	t.Run("closed", func(t *testing.T) {
+		writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+		require.NoError(t, writer.Complete("SELECT 0"))
+		assert.ErrorIs(t, SendCommandComplete(writer, "SELECT 0"), ErrClosedWriter)
+	})
+
+	t.Run("extended", func(t *testing.T) {
+		rows, err := conn.Query(ctx, "SELECT a; SELECT b;")
+		require.NoError(t, err)
+
+		for rows.Next() {
+		}
+
+		rows.Close()
+		assert.ErrorContains(t, rows.Err(), ErrStatementsExtendedQuery.Error())
+
+		// NOTE: the connection should remain usable once the portal has been
+		// rejected.
+		var value string
+		err = conn.QueryRow(ctx, "SELECT e;").Scan(&value)
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT e", value)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("undefined columns", func(t *testing.T) {
+		writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+		assert.ErrorIs(t, Batch(writer, [][]any{{int32(1)}}), ErrUndefinedColumns)
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(Columns{
+			{Name: "id", Oid: oid.T_int4, Format: TextFormat},
+			{Name: "name", Oid: oid.T_text, Format: TextFormat},
+		})
+		if err != nil {
+			return err
+		}
+
+		err = Batch(writer, [][]any{
+			{int32(1), "John"},
+			{int32(2), "admin"},
+			{int32(3), nil},
+		})
+		if err != nil {
+			return err
+		}
+
+		return writer.Complete(fmt.Sprintf("SELECT %d", writer.Written()))
+	}
+
+	filter := TransformRows(func(row []any) ([]any, error) {
+		if row[1] == "admin" {
+			return nil, nil
+		}
+
+		return row, nil
+	})
+
+	server, err := NewServer(SimpleQuery(handler), filter)
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT * FROM users;")
+	require.NoError(t, err)
+
+	ids := []int32{}
+	names := []*string{}
+	for rows.Next() {
+		var id int32
+		var name *string
+		require.NoError(t, rows.Scan(&id, &name))
+		ids = append(ids, id)
+		names = append(names, name)
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, "SELECT 2", rows.CommandTag().String())
+	assert.Equal(t, []int32{1, 3}, ids)
+	require.Len(t, names, 2)
+	assert.Equal(t, "John", *names[0])
+	assert.Nil(t, names[1])
+}
+
+func TestColumnValuesNotStored(t *testing.T) {
+	writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(io.Discard))
+	require.NoError(t, writer.Define(Columns{{Name: "id", Oid: oid.T_int4, Format: TextFormat}}))
+	require.NoError(t, writer.Row([]any{1}))
+
+	// NOTE: rows written to the client should not be stored
+	assert.Nil(t, ColumnValues(writer))
+	assert.Equal(t, uint64(1), writer.Written())
+}
+
+func TestPeek(t *testing.T) {
+	t.Parallel()
+
+	columns := Columns{
+		{Name: "id", Oid: oid.T_int4, Format: TextFormat, NotNull: true},
+		{Name: "name", Oid: oid.T_text, Format: TextFormat},
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		sink := &bytes.Buffer{}
+		writer := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(sink))
+		assert.ErrorIs(t, Peek(writer, []any{1, "John"}), ErrUndefinedColumns)
+
+		require.NoError(t, writer.Define(columns))
+		defined := sink.Len()
+
+		require.NoError(t, Peek(writer, []any{1, "John"}))
+		require.NoError(t, Peek(writer, []any{2, nil}))
+
+		err := Peek(writer, []any{"abc", "John"})
+		require.Error(t, err)
+		assert.Equal(t, codes.DatatypeMismatch, psqlerr.GetCode(err))
+
+		err = Peek(writer, []any{nil, "John"})
+		assert.ErrorIs(t, err, ErrNotNullViolation)
+		assert.Equal(t, codes.NotNullViolation, psqlerr.GetCode(err))
+
+		assert.Error(t, Peek(writer, []any{1}))
+
+		// NOTE: peeked rows should not be written to the client
+		assert.Equal(t, defined, sink.Len())
+		assert.Equal(t, uint64(0), writer.Written())
+	})
+
+	handler := func(ctx context.Context, query string, writer DataWriter, parameters []string) error {
+		err := writer.Define(columns)
+		if err != nil {
+			return err
+		}
+
+		rows := [][]any{{1, "John"}, {"two", "Jane"}}
+		for _, row := range rows {
+			err = Peek(writer, row)
+			if err != nil {
+				return err
+			}
+		}
+
+		return Batch(writer, rows)
+	}
+
+	server, err := NewServer(SimpleQuery(handler))
+	require.NoError(t, err)
+
+	address := TListenAndServe(t, server)
+
+	ctx := context.Background()
+	connstr := fmt.Sprintf("postgres://%s:%d", address.IP, address.Port)
+	conn, err := pgx.Connect(ctx, connstr)
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, "SELECT * FROM users;")
+	require.NoError(t, err)
+
+	assert.False(t, rows.Next())
+
+	pgerr := &pgconn.PgError{}
+	require.ErrorAs(t, rows.Err(), &pgerr)
+	assert.Equal(t, string(codes.DatatypeMismatch), pgerr.Code)
+}
+
+// coreWriter only implements the methods of the DataWriter interface.
+type coreWriter struct {
+	columns Columns
+	rows    [][]any
+	tag     string
+}
+
+func (writer *coreWriter) Define(columns Columns) error {
+	writer.columns = columns
+	return nil
+}
+
+func (writer *coreWriter) Row(values []any) error {
+	writer.rows = append(writer.rows, values)
+	return nil
+}
+
+func (writer *coreWriter) Written() uint64 {
+	return uint64(len(writer.rows))
+}
+
+func (writer *coreWriter) Empty() error {
+	return nil
+}
+
+func (writer *coreWriter) Complete(description string) error {
+	writer.tag = description
+	return nil
+}
+
+func TestOptionalCapabilities(t *testing.T) {
+	t.Parallel()
+
+	writer := &coreWriter{}
+	require.NoError(t, writer.Define(Columns{{Name: "id", Oid: oid.T_int4}}))
+
+	require.NoError(t, Batch(writer, [][]any{{1}, {2}}))
+	assert.Equal(t, [][]any{{1}, {2}}, writer.rows)
+
+	// NOTE: the column names could not be determined without ColumnNamer
+	assert.ErrorIs(t, MapRow(writer, map[string]any{"id": 3}), ErrUndefinedColumns)
+	assert.Nil(t, ColumnNames(writer))
+	assert.Nil(t, ColumnValues(writer))
+
+	assert.ErrorIs(t, SendCommandComplete(writer, "SELECT 2"), ErrStatementsUnsupported)
+	assert.ErrorIs(t, WriteRaw(writer, byte(types.ServerDataRow), nil), ErrRawUnsupported)
+	assert.ErrorIs(t, WriteCSV(writer, nil), ErrCopyUnsupported)
+	assert.ErrorIs(t, Peek(writer, []any{1}), ErrPeekUnsupported)
+
+	_, err := AcceptCopy(writer, TextCopyFormat)
+	assert.ErrorIs(t, err, ErrCopyUnsupported)
+
+	failure := errors.New("unexpected")
+	assert.Equal(t, failure, ErrorFromErr(writer, failure))
+
+	require.NoError(t, CompleteCopy(writer, 2))
+	assert.Equal(t, "COPY 2", writer.tag)
+}
+
+func TestUnwrapCapabilities(t *testing.T) {
+	t.Parallel()
+
+	sink := &bytes.Buffer{}
+	inner := NewDataWriter(setTypeInfo(context.Background(), newTypeInfo()), buffer.NewWriter(sink))
+	writer := wrappedWriter{inner}
+
+	require.NoError(t, writer.Define(Columns{{Name: "id", Oid: oid.T_int4, Format: TextFormat}}))
+	assert.Equal(t, []string{"id"}, ColumnNames(writer))
+	require.NoError(t, MapRow(writer, map[string]any{"id": 1}))
+	assert.Equal(t, uint64(1), writer.Written())
+
+	// NOTE: raw output is never unwrapped as it would bypass the wrapping writer
+	written := sink.Len()
+	assert.ErrorIs(t, WriteRaw(writer, byte(types.ServerDataRow), nil), ErrRawUnsupported)
+	assert.Equal(t, written, sink.Len())
+}
@@ -0,0 +1,107 @@
+package wire
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+)
+
+// XML represents a Postgres xml value. The value is encoded as a UTF-8 byte
+// sequence, the binary format is identical to the text format.
+// https://www.postgresql.org/docs/current/datatype-xml.html
+type XML struct {
+	Bytes  []byte
+	Status pgtype.Status
+}
+
+// Set converts and assigns the given source to itself. Strings and byte slices
+// are used as is, any other value is serialized using encoding/xml.
+func (dst *XML) Set(src any) error {
+	if src == nil {
+		*dst = XML{Status: pgtype.Null}
+		return nil
+	}
+
+	switch value := src.(type) {
+	case string:
+		*dst = XML{Bytes: []byte(value), Status: pgtype.Present}
+	case *string:
+		if value == nil {
+			*dst = XML{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = XML{Bytes: []byte(*value), Status: pgtype.Present}
+	case []byte:
+		if value == nil {
+			*dst = XML{Status: pgtype.Null}
+			return nil
+		}
+
+		*dst = XML{Bytes: value, Status: pgtype.Present}
+	default:
+		bb, err := xml.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("cannot convert %T to xml: %w", src, err)
+		}
+
+		*dst = XML{Bytes: bb, Status: pgtype.Present}
+	}
+
+	return nil
+}
+
+// Get returns the simplest representation of the value.
+func (dst XML) Get() any {
+	switch dst.Status {
+	case pgtype.Present:
+		return string(dst.Bytes)
+	case pgtype.Null:
+		return nil
+	default:
+		return dst.Status
+	}
+}
+
+// AssignTo assigns the value to the given destination.
+func (src *XML) AssignTo(dst any) error {
+	switch value := dst.(type) {
+	case *string:
+		if src.Status != pgtype.Present {
+			return fmt.Errorf("cannot assign non-present status to %T", dst)
+		}
+
+		*value = string(src.Bytes)
+	case *[]byte:
+		if src.Status != pgtype.Present {
+			*value = nil
+			return nil
+		}
+
+		*value = append([]byte(nil), src.Bytes...)
+	default:
+		return fmt.Errorf("unable to assign to %T", dst)
+	}
+
+	return nil
+}
+
+// EncodeText appends the text format of the value to the given buffer.
+func (src XML) EncodeText(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch src.Status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+
+	return append(buf, src.Bytes...), nil
+}
+
+// EncodeBinary appends the binary format of the value to the given buffer.
+// The binary format of xml is identical to the text format.
+func (src XML) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return src.EncodeText(ci, buf)
+}